@@ -1,6 +1,6 @@
 // Copyright 2025 Joseph Cumines
 //
-// MCP tool for MacosUseSDK - provides JSON-RPC 2.0 interface over stdio or HTTP/SSE
+// MCP tool for MacosUseSDK - provides JSON-RPC 2.0 interface over stdio, HTTP/SSE or WebSocket
 
 package main
 
@@ -42,6 +42,8 @@ func main() {
 		switch cfg.Transport {
 		case config.TransportHTTP:
 			serveErr = runHTTPTransport(cfg, mcpServer)
+		case config.TransportWebSocket:
+			serveErr = runWebSocketTransport(cfg, mcpServer)
 		default:
 			serveErr = runStdioTransport(cfg, mcpServer)
 		}
@@ -80,16 +82,102 @@ func runStdioTransport(_ *config.Config, mcpServer *server.MCPServer) error {
 	return mcpServer.Serve(tr)
 }
 
-// runHTTPTransport runs the MCP server with HTTP/SSE transport
+// runHTTPTransport runs the MCP server with Streamable HTTP transport
 func runHTTPTransport(cfg *config.Config, mcpServer *server.MCPServer) error {
-	httpCfg := &transport.HTTPTransportConfig{
-		Address:           cfg.HTTPAddress,
-		SocketPath:        cfg.HTTPSocketPath,
-		HeartbeatInterval: cfg.HeartbeatInterval,
-		CORSOrigin:        cfg.CORSOrigin,
-		ReadTimeout:       cfg.HTTPReadTimeout,
-		WriteTimeout:      cfg.HTTPWriteTimeout,
+	trConfig, err := httpTransportConfig(cfg)
+	if err != nil {
+		return err
 	}
-	tr := transport.NewHTTPTransport(httpCfg)
+	tr := transport.NewHTTPTransport(trConfig)
+	reloadAPIKeysOnSIGHUP(cfg, tr)
 	return mcpServer.ServeHTTP(tr)
 }
+
+// runWebSocketTransport runs the MCP server with WebSocket transport
+func runWebSocketTransport(cfg *config.Config, mcpServer *server.MCPServer) error {
+	trConfig, err := httpTransportConfig(cfg)
+	if err != nil {
+		return err
+	}
+	tr := transport.NewWebSocketTransport(trConfig)
+	reloadAPIKeysOnSIGHUP(cfg, tr)
+	return mcpServer.ServeWebSocket(tr)
+}
+
+// apiKeySetter is a transport whose API keys can be replaced while serving.
+type apiKeySetter interface {
+	SetAPIKeys(keys []transport.APIKey) error
+}
+
+// reloadAPIKeysOnSIGHUP reloads the API key file into tr whenever the process
+// receives SIGHUP, if one is configured. An invalid file is logged, and the
+// keys already loaded remain in use.
+func reloadAPIKeysOnSIGHUP(cfg *config.Config, tr apiKeySetter) {
+	if cfg.APIKeysFile == "" {
+		return
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			keys, err := config.LoadAPIKeys(cfg.APIKeysFile)
+			if err == nil {
+				err = tr.SetAPIKeys(transportAPIKeys(keys))
+			}
+			if err != nil {
+				log.Printf("Failed to reload API keys, keeping the current keys: %v", err)
+				continue
+			}
+			log.Printf("Reloaded %d API keys from %s", len(keys), cfg.APIKeysFile)
+		}
+	}()
+}
+
+// transportAPIKeys converts API keys loaded from the key file for the transports.
+func transportAPIKeys(keys []config.APIKey) []transport.APIKey {
+	result := make([]transport.APIKey, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, transport.APIKey{Name: key.Name, Key: key.Key, Scopes: key.Scopes})
+	}
+	return result
+}
+
+// httpTransportConfig returns the configuration shared by the HTTP and
+// WebSocket transports. It fails if OAuth is configured with an invalid
+// key set.
+func httpTransportConfig(cfg *config.Config) (*transport.HTTPTransportConfig, error) {
+	var oauth *transport.OAuthValidator
+	if cfg.OAuthIssuer != "" || cfg.OAuthJWKSFile != "" {
+		var err error
+		oauth, err = transport.NewOAuthValidator(transport.OAuthConfig{
+			Issuer:          cfg.OAuthIssuer,
+			Audience:        cfg.OAuthAudience,
+			JWKSFile:        cfg.OAuthJWKSFile,
+			ScopesSupported: []string{config.ScopeRead, config.ScopeInput, config.ScopeRun},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &transport.HTTPTransportConfig{
+		Address:            cfg.HTTPAddress,
+		SocketPath:         cfg.HTTPSocketPath,
+		HeartbeatInterval:  cfg.HeartbeatInterval,
+		CORSOrigin:         cfg.CORSOrigin,
+		TLSCertFile:        cfg.TLSCertFile,
+		TLSKeyFile:         cfg.TLSKeyFile,
+		TLSClientCAFile:    cfg.TLSClientCAFile,
+		ClientCertScopes:   cfg.TLSClientScopes,
+		APIKey:             cfg.APIKey,
+		APIKeys:            transportAPIKeys(cfg.APIKeys),
+		OAuth:              oauth,
+		ReadTimeout:        cfg.HTTPReadTimeout,
+		WriteTimeout:       cfg.HTTPWriteTimeout,
+		RateLimit:          cfg.RateLimit,
+		ToolCosts:          cfg.RateLimitCosts,
+		SessionIdleTimeout: cfg.HTTPSessionIdleTimeout,
+		MaxSessions:        cfg.HTTPMaxSessions,
+		MaxConcurrency:     cfg.MaxConcurrency,
+		LegacySSE:          cfg.HTTPLegacySSE,
+	}, nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2025 Joseph Cumines
+//
+// Named API key file loading
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// API key scopes. Each grants a class of tools; a key may only call the tools
+// its scopes grant.
+const (
+	// ScopeRead grants the tools that only observe the desktop, such as
+	// screenshot and list_windows, and reading MCP resources.
+	ScopeRead = "read"
+	// ScopeInput grants the tools that change the desktop, such as click,
+	// type and move_window.
+	ScopeInput = "input"
+	// ScopeRun grants the tools that execute scripts and macros: run and
+	// macro_execute.
+	ScopeRun = "run"
+)
+
+// validScopes are the scopes an API key may grant.
+var validScopes = []string{ScopeRead, ScopeInput, ScopeRun}
+
+// APIKey is a named API key, loaded from the JSON file named by
+// MCP_API_KEYS_FILE.
+//
+// Example file:
+//
+//	{
+//	  "keys": [
+//	    {"name": "alice", "key": "...", "scopes": ["read", "input"]},
+//	    {"name": "dashboard", "key": "...", "scopes": ["read"]}
+//	  ]
+//	}
+type APIKey struct {
+	// Name identifies the key's holder in the audit log.
+	Name string `json:"name"`
+	// Key is the secret sent as the Bearer token.
+	Key string `json:"key"`
+	// Scopes lists the scopes the key grants: read, input and/or run.
+	Scopes []string `json:"scopes"`
+}
+
+// LoadAPIKeys reads and validates an API key file.
+func LoadAPIKeys(path string) ([]APIKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API key file: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var file struct {
+		Keys []APIKey `json:"keys"`
+	}
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid API key file %s: %w", path, err)
+	}
+
+	if err := validateAPIKeys(file.Keys); err != nil {
+		return nil, fmt.Errorf("invalid API key file %s: %w", path, err)
+	}
+
+	return file.Keys, nil
+}
+
+func validateAPIKeys(keys []APIKey) error {
+	if len(keys) == 0 {
+		return fmt.Errorf("no keys defined")
+	}
+	names := make(map[string]bool, len(keys))
+	secrets := make(map[string]bool, len(keys))
+	for i, key := range keys {
+		if key.Name == "" {
+			return fmt.Errorf("keys[%d]: name is required", i)
+		}
+		if names[key.Name] {
+			return fmt.Errorf("keys[%d]: duplicate name %q", i, key.Name)
+		}
+		names[key.Name] = true
+		if key.Key == "" {
+			return fmt.Errorf("key %q: key is required", key.Name)
+		}
+		if secrets[key.Key] {
+			return fmt.Errorf("key %q: key is shared with another entry", key.Name)
+		}
+		secrets[key.Key] = true
+		if len(key.Scopes) == 0 {
+			return fmt.Errorf("key %q: at least one scope is required (valid: %s)", key.Name, strings.Join(validScopes, ", "))
+		}
+		for _, scope := range key.Scopes {
+			if !slices.Contains(validScopes, scope) {
+				return fmt.Errorf("key %q: unknown scope %q (valid: %s)", key.Name, scope, strings.Join(validScopes, ", "))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2025 Joseph Cumines
+//
+// API key file unit tests
+
+package config
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadAPIKeys_Valid(t *testing.T) {
+	path := writePolicyFile(t, `{"keys": [
+		{"name": "alice", "key": "alice-secret", "scopes": ["read", "input"]},
+		{"name": "ci", "key": "ci-secret", "scopes": ["run"]}
+	]}`)
+
+	keys, err := LoadAPIKeys(path)
+	if err != nil {
+		t.Fatalf("LoadAPIKeys() error = %v", err)
+	}
+	if len(keys) != 2 || keys[0].Name != "alice" || keys[0].Key != "alice-secret" || !slices.Equal(keys[0].Scopes, []string{ScopeRead, ScopeInput}) {
+		t.Errorf("keys = %+v", keys)
+	}
+}
+
+func TestLoadAPIKeys_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"malformed", `{`, "invalid API key file"},
+		{"unknown field", `{"keys": [{"name": "a", "key": "k", "scopes": ["read"], "admin": true}]}`, "unknown field"},
+		{"no keys", `{"keys": []}`, "no keys defined"},
+		{"missing name", `{"keys": [{"key": "k", "scopes": ["read"]}]}`, "name is required"},
+		{"duplicate name", `{"keys": [{"name": "a", "key": "k1", "scopes": ["read"]}, {"name": "a", "key": "k2", "scopes": ["read"]}]}`, `duplicate name "a"`},
+		{"missing key", `{"keys": [{"name": "a", "scopes": ["read"]}]}`, "key is required"},
+		{"shared key", `{"keys": [{"name": "a", "key": "k", "scopes": ["read"]}, {"name": "b", "key": "k", "scopes": ["run"]}]}`, "shared with another entry"},
+		{"no scopes", `{"keys": [{"name": "a", "key": "k"}]}`, "at least one scope"},
+		{"unknown scope", `{"keys": [{"name": "a", "key": "k", "scopes": ["admin"]}]}`, `unknown scope "admin"`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadAPIKeys(writePolicyFile(t, tc.content))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("LoadAPIKeys() error = %v, want containing %q", err, tc.wantErr)
+			}
+		})
+	}
+
+	if _, err := LoadAPIKeys(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestLoad_APIKeysFile(t *testing.T) {
+	t.Setenv("MCP_API_KEY", "")
+	t.Setenv("MCP_API_KEYS_FILE", writePolicyFile(t, `{"keys": [{"name": "alice", "key": "secret", "scopes": ["read"]}]}`))
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cfg.APIKeys) != 1 || cfg.APIKeys[0].Name != "alice" {
+		t.Errorf("APIKeys = %+v, want alice", cfg.APIKeys)
+	}
+
+	t.Setenv("MCP_API_KEY", "secret")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Errorf("Load() error = %v, want MCP_API_KEY and MCP_API_KEYS_FILE rejected together", err)
+	}
+}
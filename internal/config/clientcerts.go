@@ -0,0 +1,68 @@
+// Copyright 2025 Joseph Cumines
+//
+// Client certificate scope file loading
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ClientCertificate grants scopes to the clients whose TLS certificate has a
+// subject, loaded from the JSON file named by MCP_TLS_CLIENT_SCOPES_FILE.
+//
+// Example file:
+//
+//	{
+//	  "certificates": [
+//	    {"subject": "CN=alice,O=Example", "scopes": ["read", "input"]},
+//	    {"subject": "CN=dashboard,O=Example", "scopes": ["read"]}
+//	  ]
+//	}
+type ClientCertificate struct {
+	// Subject is the certificate's subject, in RFC 2253 form.
+	Subject string `json:"subject"`
+	// Scopes lists the scopes the certificate grants: read, input and/or run.
+	Scopes []string `json:"scopes"`
+}
+
+// LoadClientCertScopes reads and validates a client certificate scope file,
+// returning the scopes of each certificate subject.
+func LoadClientCertScopes(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client certificate scope file: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var file struct {
+		Certificates []ClientCertificate `json:"certificates"`
+	}
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid client certificate scope file %s: %w", path, err)
+	}
+
+	scopes := make(map[string][]string, len(file.Certificates))
+	for i, cert := range file.Certificates {
+		if cert.Subject == "" {
+			return nil, fmt.Errorf("invalid client certificate scope file %s: certificates[%d]: subject is required", path, i)
+		}
+		if _, ok := scopes[cert.Subject]; ok {
+			return nil, fmt.Errorf("invalid client certificate scope file %s: certificates[%d]: duplicate subject %q", path, i, cert.Subject)
+		}
+		for _, scope := range cert.Scopes {
+			if !slices.Contains(validScopes, scope) {
+				return nil, fmt.Errorf("invalid client certificate scope file %s: certificate %q: unknown scope %q (valid: %s)",
+					path, cert.Subject, scope, strings.Join(validScopes, ", "))
+			}
+		}
+		scopes[cert.Subject] = cert.Scopes
+	}
+	return scopes, nil
+}
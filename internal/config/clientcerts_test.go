@@ -0,0 +1,67 @@
+// Copyright 2025 Joseph Cumines
+//
+// Client certificate scope file unit tests
+
+package config
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadClientCertScopes_Valid(t *testing.T) {
+	path := writePolicyFile(t, `{"certificates": [
+		{"subject": "CN=alice,O=Example", "scopes": ["read", "input"]},
+		{"subject": "CN=kiosk", "scopes": []}
+	]}`)
+
+	scopes, err := LoadClientCertScopes(path)
+	if err != nil {
+		t.Fatalf("LoadClientCertScopes() error = %v", err)
+	}
+	if len(scopes) != 2 || !slices.Equal(scopes["CN=alice,O=Example"], []string{ScopeRead, ScopeInput}) || len(scopes["CN=kiosk"]) != 0 {
+		t.Errorf("scopes = %+v", scopes)
+	}
+}
+
+func TestLoadClientCertScopes_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"malformed", `{`, "invalid client certificate scope file"},
+		{"unknown field", `{"certificates": [{"subject": "CN=a", "scopes": ["read"], "admin": true}]}`, "unknown field"},
+		{"missing subject", `{"certificates": [{"scopes": ["read"]}]}`, "subject is required"},
+		{"duplicate subject", `{"certificates": [{"subject": "CN=a", "scopes": ["read"]}, {"subject": "CN=a", "scopes": ["run"]}]}`, `duplicate subject "CN=a"`},
+		{"unknown scope", `{"certificates": [{"subject": "CN=a", "scopes": ["admin"]}]}`, `unknown scope "admin"`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadClientCertScopes(writePolicyFile(t, tc.content))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("LoadClientCertScopes() error = %v, want containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad_TLSClientScopesFile(t *testing.T) {
+	t.Setenv("MCP_TLS_CLIENT_SCOPES_FILE", writePolicyFile(t, `{"certificates": [{"subject": "CN=alice", "scopes": ["read"]}]}`))
+	t.Setenv("MCP_TLS_CLIENT_CA_FILE", "")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "requires MCP_TLS_CLIENT_CA_FILE") {
+		t.Errorf("Load() error = %v, want MCP_TLS_CLIENT_CA_FILE required", err)
+	}
+
+	t.Setenv("MCP_TLS_CERT_FILE", "server.crt")
+	t.Setenv("MCP_TLS_KEY_FILE", "server.key")
+	t.Setenv("MCP_TLS_CLIENT_CA_FILE", "ca.pem")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !slices.Equal(cfg.TLSClientScopes["CN=alice"], []string{ScopeRead}) {
+		t.Errorf("TLSClientScopes = %+v", cfg.TLSClientScopes)
+	}
+}
@@ -6,18 +6,35 @@ package config
 
 import (
 	"fmt"
+	"maps"
+	"math"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // TransportType represents the MCP transport type
 type TransportType string
 
+// DefaultMaxConcurrency is the default for Config.MaxConcurrency.
+const DefaultMaxConcurrency = 8
+
+// DefaultRateLimitCosts are the default tool costs for Config.RateLimitCosts:
+// screenshots and shell commands are the most expensive tools to run.
+var DefaultRateLimitCosts = map[string]float64{
+	"screenshot": 5,
+	"run":        5,
+}
+
 const (
 	// TransportStdio uses stdin/stdout for communication
 	TransportStdio TransportType = "stdio"
 	// TransportHTTP uses HTTP/SSE for communication
 	TransportHTTP TransportType = "sse"
+	// TransportWebSocket uses WebSocket for communication
+	TransportWebSocket TransportType = "websocket"
 )
 
 // Config holds the configuration for the MCP tool, loaded from environment variables.
@@ -41,14 +58,40 @@ type Config struct {
 	TLSCertFile string
 	// TLSKeyFile is the path to the TLS private key for HTTPS (env: MCP_TLS_KEY_FILE, optional)
 	TLSKeyFile string
+	// TLSClientCAFile is the path to a PEM bundle of CAs for client certificates (env:
+	// MCP_TLS_CLIENT_CA_FILE, optional). If set, clients must present a certificate signed by one
+	// of them. Requires TLSCertFile and TLSKeyFile.
+	TLSClientCAFile string
+	// TLSClientScopesFile is the path to a JSON file of the scopes granted to client certificates
+	// by subject (env: MCP_TLS_CLIENT_SCOPES_FILE, optional). It applies to clients identified by
+	// their certificate alone; other certificates grant no scopes. Requires TLSClientCAFile.
+	TLSClientScopesFile string
+	// TLSClientScopes are the scopes of each certificate subject loaded from TLSClientScopesFile.
+	TLSClientScopes map[string][]string
 	// APIKey is the API key for Bearer token authentication (env: MCP_API_KEY, optional)
 	// If set, all requests (except /health) require Authorization: Bearer <key> header.
 	APIKey string
+	// APIKeysFile is the path to a JSON file of named API keys with scopes (env: MCP_API_KEYS_FILE,
+	// optional). If set, the keys are loaded into APIKeys, and all requests (except /health) require
+	// one of them. The file is reloaded on SIGHUP. Mutually exclusive with APIKey.
+	APIKeysFile string
+	// APIKeys are the named API keys loaded from APIKeysFile, or nil if none is configured.
+	APIKeys []APIKey
+	// OAuthIssuer is the issuer of the OAuth access tokens to accept (env: MCP_OAUTH_ISSUER,
+	// optional). Unless OAuthJWKSFile is set, its signing keys are found through its metadata.
+	OAuthIssuer string
+	// OAuthAudience is this server's canonical URI, which tokens must be issued for
+	// (env: MCP_OAUTH_AUDIENCE, required with OAuthIssuer or OAuthJWKSFile).
+	OAuthAudience string
+	// OAuthJWKSFile is the path to a JSON Web Key Set of the token signing keys
+	// (env: MCP_OAUTH_JWKS_FILE, optional). If set, or OAuthIssuer is, requests may authenticate
+	// with an OAuth access token, alongside any API keys.
+	OAuthJWKSFile string
 	// AuditLogFile is the path to the audit log file (env: MCP_AUDIT_LOG_FILE, optional)
 	// If set, tool invocations are logged to this file in structured JSON format.
 	// If empty, audit logging is disabled.
 	AuditLogFile string
-	// Transport is the transport type: "stdio" or "sse" (env: MCP_TRANSPORT, default: stdio)
+	// Transport is the transport type: "stdio", "sse" or "websocket" (env: MCP_TRANSPORT, default: stdio)
 	Transport TransportType
 	// HeartbeatInterval is the SSE heartbeat interval (env: MCP_HEARTBEAT_INTERVAL, default: 30s)
 	HeartbeatInterval time.Duration
@@ -56,8 +99,21 @@ type Config struct {
 	HTTPReadTimeout time.Duration
 	// HTTPWriteTimeout is the HTTP server write timeout (env: MCP_HTTP_WRITE_TIMEOUT, default: 30s)
 	HTTPWriteTimeout time.Duration
-	// RateLimit is the rate limit in requests per second (env: MCP_RATE_LIMIT, default: 0 = disabled)
+	// HTTPLegacySSE also serves the legacy POST /message and GET /events endpoints alongside
+	// the Streamable HTTP endpoint /mcp (env: MCP_HTTP_LEGACY_SSE, default: false)
+	HTTPLegacySSE bool
+	// HTTPSessionIdleTimeout ends Streamable HTTP sessions that go unused for this long
+	// (env: MCP_HTTP_SESSION_IDLE_TIMEOUT, default: 30m)
+	HTTPSessionIdleTimeout time.Duration
+	// HTTPMaxSessions is the maximum number of open Streamable HTTP sessions (env: MCP_HTTP_MAX_SESSIONS,
+	// default: 100). Further initialize requests are refused until a session ends.
+	HTTPMaxSessions int
+	// RateLimit is the rate limit in requests per second for each client, identified by API key
+	// or remote address (env: MCP_RATE_LIMIT, default: 0 = disabled)
 	RateLimit float64
+	// RateLimitCosts weights tool calls against RateLimit, in requests (env: MCP_RATE_LIMIT_COSTS,
+	// "tool=cost" comma-separated, optional). Entries override DefaultRateLimitCosts; other tools cost 1.
+	RateLimitCosts map[string]float64
 	// RequestTimeout is the gRPC request timeout in seconds (env: MACOS_USE_REQUEST_TIMEOUT, default: 30)
 	RequestTimeout int
 	// ServerTLS enables TLS for gRPC (env: MACOS_USE_SERVER_TLS, default: false)
@@ -67,6 +123,31 @@ type Config struct {
 	// ShellCommandsEnabled enables shell command execution (env: MCP_SHELL_COMMANDS_ENABLED, default: false)
 	// WARNING: Enabling this allows arbitrary command execution and should only be used in trusted environments.
 	ShellCommandsEnabled bool
+	// PolicyFile is the path to a JSON tool policy file (env: MCP_POLICY_FILE, optional)
+	// If set, the policy is loaded into Policy and enforced on every tool call.
+	PolicyFile string
+	// Policy is the tool policy loaded from PolicyFile, or nil if none is configured.
+	Policy *Policy
+	// ConfirmTools lists tools that require operator approval via MCP elicitation before
+	// running (env: MCP_CONFIRM_TOOLS, comma-separated, optional). Entries are a tool name,
+	// or "tool:action" to confirm only calls with that action argument (e.g. "clipboard:set").
+	ConfirmTools []string
+	// ConfirmTimeout is how long to wait for the operator to respond to a confirmation
+	// request before denying the call (env: MCP_CONFIRM_TIMEOUT, default: 2m)
+	ConfirmTimeout time.Duration
+	// MaxConcurrency is the maximum number of stdio requests, or requests on each WebSocket
+	// connection, handled concurrently (env: MCP_MAX_CONCURRENCY, default: 8). Further requests
+	// wait for a free worker.
+	MaxConcurrency int
+	// SerialTools lists tools whose calls are run one at a time (env: MCP_SERIAL_TOOLS,
+	// comma-separated, optional). Input tools are always serialised by the input arbiter.
+	SerialTools []string
+	// InputFairness is the order in which waiting input tool calls run (env: MCP_INPUT_FAIRNESS,
+	// default: fifo). "fifo" runs them in arrival order; "none" lets any waiting call run next.
+	InputFairness string
+	// InputLeaseMax is the longest an input_lease may be held before it must be renewed
+	// (env: MCP_INPUT_LEASE_MAX, default: 5m)
+	InputLeaseMax time.Duration
 }
 
 // Load loads configuration from environment variables and returns a Config.
@@ -92,11 +173,41 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
+	httpSessionIdleTimeout, err := getEnvAsDuration("MCP_HTTP_SESSION_IDLE_TIMEOUT", 30*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	httpMaxSessions, err := getEnvAsInt("MCP_HTTP_MAX_SESSIONS", 100)
+	if err != nil {
+		return nil, err
+	}
+
 	rateLimit, err := getEnvAsFloat("MCP_RATE_LIMIT", 0)
 	if err != nil {
 		return nil, err
 	}
 
+	rateLimitCosts, err := getEnvAsCosts("MCP_RATE_LIMIT_COSTS", DefaultRateLimitCosts)
+	if err != nil {
+		return nil, err
+	}
+
+	confirmTimeout, err := getEnvAsDuration("MCP_CONFIRM_TIMEOUT", 2*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	maxConcurrency, err := getEnvAsInt("MCP_MAX_CONCURRENCY", DefaultMaxConcurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	inputLeaseMax, err := getEnvAsDuration("MCP_INPUT_LEASE_MAX", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
 	cfg := &Config{
 		ServerAddr:       getEnv("MACOS_USE_SERVER_ADDR", "localhost:50051"),
 		ServerSocketPath: os.Getenv("MACOS_USE_SERVER_SOCKET_PATH"),
@@ -112,17 +223,41 @@ func Load() (*Config, error) {
 		CORSOrigin:        getEnv("MCP_CORS_ORIGIN", "*"),
 		HTTPReadTimeout:   httpReadTimeout,
 		HTTPWriteTimeout:  httpWriteTimeout,
+		HTTPLegacySSE:     getEnvAsBool("MCP_HTTP_LEGACY_SSE", false),
+		// Streamable HTTP sessions
+		HTTPSessionIdleTimeout: httpSessionIdleTimeout,
+		HTTPMaxSessions:        httpMaxSessions,
 		// TLS configuration for HTTPS
 		TLSCertFile: os.Getenv("MCP_TLS_CERT_FILE"),
 		TLSKeyFile:  os.Getenv("MCP_TLS_KEY_FILE"),
+		// Mutual TLS
+		TLSClientCAFile:     os.Getenv("MCP_TLS_CLIENT_CA_FILE"),
+		TLSClientScopesFile: os.Getenv("MCP_TLS_CLIENT_SCOPES_FILE"),
 		// API key authentication
-		APIKey: os.Getenv("MCP_API_KEY"),
+		APIKey:      os.Getenv("MCP_API_KEY"),
+		APIKeysFile: os.Getenv("MCP_API_KEYS_FILE"),
+		// OAuth access token validation
+		OAuthIssuer:   os.Getenv("MCP_OAUTH_ISSUER"),
+		OAuthAudience: os.Getenv("MCP_OAUTH_AUDIENCE"),
+		OAuthJWKSFile: os.Getenv("MCP_OAUTH_JWKS_FILE"),
 		// Audit logging
 		AuditLogFile: os.Getenv("MCP_AUDIT_LOG_FILE"),
 		// Rate limiting
-		RateLimit: rateLimit,
+		RateLimit:      rateLimit,
+		RateLimitCosts: rateLimitCosts,
 		// Security: shell commands are disabled by default
 		ShellCommandsEnabled: getEnvAsBool("MCP_SHELL_COMMANDS_ENABLED", false),
+		// Tool policy
+		PolicyFile: os.Getenv("MCP_POLICY_FILE"),
+		// Operator confirmation
+		ConfirmTools:   getEnvAsList("MCP_CONFIRM_TOOLS"),
+		ConfirmTimeout: confirmTimeout,
+		// Request concurrency
+		MaxConcurrency: maxConcurrency,
+		SerialTools:    getEnvAsList("MCP_SERIAL_TOOLS"),
+		// Input arbitration
+		InputFairness: getEnv("MCP_INPUT_FAIRNESS", "fifo"),
+		InputLeaseMax: inputLeaseMax,
 	}
 
 	if cfg.ServerAddr == "" && cfg.ServerSocketPath == "" {
@@ -130,8 +265,72 @@ func Load() (*Config, error) {
 	}
 
 	// Validate transport type
-	if cfg.Transport != TransportStdio && cfg.Transport != TransportHTTP {
-		return nil, fmt.Errorf("invalid transport type: %s (must be 'stdio' or 'sse')", cfg.Transport)
+	if cfg.Transport != TransportStdio && cfg.Transport != TransportHTTP && cfg.Transport != TransportWebSocket {
+		return nil, fmt.Errorf("invalid transport type: %s (must be 'stdio', 'sse' or 'websocket')", cfg.Transport)
+	}
+
+	if cfg.HTTPSessionIdleTimeout <= 0 {
+		return nil, fmt.Errorf("invalid value for MCP_HTTP_SESSION_IDLE_TIMEOUT: %s (must be positive)", cfg.HTTPSessionIdleTimeout)
+	}
+
+	if cfg.HTTPMaxSessions < 1 {
+		return nil, fmt.Errorf("invalid value for MCP_HTTP_MAX_SESSIONS: %d (must be at least 1)", cfg.HTTPMaxSessions)
+	}
+
+	if cfg.MaxConcurrency < 1 {
+		return nil, fmt.Errorf("invalid value for MCP_MAX_CONCURRENCY: %d (must be at least 1)", cfg.MaxConcurrency)
+	}
+
+	if cfg.InputFairness != "fifo" && cfg.InputFairness != "none" {
+		return nil, fmt.Errorf("invalid value for MCP_INPUT_FAIRNESS: %s (must be 'fifo' or 'none')", cfg.InputFairness)
+	}
+
+	if cfg.InputLeaseMax <= 0 {
+		return nil, fmt.Errorf("invalid value for MCP_INPUT_LEASE_MAX: %s (must be positive)", cfg.InputLeaseMax)
+	}
+
+	if cfg.TLSClientCAFile != "" && (cfg.TLSCertFile == "" || cfg.TLSKeyFile == "") {
+		return nil, fmt.Errorf("MCP_TLS_CLIENT_CA_FILE requires MCP_TLS_CERT_FILE and MCP_TLS_KEY_FILE")
+	}
+
+	if cfg.TLSClientScopesFile != "" {
+		if cfg.TLSClientCAFile == "" {
+			return nil, fmt.Errorf("MCP_TLS_CLIENT_SCOPES_FILE requires MCP_TLS_CLIENT_CA_FILE")
+		}
+		cfg.TLSClientScopes, err = LoadClientCertScopes(cfg.TLSClientScopesFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.APIKeysFile != "" {
+		if cfg.APIKey != "" {
+			return nil, fmt.Errorf("MCP_API_KEY and MCP_API_KEYS_FILE are mutually exclusive")
+		}
+		cfg.APIKeys, err = LoadAPIKeys(cfg.APIKeysFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.OAuthIssuer != "" || cfg.OAuthJWKSFile != "" {
+		if cfg.OAuthAudience == "" {
+			return nil, fmt.Errorf("MCP_OAUTH_AUDIENCE is required with MCP_OAUTH_ISSUER or MCP_OAUTH_JWKS_FILE")
+		}
+		if cfg.OAuthIssuer != "" {
+			if u, err := url.Parse(cfg.OAuthIssuer); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+				return nil, fmt.Errorf("invalid value for MCP_OAUTH_ISSUER: %s (must be an http or https URL)", cfg.OAuthIssuer)
+			}
+		}
+	} else if cfg.OAuthAudience != "" {
+		return nil, fmt.Errorf("MCP_OAUTH_AUDIENCE requires MCP_OAUTH_ISSUER or MCP_OAUTH_JWKS_FILE")
+	}
+
+	if cfg.PolicyFile != "" {
+		cfg.Policy, err = LoadPolicy(cfg.PolicyFile)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return cfg, nil
@@ -152,6 +351,37 @@ func getEnvAsBool(key string, defaultValue bool) bool {
 	return value == "true" || value == "1" || value == "yes"
 }
 
+func getEnvAsList(key string) []string {
+	var result []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// getEnvAsCosts parses a comma-separated list of "name=cost" entries over a
+// copy of defaults. Costs must be non-negative numbers.
+func getEnvAsCosts(key string, defaults map[string]float64) (map[string]float64, error) {
+	result := maps.Clone(defaults)
+	for _, item := range getEnvAsList(key) {
+		name, value, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		var cost float64
+		if ok {
+			var err error
+			cost, err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+			ok = err == nil && cost >= 0 && !math.IsInf(cost, 0)
+		}
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid value for %s: %q (expected name=cost, with a non-negative cost)", key, item)
+		}
+		result[name] = cost
+	}
+	return result, nil
+}
+
 func getEnvAsInt(key string, defaultValue int) (int, error) {
 	value := os.Getenv(key)
 	if value == "" {
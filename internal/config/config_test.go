@@ -5,7 +5,9 @@
 package config
 
 import (
+	"maps"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -89,6 +91,20 @@ func TestLoad_TransportSSE(t *testing.T) {
 	}
 }
 
+func TestLoad_TransportWebSocket(t *testing.T) {
+	os.Setenv("MCP_TRANSPORT", "websocket")
+	defer os.Unsetenv("MCP_TRANSPORT")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.Transport != TransportWebSocket {
+		t.Errorf("Transport = %s, want websocket", cfg.Transport)
+	}
+}
+
 func TestLoad_TransportInvalid(t *testing.T) {
 	os.Setenv("MCP_TRANSPORT", "invalid")
 	defer os.Unsetenv("MCP_TRANSPORT")
@@ -126,6 +142,7 @@ func TestLoad_HTTPConfig(t *testing.T) {
 	os.Setenv("MCP_CORS_ORIGIN", "https://example.com")
 	os.Setenv("MCP_HTTP_READ_TIMEOUT", "45s")
 	os.Setenv("MCP_HTTP_WRITE_TIMEOUT", "45s")
+	os.Setenv("MCP_HTTP_LEGACY_SSE", "true")
 	defer func() {
 		os.Unsetenv("MCP_HTTP_ADDRESS")
 		os.Unsetenv("MCP_HTTP_SOCKET")
@@ -133,6 +150,7 @@ func TestLoad_HTTPConfig(t *testing.T) {
 		os.Unsetenv("MCP_CORS_ORIGIN")
 		os.Unsetenv("MCP_HTTP_READ_TIMEOUT")
 		os.Unsetenv("MCP_HTTP_WRITE_TIMEOUT")
+		os.Unsetenv("MCP_HTTP_LEGACY_SSE")
 	}()
 
 	cfg, err := Load()
@@ -163,6 +181,10 @@ func TestLoad_HTTPConfig(t *testing.T) {
 	if cfg.HTTPWriteTimeout != 45*time.Second {
 		t.Errorf("HTTPWriteTimeout = %v, want 45s", cfg.HTTPWriteTimeout)
 	}
+
+	if !cfg.HTTPLegacySSE {
+		t.Error("HTTPLegacySSE = false, want true")
+	}
 }
 
 func TestTransportTypeConstants(t *testing.T) {
@@ -173,6 +195,10 @@ func TestTransportTypeConstants(t *testing.T) {
 	if TransportHTTP != "sse" {
 		t.Errorf("TransportHTTP = %s, want sse", TransportHTTP)
 	}
+
+	if TransportWebSocket != "websocket" {
+		t.Errorf("TransportWebSocket = %s, want websocket", TransportWebSocket)
+	}
 }
 
 func TestGetEnvAsDuration(t *testing.T) {
@@ -427,6 +453,50 @@ func TestLoad_RateLimitInvalid(t *testing.T) {
 	}
 }
 
+func TestLoad_RateLimitCosts(t *testing.T) {
+	os.Setenv("MCP_RATE_LIMIT_COSTS", "run=10, find_elements=2.5,ping=0")
+	defer os.Unsetenv("MCP_RATE_LIMIT_COSTS")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := map[string]float64{"screenshot": 5, "run": 10, "find_elements": 2.5, "ping": 0}
+	if !maps.Equal(cfg.RateLimitCosts, want) {
+		t.Errorf("RateLimitCosts = %v, want %v", cfg.RateLimitCosts, want)
+	}
+	if DefaultRateLimitCosts["run"] != 5 {
+		t.Error("Load() modified DefaultRateLimitCosts")
+	}
+}
+
+func TestLoad_RateLimitCostsDefault(t *testing.T) {
+	os.Unsetenv("MCP_RATE_LIMIT_COSTS")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if !maps.Equal(cfg.RateLimitCosts, DefaultRateLimitCosts) {
+		t.Errorf("RateLimitCosts = %v, want %v", cfg.RateLimitCosts, DefaultRateLimitCosts)
+	}
+}
+
+func TestLoad_RateLimitCostsInvalid(t *testing.T) {
+	for _, value := range []string{"run", "run=", "run=abc", "run=-1", "=5", "click=1.5x", "run=NaN", "run=Inf", "run=-0.5"} {
+		t.Run(value, func(t *testing.T) {
+			os.Setenv("MCP_RATE_LIMIT_COSTS", value)
+			defer os.Unsetenv("MCP_RATE_LIMIT_COSTS")
+
+			if _, err := Load(); err == nil {
+				t.Errorf("Load() should return error for MCP_RATE_LIMIT_COSTS=%q", value)
+			}
+		})
+	}
+}
+
 func TestGetEnvAsFloat(t *testing.T) {
 	tests := []struct {
 		value     string
@@ -632,3 +702,149 @@ func TestLoad_DebugConfigDefault(t *testing.T) {
 		t.Errorf("Debug = %v, want false (default)", cfg.Debug)
 	}
 }
+
+func TestLoad_ConcurrencyConfigDefaults(t *testing.T) {
+	os.Unsetenv("MCP_MAX_CONCURRENCY")
+	os.Unsetenv("MCP_SERIAL_TOOLS")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.MaxConcurrency != DefaultMaxConcurrency {
+		t.Errorf("MaxConcurrency = %d, want %d", cfg.MaxConcurrency, DefaultMaxConcurrency)
+	}
+	if len(cfg.SerialTools) != 0 {
+		t.Errorf("SerialTools = %v, want none", cfg.SerialTools)
+	}
+}
+
+func TestLoad_ConcurrencyConfig(t *testing.T) {
+	t.Setenv("MCP_MAX_CONCURRENCY", "2")
+	t.Setenv("MCP_SERIAL_TOOLS", "click, type")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.MaxConcurrency != 2 {
+		t.Errorf("MaxConcurrency = %d, want 2", cfg.MaxConcurrency)
+	}
+	if strings.Join(cfg.SerialTools, ",") != "click,type" {
+		t.Errorf("SerialTools = %v, want [click type]", cfg.SerialTools)
+	}
+}
+
+func TestLoad_MaxConcurrencyInvalid(t *testing.T) {
+	t.Setenv("MCP_MAX_CONCURRENCY", "0")
+
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "MCP_MAX_CONCURRENCY") {
+		t.Errorf("Load() error = %v, want MCP_MAX_CONCURRENCY error", err)
+	}
+}
+
+func TestLoad_HTTPSessionConfig(t *testing.T) {
+	os.Unsetenv("MCP_HTTP_SESSION_IDLE_TIMEOUT")
+	os.Unsetenv("MCP_HTTP_MAX_SESSIONS")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.HTTPSessionIdleTimeout != 30*time.Minute || cfg.HTTPMaxSessions != 100 {
+		t.Errorf("HTTPSessionIdleTimeout = %v, HTTPMaxSessions = %d, want 30m, 100", cfg.HTTPSessionIdleTimeout, cfg.HTTPMaxSessions)
+	}
+
+	t.Setenv("MCP_HTTP_SESSION_IDLE_TIMEOUT", "5m")
+	t.Setenv("MCP_HTTP_MAX_SESSIONS", "10")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.HTTPSessionIdleTimeout != 5*time.Minute || cfg.HTTPMaxSessions != 10 {
+		t.Errorf("HTTPSessionIdleTimeout = %v, HTTPMaxSessions = %d, want 5m, 10", cfg.HTTPSessionIdleTimeout, cfg.HTTPMaxSessions)
+	}
+
+	t.Setenv("MCP_HTTP_MAX_SESSIONS", "0")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "MCP_HTTP_MAX_SESSIONS") {
+		t.Errorf("Load() error = %v, want MCP_HTTP_MAX_SESSIONS error", err)
+	}
+}
+
+func TestLoad_InputArbitrationConfig(t *testing.T) {
+	os.Unsetenv("MCP_INPUT_FAIRNESS")
+	os.Unsetenv("MCP_INPUT_LEASE_MAX")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.InputFairness != "fifo" || cfg.InputLeaseMax != 5*time.Minute {
+		t.Errorf("InputFairness = %s, InputLeaseMax = %v, want fifo, 5m", cfg.InputFairness, cfg.InputLeaseMax)
+	}
+
+	t.Setenv("MCP_INPUT_FAIRNESS", "none")
+	t.Setenv("MCP_INPUT_LEASE_MAX", "1m")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.InputFairness != "none" || cfg.InputLeaseMax != time.Minute {
+		t.Errorf("InputFairness = %s, InputLeaseMax = %v, want none, 1m", cfg.InputFairness, cfg.InputLeaseMax)
+	}
+
+	t.Setenv("MCP_INPUT_FAIRNESS", "random")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "MCP_INPUT_FAIRNESS") {
+		t.Errorf("Load() error = %v, want MCP_INPUT_FAIRNESS error", err)
+	}
+}
+
+func TestLoad_OAuthConfig(t *testing.T) {
+	t.Setenv("MCP_OAUTH_ISSUER", "https://auth.example.com")
+	t.Setenv("MCP_OAUTH_AUDIENCE", "https://mcp.example.com/mcp")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.OAuthIssuer != "https://auth.example.com" || cfg.OAuthAudience != "https://mcp.example.com/mcp" {
+		t.Errorf("OAuthIssuer = %s, OAuthAudience = %s", cfg.OAuthIssuer, cfg.OAuthAudience)
+	}
+
+	t.Setenv("MCP_OAUTH_ISSUER", "auth.example.com")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "MCP_OAUTH_ISSUER") {
+		t.Errorf("Load() error = %v, want MCP_OAUTH_ISSUER error", err)
+	}
+
+	t.Setenv("MCP_OAUTH_ISSUER", "")
+	t.Setenv("MCP_OAUTH_JWKS_FILE", "/etc/mcp/jwks.json")
+	t.Setenv("MCP_OAUTH_AUDIENCE", "")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "MCP_OAUTH_AUDIENCE is required") {
+		t.Errorf("Load() error = %v, want MCP_OAUTH_AUDIENCE error", err)
+	}
+
+	t.Setenv("MCP_OAUTH_JWKS_FILE", "")
+	t.Setenv("MCP_OAUTH_AUDIENCE", "https://mcp.example.com/mcp")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "requires MCP_OAUTH_ISSUER") {
+		t.Errorf("Load() error = %v, want an error for an audience without OAuth", err)
+	}
+}
+
+func TestLoad_TLSClientCAFile(t *testing.T) {
+	t.Setenv("MCP_TLS_CLIENT_CA_FILE", "/etc/mcp/clients.pem")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "MCP_TLS_CLIENT_CA_FILE requires") {
+		t.Errorf("Load() error = %v, want an error for a client CA without TLS", err)
+	}
+
+	t.Setenv("MCP_TLS_CERT_FILE", "/etc/mcp/cert.pem")
+	t.Setenv("MCP_TLS_KEY_FILE", "/etc/mcp/key.pem")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.TLSClientCAFile != "/etc/mcp/clients.pem" {
+		t.Errorf("TLSClientCAFile = %s, want /etc/mcp/clients.pem", cfg.TLSClientCAFile)
+	}
+}
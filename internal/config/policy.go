@@ -0,0 +1,149 @@
+// Copyright 2025 Joseph Cumines
+//
+// Tool policy file loading
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// validRunTypes are the script types accepted by the run tool.
+var validRunTypes = []string{"shell", "applescript", "javascript"}
+
+// Policy restricts which tools may be called, and with what arguments. It is
+// loaded from the JSON file named by MCP_POLICY_FILE. Every section is
+// optional; an empty section imposes no restriction.
+//
+// Example:
+//
+//	{
+//	  "tools": {"deny": ["clipboard"]},
+//	  "applications": {"allowed_bundle_ids": ["com.apple.TextEdit"]},
+//	  "run": {"allowed_types": ["applescript"]},
+//	  "displays": {"allowed": ["main"]}
+//	}
+type Policy struct {
+	// Tools allows or denies tools by name.
+	Tools ToolPolicy `json:"tools"`
+	// Applications restricts the applications open_app and close_app may target.
+	Applications ApplicationPolicy `json:"applications"`
+	// Run restricts the script types accepted by the run tool.
+	Run RunPolicy `json:"run"`
+	// Displays restricts the displays that coordinate-based input tools may target.
+	Displays DisplayPolicy `json:"displays"`
+}
+
+// ToolPolicy allows or denies tools by name. Deny takes precedence over Allow.
+type ToolPolicy struct {
+	// Allow, if non-empty, lists the only tools that may be called.
+	Allow []string `json:"allow"`
+	// Deny lists tools that may not be called.
+	Deny []string `json:"deny"`
+}
+
+// ApplicationPolicy restricts application lifecycle tools.
+type ApplicationPolicy struct {
+	// AllowedBundleIDs, if non-empty, lists the bundle identifiers that
+	// open_app and close_app may target (case-insensitive). macro_execute is
+	// then denied, as the applications a macro acts on can't be checked.
+	AllowedBundleIDs []string `json:"allowed_bundle_ids"`
+}
+
+// RunPolicy restricts the run tool.
+type RunPolicy struct {
+	// AllowedTypes, if non-empty, lists the permitted run types: shell,
+	// applescript and/or javascript.
+	AllowedTypes []string `json:"allowed_types"`
+}
+
+// DisplayPolicy restricts coordinate-based input tools.
+type DisplayPolicy struct {
+	// Allowed, if non-empty, lists the displays that coordinates must fall
+	// within. Each entry is either "main" or a display ID. macro_execute is
+	// then denied, as the coordinates a macro acts on can't be checked.
+	Allowed []string `json:"allowed"`
+}
+
+// LoadPolicy reads and validates a policy file.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var policy Policy
+	if err := dec.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %w", path, err)
+	}
+
+	if err := policy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %w", path, err)
+	}
+
+	return &policy, nil
+}
+
+func (p *Policy) validate() error {
+	for _, t := range p.Run.AllowedTypes {
+		if !slices.Contains(validRunTypes, t) {
+			return fmt.Errorf("run.allowed_types: unknown type %q (valid: %s)", t, strings.Join(validRunTypes, ", "))
+		}
+	}
+	for _, d := range p.Displays.Allowed {
+		if d == "main" {
+			continue
+		}
+		if _, err := strconv.ParseInt(d, 10, 64); err != nil {
+			return fmt.Errorf("displays.allowed: invalid display %q (expected \"main\" or a display ID)", d)
+		}
+	}
+	return nil
+}
+
+// ToolAllowed reports whether the named tool may be called.
+func (p *Policy) ToolAllowed(name string) bool {
+	if slices.Contains(p.Tools.Deny, name) {
+		return false
+	}
+	return len(p.Tools.Allow) == 0 || slices.Contains(p.Tools.Allow, name)
+}
+
+// BundleIDAllowed reports whether open_app and close_app may target the
+// application with the given bundle identifier.
+func (p *Policy) BundleIDAllowed(bundleID string) bool {
+	if len(p.Applications.AllowedBundleIDs) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(p.Applications.AllowedBundleIDs, func(id string) bool {
+		return strings.EqualFold(id, bundleID)
+	})
+}
+
+// RunTypeAllowed reports whether the run tool may execute scripts of the given type.
+func (p *Policy) RunTypeAllowed(runType string) bool {
+	return len(p.Run.AllowedTypes) == 0 || slices.Contains(p.Run.AllowedTypes, runType)
+}
+
+// DisplayAllowed reports whether coordinates may target the display with the
+// given ID.
+func (p *Policy) DisplayAllowed(displayID int64, isMain bool) bool {
+	if len(p.Displays.Allowed) == 0 {
+		return true
+	}
+	id := strconv.FormatInt(displayID, 10)
+	for _, d := range p.Displays.Allowed {
+		if d == id || (d == "main" && isMain) {
+			return true
+		}
+	}
+	return false
+}
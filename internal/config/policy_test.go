@@ -0,0 +1,105 @@
+// Copyright 2025 Joseph Cumines
+//
+// Tool policy unit tests
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePolicyFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPolicy_Valid(t *testing.T) {
+	path := writePolicyFile(t, `{
+		"tools": {"allow": ["click", "run", "open_app"], "deny": ["run"]},
+		"applications": {"allowed_bundle_ids": ["com.apple.TextEdit"]},
+		"run": {"allowed_types": ["applescript"]},
+		"displays": {"allowed": ["main", "69734208"]}
+	}`)
+
+	p, err := LoadPolicy(path)
+	if err != nil {
+		t.Fatalf("LoadPolicy() error = %v", err)
+	}
+
+	for name, want := range map[string]bool{"click": true, "open_app": true, "run": false, "type": false} {
+		if got := p.ToolAllowed(name); got != want {
+			t.Errorf("ToolAllowed(%q) = %v, want %v", name, got, want)
+		}
+	}
+	if !p.BundleIDAllowed("com.apple.textedit") {
+		t.Error("BundleIDAllowed should be case-insensitive")
+	}
+	if p.BundleIDAllowed("com.apple.Terminal") {
+		t.Error("BundleIDAllowed(com.apple.Terminal) = true, want false")
+	}
+	if !p.RunTypeAllowed("applescript") || p.RunTypeAllowed("shell") {
+		t.Error("RunTypeAllowed should only permit applescript")
+	}
+	if !p.DisplayAllowed(1, true) || !p.DisplayAllowed(69734208, false) || p.DisplayAllowed(2, false) {
+		t.Error("DisplayAllowed should permit the main display and display 69734208 only")
+	}
+}
+
+func TestPolicy_EmptySectionsAllowEverything(t *testing.T) {
+	p, err := LoadPolicy(writePolicyFile(t, `{}`))
+	if err != nil {
+		t.Fatalf("LoadPolicy() error = %v", err)
+	}
+	if !p.ToolAllowed("run") || !p.BundleIDAllowed("any.app") || !p.RunTypeAllowed("shell") || !p.DisplayAllowed(3, false) {
+		t.Error("empty policy should not restrict anything")
+	}
+}
+
+func TestLoadPolicy_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"malformed", `{`, "invalid policy file"},
+		{"unknown field", `{"tool": {}}`, "unknown field"},
+		{"unknown run type", `{"run": {"allowed_types": ["python"]}}`, `unknown type "python"`},
+		{"invalid display", `{"displays": {"allowed": ["secondary"]}}`, `invalid display "secondary"`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadPolicy(writePolicyFile(t, tc.content))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("LoadPolicy() error = %v, want containing %q", err, tc.wantErr)
+			}
+		})
+	}
+
+	if _, err := LoadPolicy(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestLoad_PolicyFile(t *testing.T) {
+	t.Setenv("MCP_POLICY_FILE", writePolicyFile(t, `{"tools": {"deny": ["run"]}}`))
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Policy == nil || cfg.Policy.ToolAllowed("run") {
+		t.Errorf("Policy = %+v, want run denied", cfg.Policy)
+	}
+
+	t.Setenv("MCP_POLICY_FILE", writePolicyFile(t, `{"run": {"allowed_types": ["perl"]}}`))
+	if _, err := Load(); err == nil {
+		t.Error("Load() should fail for an invalid policy file")
+	}
+}
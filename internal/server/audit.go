@@ -5,6 +5,7 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"log/slog"
 	"os"
@@ -92,9 +93,10 @@ func (a *AuditLogger) IsEnabled() bool {
 	return a.enabled
 }
 
-// LogToolCall logs a tool invocation with redacted arguments.
+// LogToolCall logs a tool invocation with redacted arguments, and attrs,
+// such as the identity of the client.
 // Sensitive fields like passwords and tokens are automatically redacted.
-func (a *AuditLogger) LogToolCall(tool string, args json.RawMessage, status string, duration time.Duration) {
+func (a *AuditLogger) LogToolCall(tool string, args json.RawMessage, status string, duration time.Duration, attrs ...slog.Attr) {
 	if !a.IsEnabled() {
 		return
 	}
@@ -110,13 +112,14 @@ func (a *AuditLogger) LogToolCall(tool string, args json.RawMessage, status stri
 	// Redact sensitive arguments
 	redactedArgs := redactArguments(args)
 
-	logger.Info("tool_invocation",
+	logAttrs := []slog.Attr{
 		slog.String("tool", tool),
 		slog.String("arguments", redactedArgs),
 		slog.String("status", status),
 		slog.Float64("duration_seconds", duration.Seconds()),
 		slog.Time("timestamp", time.Now().UTC()),
-	)
+	}
+	logger.LogAttrs(context.Background(), slog.LevelInfo, "tool_invocation", append(logAttrs, attrs...)...)
 }
 
 // redactArguments redacts sensitive values from JSON arguments.
@@ -0,0 +1,118 @@
+// Copyright 2025 Joseph Cumines
+//
+// Request cancellation — notifications/cancelled for in-flight tool calls
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/joeycumines/MacosUseSDK/internal/transport"
+)
+
+// errRequestCancelled is the cause of a tool call context cancelled by the
+// client with notifications/cancelled.
+var errRequestCancelled = errors.New("request cancelled by client")
+
+// inflightRequest is a tools/call request that may be cancelled by the client.
+type inflightRequest struct {
+	cancel context.CancelCauseFunc
+}
+
+// inflightKey identifies an in-flight request. JSON-RPC IDs are chosen by
+// the client, so they are only unique within its session.
+type inflightKey struct {
+	session string
+	id      string
+}
+
+// requestKey normalises a JSON-RPC ID from the client session for use as a
+// map key, so that e.g. `1` and ` 1 ` refer to the same request.
+func requestKey(session string, id json.RawMessage) inflightKey {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, id); err != nil {
+		return inflightKey{session: session, id: string(id)}
+	}
+	return inflightKey{session: session, id: buf.String()}
+}
+
+// trackRequest registers the in-flight request id from the client session, so
+// it can be cancelled via notifications/cancelled, and returns a function that
+// unregisters it. A request whose ID is reused while it is in flight replaces
+// the earlier entry for cancellation purposes.
+func (s *MCPServer) trackRequest(session string, id json.RawMessage, cancel context.CancelCauseFunc) (untrack func()) {
+	key := requestKey(session, id)
+	req := &inflightRequest{cancel: cancel}
+
+	s.inflightMu.Lock()
+	if s.inflight == nil {
+		s.inflight = make(map[inflightKey]*inflightRequest)
+	}
+	s.inflight[key] = req
+	s.inflightMu.Unlock()
+
+	return func() {
+		s.inflightMu.Lock()
+		if s.inflight[key] == req {
+			delete(s.inflight, key)
+		}
+		s.inflightMu.Unlock()
+	}
+}
+
+// handleCancelledNotification handles notifications/cancelled from the client.
+// Only the client's own requests can be cancelled. Per MCP 2025-11-25
+// basic/utilities/cancellation, unknown or already completed requests are
+// ignored, and no response is sent for the cancelled request.
+func (s *MCPServer) handleCancelledNotification(req *MethodRequest) *transport.Message {
+	var params struct {
+		RequestID json.RawMessage `json:"requestId"`
+		Reason    string          `json:"reason"`
+	}
+	if err := json.Unmarshal(req.Message.Params, &params); err != nil || len(params.RequestID) == 0 {
+		log.Printf("Ignoring invalid notifications/cancelled: %s", req.Message.Params)
+		return nil
+	}
+
+	session, _ := sessionOf(req.Transport)
+	s.inflightMu.Lock()
+	inflight := s.inflight[requestKey(session, params.RequestID)]
+	s.inflightMu.Unlock()
+	if inflight == nil {
+		return nil
+	}
+
+	cause := errRequestCancelled
+	if params.Reason != "" {
+		cause = fmt.Errorf("%w: %s", errRequestCancelled, params.Reason)
+	}
+	inflight.cancel(cause)
+	return nil
+}
+
+// cancelledByClient reports whether call was cancelled with notifications/cancelled.
+func cancelledByClient(call *ToolCall) bool {
+	return errors.Is(context.Cause(call.Context()), errRequestCancelled)
+}
+
+// cancellationMiddleware reports calls cancelled by the client as a soft error
+// with status "cancelled", whatever the handler returned, so they are audited
+// and counted as such.
+func cancellationMiddleware(next ToolHandler) ToolHandler {
+	return func(call *ToolCall) (*ToolResult, error) {
+		result, err := next(call)
+		if !cancelledByClient(call) {
+			return result, err
+		}
+		if err != nil || result == nil || !result.IsError {
+			result = errorResultf("%s was cancelled: %v", call.Name, context.Cause(call.Context()))
+		}
+		result.status = "cancelled"
+		return result, nil
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright 2025 Joseph Cumines
+//
+// Tests for notifications/cancelled handling of in-flight tool calls.
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/v1"
+	"github.com/joeycumines/MacosUseSDK/internal/transport"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// mockBlockingClient blocks GetClipboard until its context is done, as a slow
+// gRPC call would, and reports the error the call was aborted with.
+type mockBlockingClient struct {
+	pb.MacosUseClient
+	started chan struct{}
+	aborted chan error
+}
+
+func newMockBlockingClient() *mockBlockingClient {
+	return &mockBlockingClient{started: make(chan struct{}, 1), aborted: make(chan error, 1)}
+}
+
+func (m *mockBlockingClient) GetClipboard(ctx context.Context, req *pb.GetClipboardRequest, opts ...grpc.CallOption) (*pb.Clipboard, error) {
+	m.started <- struct{}{}
+	<-ctx.Done()
+	err := status.FromContextError(ctx.Err()).Err()
+	m.aborted <- err
+	return nil, err
+}
+
+func cancelledNotification(requestID, reason string) *MethodRequest {
+	params, _ := json.Marshal(map[string]any{"requestId": json.RawMessage(requestID), "reason": reason})
+	return &MethodRequest{Message: &transport.Message{JSONRPC: "2.0", Method: "notifications/cancelled", Params: params}}
+}
+
+func TestCancellation_AbortsToolCall(t *testing.T) {
+	client := newMockBlockingClient()
+	s := newTestMCPServer(client)
+	s.tools["clipboard"] = &Tool{Name: "clipboard", Handler: s.handleClipboard}
+
+	logPath := filepath.Join(t.TempDir(), "audit.log")
+	logger, err := NewAuditLogger(logPath)
+	if err != nil {
+		t.Fatalf("NewAuditLogger error = %v", err)
+	}
+	defer logger.Close()
+	s.auditLogger = logger
+	s.metrics = transport.NewMetricsRegistry()
+
+	responses := make(chan *transport.Message, 1)
+	go func() {
+		responses <- s.dispatch(&MethodRequest{Message: &transport.Message{
+			JSONRPC: "2.0",
+			ID:      json.RawMessage(`"req-1"`),
+			Method:  "tools/call",
+			Params:  json.RawMessage(`{"name":"clipboard","arguments":{"action":"get"}}`),
+		}})
+	}()
+
+	select {
+	case <-client.started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("tool call did not reach the gRPC client")
+	}
+
+	// Cancelling other requests leaves the call running.
+	if resp := s.dispatch(cancelledNotification(`"req-2"`, "")); resp != nil {
+		t.Errorf("unexpected response to notification: %+v", resp)
+	}
+	select {
+	case err := <-client.aborted:
+		t.Fatalf("call aborted by cancellation of another request: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if resp := s.dispatch(cancelledNotification(` "req-1"`, "user aborted")); resp != nil {
+		t.Errorf("unexpected response to notification: %+v", resp)
+	}
+	select {
+	case err := <-client.aborted:
+		if status.Code(err) != codes.Canceled {
+			t.Errorf("gRPC call error = %v, want Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("gRPC call was not aborted")
+	}
+	select {
+	case resp := <-responses:
+		if resp != nil {
+			t.Errorf("no response is due for a cancelled request, got %+v", resp)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("tools/call did not return after cancellation")
+	}
+
+	if len(s.inflight) != 0 {
+		t.Errorf("in-flight request not cleaned up: %v", s.inflight)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry map[string]any
+	if err := json.Unmarshal(bytes.TrimSpace(data), &entry); err != nil {
+		t.Fatalf("invalid audit entry %q: %v", data, err)
+	}
+	if entry["tool"] != "clipboard" || entry["status"] != "cancelled" {
+		t.Errorf("unexpected audit entry: %v", entry)
+	}
+
+	var out bytes.Buffer
+	if err := s.metrics.WritePrometheus(&out); err != nil {
+		t.Fatal(err)
+	}
+	if want := `mcp_requests_total{tool="clipboard",status="cancelled"} 1`; !strings.Contains(out.String(), want) {
+		t.Errorf("expected %q in metrics, got:\n%s", want, out.String())
+	}
+}
+
+func TestCancellation_IgnoresUnknownAndInvalid(t *testing.T) {
+	s := newTestMCPServer(nil)
+	for _, req := range []*MethodRequest{
+		cancelledNotification(`42`, "too late"),
+		{Message: &transport.Message{JSONRPC: "2.0", Method: "notifications/cancelled", Params: json.RawMessage(`{}`)}},
+		{Message: &transport.Message{JSONRPC: "2.0", Method: "notifications/cancelled", Params: json.RawMessage(`"bad"`)}},
+	} {
+		if resp := s.dispatch(req); resp != nil {
+			t.Errorf("unexpected response to %s: %+v", req.Message.Params, resp)
+		}
+	}
+
+	// Calls that complete normally are not marked cancelled.
+	s.tools["echo"] = &Tool{Name: "echo", Handler: func(call *ToolCall) (*ToolResult, error) {
+		if call.Context().Err() != nil {
+			t.Errorf("call context done during call: %v", call.Context().Err())
+		}
+		return textResult("ok"), nil
+	}}
+	resp := callToolMethod(t, s, "echo", `{}`)
+	if resp == nil || resp.Error != nil || !strings.Contains(string(resp.Result), "ok") {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestCancellationMiddleware_Status(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(errRequestCancelled)
+	call := &ToolCall{Name: "wait", ctx: ctx}
+
+	result, err := cancellationMiddleware(func(*ToolCall) (*ToolResult, error) {
+		return textResult("Wait interrupted"), nil
+	})(call)
+	if err != nil || !result.IsError || toolCallStatus(result, err) != "cancelled" {
+		t.Errorf("unexpected result: %+v, err = %v", result, err)
+	}
+
+	// Cancellation for other reasons, e.g. shutdown, is left alone.
+	ctx, cancel = context.WithCancelCause(context.Background())
+	cancel(nil)
+	call = &ToolCall{Name: "wait", ctx: ctx}
+	result, _ = cancellationMiddleware(func(*ToolCall) (*ToolResult, error) {
+		return textResult("Wait interrupted"), nil
+	})(call)
+	if toolCallStatus(result, nil) != "ok" {
+		t.Errorf("status = %q, want ok", toolCallStatus(result, nil))
+	}
+}
+
+// TestCancellation_Stdio cancels a tool call over the stdio transport: the
+// cancelled request gets no response, and later requests are still served.
+func TestCancellation_Stdio(t *testing.T) {
+	client := newMockBlockingClient()
+	s := newTestMCPServer(client)
+	s.tools["clipboard"] = &Tool{Name: "clipboard", Handler: s.handleClipboard}
+
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(transport.NewStdioTransport(stdinR, stdoutW)) }()
+
+	lines := bufio.NewScanner(stdoutR)
+	send := func(msg string) {
+		t.Helper()
+		if _, err := io.WriteString(stdinW, msg+"\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send(`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"clipboard","arguments":{"action":"get"}}}`)
+	select {
+	case <-client.started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("tool call did not reach the gRPC client")
+	}
+	send(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":7}}`)
+	select {
+	case <-client.aborted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("gRPC call was not aborted")
+	}
+
+	send(`{"jsonrpc":"2.0","id":8,"method":"ping"}`)
+	if !lines.Scan() {
+		t.Fatalf("failed to read message: %v", lines.Err())
+	}
+	var resp transport.Message
+	if err := json.Unmarshal(lines.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.ID) != "8" {
+		t.Errorf("expected only the ping response, got %s", lines.Bytes())
+	}
+
+	stdinW.Close()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Serve() error = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve did not return after stdin closed")
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2025 Joseph Cumines
+//
+// Request concurrency — the stdio worker limit and per-tool serialisation
+
+package server
+
+import (
+	"context"
+	"slices"
+
+	"github.com/joeycumines/MacosUseSDK/internal/config"
+)
+
+// maxConcurrency returns the maximum number of stdio requests handled at once.
+func (s *MCPServer) maxConcurrency() int {
+	if s.cfg == nil || s.cfg.MaxConcurrency < 1 {
+		return config.DefaultMaxConcurrency
+	}
+	return s.cfg.MaxConcurrency
+}
+
+// toolLock returns the lock serialising calls to the named tool. The lock is
+// a channel with capacity one, so waiting for it can be abandoned.
+func (s *MCPServer) toolLock(name string) chan struct{} {
+	s.toolLocksMu.Lock()
+	defer s.toolLocksMu.Unlock()
+	if s.toolLocks == nil {
+		s.toolLocks = make(map[string]chan struct{})
+	}
+	lock, ok := s.toolLocks[name]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		s.toolLocks[name] = lock
+	}
+	return lock
+}
+
+// serialMiddleware runs calls to the tools configured in MCP_SERIAL_TOOLS one
+// at a time, in the order they acquire the tool's lock. Calls to other tools
+// are unaffected. A call cancelled while waiting returns a soft error without
+// running. Input tools are serialised regardless, by inputMiddleware.
+func (s *MCPServer) serialMiddleware(next ToolHandler) ToolHandler {
+	if s.cfg == nil || len(s.cfg.SerialTools) == 0 {
+		return next
+	}
+	serial := s.cfg.SerialTools
+	return func(call *ToolCall) (*ToolResult, error) {
+		if !slices.Contains(serial, call.Name) {
+			return next(call)
+		}
+		lock := s.toolLock(call.Name)
+		select {
+		case lock <- struct{}{}:
+		case <-call.Context().Done():
+			return errorResultf("%s was not run: %v", call.Name, context.Cause(call.Context())), nil
+		}
+		defer func() { <-lock }()
+		return next(call)
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2025 Joseph Cumines
+//
+// Tests for stdio request concurrency and per-tool serialisation.
+
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/joeycumines/MacosUseSDK/internal/transport"
+)
+
+func TestServe_ConcurrentOutOfOrderResponses(t *testing.T) {
+	s := newTestMCPServer(nil)
+	s.cfg.MaxConcurrency = 2
+
+	started := make(chan struct{}, 4)
+	release := make(chan struct{})
+	var running, maxRunning atomic.Int32
+	s.tools["block"] = &Tool{Name: "block", Handler: func(call *ToolCall) (*ToolResult, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		started <- struct{}{}
+		<-release
+		return textResult("done"), nil
+	}}
+
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(transport.NewStdioTransport(stdinR, stdoutW)) }()
+
+	lines := bufio.NewScanner(stdoutR)
+	send := func(msg string) {
+		t.Helper()
+		if _, err := io.WriteString(stdinW, msg+"\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	receiveID := func() string {
+		t.Helper()
+		if !lines.Scan() {
+			t.Fatalf("failed to read message: %v", lines.Err())
+		}
+		var msg transport.Message
+		if err := json.Unmarshal(lines.Bytes(), &msg); err != nil {
+			t.Fatal(err)
+		}
+		return string(msg.ID)
+	}
+	waitStarted := func() {
+		t.Helper()
+		select {
+		case <-started:
+		case <-time.After(2 * time.Second):
+			t.Fatal("tool call did not start")
+		}
+	}
+
+	// A slow call doesn't hold up the requests behind it.
+	send(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"block"}}`)
+	waitStarted()
+	send(`{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+	if id := receiveID(); id != "2" {
+		t.Fatalf("expected the ping response first, got ID %s", id)
+	}
+
+	// At most MaxConcurrency requests run at once.
+	send(`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"block"}}`)
+	waitStarted()
+	send(`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"block"}}`)
+	select {
+	case <-started:
+		t.Fatal("request started beyond the concurrency limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	ids := map[string]bool{}
+	for range 3 {
+		ids[receiveID()] = true
+	}
+	if !ids["1"] || !ids["3"] || !ids["4"] {
+		t.Errorf("missing responses, got %v", ids)
+	}
+	if maxRunning.Load() != 2 {
+		t.Errorf("max concurrent calls = %d, want 2", maxRunning.Load())
+	}
+
+	stdinW.Close()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Serve() error = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve did not return after stdin closed")
+	}
+}
+
+// TestServe_CancelWhileSaturated cancels a call while every worker is busy and
+// a request is waiting for one: the cancellation is still read and handled.
+func TestServe_CancelWhileSaturated(t *testing.T) {
+	s := newTestMCPServer(nil)
+	s.cfg.MaxConcurrency = 1
+
+	started := make(chan string, 2)
+	s.tools["block"] = &Tool{Name: "block", Handler: func(call *ToolCall) (*ToolResult, error) {
+		started <- string(call.Arguments)
+		<-call.Context().Done()
+		return errorResult("interrupted"), nil
+	}}
+	s.tools["echo"] = &Tool{Name: "echo", Handler: func(call *ToolCall) (*ToolResult, error) {
+		started <- string(call.Arguments)
+		return textResult("ok"), nil
+	}}
+
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(transport.NewStdioTransport(stdinR, stdoutW)) }()
+	defer func() {
+		stdinW.Close()
+		select {
+		case <-served:
+		case <-time.After(2 * time.Second):
+			t.Error("Serve did not return after stdin closed")
+		}
+	}()
+
+	lines := bufio.NewScanner(stdoutR)
+	send := func(msg string) {
+		t.Helper()
+		if _, err := io.WriteString(stdinW, msg+"\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitStarted := func(want string) {
+		t.Helper()
+		select {
+		case got := <-started:
+			if got != want {
+				t.Fatalf("started call with arguments %s, want %s", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("call with arguments %s did not start", want)
+		}
+	}
+
+	send(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"block","arguments":{"n":1}}}`)
+	waitStarted(`{"n":1}`)
+	send(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"echo","arguments":{"n":2}}}`)
+	send(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":1}}`)
+
+	// The cancelled call frees its worker for the waiting request.
+	waitStarted(`{"n":2}`)
+	if !lines.Scan() {
+		t.Fatalf("failed to read message: %v", lines.Err())
+	}
+	var resp transport.Message
+	if err := json.Unmarshal(lines.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.ID) != "2" {
+		t.Errorf("expected only the response to the waiting request, got %s", lines.Bytes())
+	}
+}
+
+func TestSerialMiddleware(t *testing.T) {
+	s := newTestMCPServer(nil)
+	s.cfg.SerialTools = []string{"type"}
+
+	var mu sync.Mutex
+	active := map[string]int{}
+	overlapped := map[string]bool{}
+	for _, name := range []string{"type", "screenshot"} {
+		s.tools[name] = &Tool{Name: name, Handler: func(call *ToolCall) (*ToolResult, error) {
+			mu.Lock()
+			active[call.Name]++
+			if active[call.Name] > 1 {
+				overlapped[call.Name] = true
+			}
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			active[call.Name]--
+			mu.Unlock()
+			return textResult("ok"), nil
+		}}
+	}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		for _, name := range []string{"type", "screenshot"} {
+			wg.Go(func() {
+				if result := callToolOn(s, nil, name, `{}`); result.IsError {
+					t.Errorf("unexpected result: %+v", result)
+				}
+			})
+		}
+	}
+	wg.Wait()
+
+	if overlapped["type"] {
+		t.Error("calls to a serial tool overlapped")
+	}
+	if !overlapped["screenshot"] {
+		t.Error("calls to other tools should run concurrently")
+	}
+}
+
+func TestSerialMiddleware_CancelledWhileWaiting(t *testing.T) {
+	s := newTestMCPServer(nil)
+	s.cfg.SerialTools = []string{"type"}
+
+	lock := s.toolLock("type")
+	lock <- struct{}{}
+	defer func() { <-lock }()
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(errRequestCancelled)
+	called := false
+	handler := s.serialMiddleware(func(*ToolCall) (*ToolResult, error) {
+		called = true
+		return textResult("ok"), nil
+	})
+	result, err := handler(&ToolCall{Name: "type", ctx: ctx})
+	if err != nil || called || !result.IsError || !resultContains(result, "type was not run") {
+		t.Errorf("unexpected result: %+v, called = %v, err = %v", result, called, err)
+	}
+}
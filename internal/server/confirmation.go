@@ -0,0 +1,173 @@
+// Copyright 2025 Joseph Cumines
+//
+// Human-in-the-loop confirmation of tool calls via MCP elicitation
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/joeycumines/MacosUseSDK/internal/transport"
+)
+
+// maxConfirmArgumentLen bounds each argument value shown in a confirmation prompt.
+const maxConfirmArgumentLen = 200
+
+// elicitationResult is the result of an elicitation/create request.
+type elicitationResult struct {
+	Content map[string]any `json:"content"`
+	Action  string         `json:"action"`
+}
+
+// declinedResult creates an error result for a call the operator did not approve.
+func declinedResult(format string, args ...any) *ToolResult {
+	result := errorResultf(format, args...)
+	result.status = "declined"
+	return result
+}
+
+// requiresConfirmation reports whether the call matches MCP_CONFIRM_TOOLS.
+// Entries are a tool name, or "tool:action" to match only calls whose action
+// argument is action.
+func (s *MCPServer) requiresConfirmation(call *ToolCall) bool {
+	if s.cfg == nil {
+		return false
+	}
+	var action string
+	for _, entry := range s.cfg.ConfirmTools {
+		tool, want, scoped := strings.Cut(entry, ":")
+		if tool != call.Name {
+			continue
+		}
+		if !scoped {
+			return true
+		}
+		if action == "" {
+			var params struct {
+				Action string `json:"action"`
+			}
+			_ = json.Unmarshal(call.Arguments, &params)
+			action = params.Action
+		}
+		if strings.EqualFold(action, want) {
+			return true
+		}
+	}
+	return false
+}
+
+// confirmationMiddleware asks the operator to approve calls to the tools
+// configured in MCP_CONFIRM_TOOLS, using an elicitation/create request to the
+// client. Calls proceed only if the operator accepts; otherwise, including
+// when the client cannot be asked, they return a soft error and are audited
+// as "declined".
+func (s *MCPServer) confirmationMiddleware(next ToolHandler) ToolHandler {
+	if s.cfg == nil || len(s.cfg.ConfirmTools) == 0 {
+		return next
+	}
+	return func(call *ToolCall) (*ToolResult, error) {
+		if !s.requiresConfirmation(call) {
+			return next(call)
+		}
+		if result := s.confirmToolCall(call); result != nil {
+			return result, nil
+		}
+		return next(call)
+	}
+}
+
+// confirmToolCall requests operator approval for call. It returns nil if the
+// call was approved, or the result to return instead.
+func (s *MCPServer) confirmToolCall(call *ToolCall) *ToolResult {
+	session, _ := sessionOf(call.transport)
+	var elicitation bool
+	s.withSession(session, func(c *clientSession) { elicitation = c.elicitation })
+
+	requester, ok := call.transport.(transport.Requester)
+	if !ok || !elicitation {
+		return declinedResult("%s requires operator confirmation, but the client does not support elicitation", call.Name)
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if s.cfg.ConfirmTimeout > 0 {
+		ctx, cancel = context.WithTimeout(call.Context(), s.cfg.ConfirmTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(call.Context())
+	}
+	defer cancel()
+
+	resp, err := requester.Request(ctx, "elicitation/create", map[string]any{
+		"mode":    "form",
+		"message": confirmationMessage(call),
+		"requestedSchema": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"approve": map[string]any{
+					"type":        "boolean",
+					"title":       "Approve",
+					"description": fmt.Sprintf("Allow %s to run with these arguments", call.Name),
+				},
+			},
+			"required": []string{"approve"},
+		},
+	})
+	if err != nil {
+		return declinedResult("%s was not confirmed: %v", call.Name, err)
+	}
+	if resp.Error != nil {
+		return declinedResult("%s was not confirmed: elicitation failed: %s", call.Name, resp.Error.Message)
+	}
+
+	var result elicitationResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return declinedResult("%s was not confirmed: invalid elicitation result: %v", call.Name, err)
+	}
+	if result.Action != "accept" {
+		return declinedResult("%s was not confirmed: operator chose %s", call.Name, result.Action)
+	}
+	if approve, _ := result.Content["approve"].(bool); !approve {
+		return declinedResult("%s was not approved by the operator", call.Name)
+	}
+	return nil
+}
+
+// confirmationMessage summarises a tool call for the operator.
+func confirmationMessage(call *ToolCall) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Approve %s?", call.Name)
+
+	var args map[string]json.RawMessage
+	if err := json.Unmarshal(call.Arguments, &args); err != nil || len(args) == 0 {
+		return b.String()
+	}
+
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	b.WriteString("\n")
+	for _, k := range keys {
+		value := args[k]
+		var str string
+		if json.Unmarshal(value, &str) != nil {
+			var compact bytes.Buffer
+			if json.Compact(&compact, value) == nil {
+				value = compact.Bytes()
+			}
+			str = string(value)
+		}
+		if len(str) > maxConfirmArgumentLen {
+			str = str[:maxConfirmArgumentLen] + "..."
+		}
+		fmt.Fprintf(&b, "\n  %s: %s", k, str)
+	}
+	return b.String()
+}
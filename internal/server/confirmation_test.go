@@ -0,0 +1,202 @@
+// Copyright 2025 Joseph Cumines
+//
+// Tests for operator confirmation of tool calls via MCP elicitation.
+
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joeycumines/MacosUseSDK/internal/transport"
+)
+
+// elicitingSink is a transport that answers server-initiated requests with a
+// canned result and records them.
+type elicitingSink struct {
+	chanSink
+	result   string
+	requests []*transport.Message
+}
+
+func (e *elicitingSink) Request(ctx context.Context, method string, params any) (*transport.Message, error) {
+	data, _ := json.Marshal(params)
+	e.requests = append(e.requests, &transport.Message{Method: method, Params: data})
+	return &transport.Message{JSONRPC: "2.0", Result: json.RawMessage(e.result)}, nil
+}
+
+func newConfirmationTestServer(confirmTools ...string) (*MCPServer, *[]string) {
+	s := newTestMCPServer(nil)
+	s.cfg.ConfirmTools = confirmTools
+	s.cfg.ConfirmTimeout = 2 * time.Second
+	s.withSession("", func(c *clientSession) { c.elicitation = true })
+
+	var called []string
+	for _, name := range []string{"close_app", "clipboard", "run"} {
+		s.tools[name] = &Tool{Name: name, Handler: func(call *ToolCall) (*ToolResult, error) {
+			called = append(called, call.Name)
+			return textResult("done"), nil
+		}}
+	}
+	return s, &called
+}
+
+func callToolOn(s *MCPServer, tr transport.Transport, name, args string) *ToolResult {
+	handler := s.chainToolHandler(s.tools[name].Handler)
+	result, _ := handler(&ToolCall{Name: name, Arguments: json.RawMessage(args), transport: tr})
+	return result
+}
+
+func TestConfirmation_Outcomes(t *testing.T) {
+	tests := []struct {
+		name       string
+		result     string
+		wantCalled bool
+		wantText   string
+	}{
+		{"approved", `{"action":"accept","content":{"approve":true}}`, true, "done"},
+		{"not approved", `{"action":"accept","content":{"approve":false}}`, false, "close_app was not approved by the operator"},
+		{"declined", `{"action":"decline"}`, false, "operator chose decline"},
+		{"cancelled", `{"action":"cancel"}`, false, "operator chose cancel"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, called := newConfirmationTestServer("close_app")
+			sink := &elicitingSink{result: tc.result}
+
+			result := callToolOn(s, sink, "close_app", `{"app":"TextEdit","force":true}`)
+			if (len(*called) == 1) != tc.wantCalled {
+				t.Errorf("handler called = %v, want %v", *called, tc.wantCalled)
+			}
+			if result.IsError == tc.wantCalled || !resultContains(result, tc.wantText) {
+				t.Errorf("unexpected result: %+v", result)
+			}
+			if !tc.wantCalled && toolCallStatus(result, nil) != "declined" {
+				t.Errorf("status = %q, want declined", toolCallStatus(result, nil))
+			}
+
+			if len(sink.requests) != 1 || sink.requests[0].Method != "elicitation/create" {
+				t.Fatalf("expected one elicitation/create request, got %+v", sink.requests)
+			}
+			var params struct {
+				Message         string         `json:"message"`
+				RequestedSchema map[string]any `json:"requestedSchema"`
+			}
+			if err := json.Unmarshal(sink.requests[0].Params, &params); err != nil {
+				t.Fatal(err)
+			}
+			if want := "Approve close_app?\n\n  app: TextEdit\n  force: true"; params.Message != want {
+				t.Errorf("message = %q, want %q", params.Message, want)
+			}
+			if params.RequestedSchema["type"] != "object" {
+				t.Errorf("unexpected requestedSchema: %v", params.RequestedSchema)
+			}
+		})
+	}
+}
+
+func TestConfirmation_ActionScopedEntries(t *testing.T) {
+	s, called := newConfirmationTestServer("clipboard:set")
+	sink := &elicitingSink{result: `{"action":"decline"}`}
+
+	if result := callToolOn(s, sink, "clipboard", `{"action":"get"}`); result.IsError {
+		t.Errorf("clipboard get should not require confirmation: %+v", result)
+	}
+	if result := callToolOn(s, sink, "run", `{"command":"ls"}`); result.IsError {
+		t.Errorf("run should not require confirmation: %+v", result)
+	}
+	if result := callToolOn(s, sink, "clipboard", `{"action":"set","text":"x"}`); !result.IsError {
+		t.Errorf("clipboard set should require confirmation: %+v", result)
+	}
+	if len(sink.requests) != 1 || len(*called) != 2 {
+		t.Errorf("requests = %d, called = %v", len(sink.requests), *called)
+	}
+}
+
+func TestConfirmation_ClientWithoutElicitation(t *testing.T) {
+	s, called := newConfirmationTestServer("run")
+
+	// A transport that can't send requests.
+	result := callToolOn(s, newChanSink(), "run", `{"command":"ls"}`)
+	if !result.IsError || !resultContains(result, "client does not support elicitation") {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	// A client that didn't declare the capability.
+	s.withSession("", func(c *clientSession) { c.elicitation = false })
+	result = callToolOn(s, &elicitingSink{result: `{"action":"accept","content":{"approve":true}}`}, "run", `{"command":"ls"}`)
+	if !result.IsError || !resultContains(result, "client does not support elicitation") {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if len(*called) != 0 {
+		t.Errorf("handler should not run, called = %v", *called)
+	}
+}
+
+// TestConfirmation_Stdio runs the full round trip over the stdio transport:
+// the elicitation request is written to stdout while the tool call is in
+// flight, and the client's response on stdin releases it.
+func TestConfirmation_Stdio(t *testing.T) {
+	s, called := newConfirmationTestServer("close_app")
+	s.withSession("", func(c *clientSession) { c.elicitation = false })
+
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+	tr := transport.NewStdioTransport(stdinR, stdoutW)
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(tr) }()
+
+	lines := bufio.NewScanner(stdoutR)
+	send := func(msg string) {
+		t.Helper()
+		if _, err := io.WriteString(stdinW, msg+"\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	receive := func() *transport.Message {
+		t.Helper()
+		if !lines.Scan() {
+			t.Fatalf("failed to read message: %v", lines.Err())
+		}
+		var msg transport.Message
+		if err := json.Unmarshal(lines.Bytes(), &msg); err != nil {
+			t.Fatalf("failed to decode message: %v", err)
+		}
+		return &msg
+	}
+
+	send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-11-25","capabilities":{"elicitation":{}}}}`)
+	if resp := receive(); string(resp.ID) != "1" || resp.Error != nil {
+		t.Fatalf("unexpected initialize response: %+v", resp)
+	}
+
+	send(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"close_app","arguments":{"app":"TextEdit"}}}`)
+	req := receive()
+	if req.Method != "elicitation/create" || !strings.HasPrefix(string(req.ID), `"srv-`) {
+		t.Fatalf("expected elicitation/create request, got %+v", req)
+	}
+	if len(*called) != 0 {
+		t.Fatal("handler ran before confirmation")
+	}
+
+	send(`{"jsonrpc":"2.0","id":` + string(req.ID) + `,"result":{"action":"accept","content":{"approve":true}}}`)
+	resp := receive()
+	if string(resp.ID) != "2" || resp.Error != nil || !strings.Contains(string(resp.Result), "done") {
+		t.Fatalf("unexpected tools/call response: %+v", resp)
+	}
+
+	stdinW.Close()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Serve() error = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve did not return after stdin closed")
+	}
+}
@@ -0,0 +1,513 @@
+// Copyright 2025 Joseph Cumines
+//
+// Accessibility tree tool handler — get_accessibility_tree
+
+package server
+
+import (
+	"cmp"
+	"container/heap"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	typepb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/type"
+	pb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/v1"
+)
+
+// defaultTreeMaxElements is the number of elements get_accessibility_tree
+// shows when max_elements is unset.
+const defaultTreeMaxElements = 200
+
+// treeTextLimit is the length the outline format truncates element text to.
+const treeTextLimit = 60
+
+// structuralRoles are the roles the traversal treats as non-interactable. They
+// are the least relevant elements when a tree is truncated.
+var structuralRoles = map[string]bool{
+	"AXGroup": true, "AXStaticText": true, "AXUnknown": true, "AXSeparator": true,
+	"AXHeading": true, "AXLayoutArea": true, "AXHelpTag": true, "AXGrowArea": true,
+	"AXOutline": true, "AXScrollArea": true, "AXSplitGroup": true, "AXSplitter": true,
+	"AXToolbar": true, "AXDisclosureTriangle": true,
+}
+
+// handleGetAccessibilityTree handles the get_accessibility_tree tool — dump
+// the accessibility tree of an application or one of its windows.
+func (s *MCPServer) handleGetAccessibilityTree(call *ToolCall) (*ToolResult, error) {
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
+	defer cancel()
+
+	var params struct {
+		Parent      string `json:"parent"`
+		MaxDepth    int    `json:"max_depth"`
+		VisibleOnly bool   `json:"visible_only"`
+		Format      string `json:"format"`
+		MaxElements int    `json:"max_elements"`
+		ElementIDs  bool   `json:"element_ids"`
+	}
+	if err := json.Unmarshal(call.Arguments, &params); err != nil {
+		return errorResultf("Invalid parameters: %v", err), nil
+	}
+	if params.Parent == "" {
+		return errorResult("parent parameter is required"), nil
+	}
+	pid := parseParentPID(params.Parent)
+	if pid == 0 {
+		return errorResult("parent must be an application or window, e.g. applications/123"), nil
+	}
+	if params.MaxDepth < 0 {
+		return errorResult("max_depth can't be negative"), nil
+	}
+	if params.MaxElements < 0 {
+		return errorResult("max_elements can't be negative"), nil
+	}
+	if params.MaxElements == 0 {
+		params.MaxElements = defaultTreeMaxElements
+	}
+	switch params.Format {
+	case "":
+		params.Format = "text"
+	case "text", "outline", "json":
+	default:
+		return errorResultf("Invalid format %q: must be text, outline or json", params.Format), nil
+	}
+
+	app := fmt.Sprintf("applications/%d", pid)
+	resp, err := s.client.TraverseAccessibility(ctx, &pb.TraverseAccessibilityRequest{
+		Name:        app,
+		VisibleOnly: params.VisibleOnly,
+	})
+	if err != nil {
+		return grpcErrorResult(err, "get_accessibility_tree"), nil
+	}
+
+	tree := newElementTree(resp.GetElements())
+	roots := tree.root.children
+	if window := extractWindowFromParent(params.Parent); window != "" {
+		// The traversal covers the whole application, so the window's subtree
+		// is found by matching its bounds against the top-level windows.
+		w, err := s.client.GetWindow(ctx, &pb.GetWindowRequest{Name: window})
+		if err != nil {
+			return grpcErrorResult(err, "get_accessibility_tree"), nil
+		}
+		node := windowNode(tree, w)
+		if node == nil {
+			return errorResultf("Window %s was not found in the accessibility tree. It may be minimized or on another Space; use list_windows to check.", window), nil
+		}
+		roots = []*elementNode{node}
+	}
+
+	view := newTreeView(roots, params.MaxDepth)
+	view.truncate(params.MaxElements)
+
+	var ids map[string]string
+	if params.ElementIDs {
+		if ids, err = s.resolveElementIDs(ctx, app, view.shownElements()); err != nil {
+			return grpcErrorResult(err, "get_accessibility_tree"), nil
+		}
+	}
+
+	switch params.Format {
+	case "json":
+		data, err := json.Marshal(view.json(params.Parent, resp.GetStats(), ids))
+		if err != nil {
+			return errorResultf("Failed to encode tree: %v", err), nil
+		}
+		return textResult(string(data)), nil
+	case "outline":
+		return textResult(view.summary(params.Parent, resp.GetStats()) +
+			"\nOutline: role \"text\" #id @x,y,width,height; * focused, ! disabled\n" + view.outline(ids)), nil
+	default:
+		return textResult(view.summary(params.Parent, resp.GetStats()) + "\n" + view.text(ids)), nil
+	}
+}
+
+// windowNode returns the application's window element with w's bounds,
+// preferring one with w's title, or nil if there is none.
+func windowNode(tree *elementTree, w *pb.Window) *elementNode {
+	const tolerance = 2
+	bounds := w.GetBounds()
+	var match *elementNode
+	for _, node := range tree.nodes {
+		e := node.element
+		if len(e.GetPath()) != 1 || !rolesEqual(e.GetRole(), "AXWindow") ||
+			math.Abs(e.GetX()-bounds.GetX()) > tolerance || math.Abs(e.GetY()-bounds.GetY()) > tolerance ||
+			math.Abs(e.GetWidth()-bounds.GetWidth()) > tolerance || math.Abs(e.GetHeight()-bounds.GetHeight()) > tolerance {
+			continue
+		}
+		if e.GetText() == w.GetTitle() {
+			return node
+		}
+		if match == nil {
+			match = node
+		}
+	}
+	return match
+}
+
+// treeView is the part of an element tree get_accessibility_tree shows: the
+// subtrees of roots, down to a depth limit, and truncated to the most
+// relevant branches.
+type treeView struct {
+	roots []*elementNode
+	// total is the number of elements within the depth limit.
+	total int
+	// beyondDepth is the number of elements below the depth limit.
+	beyondDepth int
+	maxDepth    int
+	// relevance is each element's highest relevance within its subtree.
+	relevance map[*elementNode]int
+	depth     map[*elementNode]int
+	// size is each element's number of descendants, plus one.
+	size map[*elementNode]int
+	// shown is nil unless the view has been truncated.
+	shown map[*elementNode]bool
+}
+
+// newTreeView returns a view of the subtrees of roots, which are at depth 1,
+// down to maxDepth (no limit if 0).
+func newTreeView(roots []*elementNode, maxDepth int) *treeView {
+	v := &treeView{
+		roots:     roots,
+		maxDepth:  maxDepth,
+		relevance: make(map[*elementNode]int),
+		depth:     make(map[*elementNode]int),
+		size:      make(map[*elementNode]int),
+	}
+	var walk func(node *elementNode, depth int) int
+	walk = func(node *elementNode, depth int) int {
+		if v.maxDepth > 0 && depth > v.maxDepth {
+			v.beyondDepth += v.subtreeSize(node)
+			return 0
+		}
+		v.total++
+		v.depth[node] = depth
+		best := elementRelevance(node.element)
+		for _, child := range node.children {
+			best = max(best, walk(child, depth+1))
+		}
+		v.relevance[node] = best
+		return best
+	}
+	for _, root := range roots {
+		walk(root, 1)
+	}
+	return v
+}
+
+// elementRelevance scores how useful an element is to show: the focused
+// element most, then interactive elements and elements with text.
+func elementRelevance(element *typepb.Element) int {
+	score := 0
+	if element.GetFocused() {
+		score += 10
+	}
+	if !structuralRoles[canonicalRole(element.GetRole())] {
+		score += 3
+	}
+	if element.GetText() != "" {
+		score += 2
+	}
+	return score
+}
+
+// truncate limits the view to limit elements. Branches are expanded most
+// relevant first, and breadth first among equally relevant ones, so the
+// result is the tree's outline plus the branches leading to its most
+// relevant elements.
+func (v *treeView) truncate(limit int) {
+	if v.total <= limit {
+		return
+	}
+	v.shown = make(map[*elementNode]bool, limit)
+	queue := &nodeQueue{view: v}
+	for _, root := range v.roots {
+		heap.Push(queue, root)
+	}
+	for len(v.shown) < limit && queue.Len() > 0 {
+		node := heap.Pop(queue).(*elementNode)
+		v.shown[node] = true
+		for _, child := range node.children {
+			if _, ok := v.depth[child]; ok {
+				heap.Push(queue, child)
+			}
+		}
+	}
+}
+
+// nodeQueue orders elements for truncate.
+type nodeQueue struct {
+	view  *treeView
+	nodes []*elementNode
+}
+
+func (q *nodeQueue) Len() int { return len(q.nodes) }
+
+func (q *nodeQueue) Less(i, j int) bool {
+	a, b := q.nodes[i], q.nodes[j]
+	if c := cmp.Compare(q.view.relevance[b], q.view.relevance[a]); c != 0 {
+		return c < 0
+	}
+	if c := cmp.Compare(q.view.depth[a], q.view.depth[b]); c != 0 {
+		return c < 0
+	}
+	return a.order < b.order
+}
+
+func (q *nodeQueue) Swap(i, j int) { q.nodes[i], q.nodes[j] = q.nodes[j], q.nodes[i] }
+
+func (q *nodeQueue) Push(x any) { q.nodes = append(q.nodes, x.(*elementNode)) }
+
+func (q *nodeQueue) Pop() any {
+	node := q.nodes[len(q.nodes)-1]
+	q.nodes = q.nodes[:len(q.nodes)-1]
+	return node
+}
+
+// subtreeSize returns the number of elements in the subtree of node.
+func (v *treeView) subtreeSize(node *elementNode) int {
+	if n, ok := v.size[node]; ok {
+		return n
+	}
+	n := 1
+	for _, child := range node.children {
+		n += v.subtreeSize(child)
+	}
+	v.size[node] = n
+	return n
+}
+
+// isShown reports whether node is in the view.
+func (v *treeView) isShown(node *elementNode) bool {
+	if _, ok := v.depth[node]; !ok {
+		return false
+	}
+	return v.shown == nil || v.shown[node]
+}
+
+// visit calls fn for each element in the view, in document order, with the
+// number of its descendants left out by the depth limit or truncation.
+func (v *treeView) visit(fn func(node *elementNode, depth, hidden int)) {
+	var walk func(node *elementNode)
+	walk = func(node *elementNode) {
+		hidden := 0
+		for _, child := range node.children {
+			if !v.isShown(child) {
+				hidden += v.subtreeSize(child)
+			}
+		}
+		fn(node, v.depth[node], hidden)
+		for _, child := range node.children {
+			if v.isShown(child) {
+				walk(child)
+			}
+		}
+	}
+	for _, root := range v.roots {
+		if v.isShown(root) {
+			walk(root)
+		}
+	}
+}
+
+// shownElements returns the elements in the view.
+func (v *treeView) shownElements() []*typepb.Element {
+	var elements []*typepb.Element
+	v.visit(func(node *elementNode, _, _ int) {
+		elements = append(elements, node.element)
+	})
+	return elements
+}
+
+// summary describes the view and the traversal's statistics.
+func (v *treeView) summary(parent string, stats *typepb.TraversalStats) string {
+	shown := v.total
+	if v.shown != nil {
+		shown = len(v.shown)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Accessibility tree of %s: showing %d of %d elements", parent, shown, v.total+v.beyondDepth)
+	var notes []string
+	if v.beyondDepth > 0 {
+		notes = append(notes, fmt.Sprintf("%d below max_depth %d", v.beyondDepth, v.maxDepth))
+	}
+	if v.shown != nil {
+		notes = append(notes, fmt.Sprintf("truncated to the %d most relevant; raise max_elements or lower max_depth to see more", shown))
+	}
+	if len(notes) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(notes, "; "))
+	}
+	b.WriteString("\n")
+
+	if stats != nil {
+		fmt.Fprintf(&b, "Traversal: %d collected, %d excluded (%d non-interactable, %d without text), %d visible, %d with text",
+			stats.GetCount(), stats.GetExcludedCount(), stats.GetExcludedNonInteractable(), stats.GetExcludedNoText(),
+			stats.GetVisibleElementsCount(), stats.GetTextElementsCount())
+		if roles := topRoles(stats.GetRoleCounts(), 8); roles != "" {
+			b.WriteString("\nRoles: " + roles)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// topRoles formats the n most common roles in counts.
+func topRoles(counts map[string]int32, n int) string {
+	roles := make([]string, 0, len(counts))
+	for role := range counts {
+		roles = append(roles, role)
+	}
+	slices.SortFunc(roles, func(a, b string) int {
+		if c := cmp.Compare(counts[b], counts[a]); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	parts := make([]string, 0, n+1)
+	for i, role := range roles {
+		if i == n {
+			parts = append(parts, fmt.Sprintf("and %d more", len(roles)-n))
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%s %d", role, counts[role]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// text formats the view as an indented list.
+func (v *treeView) text(ids map[string]string) string {
+	var b strings.Builder
+	v.visit(func(node *elementNode, depth, hidden int) {
+		indent := strings.Repeat("  ", depth-1)
+		// Without an ID, the description starts with its separator.
+		desc := strings.TrimPrefix(describeElement(ids[pathKey(node.element.GetPath())], node.element), " - ")
+		b.WriteString(indent + "- " + desc)
+		if node.element.GetFocused() {
+			b.WriteString(" [focused]")
+		}
+		if node.element.Enabled != nil && !node.element.GetEnabled() {
+			b.WriteString(" [disabled]")
+		}
+		b.WriteString("\n")
+		if hidden > 0 {
+			fmt.Fprintf(&b, "%s  ... %d more\n", indent, hidden)
+		}
+	})
+	return b.String()
+}
+
+// outline formats the view compactly, one element per line, indented by one
+// space per level.
+func (v *treeView) outline(ids map[string]string) string {
+	var b strings.Builder
+	v.visit(func(node *elementNode, depth, hidden int) {
+		e := node.element
+		b.WriteString(strings.Repeat(" ", depth-1))
+		b.WriteString(strings.TrimPrefix(canonicalRole(e.GetRole()), "AX"))
+		if text := e.GetText(); text != "" {
+			if len([]rune(text)) > treeTextLimit {
+				text = string([]rune(text)[:treeTextLimit]) + "…"
+			}
+			fmt.Fprintf(&b, " %q", text)
+		}
+		if id := ids[pathKey(e.GetPath())]; id != "" {
+			b.WriteString(" #" + id)
+		}
+		if w, h := e.GetWidth(), e.GetHeight(); w > 0 || h > 0 {
+			fmt.Fprintf(&b, " @%.0f,%.0f,%.0f,%.0f", e.GetX(), e.GetY(), w, h)
+		}
+		if e.GetFocused() {
+			b.WriteString(" *")
+		}
+		if e.Enabled != nil && !e.GetEnabled() {
+			b.WriteString(" !")
+		}
+		if hidden > 0 {
+			fmt.Fprintf(&b, " +%d", hidden)
+		}
+		b.WriteString("\n")
+	})
+	return b.String()
+}
+
+// treeJSON is an element in the json format.
+type treeJSON struct {
+	Role     string      `json:"role"`
+	Text     string      `json:"text,omitempty"`
+	ID       string      `json:"id,omitempty"`
+	Path     []int32     `json:"path"`
+	Bounds   *treeBounds `json:"bounds,omitempty"`
+	Enabled  *bool       `json:"enabled,omitempty"`
+	Focused  bool        `json:"focused,omitempty"`
+	Hidden   int         `json:"hidden,omitempty"`
+	Children []*treeJSON `json:"children,omitempty"`
+}
+
+// treeBounds is an element's bounds in the json format.
+type treeBounds struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// json returns the view for the json format.
+func (v *treeView) json(parent string, stats *typepb.TraversalStats, ids map[string]string) map[string]any {
+	var elements []*treeJSON
+	byNode := make(map[*elementNode]*treeJSON)
+	v.visit(func(node *elementNode, _, hidden int) {
+		e := node.element
+		item := &treeJSON{
+			Role:    e.GetRole(),
+			Text:    e.GetText(),
+			ID:      ids[pathKey(e.GetPath())],
+			Path:    e.GetPath(),
+			Enabled: e.Enabled,
+			Focused: e.GetFocused(),
+			Hidden:  hidden,
+		}
+		if w, h := e.GetWidth(), e.GetHeight(); w > 0 || h > 0 {
+			item.Bounds = &treeBounds{X: e.GetX(), Y: e.GetY(), Width: w, Height: h}
+		}
+		byNode[node] = item
+		if p := byNode[node.parent]; p != nil {
+			p.Children = append(p.Children, item)
+		} else {
+			elements = append(elements, item)
+		}
+	})
+
+	shown := v.total
+	if v.shown != nil {
+		shown = len(v.shown)
+	}
+	result := map[string]any{
+		"parent":   parent,
+		"shown":    shown,
+		"total":    v.total + v.beyondDepth,
+		"elements": elements,
+	}
+	if v.beyondDepth > 0 {
+		result["beyondMaxDepth"] = v.beyondDepth
+	}
+	if v.shown != nil {
+		result["truncated"] = true
+	}
+	if stats != nil {
+		result["stats"] = map[string]any{
+			"count":                   stats.GetCount(),
+			"excludedCount":           stats.GetExcludedCount(),
+			"excludedNonInteractable": stats.GetExcludedNonInteractable(),
+			"excludedNoText":          stats.GetExcludedNoText(),
+			"textElementsCount":       stats.GetTextElementsCount(),
+			"nonTextElementsCount":    stats.GetNonTextElementsCount(),
+			"visibleElementsCount":    stats.GetVisibleElementsCount(),
+			"roleCounts":              stats.GetRoleCounts(),
+		}
+	}
+	return result
+}
@@ -0,0 +1,209 @@
+// Copyright 2025 Joseph Cumines
+//
+// Accessibility tree tool handler tests
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	typepb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/type"
+	pb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// testEditorElements is a traversal of an editor with a document window and
+// a palette window, whose focused element is the search field.
+func testEditorElements() []*typepb.Element {
+	search := withBounds(testElement("AXTextField (search text field)", "", -1, 0, 1), 210, 10, 100, 20)
+	search.Focused = proto.Bool(true)
+	save := withBounds(testElement("AXButton", "Save", -1, 0, 0), 10, 10, 60, 20)
+	save.Enabled = proto.Bool(false)
+	return []*typepb.Element{
+		testElement("AXApplication", "Editor"),
+		withBounds(testElement("AXWindow (standard window)", "Untitled", -1), 0, 0, 800, 600),
+		testElement("AXToolbar", "", -1, 0),
+		save,
+		search,
+		testElement("AXGroup", "", -1, 1),
+		testElement("AXGroup", "", -1, 1, 0),
+		testElement("AXStaticText", "Hello", -1, 1, 0, 0),
+		withBounds(testElement("AXWindow", "Colors", -2), 900, 0, 200, 300),
+		withBounds(testElement("AXSlider", "Hue", -2, 0), 910, 40, 180, 20),
+	}
+}
+
+func newTreeTestServer(t *testing.T) *MCPServer {
+	t.Helper()
+	return newTestMCPServer(&mockMacosUseClient{
+		traverseAccessibilityFunc: func(_ context.Context, req *pb.TraverseAccessibilityRequest) (*pb.TraverseAccessibilityResponse, error) {
+			if req.Name != "applications/42" {
+				t.Errorf("TraverseAccessibility Name = %q, want applications/42", req.Name)
+			}
+			return &pb.TraverseAccessibilityResponse{
+				Elements: testEditorElements(),
+				Stats: &typepb.TraversalStats{
+					Count: 10, ExcludedCount: 4, ExcludedNonInteractable: 3, ExcludedNoText: 4,
+					TextElementsCount: 6, VisibleElementsCount: 5,
+					RoleCounts: map[string]int32{"AXGroup": 5, "AXButton": 1, "AXWindow": 2},
+				},
+			}, nil
+		},
+		getWindowFunc: func(_ context.Context, req *pb.GetWindowRequest, _ ...grpc.CallOption) (*pb.Window, error) {
+			return &pb.Window{Name: req.Name, Title: "Colors", Bounds: &pb.Bounds{X: 900, Y: 1, Width: 200, Height: 300}}, nil
+		},
+	})
+}
+
+func callAccessibilityTree(t *testing.T, s *MCPServer, args string) string {
+	t.Helper()
+	result, err := s.handleGetAccessibilityTree(&ToolCall{Name: "get_accessibility_tree", Arguments: json.RawMessage(args)})
+	if err != nil {
+		t.Fatalf("handleGetAccessibilityTree returned error: %v", err)
+	}
+	if resultIsError(result) {
+		t.Fatalf("unexpected error result: %s", resultText(result))
+	}
+	return resultText(result)
+}
+
+func TestHandleGetAccessibilityTree_Text(t *testing.T) {
+	got := callAccessibilityTree(t, newTreeTestServer(t), `{"parent":"applications/42"}`)
+	want := `Accessibility tree of applications/42: showing 10 of 10 elements
+Traversal: 10 collected, 4 excluded (3 non-interactable, 4 without text), 5 visible, 6 with text
+Roles: AXGroup 5, AXWindow 2, AXButton 1
+
+- Editor (AXApplication)
+  - Untitled (AXWindow (standard window)) at (0, 0) 800x600
+    - (no text) (AXToolbar)
+      - Save (AXButton) at (10, 10) 60x20 [disabled]
+      - (no text) (AXTextField (search text field)) at (210, 10) 100x20 [focused]
+    - (no text) (AXGroup)
+      - (no text) (AXGroup)
+        - Hello (AXStaticText)
+  - Colors (AXWindow) at (900, 0) 200x300
+    - Hue (AXSlider) at (910, 40) 180x20
+`
+	if got != want {
+		t.Errorf("text =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHandleGetAccessibilityTree_OutlineDepthAndTruncation(t *testing.T) {
+	s := newTreeTestServer(t)
+
+	got := callAccessibilityTree(t, s, `{"parent":"applications/42","format":"outline","max_depth":3}`)
+	if !strings.HasPrefix(got, "Accessibility tree of applications/42: showing 6 of 10 elements (4 below max_depth 3)\n") {
+		t.Errorf("unexpected summary: %s", got)
+	}
+	wantOutline := `Application "Editor"
+ Window "Untitled" @0,0,800,600
+  Toolbar +2
+  Group +2
+ Window "Colors" @900,0,200,300
+  Slider "Hue" @910,40,180,20
+`
+	if !strings.HasSuffix(got, "\nOutline: role \"text\" #id @x,y,width,height; * focused, ! disabled\n"+wantOutline) {
+		t.Errorf("outline =\n%s\nwant it to end with:\n%s", got, wantOutline)
+	}
+
+	// The focused element's branch is kept, then the outline of the rest,
+	// leaving out the empty groups first.
+	got = callAccessibilityTree(t, s, `{"parent":"applications/42","format":"outline","max_elements":6}`)
+	if !strings.Contains(got, "showing 6 of 10 elements (truncated to the 6 most relevant;") {
+		t.Errorf("unexpected summary: %s", got)
+	}
+	wantOutline = `Application "Editor"
+ Window "Untitled" @0,0,800,600 +3
+  Toolbar +1
+   TextField @210,10,100,20 *
+ Window "Colors" @900,0,200,300
+  Slider "Hue" @910,40,180,20
+`
+	if !strings.HasSuffix(got, wantOutline) {
+		t.Errorf("outline =\n%s\nwant it to end with:\n%s", got, wantOutline)
+	}
+}
+
+func TestHandleGetAccessibilityTree_WindowJSON(t *testing.T) {
+	got := callAccessibilityTree(t, newTreeTestServer(t), `{"parent":"applications/42/windows/7","format":"json"}`)
+	var tree struct {
+		Parent   string `json:"parent"`
+		Shown    int    `json:"shown"`
+		Total    int    `json:"total"`
+		Elements []struct {
+			Role     string `json:"role"`
+			Text     string `json:"text"`
+			Path     []int32
+			Bounds   *treeBounds `json:"bounds"`
+			Children []struct {
+				Role string `json:"role"`
+			} `json:"children"`
+		} `json:"elements"`
+		Stats map[string]any `json:"stats"`
+	}
+	if err := json.Unmarshal([]byte(got), &tree); err != nil {
+		t.Fatalf("invalid JSON %s: %v", got, err)
+	}
+	if tree.Parent != "applications/42/windows/7" || tree.Shown != 2 || tree.Total != 2 || len(tree.Elements) != 1 {
+		t.Fatalf("unexpected tree: %s", got)
+	}
+	window := tree.Elements[0]
+	if window.Text != "Colors" || *window.Bounds != (treeBounds{X: 900, Width: 200, Height: 300}) ||
+		len(window.Children) != 1 || window.Children[0].Role != "AXSlider" {
+		t.Errorf("unexpected window: %s", got)
+	}
+	if tree.Stats["count"] != float64(10) || tree.Stats["roleCounts"] == nil {
+		t.Errorf("unexpected stats: %v", tree.Stats)
+	}
+}
+
+func TestHandleGetAccessibilityTree_ElementIDs(t *testing.T) {
+	s := newPathTestServer(t, &mockMacosUseClient{})
+	got := callAccessibilityTree(t, s, `{"parent":"applications/42","format":"outline","element_ids":true,"max_depth":3}`)
+	for _, want := range []string{
+		"\nApplication \"Shop\" #e\n",
+		"\n  Button \"Pay\" #e-1.2 @40,50,20,20\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("outline missing %q:\n%s", want, got)
+		}
+	}
+
+	got = callAccessibilityTree(t, s, `{"parent":"applications/42","element_ids":true}`)
+	if want := "\n    - e-1.2 - Pay (AXButton (button)) at (40, 50) 20x20\n"; !strings.Contains(got, want) {
+		t.Errorf("text missing %q:\n%s", want, got)
+	}
+}
+
+func TestHandleGetAccessibilityTree_Errors(t *testing.T) {
+	s := newTreeTestServer(t)
+	s.client.(*mockMacosUseClient).getWindowFunc = func(_ context.Context, req *pb.GetWindowRequest, _ ...grpc.CallOption) (*pb.Window, error) {
+		return &pb.Window{Name: req.Name, Bounds: &pb.Bounds{X: 5, Y: 5, Width: 10, Height: 10}}, nil
+	}
+
+	tests := []struct {
+		args    string
+		wantErr string
+	}{
+		{`{}`, "parent parameter is required"},
+		{`{"parent":"displays/1"}`, "parent must be an application or window"},
+		{`{"parent":"applications/42","max_depth":-1}`, "max_depth can't be negative"},
+		{`{"parent":"applications/42","max_elements":-1}`, "max_elements can't be negative"},
+		{`{"parent":"applications/42","format":"xml"}`, `Invalid format "xml"`},
+		{`{"parent":"applications/42/windows/9"}`, "Window applications/42/windows/9 was not found in the accessibility tree"},
+	}
+	for _, tt := range tests {
+		result, err := s.handleGetAccessibilityTree(&ToolCall{Name: "get_accessibility_tree", Arguments: json.RawMessage(tt.args)})
+		if err != nil {
+			t.Fatalf("%s: handleGetAccessibilityTree returned error: %v", tt.args, err)
+		}
+		if !resultIsError(result) || !resultContains(result, tt.wantErr) {
+			t.Errorf("%s: expected error containing %q, got: %s", tt.args, tt.wantErr, resultText(result))
+		}
+	}
+}
@@ -18,7 +18,6 @@ import (
 
 	longrunningpb "cloud.google.com/go/longrunning/autogen/longrunningpb"
 	pb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/v1"
-	"github.com/joeycumines/MacosUseSDK/internal/server/tools"
 	"google.golang.org/protobuf/types/known/durationpb"
 )
 
@@ -28,7 +27,7 @@ import (
 //   - force_new_instance: Always launch a new process
 //   - activate_only: Error if not running, otherwise activate
 func (s *MCPServer) handleOpenApp(call *ToolCall) (*ToolResult, error) {
-	ctx, cancel := context.WithTimeout(s.ctx, time.Duration(s.cfg.RequestTimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
 	defer cancel()
 
 	var params struct {
@@ -113,9 +112,22 @@ func (s *MCPServer) pollForWindows(ctx context.Context, appName string) []*pb.Wi
 }
 
 // detectBareBinary checks if a process is a bare binary (no bundle identity).
-// Uses AppleScript/System Events to look up the bundle identifier by PID.
 // Processes without a bundle identifier (e.g. command-line tools) are flagged as bare.
 func (s *MCPServer) detectBareBinary(ctx context.Context, pid int32) (bool, string) {
+	bundleID, err := s.lookupBundleID(ctx, pid)
+	if err != nil {
+		// Can't determine — assume not bare binary
+		return false, ""
+	}
+	if bundleID == "" {
+		return true, "\n  WARNING: This app is a bare binary process (no bundle identity).\n    - It may not appear in the Dock\n    - Its windows may not respond to standard activation\n    - Use find_elements to discover interactive elements"
+	}
+	return false, ""
+}
+
+// lookupBundleID returns the bundle identifier of the process with the given
+// PID, or "" if it has none. Uses AppleScript/System Events to look it up.
+func (s *MCPServer) lookupBundleID(ctx context.Context, pid int32) (string, error) {
 	script := fmt.Sprintf(`tell application "System Events" to return bundle identifier of first application process whose unix id is %d`, pid)
 	shellResp, err := s.client.ExecuteShellCommand(ctx, &pb.ExecuteShellCommandRequest{
 		Command: "/usr/bin/osascript",
@@ -123,15 +135,17 @@ func (s *MCPServer) detectBareBinary(ctx context.Context, pid int32) (bool, stri
 		Timeout: durationpb.New(5 * time.Second),
 	})
 	// Safe: %d only accepts integers, preventing shell injection via pid
-	if err != nil || shellResp.ExitCode != 0 {
-		// Can't determine — assume not bare binary
-		return false, ""
+	if err != nil {
+		return "", err
+	}
+	if shellResp.ExitCode != 0 {
+		return "", fmt.Errorf("osascript exited with code %d: %s", shellResp.ExitCode, strings.TrimSpace(shellResp.Stderr))
 	}
 	bundleID := strings.TrimSpace(shellResp.Stdout)
-	if bundleID == "" || bundleID == "-" || strings.EqualFold(bundleID, "missing value") {
-		return true, "\n  WARNING: This app is a bare binary process (no bundle identity).\n    - It may not appear in the Dock\n    - Its windows may not respond to standard activation\n    - Use find_elements to discover interactive elements"
+	if bundleID == "-" || strings.EqualFold(bundleID, "missing value") {
+		bundleID = ""
 	}
-	return false, ""
+	return bundleID, nil
 }
 
 // formatEnrichedAppResponse formats the enriched open_app response with window info,
@@ -343,21 +357,9 @@ func (s *MCPServer) openAppActivateOnly(ctx context.Context, id string, bringToF
 
 // pollOpenAppOperation polls an OpenApplication LRO until completion.
 func (s *MCPServer) pollOpenAppOperation(ctx context.Context, op *longrunningpb.Operation) (*pb.OpenApplicationResponse, error) {
-	if !op.Done {
-		opsClient := &tools.OperationClient{Client: s.opsClient}
-		if err := tools.PollUntilComplete(ctx, opsClient, op.Name, 100*time.Millisecond); err != nil {
-			return nil, fmt.Errorf("polling failed: %w", err)
-		}
-
-		latestOp, err := s.opsClient.GetOperation(ctx, &longrunningpb.GetOperationRequest{Name: op.Name})
-		if err != nil {
-			return nil, fmt.Errorf("get operation failed: %w", err)
-		}
-		op = latestOp
-	}
-
-	if opErr := op.GetError(); opErr != nil {
-		return nil, fmt.Errorf("operation error: %s", opErr.Message)
+	op, err := s.awaitOperation(ctx, op)
+	if err != nil {
+		return nil, err
 	}
 
 	var response pb.OpenApplicationResponse
@@ -373,7 +375,7 @@ func (s *MCPServer) pollOpenAppOperation(ctx context.Context, op *longrunningpb.
 // handleListApps handles the list_apps tool — enriched listing of tracked apps
 // with per-app window counts and focused window info.
 func (s *MCPServer) handleListApps(call *ToolCall) (*ToolResult, error) {
-	ctx, cancel := context.WithTimeout(s.ctx, time.Duration(s.cfg.RequestTimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
 	defer cancel()
 
 	resp, err := s.client.ListApplications(ctx, &pb.ListApplicationsRequest{})
@@ -434,7 +436,7 @@ func quitApplicationAppleScriptArgs(displayName string) []string {
 // Unlike the old delete_application which only untracked, this quits the process.
 // Enhanced with post-close verification.
 func (s *MCPServer) handleCloseApp(call *ToolCall) (*ToolResult, error) {
-	ctx, cancel := context.WithTimeout(s.ctx, time.Duration(s.cfg.RequestTimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
 	defer cancel()
 
 	var params struct {
@@ -16,7 +16,7 @@ import (
 // handleClipboard handles the clipboard tool — unified clipboard operations.
 // Action discriminator: get, set, clear.
 func (s *MCPServer) handleClipboard(call *ToolCall) (*ToolResult, error) {
-	ctx, cancel := context.WithTimeout(s.ctx, time.Duration(s.cfg.RequestTimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
 	defer cancel()
 
 	var params struct {
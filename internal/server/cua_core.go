@@ -216,6 +216,31 @@ func inputName(r *pb.Input) string {
 	return name
 }
 
+// cuaKeysToKeyPress converts a CUA keys[] combination into a KeyPress.
+// The primary key is the last non-modifier key, or the last key if all are
+// modifiers. Returns nil if no valid keys were provided.
+func cuaKeysToKeyPress(keys []string) *pb.KeyPress {
+	modifierEnums, nonModifierKeys := cuaKeysToModifiers(keys)
+
+	if len(nonModifierKeys) == 0 && len(modifierEnums) == 0 {
+		return nil
+	}
+
+	var primaryKey string
+	if len(nonModifierKeys) > 0 {
+		primaryKey = normalizeCUAKey(nonModifierKeys[len(nonModifierKeys)-1])
+	} else {
+		// All keys are modifiers — press the last modifier as primary
+		primaryKey = modifierToKeyName(modifierEnums[len(modifierEnums)-1])
+		modifierEnums = modifierEnums[:len(modifierEnums)-1]
+	}
+
+	return &pb.KeyPress{
+		Key:       primaryKey,
+		Modifiers: modifierEnums,
+	}
+}
+
 // modifierToKeyName converts a proto modifier enum to the key name string.
 func modifierToKeyName(mod pb.KeyPress_Modifier) string {
 	switch mod {
@@ -267,7 +292,7 @@ func buttonDisplayName(clickType pb.MouseClick_ClickType) string {
 // to CaptureScreenshot, CaptureWindowScreenshot, or CaptureRegionScreenshot
 // based on which parameters are provided.
 func (s *MCPServer) handleScreenshot(call *ToolCall) (*ToolResult, error) {
-	ctx, cancel := context.WithTimeout(s.ctx, time.Duration(s.cfg.RequestTimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
 	defer cancel()
 
 	var params struct {
@@ -378,7 +403,7 @@ func screenshotResult(imageData []byte, format pb.ImageFormat, width, height int
 // handleClick handles the click tool — click at screen coordinates with optional
 // modifier keys held during the click.
 func (s *MCPServer) cuaHandleClick(call *ToolCall) (*ToolResult, error) {
-	ctx, cancel := context.WithTimeout(s.ctx, time.Duration(s.cfg.RequestTimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
 	defer cancel()
 
 	var params struct {
@@ -456,7 +481,7 @@ func (s *MCPServer) cuaHandleClick(call *ToolCall) (*ToolResult, error) {
 
 // handleDoubleClick handles the double_click tool.
 func (s *MCPServer) handleDoubleClick(call *ToolCall) (*ToolResult, error) {
-	ctx, cancel := context.WithTimeout(s.ctx, time.Duration(s.cfg.RequestTimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
 	defer cancel()
 
 	var params struct {
@@ -514,7 +539,7 @@ func (s *MCPServer) handleDoubleClick(call *ToolCall) (*ToolResult, error) {
 // handleType handles the type tool — type text as keyboard input.
 // If no parent is supplied, keystrokes are delivered to the current frontmost application.
 func (s *MCPServer) handleType(call *ToolCall) (*ToolResult, error) {
-	ctx, cancel := context.WithTimeout(s.ctx, time.Duration(s.cfg.RequestTimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
 	defer cancel()
 
 	var params struct {
@@ -583,7 +608,7 @@ func (s *MCPServer) handleType(call *ToolCall) (*ToolResult, error) {
 // CUA keys[] format: ["ctrl","c"] or ["meta","shift","3"].
 // The last non-modifier key is the primary key; all others are modifiers.
 func (s *MCPServer) handleKeypress(call *ToolCall) (*ToolResult, error) {
-	ctx, cancel := context.WithTimeout(s.ctx, time.Duration(s.cfg.RequestTimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
 	defer cancel()
 
 	var params struct {
@@ -598,30 +623,15 @@ func (s *MCPServer) handleKeypress(call *ToolCall) (*ToolResult, error) {
 		return errorResult("keys parameter is required and must be non-empty"), nil
 	}
 
-	// Separate modifiers from the primary key
-	modifierEnums, nonModifierKeys := cuaKeysToModifiers(params.Keys)
-
-	if len(nonModifierKeys) == 0 && len(modifierEnums) == 0 {
+	keyPress := cuaKeysToKeyPress(params.Keys)
+	if keyPress == nil {
 		return errorResult("no valid keys provided"), nil
 	}
 
-	// The primary key is the last non-modifier key, or the last key if all are modifiers
-	var primaryKey string
-	if len(nonModifierKeys) > 0 {
-		primaryKey = normalizeCUAKey(nonModifierKeys[len(nonModifierKeys)-1])
-	} else {
-		// All keys are modifiers — press the last modifier as primary
-		primaryKey = modifierToKeyName(modifierEnums[len(modifierEnums)-1])
-		modifierEnums = modifierEnums[:len(modifierEnums)-1]
-	}
-
 	input := &pb.Input{
 		Action: &pb.InputAction{
 			InputType: &pb.InputAction_PressKey{
-				PressKey: &pb.KeyPress{
-					Key:       primaryKey,
-					Modifiers: modifierEnums,
-				},
+				PressKey: keyPress,
 			},
 		},
 	}
@@ -648,7 +658,7 @@ func (s *MCPServer) handleKeypress(call *ToolCall) (*ToolResult, error) {
 // handleScroll handles the scroll tool — scroll at a position by delta amounts.
 // Uses CUA-style scroll_x/scroll_y instead of old horizontal/vertical.
 func (s *MCPServer) cuaHandleScroll(call *ToolCall) (*ToolResult, error) {
-	ctx, cancel := context.WithTimeout(s.ctx, time.Duration(s.cfg.RequestTimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
 	defer cancel()
 
 	var params struct {
@@ -738,7 +748,7 @@ func (s *MCPServer) cuaHandleScroll(call *ToolCall) (*ToolResult, error) {
 // handleDrag handles the drag tool — click-and-drag along a sequence of waypoints.
 // Uses CUA-style path[] instead of old start_x/start_y/end_x/end_y.
 func (s *MCPServer) cuaHandleDrag(call *ToolCall) (*ToolResult, error) {
-	ctx, cancel := context.WithTimeout(s.ctx, time.Duration(s.cfg.RequestTimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
 	defer cancel()
 
 	var params struct {
@@ -819,7 +829,7 @@ func (s *MCPServer) cuaHandleDrag(call *ToolCall) (*ToolResult, error) {
 
 // handleMove handles the move tool — move mouse cursor without clicking.
 func (s *MCPServer) handleMove(call *ToolCall) (*ToolResult, error) {
-	ctx, cancel := context.WithTimeout(s.ctx, time.Duration(s.cfg.RequestTimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
 	defer cancel()
 
 	var params struct {
@@ -904,10 +914,14 @@ func (s *MCPServer) handleWait(call *ToolCall) (*ToolResult, error) {
 		params.Duration = maxWait
 	}
 
-	timer := time.NewTimer(time.Duration(params.Duration * float64(time.Second)))
+	duration := time.Duration(params.Duration * float64(time.Second))
+	stop := trackProgress(call.Context(), duration, "Waiting")
+	defer stop()
+
+	timer := time.NewTimer(duration)
 	defer timer.Stop()
 	select {
-	case <-s.ctx.Done():
+	case <-call.Context().Done():
 		return textResultf("Wait interrupted"), nil
 	case <-timer.C:
 	}
@@ -15,7 +15,7 @@ import (
 
 // handleGetDisplay handles the get_display tool — returns display info and cursor position.
 func (s *MCPServer) cuaHandleGetDisplay(call *ToolCall) (*ToolResult, error) {
-	ctx, cancel := context.WithTimeout(s.ctx, time.Duration(s.cfg.RequestTimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
 	defer cancel()
 
 	// Get displays
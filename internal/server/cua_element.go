@@ -17,9 +17,10 @@ import (
 )
 
 // handleFindElements handles the find_elements tool — find UI elements by criteria.
-// Uses flat parameters (role, text, text_contains) instead of nested selector object.
+// Uses flat parameters (role, text, text_contains) and an optional selector
+// string, all of which must match, or alternatively a path query.
 func (s *MCPServer) cuaHandleFindElements(call *ToolCall) (*ToolResult, error) {
-	ctx, cancel := context.WithTimeout(s.ctx, time.Duration(s.cfg.RequestTimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
 	defer cancel()
 
 	var params struct {
@@ -27,6 +28,8 @@ func (s *MCPServer) cuaHandleFindElements(call *ToolCall) (*ToolResult, error) {
 		Role         string `json:"role"`
 		Text         string `json:"text"`
 		TextContains string `json:"text_contains"`
+		Selector     string `json:"selector"`
+		Path         string `json:"path"`
 		ForceRefresh bool   `json:"force_refresh"`
 		PageSize     int32  `json:"page_size"`
 		PageToken    string `json:"page_token"`
@@ -43,46 +46,56 @@ func (s *MCPServer) cuaHandleFindElements(call *ToolCall) (*ToolResult, error) {
 		return errorResult("page_size must be non-negative"), nil
 	}
 
-	// Build selector from flat params — selector uses oneof, so we set one criterion
-	var selector *typepb.ElementSelector
-	if params.Role != "" || params.Text != "" || params.TextContains != "" {
-		selector = &typepb.ElementSelector{}
-		switch {
-		case params.Role != "":
-			selector.Criteria = &typepb.ElementSelector_Role{Role: params.Role}
-		case params.Text != "":
-			selector.Criteria = &typepb.ElementSelector_Text{Text: params.Text}
-		case params.TextContains != "":
-			selector.Criteria = &typepb.ElementSelector_TextContains{TextContains: params.TextContains}
+	if params.Path != "" {
+		if params.Role != "" || params.Text != "" || params.TextContains != "" || params.Selector != "" {
+			return errorResult("path can't be combined with role, text, text_contains or selector"), nil
 		}
+		if params.PageToken != "" {
+			return errorResult("page_token isn't supported with path; raise page_size instead"), nil
+		}
+		if parseParentPID(params.Parent) == 0 {
+			return errorResult("path requires an application or window parent, e.g. applications/123"), nil
+		}
+		path, err := parseElementPath(params.Path)
+		if err != nil {
+			return errorResultf("Invalid path: %v", err), nil
+		}
+		limit := int(params.PageSize)
+		if limit == 0 {
+			limit = defaultPathPageSize
+		}
+		matches, total, err := s.findElementsByPath(ctx, params.Parent, path, limit)
+		if err != nil {
+			return grpcErrorResult(err, "find_elements"), nil
+		}
+		if total == 0 {
+			return textResult("No elements found matching path"), nil
+		}
+		return textResult(formatPathMatches(matches, total)), nil
 	}
 
-	// Warn if multiple criteria were provided (only one is actually used due to oneof)
-	providedCriteria := 0
+	// Every criterion given must match: the flat params and the selector.
+	var criteria []*typepb.ElementSelector
+	if params.Selector != "" {
+		selector, err := parseElementSelector(params.Selector)
+		if err != nil {
+			return errorResultf("Invalid selector: %v", err), nil
+		}
+		criteria = append(criteria, selector)
+	}
 	if params.Role != "" {
-		providedCriteria++
+		criteria = append(criteria, &typepb.ElementSelector{Criteria: &typepb.ElementSelector_Role{Role: params.Role}})
 	}
 	if params.Text != "" {
-		providedCriteria++
+		criteria = append(criteria, &typepb.ElementSelector{Criteria: &typepb.ElementSelector_Text{Text: params.Text}})
 	}
 	if params.TextContains != "" {
-		providedCriteria++
-	}
-	var criteriaWarning string
-	if providedCriteria > 1 {
-		// Match the selector priority: role > text > text_contains
-		criteriaName := "role"
-		if params.Role == "" && params.Text != "" {
-			criteriaName = "text"
-		} else if params.Role == "" && params.Text == "" && params.TextContains != "" {
-			criteriaName = "text_contains"
-		}
-		criteriaWarning = fmt.Sprintf("\n\nNote: Only one search criterion is supported at a time. Using %s. Other criteria were ignored.", criteriaName)
+		criteria = append(criteria, &typepb.ElementSelector{Criteria: &typepb.ElementSelector_TextContains{TextContains: params.TextContains}})
 	}
 
 	resp, err := s.client.FindElements(ctx, &pb.FindElementsRequest{
 		Parent:       params.Parent,
-		Selector:     selector,
+		Selector:     combineSelectors(criteria...),
 		ForceRefresh: params.ForceRefresh,
 		PageSize:     params.PageSize,
 		PageToken:    params.PageToken,
@@ -109,9 +122,6 @@ func (s *MCPServer) cuaHandleFindElements(call *ToolCall) (*ToolResult, error) {
 	}
 
 	result := fmt.Sprintf("Found %d elements:\n%s", len(resp.Elements), strings.Join(lines, "\n"))
-	if criteriaWarning != "" {
-		result += criteriaWarning
-	}
 	if resp.NextPageToken != "" {
 		result += fmt.Sprintf("\n\nMore results available. Use page_token: %s", resp.NextPageToken)
 	}
@@ -119,16 +129,17 @@ func (s *MCPServer) cuaHandleFindElements(call *ToolCall) (*ToolResult, error) {
 }
 
 // handleClickElement handles the click_element tool — click a UI element via accessibility APIs.
-// Targeting can be done by either element ID or selector (e.g., "role:AXButton", "text:Save").
+// Targeting can be done by element ID, selector (e.g., "role:AXButton", "text:Save") or path.
 // Selector is preferred because element IDs from find_elements are ephemeral.
 func (s *MCPServer) cuaHandleClickElement(call *ToolCall) (*ToolResult, error) {
-	ctx, cancel := context.WithTimeout(s.ctx, time.Duration(s.cfg.RequestTimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
 	defer cancel()
 
 	var params struct {
 		Parent   string `json:"parent"`
 		Element  string `json:"element"`
 		Selector string `json:"selector"`
+		Path     string `json:"path"`
 	}
 
 	if err := json.Unmarshal(call.Arguments, &params); err != nil {
@@ -138,12 +149,25 @@ func (s *MCPServer) cuaHandleClickElement(call *ToolCall) (*ToolResult, error) {
 	if params.Parent == "" {
 		return errorResult("parent parameter is required"), nil
 	}
-	if params.Element == "" && params.Selector == "" {
-		return errorResult("element or selector parameter is required"), nil
+	if params.Element == "" && params.Selector == "" && params.Path == "" {
+		return errorResult("element, selector or path parameter is required"), nil
 	}
 	if params.Element != "" && params.Selector != "" {
 		return errorResult("provide either element or selector, not both"), nil
 	}
+	if params.Path != "" && (params.Element != "" || params.Selector != "") {
+		return errorResult("provide either path or element/selector, not both"), nil
+	}
+
+	if params.Path != "" {
+		// The path is matched against a fresh traversal, then the one match
+		// is clicked by ID.
+		elementID, errResult := s.elementIDForPath(ctx, "click_element", params.Parent, params.Path)
+		if errResult != nil {
+			return errResult, nil
+		}
+		return s.clickElementFallback(ctx, params.Parent, elementID)
+	}
 
 	if params.Selector != "" {
 		// Resolve stable selector server-side, which also handles focus acquisition
@@ -180,9 +204,16 @@ func (s *MCPServer) cuaHandleClickElement(call *ToolCall) (*ToolResult, error) {
 }
 
 // clickElementError categorizes a ClickElement RPC error into a structured,
-// actionable ToolResult. It intentionally mirrors only the strings emitted by
-// the Swift clickElement method so the Go-side heuristics stay in sync.
+// actionable ToolResult.
 func clickElementError(err error, targetDesc string) *ToolResult {
+	return elementTargetError(err, "click_element", targetDesc)
+}
+
+// elementTargetError categorizes an error from an RPC targeting an element,
+// such as ClickElement or PerformElementAction, into a structured, actionable
+// ToolResult for tool. It intentionally mirrors only the strings emitted by
+// the Swift element methods so the Go-side heuristics stay in sync.
+func elementTargetError(err error, tool, targetDesc string) *ToolResult {
 	// gRPC status messages are mixed-case; normalize once for reliable matching.
 	msg := strings.ToLower(err.Error())
 
@@ -206,7 +237,7 @@ func clickElementError(err error, targetDesc string) *ToolResult {
 		return errorResultf("Element %s has no usable position information. It may be hidden or off-screen.", targetDesc)
 	}
 
-	return grpcErrorResult(err, "click_element")
+	return grpcErrorResult(err, tool)
 }
 
 // clickElementFallback handles the element-ID path for click_element.
@@ -286,36 +317,11 @@ func elementResourceName(parent, elementID string) string {
 	return fmt.Sprintf("%s/elements/%s", parent, elementID)
 }
 
-// parseElementSelector converts a simple "key:value" selector string into a proto
-// ElementSelector. Supported keys: role, text, text_contains (and textcontains).
-// Example: "role:AXTextArea", "text:hello", "text_contains:world".
-func parseElementSelector(selector string) (*typepb.ElementSelector, error) {
-	before, after, ok := strings.Cut(selector, ":")
-	if !ok {
-		return nil, fmt.Errorf("selector must be in the form key:value (e.g. role:AXTextArea)")
-	}
-	key := strings.TrimSpace(strings.ToLower(before))
-	value := strings.TrimSpace(after)
-	// Empty values are intentionally allowed: locating an AXTextField whose
-	// current value is empty is a valid UI state, and role:"" will simply
-	// fail to match against real role strings.
-	switch key {
-	case "role":
-		return &typepb.ElementSelector{Criteria: &typepb.ElementSelector_Role{Role: value}}, nil
-	case "text":
-		return &typepb.ElementSelector{Criteria: &typepb.ElementSelector_Text{Text: value}}, nil
-	case "textcontains", "text_contains":
-		return &typepb.ElementSelector{Criteria: &typepb.ElementSelector_TextContains{TextContains: value}}, nil
-	default:
-		return nil, fmt.Errorf("unsupported selector key %q; use role, text, or text_contains", key)
-	}
-}
-
 // handleTypeElement handles the type_element tool — set value of a UI element with auto-focus.
 // Targeting can be done by either element ID or selector (e.g., "role:AXTextArea", "text:Save").
 // Selector is preferred because element IDs from find_elements are ephemeral.
 func (s *MCPServer) handleTypeElement(call *ToolCall) (*ToolResult, error) {
-	ctx, cancel := context.WithTimeout(s.ctx, time.Duration(s.cfg.RequestTimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
 	defer cancel()
 
 	var params struct {
@@ -459,20 +465,34 @@ func (s *MCPServer) handleTypeElement(call *ToolCall) (*ToolResult, error) {
 // Combines GetElement + GetElementActions into a single response.
 // Accepts either a full element resource name or an element ID returned by find_elements.
 func (s *MCPServer) handleReadElement(call *ToolCall) (*ToolResult, error) {
-	ctx, cancel := context.WithTimeout(s.ctx, time.Duration(s.cfg.RequestTimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
 	defer cancel()
 
 	var params struct {
 		Parent  string `json:"parent"`
 		Element string `json:"element"`
+		Path    string `json:"path"`
 	}
 
 	if err := json.Unmarshal(call.Arguments, &params); err != nil {
 		return errorResultf("Invalid parameters: %v", err), nil
 	}
 
-	if params.Element == "" {
-		return errorResult("element parameter is required"), nil
+	if params.Element == "" && params.Path == "" {
+		return errorResult("element or path parameter is required"), nil
+	}
+	if params.Element != "" && params.Path != "" {
+		return errorResult("provide either element or path, not both"), nil
+	}
+	if params.Path != "" {
+		if params.Parent == "" {
+			return errorResult("read_element: path requires a parent (the application or window to search)"), nil
+		}
+		elementID, errResult := s.elementIDForPath(ctx, "read_element", params.Parent, params.Path)
+		if errResult != nil {
+			return errResult, nil
+		}
+		params.Element = elementID
 	}
 
 	// find_elements returns bare IDs like "elem_..." but GetElement expects
@@ -0,0 +1,198 @@
+// Copyright 2025 Joseph Cumines
+//
+// Element action tool handlers — element_actions, perform_element_action
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	pb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/v1"
+)
+
+// axActionDescriptions describes the common accessibility actions, for
+// element_actions.
+var axActionDescriptions = map[string]string{
+	"AXPress":           "press the element, like a click",
+	"AXShowMenu":        "show the element's context or pop-up menu",
+	"AXIncrement":       "increase the value, e.g. of a slider or stepper",
+	"AXDecrement":       "decrease the value, e.g. of a slider or stepper",
+	"AXConfirm":         "confirm, like pressing Return",
+	"AXCancel":          "cancel, like pressing Escape",
+	"AXRaise":           "bring the window to the front",
+	"AXPick":            "select the item, e.g. in a menu",
+	"AXScrollToVisible": "scroll the element into view",
+	"AXShowAlternateUI": "show alternate controls, e.g. those shown on hover",
+	"AXShowDefaultUI":   "restore the default controls",
+}
+
+// elementTargetParams are the ways element tools can target an element: by
+// ID, selector or path, exactly one of which must be set.
+type elementTargetParams struct {
+	Parent   string `json:"parent"`
+	Element  string `json:"element"`
+	Selector string `json:"selector"`
+	Path     string `json:"path"`
+}
+
+// validate returns an error result unless parent and exactly one target are set.
+func (p *elementTargetParams) validate() *ToolResult {
+	if p.Parent == "" {
+		return errorResult("parent parameter is required")
+	}
+	targets := 0
+	for _, target := range []string{p.Element, p.Selector, p.Path} {
+		if target != "" {
+			targets++
+		}
+	}
+	switch {
+	case targets == 0:
+		return errorResult("element, selector or path parameter is required")
+	case targets > 1:
+		return errorResult("provide only one of element, selector or path")
+	}
+	return nil
+}
+
+// handleElementActions handles the element_actions tool — list the
+// accessibility actions an element supports.
+func (s *MCPServer) handleElementActions(call *ToolCall) (*ToolResult, error) {
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
+	defer cancel()
+
+	var params elementTargetParams
+	if err := json.Unmarshal(call.Arguments, &params); err != nil {
+		return errorResultf("Invalid parameters: %v", err), nil
+	}
+	if errResult := params.validate(); errResult != nil {
+		return errResult, nil
+	}
+
+	// GetElementActions takes an element, so selectors and paths are
+	// resolved to one first.
+	elementID := params.Element
+	var note string
+	switch {
+	case params.Selector != "":
+		selector, err := parseElementSelector(params.Selector)
+		if err != nil {
+			return errorResultf("Invalid selector: %v", err), nil
+		}
+		resp, err := s.client.FindElements(ctx, &pb.FindElementsRequest{
+			Parent:   params.Parent,
+			Selector: selector,
+			PageSize: 1,
+		})
+		if err != nil {
+			return grpcErrorResult(err, "element_actions"), nil
+		}
+		if len(resp.Elements) == 0 {
+			return errorResultf("No element found matching selector %q. Use find_elements to discover available elements.", params.Selector), nil
+		}
+		elementID = resp.Elements[0].ElementId
+		if resp.NextPageToken != "" {
+			note = "\n\nNote: the selector matches more than one element; this is the first."
+		}
+	case params.Path != "":
+		var errResult *ToolResult
+		if elementID, errResult = s.elementIDForPath(ctx, "element_actions", params.Parent, params.Path); errResult != nil {
+			return errResult, nil
+		}
+	}
+
+	elementName := elementID
+	if !strings.Contains(elementID, "/elements/") {
+		elementName = elementResourceName(params.Parent, elementID)
+	}
+	resp, err := s.client.GetElementActions(ctx, &pb.GetElementActionsRequest{Name: elementName})
+	if err != nil {
+		return elementTargetError(err, "element_actions", elementName), nil
+	}
+
+	if len(resp.Actions) == 0 {
+		return textResultf("Element %s supports no accessibility actions. Use click_element or type_element instead.%s", elementName, note), nil
+	}
+	lines := make([]string, 0, len(resp.Actions))
+	for _, action := range resp.Actions {
+		if description, ok := axActionDescriptions[action]; ok {
+			action += ": " + description
+		}
+		lines = append(lines, "- "+action)
+	}
+	return textResultf("Element %s supports %d actions:\n%s\n\nUse perform_element_action to invoke one.%s",
+		elementName, len(resp.Actions), strings.Join(lines, "\n"), note), nil
+}
+
+// handlePerformElementAction handles the perform_element_action tool —
+// invoke an accessibility action, such as AXPress or AXShowMenu, on an element.
+// Targeting and error handling follow click_element.
+func (s *MCPServer) handlePerformElementAction(call *ToolCall) (*ToolResult, error) {
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
+	defer cancel()
+
+	var params struct {
+		elementTargetParams
+		Action string `json:"action"`
+	}
+	if err := json.Unmarshal(call.Arguments, &params); err != nil {
+		return errorResultf("Invalid parameters: %v", err), nil
+	}
+	if errResult := params.validate(); errResult != nil {
+		return errResult, nil
+	}
+	params.Action = strings.TrimSpace(params.Action)
+	if params.Action == "" {
+		return errorResult("action parameter is required"), nil
+	}
+
+	req := &pb.PerformElementActionRequest{Parent: params.Parent, Action: params.Action}
+	var targetDesc string
+	switch {
+	case params.Selector != "":
+		selector, err := parseElementSelector(params.Selector)
+		if err != nil {
+			return errorResultf("Invalid selector: %v", err), nil
+		}
+		req.Target = &pb.PerformElementActionRequest_Selector{Selector: selector}
+		targetDesc = params.Selector
+	case params.Path != "":
+		elementID, errResult := s.elementIDForPath(ctx, "perform_element_action", params.Parent, params.Path)
+		if errResult != nil {
+			return errResult, nil
+		}
+		req.Target = &pb.PerformElementActionRequest_ElementId{ElementId: elementID}
+		targetDesc = params.Path
+	default:
+		req.Target = &pb.PerformElementActionRequest_ElementId{ElementId: params.Element}
+		targetDesc = params.Element
+	}
+
+	resp, err := s.client.PerformElementAction(ctx, req)
+	if err != nil {
+		// The server falls back to clicking when the action fails, so this
+		// is only reported for elements it can't click either.
+		if msg := strings.ToLower(err.Error()); strings.Contains(msg, "ax action failed") && !strings.Contains(msg, "not visible") {
+			return errorResultf("Element %s could not perform %s. Use element_actions to list the actions it supports.", targetDesc, params.Action), nil
+		}
+		return elementTargetError(err, "perform_element_action", targetDesc), nil
+	}
+
+	if !resp.Success {
+		role := "(unknown)"
+		if resp.Element != nil && resp.Element.Role != "" {
+			role = resp.Element.Role
+		}
+		return errorResultf("perform_element_action: %s was not successful. Element role: %s. Use element_actions to list the actions it supports.", params.Action, role), nil
+	}
+
+	elemInfo := targetDesc
+	if resp.Element != nil {
+		elemInfo = fmt.Sprintf("%s (%s)", resp.Element.ElementId, resp.Element.Role)
+	}
+	return textResultf("Performed %s on element: %s", params.Action, elemInfo), nil
+}
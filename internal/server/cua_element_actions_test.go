@@ -0,0 +1,251 @@
+// Copyright 2025 Joseph Cumines
+//
+// Element action tool handler tests
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	typepb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/type"
+	pb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestHandleElementActions(t *testing.T) {
+	var gotName string
+	s := newTestMCPServer(&mockMacosUseClient{
+		getElementActionsFunc: func(_ context.Context, req *pb.GetElementActionsRequest, _ ...grpc.CallOption) (*pb.ElementActions, error) {
+			gotName = req.Name
+			return &pb.ElementActions{Actions: []string{"AXPress", "AXShowMenu", "AXCustomThing"}}, nil
+		},
+	})
+
+	result, err := s.handleElementActions(&ToolCall{Name: "element_actions", Arguments: json.RawMessage(
+		`{"parent":"applications/42/windows/7","element":"elem-1"}`)})
+	if err != nil {
+		t.Fatalf("handleElementActions returned error: %v", err)
+	}
+	if resultIsError(result) {
+		t.Fatalf("unexpected error result: %s", resultText(result))
+	}
+	if gotName != "applications/42/elements/elem-1" {
+		t.Errorf("GetElementActions Name = %q, want applications/42/elements/elem-1", gotName)
+	}
+	for _, want := range []string{
+		"supports 3 actions",
+		"- AXPress: press the element",
+		"- AXShowMenu: show the element's context or pop-up menu",
+		"- AXCustomThing\n",
+		"perform_element_action",
+	} {
+		if !resultContains(result, want) {
+			t.Errorf("result missing %q: %s", want, resultText(result))
+		}
+	}
+}
+
+func TestHandleElementActions_Selector(t *testing.T) {
+	var gotName string
+	s := newTestMCPServer(&mockMacosUseClient{
+		findElementsFunc: func(_ context.Context, req *pb.FindElementsRequest) (*pb.FindElementsResponse, error) {
+			if !proto.Equal(req.Selector, roleSel("AXSlider")) || req.PageSize != 1 {
+				t.Errorf("FindElements Selector = %v, PageSize = %d", req.Selector, req.PageSize)
+			}
+			return &pb.FindElementsResponse{
+				Elements:      []*typepb.Element{{ElementId: "slider-1", Role: "AXSlider"}},
+				NextPageToken: "more",
+			}, nil
+		},
+		getElementActionsFunc: func(_ context.Context, req *pb.GetElementActionsRequest, _ ...grpc.CallOption) (*pb.ElementActions, error) {
+			gotName = req.Name
+			return &pb.ElementActions{Actions: []string{"AXIncrement", "AXDecrement"}}, nil
+		},
+	})
+
+	result, _ := s.handleElementActions(&ToolCall{Name: "element_actions", Arguments: json.RawMessage(
+		`{"parent":"applications/42","selector":"role:AXSlider"}`)})
+	if resultIsError(result) {
+		t.Fatalf("unexpected error result: %s", resultText(result))
+	}
+	if gotName != "applications/42/elements/slider-1" {
+		t.Errorf("GetElementActions Name = %q, want applications/42/elements/slider-1", gotName)
+	}
+	if !resultContains(result, "AXIncrement: increase the value") || !resultContains(result, "matches more than one element") {
+		t.Errorf("unexpected result: %s", resultText(result))
+	}
+
+	s.client.(*mockMacosUseClient).findElementsFunc = func(context.Context, *pb.FindElementsRequest) (*pb.FindElementsResponse, error) {
+		return &pb.FindElementsResponse{}, nil
+	}
+	result, _ = s.handleElementActions(&ToolCall{Name: "element_actions", Arguments: json.RawMessage(
+		`{"parent":"applications/42","selector":"role:AXSlider"}`)})
+	if !resultIsError(result) || !resultContains(result, "No element found matching selector") {
+		t.Errorf("expected no-match error, got: %s", resultText(result))
+	}
+}
+
+func TestHandleElementActions_Path(t *testing.T) {
+	var gotName string
+	s := newPathTestServer(t, &mockMacosUseClient{
+		getElementActionsFunc: func(_ context.Context, req *pb.GetElementActionsRequest, _ ...grpc.CallOption) (*pb.ElementActions, error) {
+			gotName = req.Name
+			return &pb.ElementActions{}, nil
+		},
+	})
+
+	result, _ := s.handleElementActions(&ToolCall{Name: "element_actions", Arguments: json.RawMessage(
+		`{"parent":"applications/42","path":"//AXWindow/AXButton[text:Pay]"}`)})
+	if resultIsError(result) || !resultContains(result, "supports no accessibility actions") {
+		t.Errorf("unexpected result: %s", resultText(result))
+	}
+	if gotName != "applications/42/elements/e-1.2" {
+		t.Errorf("GetElementActions Name = %q, want applications/42/elements/e-1.2", gotName)
+	}
+}
+
+func TestHandleElementActions_Errors(t *testing.T) {
+	s := newTestMCPServer(&mockMacosUseClient{
+		getElementActionsFunc: func(context.Context, *pb.GetElementActionsRequest, ...grpc.CallOption) (*pb.ElementActions, error) {
+			return nil, status.Error(codes.NotFound, "Element not found: elem-1")
+		},
+	})
+
+	tests := []struct {
+		args    string
+		wantErr string
+	}{
+		{`{"element":"elem-1"}`, "parent parameter is required"},
+		{`{"parent":"applications/42"}`, "element, selector or path parameter is required"},
+		{`{"parent":"applications/42","element":"elem-1","path":"//AXButton"}`, "provide only one of element, selector or path"},
+		{`{"parent":"applications/42","selector":"AXButton"}`, "Invalid selector"},
+		{`{"parent":"applications/42","element":"elem-1"}`, "is no longer available"},
+	}
+	for _, tt := range tests {
+		result, err := s.handleElementActions(&ToolCall{Name: "element_actions", Arguments: json.RawMessage(tt.args)})
+		if err != nil {
+			t.Fatalf("%s: handleElementActions returned error: %v", tt.args, err)
+		}
+		if !resultIsError(result) || !resultContains(result, tt.wantErr) {
+			t.Errorf("%s: expected error containing %q, got: %s", tt.args, tt.wantErr, resultText(result))
+		}
+	}
+}
+
+func TestHandlePerformElementAction(t *testing.T) {
+	var got *pb.PerformElementActionRequest
+	s := newPathTestServer(t, &mockMacosUseClient{
+		performElementActionFunc: func(_ context.Context, req *pb.PerformElementActionRequest, _ ...grpc.CallOption) (*pb.PerformElementActionResponse, error) {
+			got = req
+			return &pb.PerformElementActionResponse{Success: true, Element: &typepb.Element{ElementId: "elem-1", Role: "AXPopUpButton"}}, nil
+		},
+	})
+
+	tests := []struct {
+		args string
+		want *pb.PerformElementActionRequest
+	}{
+		{
+			`{"parent":"applications/42","element":"elem-1","action":"AXShowMenu"}`,
+			&pb.PerformElementActionRequest{Parent: "applications/42", Action: "AXShowMenu",
+				Target: &pb.PerformElementActionRequest_ElementId{ElementId: "elem-1"}},
+		},
+		{
+			`{"parent":"applications/42","selector":"role:AXSlider","action":" AXIncrement "}`,
+			&pb.PerformElementActionRequest{Parent: "applications/42", Action: "AXIncrement",
+				Target: &pb.PerformElementActionRequest_Selector{Selector: roleSel("AXSlider")}},
+		},
+		{
+			`{"parent":"applications/42","path":"//AXWindow/AXButton[text:Pay]","action":"AXPress"}`,
+			&pb.PerformElementActionRequest{Parent: "applications/42", Action: "AXPress",
+				Target: &pb.PerformElementActionRequest_ElementId{ElementId: "e-1.2"}},
+		},
+	}
+	for _, tt := range tests {
+		got = nil
+		result, err := s.handlePerformElementAction(&ToolCall{Name: "perform_element_action", Arguments: json.RawMessage(tt.args)})
+		if err != nil {
+			t.Fatalf("%s: handlePerformElementAction returned error: %v", tt.args, err)
+		}
+		if resultIsError(result) {
+			t.Errorf("%s: unexpected error result: %s", tt.args, resultText(result))
+			continue
+		}
+		if !proto.Equal(got, tt.want) {
+			t.Errorf("%s: request = %v, want %v", tt.args, got, tt.want)
+		}
+		if want := "Performed " + tt.want.Action + " on element: elem-1 (AXPopUpButton)"; resultText(result) != want {
+			t.Errorf("%s: result = %q, want %q", tt.args, resultText(result), want)
+		}
+	}
+}
+
+func TestHandlePerformElementAction_Errors(t *testing.T) {
+	var performErr error
+	var performResp *pb.PerformElementActionResponse
+	s := newTestMCPServer(&mockMacosUseClient{
+		performElementActionFunc: func(context.Context, *pb.PerformElementActionRequest, ...grpc.CallOption) (*pb.PerformElementActionResponse, error) {
+			return performResp, performErr
+		},
+	})
+
+	tests := []struct {
+		name    string
+		args    string
+		err     error
+		resp    *pb.PerformElementActionResponse
+		wantErr string
+	}{
+		{name: "missing action", args: `{"parent":"applications/42","element":"elem-1"}`, wantErr: "action parameter is required"},
+		{name: "missing target", args: `{"parent":"applications/42","action":"AXPress"}`, wantErr: "element, selector or path parameter is required"},
+		{name: "two targets", args: `{"parent":"applications/42","element":"elem-1","selector":"role:AXButton","action":"AXPress"}`, wantErr: "provide only one of element, selector or path"},
+		{
+			name:    "unsupported action",
+			args:    `{"parent":"applications/42","element":"elem-1","action":"AXIncrement"}`,
+			err:     status.Error(codes.Internal, "AX action failed: -25206 and no position available for fallback"),
+			wantErr: "Element elem-1 could not perform AXIncrement. Use element_actions",
+		},
+		{
+			name:    "not visible",
+			args:    `{"parent":"applications/42","selector":"role:AXButton","action":"AXPress"}`,
+			err:     status.Error(codes.FailedPrecondition, "AX action failed and element matching selector is not visible; bring it into view"),
+			wantErr: "Element role:AXButton is not visible",
+		},
+		{
+			name:    "no match",
+			args:    `{"parent":"applications/42","selector":"role:AXButton","action":"AXPress"}`,
+			err:     status.Error(codes.NotFound, "No element found matching selector"),
+			wantErr: "No element found matching selector role:AXButton",
+		},
+		{
+			name:    "unsuccessful",
+			args:    `{"parent":"applications/42","element":"elem-1","action":"AXConfirm"}`,
+			resp:    &pb.PerformElementActionResponse{Element: &typepb.Element{Role: "AXSheet"}},
+			wantErr: "AXConfirm was not successful. Element role: AXSheet",
+		},
+		{
+			name:    "other error",
+			args:    `{"parent":"applications/42","element":"elem-1","action":"AXPress"}`,
+			err:     errors.New("connection refused"),
+			wantErr: "perform_element_action",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			performErr, performResp = tt.err, tt.resp
+			result, err := s.handlePerformElementAction(&ToolCall{Name: "perform_element_action", Arguments: json.RawMessage(tt.args)})
+			if err != nil {
+				t.Fatalf("handlePerformElementAction returned error: %v", err)
+			}
+			if !resultIsError(result) || !resultContains(result, tt.wantErr) {
+				t.Errorf("expected error containing %q, got: %s", tt.wantErr, resultText(result))
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright 2025 Joseph Cumines
+//
+// Element wait tool handlers — wait_for_element, wait_for_state
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	pb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/v1"
+	"github.com/joeycumines/MacosUseSDK/internal/server/tools"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultElementWaitTimeout is how long wait_for_element and wait_for_state
+// wait when no timeout is given.
+const defaultElementWaitTimeout = 10 * time.Second
+
+// elementDisappearPollInterval is the interval between FindElements calls
+// while waiting for an element to disappear. It matches the server's default
+// poll interval for WaitElement.
+const elementDisappearPollInterval = 500 * time.Millisecond
+
+// elementWaitTimeout converts a timeout in seconds, defaulting it if unset and
+// capping it to the request timeout.
+func (s *MCPServer) elementWaitTimeout(seconds float64) (time.Duration, *ToolResult) {
+	if math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return 0, errorResult("timeout must be a finite number")
+	}
+	timeout := defaultElementWaitTimeout
+	if seconds > 0 {
+		timeout = time.Duration(seconds * float64(time.Second))
+	}
+	return min(timeout, time.Duration(s.cfg.RequestTimeout)*time.Second), nil
+}
+
+// isElementWaitTimeout reports whether err is a wait that timed out, either
+// reported by the server or because ctx expired first.
+func isElementWaitTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || strings.Contains(err.Error(), "within timeout")
+}
+
+// handleWaitForElement handles the wait_for_element tool — wait for an element
+// matching a selector to appear or disappear.
+func (s *MCPServer) handleWaitForElement(call *ToolCall) (*ToolResult, error) {
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
+	defer cancel()
+
+	var params struct {
+		Parent   string  `json:"parent"`
+		Selector string  `json:"selector"`
+		State    string  `json:"state"`
+		Timeout  float64 `json:"timeout"`
+	}
+	if err := json.Unmarshal(call.Arguments, &params); err != nil {
+		return errorResultf("Invalid parameters: %v", err), nil
+	}
+	if params.Parent == "" {
+		return errorResult("parent parameter is required"), nil
+	}
+	if params.Selector == "" {
+		return errorResult("selector parameter is required"), nil
+	}
+	selector, err := parseElementSelector(params.Selector)
+	if err != nil {
+		return errorResultf("Invalid selector: %v", err), nil
+	}
+	timeout, errResult := s.elementWaitTimeout(params.Timeout)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	start := time.Now()
+	switch params.State {
+	case "", "appear":
+		op, err := s.client.WaitElement(ctx, &pb.WaitElementRequest{
+			Parent:   params.Parent,
+			Selector: selector,
+			Timeout:  timeout.Seconds(),
+		})
+		if err != nil {
+			return grpcErrorResult(err, "wait_for_element"), nil
+		}
+		if op, err = s.awaitOperation(ctx, op); err != nil {
+			if isElementWaitTimeout(err) {
+				return errorResultf("No element matching selector %q appeared within %.1fs. Use find_elements to check the selector.", params.Selector, timeout.Seconds()), nil
+			}
+			return errorResultf("Failed waiting for element: %v", err), nil
+		}
+		var resp pb.WaitElementResponse
+		if result := op.GetResponse(); result != nil {
+			if err := result.UnmarshalTo(&resp); err != nil {
+				return errorResultf("Failed waiting for element: unmarshal failed: %v", err), nil
+			}
+		}
+		return textResultf("Element appeared after %.1fs: %s",
+			time.Since(start).Seconds(), describeElement(resp.GetElement().GetElementId(), resp.GetElement())), nil
+
+	case "disappear":
+		// WaitElement only waits for elements to appear, so disappearance is
+		// polled for here, with the same visibility rule.
+		waitCtx, cancelWait := context.WithTimeout(ctx, timeout)
+		defer cancelWait()
+		stop := trackProgress(waitCtx, timeout, fmt.Sprintf("Waiting for %s to disappear", params.Selector))
+		defer stop()
+
+		gone := func() (bool, error) {
+			resp, err := s.client.FindElements(waitCtx, &pb.FindElementsRequest{
+				Parent:      params.Parent,
+				Selector:    selector,
+				VisibleOnly: true,
+				PageSize:    1,
+			})
+			if err != nil {
+				return false, err
+			}
+			return len(resp.Elements) == 0, nil
+		}
+		done, err := gone()
+		if err == nil && !done {
+			err = tools.PollUntilContext(waitCtx, elementDisappearPollInterval, gone)
+		}
+		if err != nil {
+			if isElementWaitTimeout(err) {
+				return errorResultf("An element matching selector %q was still present after %.1fs.", params.Selector, timeout.Seconds()), nil
+			}
+			return grpcErrorResult(err, "wait_for_element"), nil
+		}
+		return textResultf("No element matches selector %q after %.1fs", params.Selector, time.Since(start).Seconds()), nil
+
+	default:
+		return errorResultf("Invalid state %q: must be appear or disappear", params.State), nil
+	}
+}
+
+// stateCondition returns the condition a wait_for_state state and value
+// describe, and a description of it.
+func stateCondition(state, value string) (*pb.StateCondition, string, *ToolResult) {
+	switch state {
+	case "enabled", "disabled":
+		return &pb.StateCondition{Condition: &pb.StateCondition_Enabled{Enabled: state == "enabled"}}, state, nil
+	case "focused":
+		return &pb.StateCondition{Condition: &pb.StateCondition_Focused{Focused: true}}, state, nil
+	case "value_equals":
+		return &pb.StateCondition{Condition: &pb.StateCondition_TextEquals{TextEquals: value}},
+			fmt.Sprintf("value equals %q", value), nil
+	case "value_contains":
+		if value == "" {
+			return nil, "", errorResult("value parameter is required for value_contains")
+		}
+		return &pb.StateCondition{Condition: &pb.StateCondition_TextContains{TextContains: value}},
+			fmt.Sprintf("value contains %q", value), nil
+	case "":
+		return nil, "", errorResult("state parameter is required")
+	default:
+		return nil, "", errorResultf("Invalid state %q: must be enabled, disabled, focused, value_equals or value_contains", state)
+	}
+}
+
+// handleWaitForState handles the wait_for_state tool — wait for an element to
+// become enabled, disabled or focused, or for its value to match.
+func (s *MCPServer) handleWaitForState(call *ToolCall) (*ToolResult, error) {
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
+	defer cancel()
+
+	var params struct {
+		elementTargetParams
+		State   string  `json:"state"`
+		Value   string  `json:"value"`
+		Timeout float64 `json:"timeout"`
+	}
+	if err := json.Unmarshal(call.Arguments, &params); err != nil {
+		return errorResultf("Invalid parameters: %v", err), nil
+	}
+	if errResult := params.validate(); errResult != nil {
+		return errResult, nil
+	}
+	condition, conditionDesc, errResult := stateCondition(params.State, params.Value)
+	if errResult != nil {
+		return errResult, nil
+	}
+	timeout, errResult := s.elementWaitTimeout(params.Timeout)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	req := &pb.WaitElementStateRequest{
+		Parent:    params.Parent,
+		Condition: condition,
+		Timeout:   timeout.Seconds(),
+	}
+	var targetDesc string
+	switch {
+	case params.Selector != "":
+		selector, err := parseElementSelector(params.Selector)
+		if err != nil {
+			return errorResultf("Invalid selector: %v", err), nil
+		}
+		req.Target = &pb.WaitElementStateRequest_Selector{Selector: selector}
+		targetDesc = params.Selector
+	case params.Path != "":
+		elementID, errResult := s.elementIDForPath(ctx, "wait_for_state", params.Parent, params.Path)
+		if errResult != nil {
+			return errResult, nil
+		}
+		req.Target = &pb.WaitElementStateRequest_ElementId{ElementId: elementID}
+		targetDesc = params.Path
+	default:
+		req.Target = &pb.WaitElementStateRequest_ElementId{ElementId: params.Element}
+		targetDesc = params.Element
+	}
+
+	start := time.Now()
+	op, err := s.client.WaitElementState(ctx, req)
+	if err != nil {
+		if params.Selector != "" && status.Code(err) == codes.NotFound {
+			return errorResultf("No element found matching selector %s. Use find_elements to discover available elements.", targetDesc), nil
+		}
+		return elementTargetError(err, "wait_for_state", targetDesc), nil
+	}
+	if op, err = s.awaitOperation(ctx, op); err != nil {
+		switch {
+		case isElementWaitTimeout(err):
+			return errorResultf("Element %s did not reach %s within %.1fs. Use read_element to check its current state.", targetDesc, conditionDesc, timeout.Seconds()), nil
+		case strings.Contains(err.Error(), "no longer available"):
+			return errorResultf("Element %s disappeared while waiting for %s.", targetDesc, conditionDesc), nil
+		}
+		return errorResultf("Failed waiting for element state: %v", err), nil
+	}
+	var resp pb.WaitElementStateResponse
+	if result := op.GetResponse(); result != nil {
+		if err := result.UnmarshalTo(&resp); err != nil {
+			return errorResultf("Failed waiting for element state: unmarshal failed: %v", err), nil
+		}
+	}
+	element := resp.GetElement()
+	return textResultf("Element reached %s after %.1fs: %s",
+		conditionDesc, time.Since(start).Seconds(), describeElement(element.GetElementId(), element)), nil
+}
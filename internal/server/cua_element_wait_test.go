@@ -0,0 +1,271 @@
+// Copyright 2025 Joseph Cumines
+//
+// Element wait tool handler tests
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	longrunningpb "cloud.google.com/go/longrunning/autogen/longrunningpb"
+	typepb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/type"
+	pb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/v1"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// doneOperation returns a completed operation with the given response.
+func doneOperation(t *testing.T, resp proto.Message) *longrunningpb.Operation {
+	t.Helper()
+	result, err := anypb.New(resp)
+	if err != nil {
+		t.Fatalf("anypb.New: %v", err)
+	}
+	return &longrunningpb.Operation{Name: "operations/wait", Done: true,
+		Result: &longrunningpb.Operation_Response{Response: result}}
+}
+
+// failedOperation returns a completed operation that failed with message.
+func failedOperation(message string) *longrunningpb.Operation {
+	return &longrunningpb.Operation{Name: "operations/wait", Done: true,
+		Result: &longrunningpb.Operation_Error{Error: &status.Status{Code: int32(codes.DeadlineExceeded), Message: message}}}
+}
+
+func TestHandleWaitForElement_Appear(t *testing.T) {
+	var got *pb.WaitElementRequest
+	s := newTestMCPServer(&mockMacosUseClient{
+		waitElementFunc: func(_ context.Context, req *pb.WaitElementRequest, _ ...grpc.CallOption) (*longrunningpb.Operation, error) {
+			got = req
+			return doneOperation(t, &pb.WaitElementResponse{Element: &typepb.Element{
+				ElementId: "elem-7", Role: "AXSheet", Text: proto.String("Export"), X: proto.Float64(10), Y: proto.Float64(20), Width: proto.Float64(300), Height: proto.Float64(200),
+			}}), nil
+		},
+	})
+
+	result, err := s.handleWaitForElement(&ToolCall{Name: "wait_for_element", Arguments: json.RawMessage(
+		`{"parent":"applications/42","selector":"role:AXSheet","timeout":100}`)})
+	if err != nil {
+		t.Fatalf("handleWaitForElement returned error: %v", err)
+	}
+	if resultIsError(result) {
+		t.Fatalf("unexpected error result: %s", resultText(result))
+	}
+	want := &pb.WaitElementRequest{Parent: "applications/42", Selector: roleSel("AXSheet"), Timeout: 30}
+	if !proto.Equal(got, want) {
+		t.Errorf("WaitElement request = %v, want %v (timeout capped at the request timeout)", got, want)
+	}
+	for _, want := range []string{"Element appeared after 0.", "elem-7 - Export (AXSheet) at (10, 20) 300x200"} {
+		if !resultContains(result, want) {
+			t.Errorf("result missing %q: %s", want, resultText(result))
+		}
+	}
+
+	s.client.(*mockMacosUseClient).waitElementFunc = func(_ context.Context, req *pb.WaitElementRequest, _ ...grpc.CallOption) (*longrunningpb.Operation, error) {
+		if req.Timeout != 10 {
+			t.Errorf("WaitElement Timeout = %v, want the default of 10", req.Timeout)
+		}
+		return failedOperation("Element did not appear within timeout"), nil
+	}
+	result, _ = s.handleWaitForElement(&ToolCall{Name: "wait_for_element", Arguments: json.RawMessage(
+		`{"parent":"applications/42","selector":"role:AXSheet"}`)})
+	if !resultIsError(result) || !resultContains(result, `No element matching selector "role:AXSheet" appeared within 10.0s`) {
+		t.Errorf("expected timeout error, got: %s", resultText(result))
+	}
+}
+
+func TestHandleWaitForElement_Disappear(t *testing.T) {
+	var calls int
+	s := newTestMCPServer(&mockMacosUseClient{
+		findElementsFunc: func(_ context.Context, req *pb.FindElementsRequest) (*pb.FindElementsResponse, error) {
+			if !req.VisibleOnly || !proto.Equal(req.Selector, textSel("Loading")) {
+				t.Errorf("FindElements VisibleOnly = %v, Selector = %v", req.VisibleOnly, req.Selector)
+			}
+			calls++
+			if calls < 2 {
+				return &pb.FindElementsResponse{Elements: []*typepb.Element{{ElementId: "spinner"}}}, nil
+			}
+			return &pb.FindElementsResponse{}, nil
+		},
+	})
+
+	result, err := s.handleWaitForElement(&ToolCall{Name: "wait_for_element", Arguments: json.RawMessage(
+		`{"parent":"applications/42","selector":"text:Loading","state":"disappear"}`)})
+	if err != nil {
+		t.Fatalf("handleWaitForElement returned error: %v", err)
+	}
+	if resultIsError(result) || !resultContains(result, `No element matches selector "text:Loading" after 0.5s`) {
+		t.Errorf("unexpected result: %s", resultText(result))
+	}
+	if calls != 2 {
+		t.Errorf("FindElements called %d times, want 2", calls)
+	}
+
+	s.client.(*mockMacosUseClient).findElementsFunc = func(context.Context, *pb.FindElementsRequest) (*pb.FindElementsResponse, error) {
+		return &pb.FindElementsResponse{Elements: []*typepb.Element{{ElementId: "spinner"}}}, nil
+	}
+	result, _ = s.handleWaitForElement(&ToolCall{Name: "wait_for_element", Arguments: json.RawMessage(
+		`{"parent":"applications/42","selector":"text:Loading","state":"disappear","timeout":0.2}`)})
+	if !resultIsError(result) || !resultContains(result, "was still present after 0.2s") {
+		t.Errorf("expected timeout error, got: %s", resultText(result))
+	}
+}
+
+func TestHandleWaitForElement_Errors(t *testing.T) {
+	s := newTestMCPServer(&mockMacosUseClient{})
+
+	tests := []struct {
+		args    string
+		wantErr string
+	}{
+		{`{"selector":"role:AXSheet"}`, "parent parameter is required"},
+		{`{"parent":"applications/42"}`, "selector parameter is required"},
+		{`{"parent":"applications/42","selector":"AXSheet"}`, "Invalid selector"},
+		{`{"parent":"applications/42","selector":"role:AXSheet","state":"vanish"}`, `Invalid state "vanish"`},
+	}
+	for _, tt := range tests {
+		result, err := s.handleWaitForElement(&ToolCall{Name: "wait_for_element", Arguments: json.RawMessage(tt.args)})
+		if err != nil {
+			t.Fatalf("%s: handleWaitForElement returned error: %v", tt.args, err)
+		}
+		if !resultIsError(result) || !resultContains(result, tt.wantErr) {
+			t.Errorf("%s: expected error containing %q, got: %s", tt.args, tt.wantErr, resultText(result))
+		}
+	}
+}
+
+func TestHandleWaitForState(t *testing.T) {
+	var got *pb.WaitElementStateRequest
+	s := newPathTestServer(t, &mockMacosUseClient{
+		waitElementStateFunc: func(_ context.Context, req *pb.WaitElementStateRequest, _ ...grpc.CallOption) (*longrunningpb.Operation, error) {
+			got = req
+			return doneOperation(t, &pb.WaitElementStateResponse{Element: &typepb.Element{
+				ElementId: "elem-1", Role: "AXButton", Text: proto.String("Pay"),
+			}}), nil
+		},
+	})
+
+	tests := []struct {
+		args       string
+		want       *pb.WaitElementStateRequest
+		wantResult string
+	}{
+		{
+			`{"parent":"applications/42","selector":"role:AXButton","state":"enabled"}`,
+			&pb.WaitElementStateRequest{Parent: "applications/42", Timeout: 10,
+				Target:    &pb.WaitElementStateRequest_Selector{Selector: roleSel("AXButton")},
+				Condition: &pb.StateCondition{Condition: &pb.StateCondition_Enabled{Enabled: true}}},
+			"Element reached enabled after 0.",
+		},
+		{
+			`{"parent":"applications/42","element":"elem-1","state":"disabled","timeout":2}`,
+			&pb.WaitElementStateRequest{Parent: "applications/42", Timeout: 2,
+				Target:    &pb.WaitElementStateRequest_ElementId{ElementId: "elem-1"},
+				Condition: &pb.StateCondition{Condition: &pb.StateCondition_Enabled{Enabled: false}}},
+			"Element reached disabled",
+		},
+		{
+			`{"parent":"applications/42","path":"//AXWindow/AXButton[text:Pay]","state":"focused"}`,
+			&pb.WaitElementStateRequest{Parent: "applications/42", Timeout: 10,
+				Target:    &pb.WaitElementStateRequest_ElementId{ElementId: "e-1.2"},
+				Condition: &pb.StateCondition{Condition: &pb.StateCondition_Focused{Focused: true}}},
+			"Element reached focused",
+		},
+		{
+			`{"parent":"applications/42","selector":"role:AXTextField","state":"value_equals","value":""}`,
+			&pb.WaitElementStateRequest{Parent: "applications/42", Timeout: 10,
+				Target:    &pb.WaitElementStateRequest_Selector{Selector: roleSel("AXTextField")},
+				Condition: &pb.StateCondition{Condition: &pb.StateCondition_TextEquals{}}},
+			`Element reached value equals ""`,
+		},
+		{
+			`{"parent":"applications/42","selector":"role:AXStaticText","state":"value_contains","value":"Done"}`,
+			&pb.WaitElementStateRequest{Parent: "applications/42", Timeout: 10,
+				Target:    &pb.WaitElementStateRequest_Selector{Selector: roleSel("AXStaticText")},
+				Condition: &pb.StateCondition{Condition: &pb.StateCondition_TextContains{TextContains: "Done"}}},
+			`Element reached value contains "Done"`,
+		},
+	}
+	for _, tt := range tests {
+		got = nil
+		result, err := s.handleWaitForState(&ToolCall{Name: "wait_for_state", Arguments: json.RawMessage(tt.args)})
+		if err != nil {
+			t.Fatalf("%s: handleWaitForState returned error: %v", tt.args, err)
+		}
+		if resultIsError(result) {
+			t.Errorf("%s: unexpected error result: %s", tt.args, resultText(result))
+			continue
+		}
+		if !proto.Equal(got, tt.want) {
+			t.Errorf("%s: request = %v, want %v", tt.args, got, tt.want)
+		}
+		if !resultContains(result, tt.wantResult) || !resultContains(result, "elem-1 - Pay (AXButton)") {
+			t.Errorf("%s: result = %q, want it to contain %q and the element", tt.args, resultText(result), tt.wantResult)
+		}
+	}
+}
+
+func TestHandleWaitForState_Errors(t *testing.T) {
+	var op *longrunningpb.Operation
+	var opErr error
+	s := newTestMCPServer(&mockMacosUseClient{
+		waitElementStateFunc: func(context.Context, *pb.WaitElementStateRequest, ...grpc.CallOption) (*longrunningpb.Operation, error) {
+			return op, opErr
+		},
+	})
+
+	tests := []struct {
+		name    string
+		args    string
+		op      *longrunningpb.Operation
+		err     error
+		wantErr string
+	}{
+		{name: "missing target", args: `{"parent":"applications/42","state":"enabled"}`, wantErr: "element, selector or path parameter is required"},
+		{name: "missing state", args: `{"parent":"applications/42","element":"elem-1"}`, wantErr: "state parameter is required"},
+		{name: "invalid state", args: `{"parent":"applications/42","element":"elem-1","state":"visible"}`, wantErr: `Invalid state "visible"`},
+		{name: "missing substring", args: `{"parent":"applications/42","element":"elem-1","state":"value_contains"}`, wantErr: "value parameter is required for value_contains"},
+		{name: "infinite timeout", args: `{"parent":"applications/42","element":"elem-1","state":"enabled","timeout":1e999}`, wantErr: "Invalid parameters"},
+		{
+			name:    "no selector match",
+			args:    `{"parent":"applications/42","selector":"role:AXButton","state":"enabled"}`,
+			err:     grpcstatus.Error(codes.NotFound, "Element not found"),
+			wantErr: "No element found matching selector role:AXButton",
+		},
+		{
+			name:    "stale element",
+			args:    `{"parent":"applications/42","element":"elem-1","state":"enabled"}`,
+			err:     grpcstatus.Error(codes.NotFound, "Element not found"),
+			wantErr: "Element elem-1 is no longer available",
+		},
+		{
+			name:    "timeout",
+			args:    `{"parent":"applications/42","element":"elem-1","state":"focused","timeout":3}`,
+			op:      failedOperation("Element did not reach expected state within timeout"),
+			wantErr: "Element elem-1 did not reach focused within 3.0s. Use read_element",
+		},
+		{
+			name:    "disappeared",
+			args:    `{"parent":"applications/42","selector":"role:AXButton","state":"enabled"}`,
+			op:      failedOperation(`RPCError(code: notFound, message: "Element no longer available")`),
+			wantErr: "Element role:AXButton disappeared while waiting for enabled",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, opErr = tt.op, tt.err
+			result, err := s.handleWaitForState(&ToolCall{Name: "wait_for_state", Arguments: json.RawMessage(tt.args)})
+			if err != nil {
+				t.Fatalf("handleWaitForState returned error: %v", err)
+			}
+			if !resultIsError(result) || !resultContains(result, tt.wantErr) {
+				t.Errorf("expected error containing %q, got: %s", tt.wantErr, resultText(result))
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2025 Joseph Cumines
+//
+// Input arbitration tool handler — leases for exclusive control of input
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"time"
+)
+
+// handleInputLease handles the input_lease tool.
+//
+// Actions:
+//   - acquire: wait for input to be free, then hold it for duration seconds
+//   - renew: extend a held lease to expire duration seconds from now
+//   - release: end a held lease
+//   - status: report whether input is leased and how many calls are waiting
+func (s *MCPServer) handleInputLease(call *ToolCall) (*ToolResult, error) {
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
+	defer cancel()
+
+	var params struct {
+		Action   string  `json:"action"`
+		Lease    string  `json:"lease"`
+		Duration float64 `json:"duration"`
+	}
+
+	if err := json.Unmarshal(call.Arguments, &params); err != nil {
+		return errorResultf("Invalid parameters: %v", err), nil
+	}
+
+	if math.IsNaN(params.Duration) || math.IsInf(params.Duration, 0) || params.Duration < 0 {
+		return errorResult("duration must be a non-negative finite number"), nil
+	}
+
+	arbiter := s.inputArbiter()
+	duration := arbiter.leaseDuration(time.Duration(params.Duration * float64(time.Second)))
+
+	switch params.Action {
+	case "acquire":
+		id, expires, err := arbiter.acquireLease(ctx, duration)
+		if err != nil {
+			return errorResultf("Failed to acquire input lease: %v", err), nil
+		}
+		return textResultf("Acquired input lease %s until %s (%s). Pass \"lease\": %q to input tools; other input calls wait until the lease is released or expires.",
+			id, expires.Format(time.RFC3339), duration, id), nil
+
+	case "renew":
+		if params.Lease == "" {
+			return errorResult("lease parameter is required for renew"), nil
+		}
+		expires, err := arbiter.renewLease(params.Lease, duration)
+		if err != nil {
+			return errorResultf("Failed to renew input lease: %v", err), nil
+		}
+		return textResultf("Renewed input lease %s until %s", params.Lease, expires.Format(time.RFC3339)), nil
+
+	case "release":
+		if params.Lease == "" {
+			return errorResult("lease parameter is required for release"), nil
+		}
+		if err := arbiter.releaseLease(params.Lease); err != nil {
+			return errorResultf("Failed to release input lease: %v", err), nil
+		}
+		return textResultf("Released input lease %s", params.Lease), nil
+
+	case "status":
+		data, err := json.MarshalIndent(arbiter.status(), "", "  ")
+		if err != nil {
+			return errorResultf("Failed to format status: %v", err), nil
+		}
+		return textResult(string(data)), nil
+
+	case "":
+		return errorResult("action parameter is required (acquire, renew, release, status)"), nil
+
+	default:
+		return errorResultf("Invalid action: %s (must be acquire, renew, release, or status)", params.Action), nil
+	}
+}
@@ -0,0 +1,666 @@
+// Copyright 2025 Joseph Cumines
+//
+// Macro tool handlers — create, list, get, update, delete and execute macros
+// defined as protojson MacroAction sequences
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	pb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// macroOutputOnlyFields are Macro fields that are set by the server and
+// ignored in macro definitions supplied by clients.
+var macroOutputOnlyFields = []string{"name", "create_time", "update_time", "execution_count"}
+
+// handleMacroCreate handles the macro_create tool.
+func (s *MCPServer) handleMacroCreate(call *ToolCall) (*ToolResult, error) {
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
+	defer cancel()
+
+	var params struct {
+		Macro   json.RawMessage `json:"macro"`
+		MacroID string          `json:"macro_id"`
+	}
+
+	if err := json.Unmarshal(call.Arguments, &params); err != nil {
+		return errorResultf("Invalid parameters: %v", err), nil
+	}
+
+	if len(params.Macro) == 0 {
+		return errorResult("macro parameter is required"), nil
+	}
+
+	macro, err := parseMacroDefinition(params.Macro)
+	if err != nil {
+		return errorResultf("Invalid macro definition: %v", err), nil
+	}
+	clearMacroOutputOnlyFields(macro)
+	if err := validateMacro(macro); err != nil {
+		return errorResultf("Invalid macro definition: %v", err), nil
+	}
+
+	created, err := s.client.CreateMacro(ctx, &pb.CreateMacroRequest{
+		Macro:   macro,
+		MacroId: params.MacroID,
+	})
+	if err != nil {
+		return grpcErrorResult(err, "macro_create"), nil
+	}
+
+	return textResult("Macro created\n" + formatMacroSummary(created)), nil
+}
+
+// handleMacroList handles the macro_list tool.
+func (s *MCPServer) handleMacroList(call *ToolCall) (*ToolResult, error) {
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
+	defer cancel()
+
+	var params struct {
+		PageToken string `json:"page_token"`
+		PageSize  int32  `json:"page_size"`
+	}
+
+	if err := json.Unmarshal(call.Arguments, &params); err != nil {
+		return errorResultf("Invalid parameters: %v", err), nil
+	}
+
+	if params.PageSize < 0 {
+		return errorResult("page_size must be non-negative"), nil
+	}
+
+	resp, err := s.client.ListMacros(ctx, &pb.ListMacrosRequest{
+		PageSize:  params.PageSize,
+		PageToken: params.PageToken,
+	})
+	if err != nil {
+		return grpcErrorResult(err, "macro_list"), nil
+	}
+
+	if len(resp.Macros) == 0 {
+		return textResult("No macros found"), nil
+	}
+
+	var lines []string
+	for i, m := range resp.Macros {
+		line := fmt.Sprintf("%d. %s - %s (%d actions, %d parameters, executed %d times)",
+			i+1, m.Name, m.DisplayName, len(m.Actions), len(m.Parameters), m.ExecutionCount)
+		if len(m.Tags) > 0 {
+			line += " [" + strings.Join(m.Tags, ", ") + "]"
+		}
+		lines = append(lines, line)
+	}
+
+	result := fmt.Sprintf("Found %d macros:\n%s", len(resp.Macros), strings.Join(lines, "\n"))
+	if resp.NextPageToken != "" {
+		result += fmt.Sprintf("\n\nMore results available. Use page_token: %s", resp.NextPageToken)
+	}
+	return textResult(result), nil
+}
+
+// handleMacroGet handles the macro_get tool.
+func (s *MCPServer) handleMacroGet(call *ToolCall) (*ToolResult, error) {
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
+	defer cancel()
+
+	var params struct {
+		Name string `json:"name"`
+	}
+
+	if err := json.Unmarshal(call.Arguments, &params); err != nil {
+		return errorResultf("Invalid parameters: %v", err), nil
+	}
+
+	if params.Name == "" {
+		return errorResult("name parameter is required"), nil
+	}
+
+	macro, err := s.client.GetMacro(ctx, &pb.GetMacroRequest{Name: params.Name})
+	if err != nil {
+		return grpcErrorResult(err, "macro_get"), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(formatMacroSummary(macro))
+
+	if len(macro.Actions) > 0 {
+		sb.WriteString("\nActions:")
+		writeMacroActions(&sb, macro.Actions, "  ")
+	}
+
+	definition, err := protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(macro)
+	if err == nil {
+		sb.WriteString("\n\nDefinition (JSON):\n")
+		sb.Write(definition)
+	}
+
+	return textResult(sb.String()), nil
+}
+
+// handleMacroUpdate handles the macro_update tool.
+// Without an explicit update_mask, the fields present in the macro JSON are updated.
+func (s *MCPServer) handleMacroUpdate(call *ToolCall) (*ToolResult, error) {
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
+	defer cancel()
+
+	var params struct {
+		Name       string          `json:"name"`
+		Macro      json.RawMessage `json:"macro"`
+		UpdateMask []string        `json:"update_mask"`
+	}
+
+	if err := json.Unmarshal(call.Arguments, &params); err != nil {
+		return errorResultf("Invalid parameters: %v", err), nil
+	}
+
+	if params.Name == "" {
+		return errorResult("name parameter is required"), nil
+	}
+	if len(params.Macro) == 0 {
+		return errorResult("macro parameter is required"), nil
+	}
+
+	macro, err := parseMacroDefinition(params.Macro)
+	if err != nil {
+		return errorResultf("Invalid macro definition: %v", err), nil
+	}
+	clearMacroOutputOnlyFields(macro)
+
+	paths := params.UpdateMask
+	if len(paths) == 0 {
+		macro.ProtoReflect().Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+			paths = append(paths, string(fd.Name()))
+			return true
+		})
+		if len(paths) == 0 {
+			return errorResult("macro contains no fields to update; set fields or provide update_mask"), nil
+		}
+	}
+
+	mask, err := fieldmaskpb.New(&pb.Macro{}, paths...)
+	if err != nil {
+		return errorResultf("Invalid update_mask: %v", err), nil
+	}
+	mask.Normalize()
+
+	for _, path := range mask.Paths {
+		switch path {
+		case "display_name":
+			if macro.DisplayName == "" {
+				return errorResult("Invalid macro definition: display_name cannot be cleared"), nil
+			}
+		case "actions":
+			if len(macro.Actions) == 0 {
+				return errorResult("Invalid macro definition: actions must contain at least one action"), nil
+			}
+			if err := validateMacroActions(macro.Actions, "actions"); err != nil {
+				return errorResultf("Invalid macro definition: %v", err), nil
+			}
+		case "parameters":
+			if err := validateMacroParameters(macro.Parameters); err != nil {
+				return errorResultf("Invalid macro definition: %v", err), nil
+			}
+		default:
+			for _, f := range macroOutputOnlyFields {
+				if path == f || strings.HasPrefix(path, f+".") {
+					return errorResultf("Invalid update_mask: %s is output only", path), nil
+				}
+			}
+		}
+	}
+
+	macro.Name = params.Name
+	updated, err := s.client.UpdateMacro(ctx, &pb.UpdateMacroRequest{
+		Macro:      macro,
+		UpdateMask: mask,
+	})
+	if err != nil {
+		return grpcErrorResult(err, "macro_update"), nil
+	}
+
+	return textResultf("Macro updated (fields: %s)\n%s", strings.Join(mask.Paths, ", "), formatMacroSummary(updated)), nil
+}
+
+// handleMacroDelete handles the macro_delete tool.
+func (s *MCPServer) handleMacroDelete(call *ToolCall) (*ToolResult, error) {
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
+	defer cancel()
+
+	var params struct {
+		Name  string `json:"name"`
+		Force bool   `json:"force"`
+	}
+
+	if err := json.Unmarshal(call.Arguments, &params); err != nil {
+		return errorResultf("Invalid parameters: %v", err), nil
+	}
+
+	if params.Name == "" {
+		return errorResult("name parameter is required"), nil
+	}
+
+	if _, err := s.client.DeleteMacro(ctx, &pb.DeleteMacroRequest{Name: params.Name, Force: params.Force}); err != nil {
+		return grpcErrorResult(err, "macro_delete"), nil
+	}
+
+	return textResultf("Macro deleted: %s", params.Name), nil
+}
+
+// handleMacroExecute handles the macro_execute tool. It starts ExecuteMacro,
+// polls the long-running operation, and reports the execution log.
+func (s *MCPServer) handleMacroExecute(call *ToolCall) (*ToolResult, error) {
+	var params struct {
+		ParameterValues map[string]string `json:"parameter_values"`
+		Name            string            `json:"name"`
+		Application     string            `json:"application"`
+		Speed           float64           `json:"speed"`
+		Timeout         float64           `json:"timeout"`
+		ContinueOnError bool              `json:"continue_on_error"`
+	}
+
+	if err := json.Unmarshal(call.Arguments, &params); err != nil {
+		return errorResultf("Invalid parameters: %v", err), nil
+	}
+
+	if params.Name == "" {
+		return errorResult("name parameter is required"), nil
+	}
+	if params.Speed < 0 {
+		return errorResult("speed must be non-negative"), nil
+	}
+	if params.Timeout < 0 {
+		return errorResult("timeout must be non-negative"), nil
+	}
+
+	// Use the shorter of the macro timeout and the request timeout, as for scripts.
+	effectiveTimeout := time.Duration(s.cfg.RequestTimeout) * time.Second
+	if macroTimeout := time.Duration(params.Timeout * float64(time.Second)); macroTimeout > 0 && macroTimeout < effectiveTimeout {
+		effectiveTimeout = macroTimeout
+	}
+	ctx, cancel := context.WithTimeout(call.Context(), effectiveTimeout)
+	defer cancel()
+
+	op, err := s.client.ExecuteMacro(ctx, &pb.ExecuteMacroRequest{
+		Macro:           params.Name,
+		ParameterValues: params.ParameterValues,
+		Application:     params.Application,
+		Options: &pb.ExecutionOptions{
+			Speed:           params.Speed,
+			ContinueOnError: params.ContinueOnError,
+			Timeout:         params.Timeout,
+			RecordExecution: true,
+		},
+	})
+	if err != nil {
+		return grpcErrorResult(err, "macro_execute"), nil
+	}
+
+	opName := op.GetName()
+	op, err = s.awaitOperation(ctx, op)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return errorResultf("Macro execution did not complete within %v (operation %s may still be running)", effectiveTimeout, opName), nil
+		}
+		return errorResultf("Macro execution failed: %v", err), nil
+	}
+
+	var resp pb.ExecuteMacroResponse
+	if result := op.GetResponse(); result != nil {
+		if err := result.UnmarshalTo(&resp); err != nil {
+			return errorResultf("Macro execution failed: unmarshal failed: %v", err), nil
+		}
+	}
+
+	return formatMacroExecution(params.Name, &resp), nil
+}
+
+// parseMacroDefinition decodes a protojson macro definition. Unknown fields
+// and type mismatches are reported using protojson's error messages.
+func parseMacroDefinition(raw json.RawMessage) (*pb.Macro, error) {
+	var macro pb.Macro
+	if err := protojson.Unmarshal(raw, &macro); err != nil {
+		return nil, err
+	}
+	return &macro, nil
+}
+
+// clearMacroOutputOnlyFields resets fields that are owned by the server.
+func clearMacroOutputOnlyFields(macro *pb.Macro) {
+	macro.Name = ""
+	macro.CreateTime = nil
+	macro.UpdateTime = nil
+	macro.ExecutionCount = 0
+}
+
+// validateMacro checks the required fields of a complete macro definition.
+func validateMacro(macro *pb.Macro) error {
+	if macro.DisplayName == "" {
+		return errors.New("display_name is required")
+	}
+	if len(macro.Actions) == 0 {
+		return errors.New("actions must contain at least one action")
+	}
+	if err := validateMacroActions(macro.Actions, "actions"); err != nil {
+		return err
+	}
+	return validateMacroParameters(macro.Parameters)
+}
+
+// validateMacroActions recursively checks that each action sets its oneof and
+// the fields the proto marks as required. path is used in error messages.
+func validateMacroActions(actions []*pb.MacroAction, path string) error {
+	for i, action := range actions {
+		p := fmt.Sprintf("%s[%d]", path, i)
+		switch a := action.GetAction().(type) {
+		case nil:
+			return fmt.Errorf("%s: one of input, wait, conditional, loop, assign, method_call is required", p)
+		case *pb.MacroAction_Input:
+			if a.Input.GetInputType() == nil {
+				return fmt.Errorf("%s.input: an input type is required (e.g. click, type_text, press_key)", p)
+			}
+		case *pb.MacroAction_Wait:
+			if a.Wait.GetDuration() < 0 {
+				return fmt.Errorf("%s.wait.duration must be non-negative", p)
+			}
+		case *pb.MacroAction_Conditional:
+			if err := validateMacroCondition(a.Conditional.GetCondition(), p+".conditional.condition"); err != nil {
+				return err
+			}
+			if len(a.Conditional.GetThenActions()) == 0 {
+				return fmt.Errorf("%s.conditional.then_actions must contain at least one action", p)
+			}
+			if err := validateMacroActions(a.Conditional.GetThenActions(), p+".conditional.then_actions"); err != nil {
+				return err
+			}
+			if err := validateMacroActions(a.Conditional.GetElseActions(), p+".conditional.else_actions"); err != nil {
+				return err
+			}
+		case *pb.MacroAction_Loop:
+			switch lt := a.Loop.GetLoopType().(type) {
+			case nil:
+				return fmt.Errorf("%s.loop: one of count, while_condition, foreach is required", p)
+			case *pb.LoopAction_Count:
+				if lt.Count <= 0 {
+					return fmt.Errorf("%s.loop.count must be positive", p)
+				}
+			case *pb.LoopAction_WhileCondition:
+				if err := validateMacroCondition(lt.WhileCondition, p+".loop.while_condition"); err != nil {
+					return err
+				}
+			case *pb.LoopAction_Foreach:
+				if lt.Foreach.GetCollection() == nil {
+					return fmt.Errorf("%s.loop.foreach: one of element_selector, window_pattern, values is required", p)
+				}
+				if lt.Foreach.GetItemVariable() == "" {
+					return fmt.Errorf("%s.loop.foreach.item_variable is required", p)
+				}
+			}
+			if len(a.Loop.GetActions()) == 0 {
+				return fmt.Errorf("%s.loop.actions must contain at least one action", p)
+			}
+			if err := validateMacroActions(a.Loop.GetActions(), p+".loop.actions"); err != nil {
+				return err
+			}
+		case *pb.MacroAction_Assign:
+			if a.Assign.GetVariable() == "" {
+				return fmt.Errorf("%s.assign.variable is required", p)
+			}
+			if a.Assign.GetValue() == nil {
+				return fmt.Errorf("%s.assign: one of literal, element_attribute, parameter, expression is required", p)
+			}
+		case *pb.MacroAction_MethodCall:
+			if a.MethodCall.GetMethod() == "" {
+				return fmt.Errorf("%s.method_call.method is required", p)
+			}
+		}
+	}
+	return nil
+}
+
+// validateMacroCondition checks that a condition (and any nested compound
+// conditions) sets its oneof.
+func validateMacroCondition(cond *pb.MacroCondition, path string) error {
+	switch c := cond.GetCondition().(type) {
+	case nil:
+		return fmt.Errorf("%s: one of element_exists, window_exists, application_running, variable_equals, compound is required", path)
+	case *pb.MacroCondition_VariableEquals:
+		if c.VariableEquals.GetVariable() == "" {
+			return fmt.Errorf("%s.variable_equals.variable is required", path)
+		}
+	case *pb.MacroCondition_Compound:
+		if c.Compound.GetOperator() == pb.CompoundCondition_OPERATOR_UNSPECIFIED {
+			return fmt.Errorf("%s.compound.operator is required (OPERATOR_AND, OPERATOR_OR, OPERATOR_NOT)", path)
+		}
+		if len(c.Compound.GetConditions()) == 0 {
+			return fmt.Errorf("%s.compound.conditions must contain at least one condition", path)
+		}
+		for i, nested := range c.Compound.GetConditions() {
+			if err := validateMacroCondition(nested, fmt.Sprintf("%s.compound.conditions[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateMacroParameters checks parameter keys are set and unique, and types are specified.
+func validateMacroParameters(parameters []*pb.MacroParameter) error {
+	seen := make(map[string]bool, len(parameters))
+	for i, param := range parameters {
+		p := fmt.Sprintf("parameters[%d]", i)
+		if param.GetKey() == "" {
+			return fmt.Errorf("%s.key is required", p)
+		}
+		if seen[param.GetKey()] {
+			return fmt.Errorf("%s.key %q is duplicated", p, param.GetKey())
+		}
+		seen[param.GetKey()] = true
+		if param.GetType() == pb.MacroParameter_PARAMETER_TYPE_UNSPECIFIED {
+			return fmt.Errorf("%s.type is required", p)
+		}
+	}
+	return nil
+}
+
+// formatMacroSummary renders the macro header: name, description, tags and parameters.
+func formatMacroSummary(macro *pb.Macro) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Macro: %s\n  Display name: %s\n  Actions: %d", macro.Name, macro.DisplayName, len(macro.Actions))
+	if macro.Description != "" {
+		fmt.Fprintf(&sb, "\n  Description: %s", macro.Description)
+	}
+	if len(macro.Tags) > 0 {
+		fmt.Fprintf(&sb, "\n  Tags: %s", strings.Join(macro.Tags, ", "))
+	}
+	if macro.ExecutionCount > 0 {
+		fmt.Fprintf(&sb, "\n  Executions: %d", macro.ExecutionCount)
+	}
+	if len(macro.Parameters) > 0 {
+		sb.WriteString("\n  Parameters:")
+		for _, param := range macro.Parameters {
+			kind := strings.ToLower(strings.TrimPrefix(param.Type.String(), "PARAMETER_TYPE_"))
+			fmt.Fprintf(&sb, "\n    - %s (%s", param.Key, kind)
+			if param.Required {
+				sb.WriteString(", required")
+			}
+			if param.DefaultValue != "" {
+				fmt.Fprintf(&sb, ", default %q", truncateText(param.DefaultValue))
+			}
+			sb.WriteString(")")
+			if param.Description != "" {
+				sb.WriteString(": " + param.Description)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// writeMacroActions renders an indented outline of actions, recursing into
+// conditionals and loops.
+func writeMacroActions(sb *strings.Builder, actions []*pb.MacroAction, indent string) {
+	for i, action := range actions {
+		fmt.Fprintf(sb, "\n%s%d. %s", indent, i+1, describeMacroAction(action))
+		if action.Description != "" {
+			fmt.Fprintf(sb, " — %s", action.Description)
+		}
+		switch a := action.GetAction().(type) {
+		case *pb.MacroAction_Conditional:
+			fmt.Fprintf(sb, "\n%s   then:", indent)
+			writeMacroActions(sb, a.Conditional.GetThenActions(), indent+"     ")
+			if len(a.Conditional.GetElseActions()) > 0 {
+				fmt.Fprintf(sb, "\n%s   else:", indent)
+				writeMacroActions(sb, a.Conditional.GetElseActions(), indent+"     ")
+			}
+		case *pb.MacroAction_Loop:
+			writeMacroActions(sb, a.Loop.GetActions(), indent+"   ")
+		}
+	}
+}
+
+// describeMacroAction renders a one-line description of a macro action.
+func describeMacroAction(action *pb.MacroAction) string {
+	switch a := action.GetAction().(type) {
+	case *pb.MacroAction_Input:
+		return describeInputAction(a.Input)
+	case *pb.MacroAction_Wait:
+		desc := fmt.Sprintf("wait %.2fs", a.Wait.GetDuration())
+		switch c := a.Wait.GetCondition().GetCondition().(type) {
+		case *pb.WaitCondition_ElementSelector:
+			desc += fmt.Sprintf(" for element %q", c.ElementSelector)
+		case *pb.WaitCondition_WindowTitle:
+			desc += fmt.Sprintf(" for window %q", c.WindowTitle)
+		case *pb.WaitCondition_Application:
+			desc += fmt.Sprintf(" for application %s", c.Application)
+		}
+		return desc
+	case *pb.MacroAction_Conditional:
+		return "if " + describeMacroCondition(a.Conditional.GetCondition())
+	case *pb.MacroAction_Loop:
+		switch lt := a.Loop.GetLoopType().(type) {
+		case *pb.LoopAction_Count:
+			return fmt.Sprintf("repeat %d times", lt.Count)
+		case *pb.LoopAction_WhileCondition:
+			return "while " + describeMacroCondition(lt.WhileCondition)
+		case *pb.LoopAction_Foreach:
+			return fmt.Sprintf("for each ${%s}", lt.Foreach.GetItemVariable())
+		}
+		return "loop"
+	case *pb.MacroAction_Assign:
+		var value string
+		switch v := a.Assign.GetValue().(type) {
+		case *pb.AssignAction_Literal:
+			value = fmt.Sprintf("%q", truncateText(v.Literal))
+		case *pb.AssignAction_Parameter:
+			value = "parameter " + v.Parameter
+		case *pb.AssignAction_Expression:
+			value = "expression " + truncateText(v.Expression)
+		case *pb.AssignAction_ElementAttribute:
+			value = fmt.Sprintf("%s of %q", v.ElementAttribute.GetAttribute(), v.ElementAttribute.GetElementSelector())
+		}
+		return fmt.Sprintf("set ${%s} = %s", a.Assign.GetVariable(), value)
+	case *pb.MacroAction_MethodCall:
+		return "call " + a.MethodCall.GetMethod()
+	default:
+		return "(empty action)"
+	}
+}
+
+// describeInputAction renders a one-line description of an input action.
+func describeInputAction(input *pb.InputAction) string {
+	switch it := input.GetInputType().(type) {
+	case *pb.InputAction_Click:
+		return fmt.Sprintf("click at (%.0f, %.0f)", it.Click.GetPosition().GetX(), it.Click.GetPosition().GetY())
+	case *pb.InputAction_TypeText:
+		return fmt.Sprintf("type %q", truncateText(it.TypeText.GetText()))
+	case *pb.InputAction_PressKey:
+		return "press " + it.PressKey.GetKey()
+	case *pb.InputAction_MoveMouse:
+		return fmt.Sprintf("move to (%.0f, %.0f)", it.MoveMouse.GetPosition().GetX(), it.MoveMouse.GetPosition().GetY())
+	case *pb.InputAction_Drag:
+		return fmt.Sprintf("drag (%.0f, %.0f) to (%.0f, %.0f)",
+			it.Drag.GetStartPosition().GetX(), it.Drag.GetStartPosition().GetY(),
+			it.Drag.GetEndPosition().GetX(), it.Drag.GetEndPosition().GetY())
+	case *pb.InputAction_Scroll:
+		return fmt.Sprintf("scroll (%.0f, %.0f)", it.Scroll.GetHorizontal(), it.Scroll.GetVertical())
+	default:
+		return "input"
+	}
+}
+
+// describeMacroCondition renders a one-line description of a macro condition.
+func describeMacroCondition(cond *pb.MacroCondition) string {
+	switch c := cond.GetCondition().(type) {
+	case *pb.MacroCondition_ElementExists:
+		return fmt.Sprintf("element %q exists", c.ElementExists)
+	case *pb.MacroCondition_WindowExists:
+		return fmt.Sprintf("window %q exists", c.WindowExists)
+	case *pb.MacroCondition_ApplicationRunning:
+		return fmt.Sprintf("application %s running", c.ApplicationRunning)
+	case *pb.MacroCondition_VariableEquals:
+		return fmt.Sprintf("${%s} == %q", c.VariableEquals.GetVariable(), c.VariableEquals.GetValue())
+	case *pb.MacroCondition_Compound:
+		op := strings.TrimPrefix(c.Compound.GetOperator().String(), "OPERATOR_")
+		parts := make([]string, 0, len(c.Compound.GetConditions()))
+		for _, nested := range c.Compound.GetConditions() {
+			parts = append(parts, describeMacroCondition(nested))
+		}
+		if op == "NOT" {
+			return "not (" + strings.Join(parts, ", ") + ")"
+		}
+		return "(" + strings.Join(parts, " "+strings.ToLower(op)+" ") + ")"
+	default:
+		return "(no condition)"
+	}
+}
+
+// formatMacroExecution renders an ExecuteMacroResponse and its execution log.
+// A failed execution is reported as an error result so agents can react to it.
+func formatMacroExecution(name string, resp *pb.ExecuteMacroResponse) *ToolResult {
+	var sb strings.Builder
+	status := "succeeded"
+	if !resp.Success {
+		status = "failed"
+	}
+	fmt.Fprintf(&sb, "Macro execution %s: %s\n  Actions executed: %d", status, name, resp.ActionsExecuted)
+	if d := resp.ExecutionDuration; d != nil {
+		fmt.Fprintf(&sb, "\n  Duration: %v", d.AsDuration().Round(time.Millisecond))
+	}
+	if resp.Error != "" {
+		fmt.Fprintf(&sb, "\n  Error: %s", resp.Error)
+	}
+
+	if len(resp.Log) > 0 {
+		failed := 0
+		for _, entry := range resp.Log {
+			if !entry.Success {
+				failed++
+			}
+		}
+		fmt.Fprintf(&sb, "\nExecution log (%d entries, %d failed):", len(resp.Log), failed)
+		for _, entry := range resp.Log {
+			entryStatus := "OK"
+			if !entry.Success {
+				entryStatus = "FAILED"
+			}
+			fmt.Fprintf(&sb, "\n  [%s] action %d: %s (%.3fs)", entryStatus, entry.ActionIndex, entry.Description, entry.Duration)
+			if entry.Error != "" {
+				fmt.Fprintf(&sb, " — %s", entry.Error)
+			}
+		}
+	}
+
+	if !resp.Success {
+		return errorResult(sb.String())
+	}
+	return textResult(sb.String())
+}
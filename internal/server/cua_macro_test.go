@@ -0,0 +1,299 @@
+// Copyright 2025 Joseph Cumines
+//
+// Tests for the macro tool handlers and macro definition validation.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	longrunningpb "cloud.google.com/go/longrunning/autogen/longrunningpb"
+	pb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/v1"
+	statuspb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// mockMacroClient implements only the gRPC methods used by the macro tools.
+type mockMacroClient struct {
+	pb.MacosUseClient
+
+	createMacroFunc  func(ctx context.Context, req *pb.CreateMacroRequest) (*pb.Macro, error)
+	getMacroFunc     func(ctx context.Context, req *pb.GetMacroRequest) (*pb.Macro, error)
+	listMacrosFunc   func(ctx context.Context, req *pb.ListMacrosRequest) (*pb.ListMacrosResponse, error)
+	updateMacroFunc  func(ctx context.Context, req *pb.UpdateMacroRequest) (*pb.Macro, error)
+	deleteMacroFunc  func(ctx context.Context, req *pb.DeleteMacroRequest) (*emptypb.Empty, error)
+	executeMacroFunc func(ctx context.Context, req *pb.ExecuteMacroRequest) (*longrunningpb.Operation, error)
+}
+
+func (m *mockMacroClient) CreateMacro(ctx context.Context, req *pb.CreateMacroRequest, opts ...grpc.CallOption) (*pb.Macro, error) {
+	return m.createMacroFunc(ctx, req)
+}
+
+func (m *mockMacroClient) GetMacro(ctx context.Context, req *pb.GetMacroRequest, opts ...grpc.CallOption) (*pb.Macro, error) {
+	return m.getMacroFunc(ctx, req)
+}
+
+func (m *mockMacroClient) ListMacros(ctx context.Context, req *pb.ListMacrosRequest, opts ...grpc.CallOption) (*pb.ListMacrosResponse, error) {
+	return m.listMacrosFunc(ctx, req)
+}
+
+func (m *mockMacroClient) UpdateMacro(ctx context.Context, req *pb.UpdateMacroRequest, opts ...grpc.CallOption) (*pb.Macro, error) {
+	return m.updateMacroFunc(ctx, req)
+}
+
+func (m *mockMacroClient) DeleteMacro(ctx context.Context, req *pb.DeleteMacroRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	return m.deleteMacroFunc(ctx, req)
+}
+
+func (m *mockMacroClient) ExecuteMacro(ctx context.Context, req *pb.ExecuteMacroRequest, opts ...grpc.CallOption) (*longrunningpb.Operation, error) {
+	return m.executeMacroFunc(ctx, req)
+}
+
+func TestMacroHandlers_InvalidParams(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name       string
+		handler    func(*ToolCall) (*ToolResult, error)
+		args       string
+		wantSubstr string
+	}{
+		{"create missing macro", s.handleMacroCreate, `{}`, "macro parameter is required"},
+		{"create unknown field", s.handleMacroCreate, `{"macro":{"display_name":"x","bogus":1}}`, "Invalid macro definition"},
+		{"create missing display name", s.handleMacroCreate, `{"macro":{"actions":[{"wait":{"duration":1}}]}}`, "display_name is required"},
+		{"create no actions", s.handleMacroCreate, `{"macro":{"display_name":"x"}}`, "actions must contain at least one action"},
+		{"create empty action", s.handleMacroCreate, `{"macro":{"display_name":"x","actions":[{}]}}`, "actions[0]: one of input"},
+		{"create empty input", s.handleMacroCreate, `{"macro":{"display_name":"x","actions":[{"input":{}}]}}`, "actions[0].input: an input type is required"},
+		{"create nested loop without type", s.handleMacroCreate, `{"macro":{"display_name":"x","actions":[{"conditional":{"condition":{"element_exists":"role:AXButton"},"then_actions":[{"loop":{"actions":[{"wait":{"duration":1}}]}}]}}]}}`, "actions[0].conditional.then_actions[0].loop: one of count"},
+		{"create duplicate parameter", s.handleMacroCreate, `{"macro":{"display_name":"x","actions":[{"wait":{"duration":1}}],"parameters":[{"key":"a","type":"PARAMETER_TYPE_STRING"},{"key":"a","type":"PARAMETER_TYPE_STRING"}]}}`, `parameters[1].key "a" is duplicated`},
+		{"list negative page size", s.handleMacroList, `{"page_size":-1}`, "page_size must be non-negative"},
+		{"get missing name", s.handleMacroGet, `{}`, "name parameter is required"},
+		{"update missing name", s.handleMacroUpdate, `{"macro":{"description":"d"}}`, "name parameter is required"},
+		{"update missing macro", s.handleMacroUpdate, `{"name":"macros/a"}`, "macro parameter is required"},
+		{"update empty macro", s.handleMacroUpdate, `{"name":"macros/a","macro":{}}`, "no fields to update"},
+		{"update invalid mask", s.handleMacroUpdate, `{"name":"macros/a","macro":{"description":"d"},"update_mask":["nope"]}`, "Invalid update_mask"},
+		{"update output only mask", s.handleMacroUpdate, `{"name":"macros/a","macro":{"description":"d"},"update_mask":["execution_count"]}`, "execution_count is output only"},
+		{"update clears display name", s.handleMacroUpdate, `{"name":"macros/a","macro":{},"update_mask":["display_name"]}`, "display_name cannot be cleared"},
+		{"delete missing name", s.handleMacroDelete, `{}`, "name parameter is required"},
+		{"execute missing name", s.handleMacroExecute, `{}`, "name parameter is required"},
+		{"execute negative speed", s.handleMacroExecute, `{"name":"macros/a","speed":-1}`, "speed must be non-negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.handler(&ToolCall{Arguments: json.RawMessage(tt.args)})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !resultIsError(result) {
+				t.Errorf("expected error result, got: %+v", result)
+			}
+			if !resultContains(result, tt.wantSubstr) {
+				t.Errorf("expected result to contain %q, got: %q", tt.wantSubstr, resultText(result))
+			}
+		})
+	}
+}
+
+func TestHandleMacroCreate_ParsesProtoJSON(t *testing.T) {
+	var got *pb.CreateMacroRequest
+	client := &mockMacroClient{
+		createMacroFunc: func(ctx context.Context, req *pb.CreateMacroRequest) (*pb.Macro, error) {
+			got = req
+			m := proto.CloneOf(req.GetMacro())
+			m.Name = "macros/" + req.MacroId
+			return m, nil
+		},
+	}
+	s := newTestMCPServer(client)
+
+	args := `{"macro_id":"login","macro":{
+		"name":"macros/ignored",
+		"displayName":"Login",
+		"actions":[
+			{"input":{"type_text":{"text":"${user}"}}},
+			{"input":{"press_key":{"key":"return","modifiers":["MODIFIER_COMMAND"]}}, "description":"submit"}
+		],
+		"parameters":[{"key":"user","type":"PARAMETER_TYPE_STRING","required":true}]
+	}}`
+	result, err := s.handleMacroCreate(&ToolCall{Arguments: json.RawMessage(args)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resultIsError(result) {
+		t.Fatalf("unexpected error result: %s", resultText(result))
+	}
+	if got.GetMacro().GetName() != "" {
+		t.Errorf("output-only name should be cleared, got %q", got.GetMacro().GetName())
+	}
+	if n := len(got.GetMacro().GetActions()); n != 2 {
+		t.Fatalf("actions = %d, want 2", n)
+	}
+	if key := got.GetMacro().GetActions()[1].GetInput().GetPressKey(); key.GetKey() != "return" || len(key.GetModifiers()) != 1 {
+		t.Errorf("press_key not parsed: %+v", key)
+	}
+	text := resultText(result)
+	for _, want := range []string{"Macro created", "macros/login", "Display name: Login", "- user (string, required)"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in result, got:\n%s", want, text)
+		}
+	}
+}
+
+func TestHandleMacroUpdate_DerivesMaskFromFields(t *testing.T) {
+	var got *pb.UpdateMacroRequest
+	client := &mockMacroClient{
+		updateMacroFunc: func(ctx context.Context, req *pb.UpdateMacroRequest) (*pb.Macro, error) {
+			got = req
+			return &pb.Macro{Name: req.GetMacro().GetName(), DisplayName: "Login", Description: req.GetMacro().GetDescription()}, nil
+		},
+	}
+	s := newTestMCPServer(client)
+
+	result, _ := s.handleMacroUpdate(&ToolCall{Arguments: json.RawMessage(`{"name":"macros/login","macro":{"description":"Signs in","tags":["auth"]}}`)})
+	if resultIsError(result) {
+		t.Fatalf("unexpected error result: %s", resultText(result))
+	}
+	if got.GetMacro().GetName() != "macros/login" {
+		t.Errorf("macro name = %q, want macros/login", got.GetMacro().GetName())
+	}
+	paths := got.GetUpdateMask().GetPaths()
+	if !slices.Equal(paths, []string{"description", "tags"}) {
+		t.Errorf("update mask = %v, want [description tags]", paths)
+	}
+	if !resultContains(result, "fields: description, tags") {
+		t.Errorf("unexpected result: %s", resultText(result))
+	}
+}
+
+func TestHandleMacroGet_RendersOutline(t *testing.T) {
+	client := &mockMacroClient{
+		getMacroFunc: func(ctx context.Context, req *pb.GetMacroRequest) (*pb.Macro, error) {
+			return &pb.Macro{
+				Name:        req.Name,
+				DisplayName: "Retry save",
+				Actions: []*pb.MacroAction{
+					{Action: &pb.MacroAction_Loop{Loop: &pb.LoopAction{
+						LoopType: &pb.LoopAction_Count{Count: 3},
+						Actions: []*pb.MacroAction{
+							{Action: &pb.MacroAction_Input{Input: &pb.InputAction{InputType: &pb.InputAction_PressKey{PressKey: &pb.KeyPress{Key: "s"}}}}},
+						},
+					}}},
+					{Action: &pb.MacroAction_Conditional{Conditional: &pb.ConditionalAction{
+						Condition:   &pb.MacroCondition{Condition: &pb.MacroCondition_WindowExists{WindowExists: "Save"}},
+						ThenActions: []*pb.MacroAction{{Action: &pb.MacroAction_Wait{Wait: &pb.WaitAction{Duration: 0.5}}}},
+					}}},
+				},
+			}, nil
+		},
+	}
+	s := newTestMCPServer(client)
+
+	result, _ := s.handleMacroGet(&ToolCall{Arguments: json.RawMessage(`{"name":"macros/retry"}`)})
+	text := resultText(result)
+	for _, want := range []string{
+		"1. repeat 3 times",
+		"     1. press s",
+		`2. if window "Save" exists`,
+		"then:",
+		"1. wait 0.50s",
+		"Definition (JSON):",
+		`"displayName"`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in result, got:\n%s", want, text)
+		}
+	}
+}
+
+func TestHandleMacroExecute_ReportsLog(t *testing.T) {
+	tests := []struct {
+		name      string
+		resp      *pb.ExecuteMacroResponse
+		wantError bool
+		want      []string
+	}{
+		{
+			name: "success",
+			resp: &pb.ExecuteMacroResponse{
+				Success:           true,
+				ActionsExecuted:   2,
+				ExecutionDuration: durationpb.New(1500 * time.Millisecond),
+				Log: []*pb.ExecutionLogEntry{
+					{ActionIndex: 0, Description: "type", Success: true, Duration: 0.25},
+					{ActionIndex: 1, Description: "press return", Success: true, Duration: 0.1},
+				},
+			},
+			want: []string{"Macro execution succeeded: macros/login", "Actions executed: 2", "Duration: 1.5s", "Execution log (2 entries, 0 failed)", "[OK] action 0: type (0.250s)"},
+		},
+		{
+			name: "failure",
+			resp: &pb.ExecuteMacroResponse{
+				ActionsExecuted: 1,
+				Error:           "element not found",
+				Log: []*pb.ExecutionLogEntry{
+					{ActionIndex: 0, Description: "click", Success: false, Error: "element not found"},
+				},
+			},
+			wantError: true,
+			want:      []string{"Macro execution failed: macros/login", "Error: element not found", "[FAILED] action 0: click (0.000s) — element not found"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *pb.ExecuteMacroRequest
+			client := &mockMacroClient{
+				executeMacroFunc: func(ctx context.Context, req *pb.ExecuteMacroRequest) (*longrunningpb.Operation, error) {
+					got = req
+					resp, err := anypb.New(tt.resp)
+					if err != nil {
+						t.Fatalf("anypb.New: %v", err)
+					}
+					return &longrunningpb.Operation{Name: "operations/exec", Done: true, Result: &longrunningpb.Operation_Response{Response: resp}}, nil
+				},
+			}
+			s := newTestMCPServer(client)
+
+			result, err := s.handleMacroExecute(&ToolCall{Arguments: json.RawMessage(`{"name":"macros/login","parameter_values":{"user":"alice"}}`)})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.GetParameterValues()["user"] != "alice" || !got.GetOptions().GetRecordExecution() {
+				t.Errorf("unexpected request: %+v", got)
+			}
+			if resultIsError(result) != tt.wantError {
+				t.Errorf("IsError = %v, want %v", resultIsError(result), tt.wantError)
+			}
+			text := resultText(result)
+			for _, want := range tt.want {
+				if !strings.Contains(text, want) {
+					t.Errorf("expected %q in result, got:\n%s", want, text)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleMacroExecute_OperationError(t *testing.T) {
+	client := &mockMacroClient{
+		executeMacroFunc: func(ctx context.Context, req *pb.ExecuteMacroRequest) (*longrunningpb.Operation, error) {
+			return &longrunningpb.Operation{Name: "operations/exec", Done: true, Result: &longrunningpb.Operation_Error{Error: &statuspb.Status{Code: 5, Message: "macro not found"}}}, nil
+		},
+	}
+	s := newTestMCPServer(client)
+
+	result, _ := s.handleMacroExecute(&ToolCall{Arguments: json.RawMessage(`{"name":"macros/login"}`)})
+	if !resultIsError(result) || !resultContains(result, "Macro execution failed: operation error: macro not found") {
+		t.Errorf("unexpected result: %s", resultText(result))
+	}
+}
@@ -0,0 +1,595 @@
+// Copyright 2025 Joseph Cumines
+//
+// Observation tool handlers — start, poll, list and cancel UI observations,
+// with streamed event delivery to observe_poll and SSE clients
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	typepb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/type"
+	pb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/v1"
+	"github.com/joeycumines/MacosUseSDK/internal/transport"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	// maxBufferedObservationEvents bounds the per-observation event buffer.
+	// Once full, the oldest events are dropped and reported on the next poll.
+	maxBufferedObservationEvents = 1000
+
+	// defaultObservePollMaxEvents is the default number of events returned by observe_poll.
+	defaultObservePollMaxEvents = 100
+
+	// observationSSEEvent is the SSE event type used for streamed observation events.
+	observationSSEEvent = "observation"
+
+	// observationErrorSSEEvent is the SSE event type used when an observation stream fails.
+	observationErrorSSEEvent = "observation_error"
+)
+
+// observationStream buffers events received from StreamObservations for a
+// single observation until they are drained by observe_poll. Events are also
+// pushed to the client session that started the stream, if its transport can
+// send SSE events.
+//
+//lint:ignore BETTERALIGN struct is intentionally ordered for clarity
+type observationStream struct {
+	cancel  context.CancelFunc
+	sink    transport.Transport
+	err     error
+	notify  chan struct{}
+	events  []*pb.ObservationEvent
+	dropped int
+	done    bool
+	mu      sync.Mutex
+}
+
+func newObservationStream(cancel context.CancelFunc, sink transport.Transport) *observationStream {
+	return &observationStream{
+		cancel: cancel,
+		sink:   sink,
+		notify: make(chan struct{}),
+	}
+}
+
+// signalLocked wakes any pollers waiting on the stream. Caller must hold mu.
+func (o *observationStream) signalLocked() {
+	close(o.notify)
+	o.notify = make(chan struct{})
+}
+
+// push appends an event, dropping the oldest buffered event when full.
+func (o *observationStream) push(event *pb.ObservationEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.events) >= maxBufferedObservationEvents {
+		o.events = o.events[1:]
+		o.dropped++
+	}
+	o.events = append(o.events, event)
+	o.signalLocked()
+}
+
+// finish marks the stream as ended. A nil err indicates a clean end of stream.
+// It reports whether observe_poll has anything left to report: buffered events
+// or the error.
+func (o *observationStream) finish(err error) (unreported bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.done = true
+	o.err = err
+	o.signalLocked()
+	return len(o.events) > 0 || err != nil
+}
+
+// wait blocks until at least one event is buffered, the stream ends, or ctx is done.
+func (o *observationStream) wait(ctx context.Context) {
+	for {
+		o.mu.Lock()
+		ready := len(o.events) > 0 || o.done
+		notify := o.notify
+		o.mu.Unlock()
+		if ready {
+			return
+		}
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// drain removes and returns up to maxEvents buffered events, along with the
+// number of events dropped since the last drain and the remaining backlog.
+func (o *observationStream) drain(maxEvents int) (events []*pb.ObservationEvent, dropped, remaining int, done bool, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n := min(maxEvents, len(o.events))
+	events = o.events[:n:n]
+	o.events = o.events[n:]
+	dropped = o.dropped
+	o.dropped = 0
+	return events, dropped, len(o.events), o.done, o.err
+}
+
+// lookupObservationStream returns the active stream for the named observation, if any.
+func (s *MCPServer) lookupObservationStream(name string) *observationStream {
+	s.observationsMu.Lock()
+	defer s.observationsMu.Unlock()
+	return s.observations[name]
+}
+
+// startObservationStream begins consuming StreamObservations for the named
+// observation in the background, pushing events to sink. It is a no-op if a
+// stream is already running.
+func (s *MCPServer) startObservationStream(name string, sink transport.Transport) *observationStream {
+	s.observationsMu.Lock()
+	defer s.observationsMu.Unlock()
+
+	if existing, ok := s.observations[name]; ok {
+		existing.mu.Lock()
+		done := existing.done
+		existing.mu.Unlock()
+		if !done {
+			return existing
+		}
+	}
+
+	if s.observations == nil {
+		s.observations = make(map[string]*observationStream)
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	stream := newObservationStream(cancel, sink)
+	s.observations[name] = stream
+
+	go s.runObservationStream(ctx, name, stream)
+
+	return stream
+}
+
+// stopObservationStream cancels and forgets the stream for the named observation.
+// It returns the number of undelivered events that were discarded.
+func (s *MCPServer) stopObservationStream(name string) int {
+	s.observationsMu.Lock()
+	stream, ok := s.observations[name]
+	delete(s.observations, name)
+	s.observationsMu.Unlock()
+
+	if !ok {
+		return 0
+	}
+	stream.cancel()
+
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+	return len(stream.events)
+}
+
+// forgetObservationStream removes stream from the tracked streams, if it is
+// still the one for the named observation.
+func (s *MCPServer) forgetObservationStream(name string, stream *observationStream) {
+	s.observationsMu.Lock()
+	defer s.observationsMu.Unlock()
+	if s.observations[name] == stream {
+		delete(s.observations, name)
+	}
+}
+
+// runObservationStream receives events until the stream ends or ctx is cancelled,
+// buffering each one and pushing it to the stream's client session.
+func (s *MCPServer) runObservationStream(ctx context.Context, name string, stream *observationStream) {
+	defer stream.cancel()
+
+	events, err := s.client.StreamObservations(ctx, &pb.StreamObservationsRequest{Name: name})
+	if err != nil {
+		s.endObservationStream(ctx, name, stream, err)
+		return
+	}
+
+	for {
+		resp, err := events.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			s.endObservationStream(ctx, name, stream, err)
+			return
+		}
+		if resp.GetEvent() == nil {
+			continue
+		}
+		stream.push(resp.GetEvent())
+		pushObservationEvent(stream, resp.GetEvent())
+	}
+}
+
+// endObservationStream records the end of a stream. Errors caused by our own
+// cancellation are treated as a clean end; other errors are reported to the
+// stream's client session. The stream is forgotten unless it has events or an
+// error left for observe_poll, which then forgets it once they are reported.
+func (s *MCPServer) endObservationStream(ctx context.Context, name string, stream *observationStream, err error) {
+	if ctx.Err() != nil {
+		err = nil
+	}
+	if !stream.finish(err) {
+		s.forgetObservationStream(name, stream)
+	}
+	if err == nil {
+		return
+	}
+
+	log.Printf("Observation stream %s ended: %v", name, err)
+
+	if sender, ok := stream.sink.(transport.EventSender); ok {
+		data, _ := json.Marshal(map[string]string{
+			"observation": name,
+			"error":       err.Error(),
+		})
+		sender.SendEvent(observationErrorSSEEvent, string(data))
+	}
+}
+
+// pushObservationEvent pushes an event to the stream's client session as
+// protojson, if its transport can push events.
+func pushObservationEvent(stream *observationStream, event *pb.ObservationEvent) {
+	sender, ok := stream.sink.(transport.EventSender)
+	if !ok {
+		return
+	}
+	data, err := protojson.Marshal(event)
+	if err != nil {
+		log.Printf("Warning: failed to marshal observation event: %v", err)
+		return
+	}
+	sender.SendEvent(observationSSEEvent, string(data))
+}
+
+// handleObserveStart handles the observe_start tool.
+func (s *MCPServer) handleObserveStart(call *ToolCall) (*ToolResult, error) {
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
+	defer cancel()
+
+	var params struct {
+		Parent       string   `json:"parent"`
+		Type         string   `json:"type"`
+		Roles        []string `json:"roles"`
+		Attributes   []string `json:"attributes"`
+		PollInterval float64  `json:"poll_interval"`
+		VisibleOnly  bool     `json:"visible_only"`
+		Activate     bool     `json:"activate"`
+	}
+
+	if err := json.Unmarshal(call.Arguments, &params); err != nil {
+		return errorResultf("Invalid parameters: %v", err), nil
+	}
+
+	if params.Parent == "" {
+		return errorResult("parent parameter is required"), nil
+	}
+	if params.PollInterval < 0 {
+		return errorResult("poll_interval must be non-negative"), nil
+	}
+
+	obsType, err := parseObservationType(params.Type)
+	if err != nil {
+		return errorResult(err.Error()), nil
+	}
+
+	op, err := s.client.CreateObservation(ctx, &pb.CreateObservationRequest{
+		Parent: params.Parent,
+		Observation: &pb.Observation{
+			Type: obsType,
+			Filter: &pb.ObservationFilter{
+				PollInterval: params.PollInterval,
+				VisibleOnly:  params.VisibleOnly,
+				Roles:        params.Roles,
+				Attributes:   params.Attributes,
+			},
+			Activate: params.Activate,
+		},
+	})
+	if err != nil {
+		return grpcErrorResult(err, "observe_start"), nil
+	}
+
+	op, err = s.awaitOperation(ctx, op)
+	if err != nil {
+		return errorResultf("Failed to start observation: %v", err), nil
+	}
+
+	var observation pb.Observation
+	if result := op.GetResponse(); result != nil {
+		if err := result.UnmarshalTo(&observation); err != nil {
+			return errorResultf("Failed to start observation: unmarshal failed: %v", err), nil
+		}
+	}
+	if observation.Name == "" {
+		return errorResult("Failed to start observation: server returned no observation name"), nil
+	}
+
+	_, sink := sessionOf(call.transport)
+	s.startObservationStream(observation.Name, sink)
+
+	delivery := "buffered for observe_poll"
+	if _, ok := sink.(transport.EventSender); ok {
+		delivery += fmt.Sprintf(" and pushed to this client as %q events", observationSSEEvent)
+	}
+
+	return textResultf("Observation started: %s\n  Type: %s\n  State: %s\n  Events: %s",
+		observation.Name,
+		observationTypeName(observation.Type),
+		observationStateName(observation.State),
+		delivery), nil
+}
+
+// handleObservePoll handles the observe_poll tool.
+func (s *MCPServer) handleObservePoll(call *ToolCall) (*ToolResult, error) {
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
+	defer cancel()
+
+	var params struct {
+		Name      string  `json:"name"`
+		MaxEvents int     `json:"max_events"`
+		Wait      float64 `json:"wait"`
+	}
+
+	if err := json.Unmarshal(call.Arguments, &params); err != nil {
+		return errorResultf("Invalid parameters: %v", err), nil
+	}
+
+	if params.Name == "" {
+		return errorResult("name parameter is required"), nil
+	}
+	if params.MaxEvents < 0 {
+		return errorResult("max_events must be non-negative"), nil
+	}
+	if params.Wait < 0 {
+		return errorResult("wait must be non-negative"), nil
+	}
+	if params.MaxEvents == 0 {
+		params.MaxEvents = defaultObservePollMaxEvents
+	}
+
+	observation, err := s.client.GetObservation(ctx, &pb.GetObservationRequest{Name: params.Name})
+	if err != nil {
+		return grpcErrorResult(err, "observe_poll"), nil
+	}
+
+	var notes []string
+	stream := s.lookupObservationStream(params.Name)
+	if stream == nil {
+		// Observation created elsewhere (or by a previous server instance): attach now.
+		// Only events emitted from this point on will be delivered.
+		_, sink := sessionOf(call.transport)
+		stream = s.startObservationStream(params.Name, sink)
+		notes = append(notes, "Attached to observation; events emitted before this poll are not available.")
+	}
+
+	if params.Wait > 0 {
+		waitDuration := time.Duration(params.Wait * float64(time.Second))
+		if maxWait := time.Duration(s.cfg.RequestTimeout) * time.Second; waitDuration > maxWait {
+			waitDuration = maxWait
+		}
+		waitCtx, waitCancel := context.WithTimeout(ctx, waitDuration)
+		stream.wait(waitCtx)
+		waitCancel()
+	}
+
+	events, dropped, remaining, done, streamErr := stream.drain(params.MaxEvents)
+	if done && remaining == 0 {
+		s.forgetObservationStream(params.Name, stream)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Observation %s (%s, %s)\n",
+		observation.Name,
+		observationStateName(observation.State),
+		observationTypeName(observation.Type))
+
+	if len(events) == 0 {
+		sb.WriteString("No new events")
+	} else {
+		fmt.Fprintf(&sb, "%d events:", len(events))
+		for _, event := range events {
+			sb.WriteString("\n")
+			sb.WriteString(formatObservationEvent(event))
+		}
+	}
+
+	if dropped > 0 {
+		notes = append(notes, fmt.Sprintf("%d older events were dropped (buffer limit %d); poll more often or narrow the filter.", dropped, maxBufferedObservationEvents))
+	}
+	if remaining > 0 {
+		notes = append(notes, fmt.Sprintf("%d more events buffered; poll again to retrieve them.", remaining))
+	}
+	if done {
+		if streamErr != nil {
+			notes = append(notes, fmt.Sprintf("Event stream ended with error: %v", streamErr))
+		} else {
+			notes = append(notes, "Event stream ended.")
+		}
+	}
+	if len(notes) > 0 {
+		sb.WriteString("\n\nNote: ")
+		sb.WriteString(strings.Join(notes, "\nNote: "))
+	}
+
+	return textResult(sb.String()), nil
+}
+
+// handleObserveList handles the observe_list tool.
+func (s *MCPServer) handleObserveList(call *ToolCall) (*ToolResult, error) {
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
+	defer cancel()
+
+	var params struct {
+		Parent    string `json:"parent"`
+		PageToken string `json:"page_token"`
+		PageSize  int32  `json:"page_size"`
+	}
+
+	if err := json.Unmarshal(call.Arguments, &params); err != nil {
+		return errorResultf("Invalid parameters: %v", err), nil
+	}
+
+	if params.Parent == "" {
+		return errorResult("parent parameter is required"), nil
+	}
+	if params.PageSize < 0 {
+		return errorResult("page_size must be non-negative"), nil
+	}
+
+	resp, err := s.client.ListObservations(ctx, &pb.ListObservationsRequest{
+		Parent:    params.Parent,
+		PageSize:  params.PageSize,
+		PageToken: params.PageToken,
+	})
+	if err != nil {
+		return grpcErrorResult(err, "observe_list"), nil
+	}
+
+	if len(resp.Observations) == 0 {
+		return textResult("No observations found"), nil
+	}
+
+	var lines []string
+	for i, obs := range resp.Observations {
+		line := fmt.Sprintf("%d. %s - %s (%s)", i+1, obs.Name, observationTypeName(obs.Type), observationStateName(obs.State))
+		if s.lookupObservationStream(obs.Name) != nil {
+			line += " [streaming]"
+		}
+		lines = append(lines, line)
+	}
+
+	result := fmt.Sprintf("Found %d observations:\n%s", len(resp.Observations), strings.Join(lines, "\n"))
+	if resp.NextPageToken != "" {
+		result += fmt.Sprintf("\n\nMore results available. Use page_token: %s", resp.NextPageToken)
+	}
+	return textResult(result), nil
+}
+
+// handleObserveCancel handles the observe_cancel tool.
+func (s *MCPServer) handleObserveCancel(call *ToolCall) (*ToolResult, error) {
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
+	defer cancel()
+
+	var params struct {
+		Name string `json:"name"`
+	}
+
+	if err := json.Unmarshal(call.Arguments, &params); err != nil {
+		return errorResultf("Invalid parameters: %v", err), nil
+	}
+
+	if params.Name == "" {
+		return errorResult("name parameter is required"), nil
+	}
+
+	observation, err := s.client.CancelObservation(ctx, &pb.CancelObservationRequest{Name: params.Name})
+	if err != nil {
+		return grpcErrorResult(err, "observe_cancel"), nil
+	}
+
+	discarded := s.stopObservationStream(params.Name)
+
+	result := fmt.Sprintf("Observation cancelled: %s (%s)", observation.Name, observationStateName(observation.State))
+	if discarded > 0 {
+		result += fmt.Sprintf("\n  Discarded %d undelivered events", discarded)
+	}
+	return textResult(result), nil
+}
+
+// parseObservationType converts a tool-level observation type name to the proto enum.
+// An empty string defaults to element_changes.
+func parseObservationType(s string) (pb.ObservationType, error) {
+	switch s {
+	case "", "element_changes":
+		return pb.ObservationType_OBSERVATION_TYPE_ELEMENT_CHANGES, nil
+	case "window_changes":
+		return pb.ObservationType_OBSERVATION_TYPE_WINDOW_CHANGES, nil
+	case "application_changes":
+		return pb.ObservationType_OBSERVATION_TYPE_APPLICATION_CHANGES, nil
+	case "attribute_changes":
+		return pb.ObservationType_OBSERVATION_TYPE_ATTRIBUTE_CHANGES, nil
+	case "tree_changes":
+		return pb.ObservationType_OBSERVATION_TYPE_TREE_CHANGES, nil
+	default:
+		return pb.ObservationType_OBSERVATION_TYPE_UNSPECIFIED, fmt.Errorf("unknown observation type: %s. Valid: element_changes, window_changes, application_changes, attribute_changes, tree_changes", s)
+	}
+}
+
+// observationTypeName returns the tool-level name of an observation type (e.g. "element_changes").
+func observationTypeName(t pb.ObservationType) string {
+	return strings.ToLower(strings.TrimPrefix(t.String(), "OBSERVATION_TYPE_"))
+}
+
+// observationStateName returns the short name of an observation state (e.g. "ACTIVE").
+func observationStateName(state pb.Observation_State) string {
+	return strings.TrimPrefix(state.String(), "STATE_")
+}
+
+// formatObservationEvent renders an observation event as a single line.
+func formatObservationEvent(event *pb.ObservationEvent) string {
+	prefix := fmt.Sprintf("#%d", event.GetSequence())
+	if t := event.GetEventTime(); t != nil {
+		prefix += " " + t.AsTime().Format(time.RFC3339Nano)
+	}
+
+	var desc string
+	switch e := event.GetEventType().(type) {
+	case *pb.ObservationEvent_ElementAdded:
+		desc = "element_added: " + describeObservedElement(e.ElementAdded.GetElement())
+	case *pb.ObservationEvent_ElementRemoved:
+		desc = "element_removed: " + describeObservedElement(e.ElementRemoved.GetElement())
+	case *pb.ObservationEvent_ElementModified:
+		desc = "element_modified: " + describeObservedElement(e.ElementModified.GetNewElement())
+		if changes := e.ElementModified.GetChanges(); len(changes) > 0 {
+			parts := make([]string, 0, len(changes))
+			for _, c := range changes {
+				parts = append(parts, fmt.Sprintf("%s: %q -> %q", c.Attribute, truncateText(c.OldValue), truncateText(c.NewValue)))
+			}
+			desc += " {" + strings.Join(parts, "; ") + "}"
+		}
+	case *pb.ObservationEvent_WindowEvent:
+		kind := strings.ToLower(strings.TrimPrefix(e.WindowEvent.GetEventType().String(), "WINDOW_EVENT_TYPE_"))
+		desc = fmt.Sprintf("window_%s: %s %q", kind, e.WindowEvent.GetWindowId(), e.WindowEvent.GetTitle())
+	case *pb.ObservationEvent_ApplicationEvent:
+		kind := strings.ToLower(strings.TrimPrefix(e.ApplicationEvent.GetEventType().String(), "APPLICATION_EVENT_TYPE_"))
+		desc = "application_" + kind
+	default:
+		desc = "unknown event"
+	}
+
+	return prefix + " " + desc
+}
+
+// describeObservedElement renders an element as `role "text" (id)`.
+func describeObservedElement(elem *typepb.Element) string {
+	if elem == nil {
+		return "(unknown element)"
+	}
+	role := elem.Role
+	if role == "" {
+		role = "(unknown)"
+	}
+	desc := role
+	if text := elem.GetText(); text != "" {
+		desc += fmt.Sprintf(" %q", truncateText(text))
+	}
+	if elem.ElementId != "" {
+		desc += " (" + elem.ElementId + ")"
+	}
+	return desc
+}
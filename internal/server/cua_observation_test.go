@@ -0,0 +1,395 @@
+// Copyright 2025 Joseph Cumines
+//
+// Tests for the observation tool handlers (observe_start, observe_poll,
+// observe_list, observe_cancel) and observation event buffering.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	longrunningpb "cloud.google.com/go/longrunning/autogen/longrunningpb"
+	typepb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/type"
+	pb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// mockObservationClient implements only the gRPC methods used by the observation tools.
+type mockObservationClient struct {
+	pb.MacosUseClient
+
+	createObservationFunc  func(ctx context.Context, req *pb.CreateObservationRequest) (*longrunningpb.Operation, error)
+	getObservationFunc     func(ctx context.Context, req *pb.GetObservationRequest) (*pb.Observation, error)
+	listObservationsFunc   func(ctx context.Context, req *pb.ListObservationsRequest) (*pb.ListObservationsResponse, error)
+	cancelObservationFunc  func(ctx context.Context, req *pb.CancelObservationRequest) (*pb.Observation, error)
+	streamObservationsFunc func(ctx context.Context, req *pb.StreamObservationsRequest) (grpc.ServerStreamingClient[pb.StreamObservationsResponse], error)
+}
+
+func (m *mockObservationClient) CreateObservation(ctx context.Context, req *pb.CreateObservationRequest, opts ...grpc.CallOption) (*longrunningpb.Operation, error) {
+	return m.createObservationFunc(ctx, req)
+}
+
+func (m *mockObservationClient) GetObservation(ctx context.Context, req *pb.GetObservationRequest, opts ...grpc.CallOption) (*pb.Observation, error) {
+	return m.getObservationFunc(ctx, req)
+}
+
+func (m *mockObservationClient) ListObservations(ctx context.Context, req *pb.ListObservationsRequest, opts ...grpc.CallOption) (*pb.ListObservationsResponse, error) {
+	return m.listObservationsFunc(ctx, req)
+}
+
+func (m *mockObservationClient) CancelObservation(ctx context.Context, req *pb.CancelObservationRequest, opts ...grpc.CallOption) (*pb.Observation, error) {
+	return m.cancelObservationFunc(ctx, req)
+}
+
+func (m *mockObservationClient) StreamObservations(ctx context.Context, req *pb.StreamObservationsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[pb.StreamObservationsResponse], error) {
+	return m.streamObservationsFunc(ctx, req)
+}
+
+// chanObservationStream is a StreamObservations client stream fed from a channel.
+// Closing the channel ends the stream with io.EOF.
+type chanObservationStream struct {
+	grpc.ClientStream
+	ctx    context.Context
+	events <-chan *pb.ObservationEvent
+}
+
+func (c *chanObservationStream) Recv() (*pb.StreamObservationsResponse, error) {
+	select {
+	case event, ok := <-c.events:
+		if !ok {
+			return nil, io.EOF
+		}
+		return &pb.StreamObservationsResponse{Event: event}, nil
+	case <-c.ctx.Done():
+		return nil, c.ctx.Err()
+	}
+}
+
+func TestObservationHandlers_InvalidParams(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name       string
+		handler    func(*ToolCall) (*ToolResult, error)
+		args       string
+		wantSubstr string
+	}{
+		{"start missing parent", s.handleObserveStart, `{}`, "parent parameter is required"},
+		{"start invalid JSON", s.handleObserveStart, `{bad`, "Invalid parameters"},
+		{"start unknown type", s.handleObserveStart, `{"parent":"applications/1","type":"everything"}`, "unknown observation type"},
+		{"start negative poll interval", s.handleObserveStart, `{"parent":"applications/1","poll_interval":-1}`, "poll_interval must be non-negative"},
+		{"poll missing name", s.handleObservePoll, `{}`, "name parameter is required"},
+		{"poll negative max_events", s.handleObservePoll, `{"name":"applications/1/observations/a","max_events":-1}`, "max_events must be non-negative"},
+		{"poll negative wait", s.handleObservePoll, `{"name":"applications/1/observations/a","wait":-1}`, "wait must be non-negative"},
+		{"list missing parent", s.handleObserveList, `{}`, "parent parameter is required"},
+		{"list negative page size", s.handleObserveList, `{"parent":"applications/1","page_size":-1}`, "page_size must be non-negative"},
+		{"cancel missing name", s.handleObserveCancel, `{}`, "name parameter is required"},
+		{"cancel invalid JSON", s.handleObserveCancel, `{bad`, "Invalid parameters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.handler(&ToolCall{Arguments: json.RawMessage(tt.args)})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !resultIsError(result) {
+				t.Errorf("expected error result, got: %+v", result)
+			}
+			if !resultContains(result, tt.wantSubstr) {
+				t.Errorf("expected result to contain %q, got: %q", tt.wantSubstr, resultText(result))
+			}
+		})
+	}
+}
+
+func TestObservationHandlers_StartPollCancel(t *testing.T) {
+	const name = "applications/42/observations/obs-1"
+
+	events := make(chan *pb.ObservationEvent, 4)
+	var created *pb.CreateObservationRequest
+	var cancelled bool
+
+	client := &mockObservationClient{
+		createObservationFunc: func(ctx context.Context, req *pb.CreateObservationRequest) (*longrunningpb.Operation, error) {
+			created = req
+			resp, err := anypb.New(&pb.Observation{
+				Name:  name,
+				Type:  req.GetObservation().GetType(),
+				State: pb.Observation_STATE_ACTIVE,
+			})
+			if err != nil {
+				t.Fatalf("anypb.New: %v", err)
+			}
+			return &longrunningpb.Operation{
+				Name:   "operations/create-observation",
+				Done:   true,
+				Result: &longrunningpb.Operation_Response{Response: resp},
+			}, nil
+		},
+		getObservationFunc: func(ctx context.Context, req *pb.GetObservationRequest) (*pb.Observation, error) {
+			return &pb.Observation{Name: req.Name, Type: pb.ObservationType_OBSERVATION_TYPE_WINDOW_CHANGES, State: pb.Observation_STATE_ACTIVE}, nil
+		},
+		cancelObservationFunc: func(ctx context.Context, req *pb.CancelObservationRequest) (*pb.Observation, error) {
+			cancelled = true
+			return &pb.Observation{Name: req.Name, State: pb.Observation_STATE_CANCELLED}, nil
+		},
+		streamObservationsFunc: func(ctx context.Context, req *pb.StreamObservationsRequest) (grpc.ServerStreamingClient[pb.StreamObservationsResponse], error) {
+			if req.Name != name {
+				t.Errorf("StreamObservations name = %q, want %q", req.Name, name)
+			}
+			return &chanObservationStream{ctx: ctx, events: events}, nil
+		},
+	}
+	s := newTestMCPServer(client)
+
+	result, err := s.handleObserveStart(&ToolCall{Arguments: json.RawMessage(`{"parent":"applications/42","type":"window_changes","roles":["AXWindow"],"visible_only":true}`)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resultIsError(result) {
+		t.Fatalf("unexpected error result: %s", resultText(result))
+	}
+	if !resultContains(result, name) || !resultContains(result, "window_changes") {
+		t.Errorf("start result missing observation details: %s", resultText(result))
+	}
+	if created.GetObservation().GetType() != pb.ObservationType_OBSERVATION_TYPE_WINDOW_CHANGES {
+		t.Errorf("created type = %v, want WINDOW_CHANGES", created.GetObservation().GetType())
+	}
+	if f := created.GetObservation().GetFilter(); !f.GetVisibleOnly() || len(f.GetRoles()) != 1 {
+		t.Errorf("filter not propagated: %+v", f)
+	}
+
+	events <- &pb.ObservationEvent{
+		Sequence: 1,
+		EventType: &pb.ObservationEvent_WindowEvent{WindowEvent: &pb.WindowEvent{
+			EventType: pb.WindowEvent_WINDOW_EVENT_TYPE_CREATED,
+			WindowId:  "7",
+			Title:     "Untitled",
+		}},
+	}
+
+	result, err = s.handleObservePoll(&ToolCall{Arguments: json.RawMessage(`{"name":"` + name + `","wait":5}`)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	text := resultText(result)
+	if resultIsError(result) || !strings.Contains(text, "1 events:") || !strings.Contains(text, `window_created: 7 "Untitled"`) {
+		t.Errorf("unexpected poll result: %s", text)
+	}
+
+	// The buffer was drained by the previous poll.
+	result, _ = s.handleObservePoll(&ToolCall{Arguments: json.RawMessage(`{"name":"` + name + `"}`)})
+	if !resultContains(result, "No new events") {
+		t.Errorf("expected empty poll, got: %s", resultText(result))
+	}
+
+	stream := s.lookupObservationStream(name)
+	if stream == nil {
+		t.Fatal("expected observation stream to be tracked")
+	}
+
+	result, _ = s.handleObserveCancel(&ToolCall{Arguments: json.RawMessage(`{"name":"` + name + `"}`)})
+	if resultIsError(result) || !cancelled || !resultContains(result, "CANCELLED") {
+		t.Errorf("unexpected cancel result: %s", resultText(result))
+	}
+	if s.lookupObservationStream(name) != nil {
+		t.Error("expected observation stream to be removed after cancel")
+	}
+
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer waitCancel()
+	stream.wait(waitCtx)
+	if _, _, _, done, streamErr := stream.drain(0); !done || streamErr != nil {
+		t.Errorf("stream after cancel: done=%v err=%v, want done without error", done, streamErr)
+	}
+}
+
+func TestObservationHandlers_PollAttachesUntrackedObservation(t *testing.T) {
+	streamed := make(chan string, 1)
+	client := &mockObservationClient{
+		getObservationFunc: func(ctx context.Context, req *pb.GetObservationRequest) (*pb.Observation, error) {
+			return &pb.Observation{Name: req.Name, State: pb.Observation_STATE_ACTIVE}, nil
+		},
+		streamObservationsFunc: func(ctx context.Context, req *pb.StreamObservationsRequest) (grpc.ServerStreamingClient[pb.StreamObservationsResponse], error) {
+			streamed <- req.Name
+			events := make(chan *pb.ObservationEvent)
+			close(events)
+			return &chanObservationStream{ctx: ctx, events: events}, nil
+		},
+	}
+	s := newTestMCPServer(client)
+
+	result, err := s.handleObservePoll(&ToolCall{Arguments: json.RawMessage(`{"name":"applications/1/observations/x"}`)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resultContains(result, "Attached to observation") {
+		t.Errorf("expected attach note, got: %s", resultText(result))
+	}
+	select {
+	case name := <-streamed:
+		if name != "applications/1/observations/x" {
+			t.Errorf("StreamObservations name = %q, want applications/1/observations/x", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected poll to start a stream for an untracked observation")
+	}
+}
+
+func TestObservationHandlers_EndedStreamsForgotten(t *testing.T) {
+	s := newTestMCPServer(&mockObservationClient{
+		getObservationFunc: func(ctx context.Context, req *pb.GetObservationRequest) (*pb.Observation, error) {
+			return &pb.Observation{Name: req.Name, State: pb.Observation_STATE_COMPLETED}, nil
+		},
+	})
+	track := func(name string) *observationStream {
+		stream := newObservationStream(func() {}, nil)
+		s.observationsMu.Lock()
+		if s.observations == nil {
+			s.observations = make(map[string]*observationStream)
+		}
+		s.observations[name] = stream
+		s.observationsMu.Unlock()
+		return stream
+	}
+
+	// A stream with nothing left to report is forgotten when it ends.
+	s.endObservationStream(context.Background(), "applications/1/observations/a", track("applications/1/observations/a"), nil)
+	if s.lookupObservationStream("applications/1/observations/a") != nil {
+		t.Error("expected ended stream to be forgotten")
+	}
+
+	// Otherwise it is kept until observe_poll has reported its last events.
+	const name = "applications/1/observations/b"
+	stream := track(name)
+	stream.push(&pb.ObservationEvent{Sequence: 1})
+	stream.push(&pb.ObservationEvent{Sequence: 2})
+	s.endObservationStream(context.Background(), name, stream, nil)
+	result, _ := s.handleObservePoll(&ToolCall{Arguments: json.RawMessage(`{"name":"` + name + `","max_events":1}`)})
+	if !resultContains(result, "1 events:") || s.lookupObservationStream(name) != stream {
+		t.Fatalf("expected stream to be kept with events buffered, got: %s", resultText(result))
+	}
+	result, _ = s.handleObservePoll(&ToolCall{Arguments: json.RawMessage(`{"name":"` + name + `"}`)})
+	if !resultContains(result, "1 events:") || !resultContains(result, "Event stream ended.") {
+		t.Errorf("unexpected final poll: %s", resultText(result))
+	}
+	if s.lookupObservationStream(name) != nil {
+		t.Error("expected stream to be forgotten once its end was reported")
+	}
+}
+
+func TestObservationHandlers_List(t *testing.T) {
+	client := &mockObservationClient{
+		listObservationsFunc: func(ctx context.Context, req *pb.ListObservationsRequest) (*pb.ListObservationsResponse, error) {
+			if req.Parent != "applications/9" || req.PageSize != 5 {
+				t.Errorf("unexpected request: %+v", req)
+			}
+			return &pb.ListObservationsResponse{
+				Observations: []*pb.Observation{
+					{Name: "applications/9/observations/a", Type: pb.ObservationType_OBSERVATION_TYPE_ELEMENT_CHANGES, State: pb.Observation_STATE_ACTIVE},
+					{Name: "applications/9/observations/b", Type: pb.ObservationType_OBSERVATION_TYPE_TREE_CHANGES, State: pb.Observation_STATE_COMPLETED},
+				},
+				NextPageToken: "next",
+			}, nil
+		},
+	}
+	s := newTestMCPServer(client)
+
+	result, err := s.handleObserveList(&ToolCall{Arguments: json.RawMessage(`{"parent":"applications/9","page_size":5}`)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	text := resultText(result)
+	for _, want := range []string{
+		"Found 2 observations",
+		"applications/9/observations/a - element_changes (ACTIVE)",
+		"applications/9/observations/b - tree_changes (COMPLETED)",
+		"page_token: next",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in result, got: %s", want, text)
+		}
+	}
+}
+
+func TestObservationStream_DropsOldestWhenFull(t *testing.T) {
+	stream := newObservationStream(func() {}, nil)
+	for i := range maxBufferedObservationEvents + 3 {
+		stream.push(&pb.ObservationEvent{Sequence: int64(i)})
+	}
+
+	events, dropped, remaining, done, err := stream.drain(10)
+	if dropped != 3 {
+		t.Errorf("dropped = %d, want 3", dropped)
+	}
+	if len(events) != 10 || events[0].Sequence != 3 {
+		t.Errorf("expected 10 events starting at sequence 3, got %d starting at %d", len(events), events[0].Sequence)
+	}
+	if remaining != maxBufferedObservationEvents-10 {
+		t.Errorf("remaining = %d, want %d", remaining, maxBufferedObservationEvents-10)
+	}
+	if done || err != nil {
+		t.Errorf("done=%v err=%v, want active stream", done, err)
+	}
+
+	if _, dropped, _, _, _ := stream.drain(1); dropped != 0 {
+		t.Errorf("dropped count should reset after drain, got %d", dropped)
+	}
+}
+
+func TestFormatObservationEvent(t *testing.T) {
+	button := &typepb.Element{Role: "AXButton", Text: proto.String("Save"), ElementId: "e1"}
+
+	tests := []struct {
+		name  string
+		event *pb.ObservationEvent
+		want  string
+	}{
+		{
+			name:  "element added",
+			event: &pb.ObservationEvent{Sequence: 1, EventType: &pb.ObservationEvent_ElementAdded{ElementAdded: &pb.ElementEvent{Element: button}}},
+			want:  `#1 element_added: AXButton "Save" (e1)`,
+		},
+		{
+			name:  "element removed without element",
+			event: &pb.ObservationEvent{Sequence: 2, EventType: &pb.ObservationEvent_ElementRemoved{ElementRemoved: &pb.ElementEvent{}}},
+			want:  "#2 element_removed: (unknown element)",
+		},
+		{
+			name: "element modified",
+			event: &pb.ObservationEvent{Sequence: 3, EventType: &pb.ObservationEvent_ElementModified{ElementModified: &pb.ElementModified{
+				NewElement: button,
+				Changes:    []*pb.AttributeChange{{Attribute: "AXValue", OldValue: "a", NewValue: "b"}},
+			}}},
+			want: `#3 element_modified: AXButton "Save" (e1) {AXValue: "a" -> "b"}`,
+		},
+		{
+			name: "application event",
+			event: &pb.ObservationEvent{Sequence: 4, EventType: &pb.ObservationEvent_ApplicationEvent{ApplicationEvent: &pb.ApplicationEvent{
+				EventType: pb.ApplicationEvent_APPLICATION_EVENT_TYPE_TERMINATED,
+			}}},
+			want: "#4 application_terminated",
+		},
+		{
+			name:  "no event type",
+			event: &pb.ObservationEvent{Sequence: 5},
+			want:  "#5 unknown event",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatObservationEvent(tt.event); got != tt.want {
+				t.Errorf("formatObservationEvent() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
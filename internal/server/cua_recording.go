@@ -0,0 +1,466 @@
+// Copyright 2025 Joseph Cumines
+//
+// Recording tool handlers — capture successful tool calls and convert them
+// into a macro definition
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	typepb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/type"
+	pb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// maxRecordedCalls bounds the number of tool calls held by an active recording.
+const maxRecordedCalls = 1000
+
+// recordingControlTools are never captured, as they manage the recording itself.
+var recordingControlTools = map[string]bool{
+	"recording_start": true,
+	"recording_stop":  true,
+}
+
+// recordingPassiveTools observe state without changing it. They are captured
+// but silently omitted from the generated macro.
+var recordingPassiveTools = map[string]bool{
+	"screenshot":             true,
+	"list_apps":              true,
+	"find_elements":          true,
+	"read_element":           true,
+	"element_actions":        true,
+	"get_accessibility_tree": true,
+	"list_windows":           true,
+	"get_display":            true,
+	"observe_poll":           true,
+	"observe_list":           true,
+	"session_snapshot":       true,
+	"macro_list":             true,
+	"macro_get":              true,
+	"input_lease":            true,
+	"wait_for_element":       true,
+	"wait_for_state":         true,
+}
+
+// recordedCall is a single successful tool call captured while recording.
+type recordedCall struct {
+	name      string
+	arguments json.RawMessage
+}
+
+// macroRecording is the state of an active recording.
+type macroRecording struct {
+	startTime   time.Time
+	displayName string
+	description string
+	calls       []recordedCall
+	truncated   int
+}
+
+// recordToolCall appends a successful tool call to the active recording of the
+// client session, if any. It is invoked by the tools/call method handler for
+// every transport.
+func (s *MCPServer) recordToolCall(session, name string, arguments json.RawMessage) {
+	if recordingControlTools[name] {
+		return
+	}
+
+	s.withSession(session, func(c *clientSession) {
+		rec := c.recording
+		if rec == nil {
+			return
+		}
+		if len(rec.calls) >= maxRecordedCalls {
+			rec.truncated++
+			return
+		}
+		rec.calls = append(rec.calls, recordedCall{
+			name:      name,
+			arguments: append(json.RawMessage(nil), arguments...),
+		})
+	})
+}
+
+// handleRecordingStart handles the recording_start tool.
+func (s *MCPServer) handleRecordingStart(call *ToolCall) (*ToolResult, error) {
+	var params struct {
+		DisplayName string `json:"display_name"`
+		Description string `json:"description"`
+	}
+
+	if err := json.Unmarshal(call.Arguments, &params); err != nil {
+		return errorResultf("Invalid parameters: %v", err), nil
+	}
+
+	session, _ := sessionOf(call.transport)
+	var inProgress *ToolResult
+	s.withSession(session, func(c *clientSession) {
+		if c.recording != nil {
+			inProgress = errorResultf("A recording is already in progress (started %s, %d calls captured); use recording_stop first",
+				c.recording.startTime.Format(time.RFC3339), len(c.recording.calls))
+			return
+		}
+		c.recording = &macroRecording{
+			startTime:   time.Now(),
+			displayName: params.DisplayName,
+			description: params.Description,
+		}
+	})
+	if inProgress != nil {
+		return inProgress, nil
+	}
+
+	return textResult("Recording started. Successful click, type, keypress and other input tool calls will be captured until recording_stop is called."), nil
+}
+
+// handleRecordingStop handles the recording_stop tool.
+func (s *MCPServer) handleRecordingStop(call *ToolCall) (*ToolResult, error) {
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
+	defer cancel()
+
+	var params struct {
+		Save         *bool    `json:"save"`
+		Parameterize *bool    `json:"parameterize"`
+		MacroID      string   `json:"macro_id"`
+		DisplayName  string   `json:"display_name"`
+		Description  string   `json:"description"`
+		Tags         []string `json:"tags"`
+	}
+
+	if err := json.Unmarshal(call.Arguments, &params); err != nil {
+		return errorResultf("Invalid parameters: %v", err), nil
+	}
+
+	session, _ := sessionOf(call.transport)
+	var rec *macroRecording
+	s.withSession(session, func(c *clientSession) {
+		rec, c.recording = c.recording, nil
+	})
+
+	if rec == nil {
+		return errorResult("No recording in progress; use recording_start first"), nil
+	}
+
+	save := params.Save == nil || *params.Save
+	parameterize := params.Parameterize == nil || *params.Parameterize
+
+	macro, notes := buildRecordedMacro(rec.calls, parameterize)
+	macro.DisplayName = params.DisplayName
+	if macro.DisplayName == "" {
+		macro.DisplayName = rec.displayName
+	}
+	if macro.DisplayName == "" {
+		macro.DisplayName = "Recording " + rec.startTime.Format(time.RFC3339)
+	}
+	macro.Description = params.Description
+	if macro.Description == "" {
+		macro.Description = rec.description
+	}
+	macro.Tags = params.Tags
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Recording stopped after %s: %d tool calls captured, %d actions recorded",
+		time.Since(rec.startTime).Round(time.Millisecond), len(rec.calls), len(macro.Actions))
+	if rec.truncated > 0 {
+		fmt.Fprintf(&sb, " (%d further calls dropped, limit is %d)", rec.truncated, maxRecordedCalls)
+	}
+	if len(notes) > 0 {
+		sb.WriteString("\nNotes:")
+		for _, msg := range notes {
+			sb.WriteString("\n  - ")
+			sb.WriteString(msg)
+		}
+	}
+
+	if len(macro.Actions) == 0 {
+		sb.WriteString("\nNothing recordable was captured; no macro was created")
+		return errorResult(sb.String()), nil
+	}
+
+	if err := validateMacro(macro); err != nil {
+		return errorResultf("%s\nRecorded macro is invalid: %v", sb.String(), err), nil
+	}
+
+	if !save {
+		definition, err := protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(macro)
+		if err != nil {
+			return errorResultf("Failed to encode macro definition: %v", err), nil
+		}
+		sb.WriteString("\nMacro not saved; pass the definition below to macro_create to save it")
+		sb.WriteString("\nActions:")
+		writeMacroActions(&sb, macro.Actions, "  ")
+		sb.WriteString("\n\nDefinition (JSON):\n")
+		sb.Write(definition)
+		return textResult(sb.String()), nil
+	}
+
+	created, err := s.client.CreateMacro(ctx, &pb.CreateMacroRequest{
+		Macro:   macro,
+		MacroId: params.MacroID,
+	})
+	if err != nil {
+		return grpcErrorResult(err, "recording_stop"), nil
+	}
+
+	sb.WriteString("\nMacro created\n")
+	sb.WriteString(formatMacroSummary(created))
+	return textResult(sb.String()), nil
+}
+
+// buildRecordedMacro converts recorded tool calls into macro actions. When
+// parameterize is set, each distinct typed text becomes a string parameter
+// (text_1, text_2, ...) referenced as ${key}, defaulting to the recorded text.
+// The returned notes describe calls that were skipped or only partially
+// converted.
+func buildRecordedMacro(calls []recordedCall, parameterize bool) (*pb.Macro, []string) {
+	macro := &pb.Macro{}
+	var notes []string
+	paramKeys := map[string]string{}
+
+	for i, c := range calls {
+		step := i + 1
+		if recordingPassiveTools[c.name] {
+			continue
+		}
+
+		actions, warning := recordedCallToActions(c)
+		if warning != "" {
+			notes = append(notes, fmt.Sprintf("%s (call %d): %s", c.name, step, warning))
+		}
+
+		for _, action := range actions {
+			if typeText := action.GetInput().GetTypeText(); typeText != nil && parameterize {
+				key, ok := paramKeys[typeText.Text]
+				if !ok {
+					key = fmt.Sprintf("text_%d", len(paramKeys)+1)
+					paramKeys[typeText.Text] = key
+					macro.Parameters = append(macro.Parameters, &pb.MacroParameter{
+						Key:          key,
+						Type:         pb.MacroParameter_PARAMETER_TYPE_STRING,
+						DefaultValue: typeText.Text,
+						Description:  fmt.Sprintf("Text typed at call %d", step),
+					})
+				}
+				typeText.Text = "${" + key + "}"
+			}
+			macro.Actions = append(macro.Actions, action)
+		}
+	}
+
+	return macro, notes
+}
+
+// recordedCallToActions converts a single recorded tool call into macro actions.
+// A non-empty warning means the call was not (fully) representable.
+func recordedCallToActions(c recordedCall) ([]*pb.MacroAction, string) {
+	switch c.name {
+	case "click", "double_click":
+		var params struct {
+			X          *float64 `json:"x"`
+			Y          *float64 `json:"y"`
+			Button     string   `json:"button"`
+			ClickCount int32    `json:"click_count"`
+			Keys       []string `json:"keys"`
+		}
+		if err := json.Unmarshal(c.arguments, &params); err != nil || params.X == nil || params.Y == nil {
+			return nil, "invalid arguments"
+		}
+		clickCount := params.ClickCount
+		if c.name == "double_click" {
+			clickCount = 2
+		} else if clickCount <= 0 {
+			clickCount = 1
+		}
+		clickType := mapButtonString(params.Button)
+		position := &typepb.Point{X: *params.X, Y: *params.Y}
+
+		modifiers, _ := cuaKeysToModifiers(params.Keys)
+		if len(modifiers) == 0 {
+			return []*pb.MacroAction{inputMacroAction(&pb.InputAction{
+				InputType: &pb.InputAction_Click{Click: &pb.MouseClick{
+					Position:   position,
+					ClickType:  clickType,
+					ClickCount: clickCount,
+				}},
+			})}, ""
+		}
+
+		// Mirror clickWithModifiers: modifiers are held via button down/up pairs.
+		var actions []*pb.MacroAction
+		for range clickCount {
+			actions = append(actions,
+				inputMacroAction(&pb.InputAction{
+					InputType: &pb.InputAction_ButtonDown{ButtonDown: &pb.MouseButtonDown{
+						Position:  position,
+						Button:    clickType,
+						Modifiers: modifiers,
+					}},
+				}),
+				inputMacroAction(&pb.InputAction{
+					InputType: &pb.InputAction_ButtonUp{ButtonUp: &pb.MouseButtonUp{
+						Position:  position,
+						Button:    clickType,
+						Modifiers: modifiers,
+					}},
+				}),
+			)
+		}
+		return actions, ""
+
+	case "type":
+		var params struct {
+			Text      string  `json:"text"`
+			CharDelay float64 `json:"char_delay"`
+			Parent    string  `json:"parent"`
+		}
+		if err := json.Unmarshal(c.arguments, &params); err != nil || params.Text == "" {
+			return nil, "invalid arguments"
+		}
+		var warning string
+		if strings.TrimSpace(params.Parent) != "" {
+			warning = "parent is not recorded; text is typed into the frontmost application"
+		}
+		return []*pb.MacroAction{inputMacroAction(&pb.InputAction{
+			InputType: &pb.InputAction_TypeText{TypeText: &pb.TextInput{
+				Text:      params.Text,
+				CharDelay: params.CharDelay,
+			}},
+		})}, warning
+
+	case "keypress":
+		var params struct {
+			Keys []string `json:"keys"`
+		}
+		if err := json.Unmarshal(c.arguments, &params); err != nil {
+			return nil, "invalid arguments"
+		}
+		keyPress := cuaKeysToKeyPress(params.Keys)
+		if keyPress == nil {
+			return nil, "no valid keys"
+		}
+		return []*pb.MacroAction{inputMacroAction(&pb.InputAction{
+			InputType: &pb.InputAction_PressKey{PressKey: keyPress},
+		})}, ""
+
+	case "scroll":
+		var params struct {
+			X       float64  `json:"x"`
+			Y       float64  `json:"y"`
+			ScrollX float64  `json:"scroll_x"`
+			ScrollY float64  `json:"scroll_y"`
+			Keys    []string `json:"keys"`
+		}
+		if err := json.Unmarshal(c.arguments, &params); err != nil {
+			return nil, "invalid arguments"
+		}
+		return []*pb.MacroAction{inputMacroAction(&pb.InputAction{
+			InputType: &pb.InputAction_Scroll{Scroll: &pb.Scroll{
+				Position:   &typepb.Point{X: params.X, Y: params.Y},
+				Horizontal: params.ScrollX,
+				Vertical:   -params.ScrollY,
+			}},
+		})}, droppedModifiersWarning(params.Keys)
+
+	case "drag":
+		var params struct {
+			Path []struct {
+				X float64 `json:"x"`
+				Y float64 `json:"y"`
+			} `json:"path"`
+			Button   string   `json:"button"`
+			Keys     []string `json:"keys"`
+			Duration float64  `json:"duration"`
+		}
+		if err := json.Unmarshal(c.arguments, &params); err != nil || len(params.Path) < 2 {
+			return nil, "invalid arguments"
+		}
+		// MouseDrag has no waypoints, so like the drag tool itself the macro
+		// goes straight from the first point to the last.
+		start := params.Path[0]
+		end := params.Path[len(params.Path)-1]
+		var warnings []string
+		if len(params.Path) > 2 {
+			warnings = append(warnings, fmt.Sprintf("%d intermediate waypoint(s) dropped; replays as a straight drag", len(params.Path)-2))
+		}
+		if w := droppedModifiersWarning(params.Keys); w != "" {
+			warnings = append(warnings, w)
+		}
+		return []*pb.MacroAction{inputMacroAction(&pb.InputAction{
+			InputType: &pb.InputAction_Drag{Drag: &pb.MouseDrag{
+				StartPosition: &typepb.Point{X: start.X, Y: start.Y},
+				EndPosition:   &typepb.Point{X: end.X, Y: end.Y},
+				Duration:      params.Duration,
+				Button:        mapButtonString(params.Button),
+			}},
+		})}, strings.Join(warnings, "; ")
+
+	case "move":
+		var params struct {
+			X    *float64 `json:"x"`
+			Y    *float64 `json:"y"`
+			Keys []string `json:"keys"`
+		}
+		if err := json.Unmarshal(c.arguments, &params); err != nil || params.X == nil || params.Y == nil {
+			return nil, "invalid arguments"
+		}
+		return []*pb.MacroAction{inputMacroAction(&pb.InputAction{
+			InputType: &pb.InputAction_MoveMouse{MoveMouse: &pb.MouseMove{
+				Position: &typepb.Point{X: *params.X, Y: *params.Y},
+			}},
+		})}, droppedModifiersWarning(params.Keys)
+
+	case "wait":
+		var params struct {
+			Duration float64 `json:"duration"`
+		}
+		if err := json.Unmarshal(c.arguments, &params); err != nil {
+			return nil, "invalid arguments"
+		}
+		if params.Duration <= 0 {
+			params.Duration = 1.0
+		}
+		return []*pb.MacroAction{{
+			Action: &pb.MacroAction_Wait{Wait: &pb.WaitAction{Duration: params.Duration}},
+		}}, ""
+
+	case "click_element":
+		var params struct {
+			ElementID string `json:"element"`
+			Selector  string `json:"selector"`
+		}
+		if err := json.Unmarshal(c.arguments, &params); err != nil {
+			return nil, "invalid arguments"
+		}
+		if params.ElementID == "" {
+			return nil, "selector-based clicks cannot be replayed by the macro executor; use an element ID or coordinates"
+		}
+		return []*pb.MacroAction{{
+			Description: "click element " + params.ElementID,
+			Action: &pb.MacroAction_MethodCall{MethodCall: &pb.MethodCall{
+				Method: "ClickElement",
+				Args:   map[string]string{"elementId": params.ElementID},
+			}},
+		}}, "element IDs are ephemeral; the recorded ID may not resolve when the macro is replayed"
+	}
+
+	return nil, "not recordable, skipped"
+}
+
+// inputMacroAction wraps an input action as a macro action.
+func inputMacroAction(input *pb.InputAction) *pb.MacroAction {
+	return &pb.MacroAction{Action: &pb.MacroAction_Input{Input: input}}
+}
+
+// droppedModifiersWarning reports modifier keys that are not recorded for
+// actions which hold them via a synthetic click.
+func droppedModifiersWarning(keys []string) string {
+	if modifiers, _ := cuaKeysToModifiers(keys); len(modifiers) > 0 {
+		return "modifier keys are not recorded"
+	}
+	return ""
+}
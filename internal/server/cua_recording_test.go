@@ -0,0 +1,279 @@
+// Copyright 2025 Joseph Cumines
+//
+// Tests for the recording tools and tool-call to macro conversion.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	pb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/v1"
+	"github.com/joeycumines/MacosUseSDK/internal/transport"
+	"google.golang.org/protobuf/proto"
+)
+
+// newRecordingTestServer returns a server with the recording tools and a few
+// stub tools that succeed (or fail) without calling the backend.
+func newRecordingTestServer(client pb.MacosUseClient) *MCPServer {
+	s := newTestMCPServer(client)
+	ok := func(call *ToolCall) (*ToolResult, error) { return textResult("ok"), nil }
+	s.tools = map[string]*Tool{
+		"recording_start": {Name: "recording_start", Handler: s.handleRecordingStart},
+		"recording_stop":  {Name: "recording_stop", Handler: s.handleRecordingStop},
+		"click":           {Name: "click", Handler: ok},
+		"type":            {Name: "type", Handler: ok},
+		"screenshot":      {Name: "screenshot", Handler: ok},
+		"open_app":        {Name: "open_app", Handler: ok},
+		"keypress": {Name: "keypress", Handler: func(call *ToolCall) (*ToolResult, error) {
+			return errorResult("keypress failed"), nil
+		}},
+	}
+	return s
+}
+
+func callToolHTTP(t *testing.T, s *MCPServer, name, args string) *transport.Message {
+	t.Helper()
+	params, err := json.Marshal(map[string]any{"name": name, "arguments": json.RawMessage(args)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := s.handleHTTPMessage(&transport.Message{
+		JSONRPC: "2.0",
+		ID:      json.RawMessage(`1`),
+		Method:  "tools/call",
+		Params:  params,
+	})
+	if err != nil {
+		t.Fatalf("handleHTTPMessage(%s) error: %v", name, err)
+	}
+	return resp
+}
+
+func TestRecording_CapturesSuccessfulCalls(t *testing.T) {
+	var got *pb.CreateMacroRequest
+	client := &mockMacroClient{
+		createMacroFunc: func(ctx context.Context, req *pb.CreateMacroRequest) (*pb.Macro, error) {
+			got = req
+			m := proto.CloneOf(req.GetMacro())
+			m.Name = "macros/" + req.MacroId
+			return m, nil
+		},
+	}
+	s := newRecordingTestServer(client)
+
+	// Calls made before recording starts are not captured.
+	callToolHTTP(t, s, "click", `{"x":1,"y":1}`)
+	callToolHTTP(t, s, "recording_start", `{"display_name":"Login"}`)
+	callToolHTTP(t, s, "screenshot", `{}`)
+	callToolHTTP(t, s, "click", `{"x":100,"y":200}`)
+	callToolHTTP(t, s, "type", `{"text":"alice"}`)
+	callToolHTTP(t, s, "keypress", `{"keys":["return"]}`)
+	callToolHTTP(t, s, "open_app", `{"id":"com.apple.Safari"}`)
+	callToolHTTP(t, s, "type", `{"text":"alice"}`)
+	resp := callToolHTTP(t, s, "recording_stop", `{"macro_id":"login","tags":["auth"]}`)
+
+	var result ToolResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("failed to decode result: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected error result: %s", resultText(&result))
+	}
+	if got == nil {
+		t.Fatal("CreateMacro was not called")
+	}
+
+	macro := got.GetMacro()
+	if got.GetMacroId() != "login" || macro.GetDisplayName() != "Login" || len(macro.GetTags()) != 1 {
+		t.Errorf("unexpected macro metadata: id=%q %v", got.GetMacroId(), macro)
+	}
+	if n := len(macro.GetActions()); n != 3 {
+		t.Fatalf("actions = %d, want 3 (click, type, type): %v", n, macro.GetActions())
+	}
+	if click := macro.GetActions()[0].GetInput().GetClick(); click.GetPosition().GetX() != 100 || click.GetClickCount() != 1 {
+		t.Errorf("click not converted: %v", click)
+	}
+	for _, i := range []int{1, 2} {
+		if text := macro.GetActions()[i].GetInput().GetTypeText().GetText(); text != "${text_1}" {
+			t.Errorf("actions[%d] text = %q, want ${text_1}", i, text)
+		}
+	}
+	if params := macro.GetParameters(); len(params) != 1 || params[0].GetKey() != "text_1" || params[0].GetDefaultValue() != "alice" {
+		t.Errorf("unexpected parameters: %v", params)
+	}
+
+	text := resultText(&result)
+	for _, want := range []string{"5 tool calls captured, 3 actions recorded", "open_app (call 4): not recordable", "Macro created", "macros/login"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in result, got:\n%s", want, text)
+		}
+	}
+
+	// The recording has ended, so further calls are not captured.
+	s.recordToolCall("", "click", json.RawMessage(`{"x":1,"y":1}`))
+	s.withSession("", func(c *clientSession) {
+		if c.recording != nil {
+			t.Error("recording should be cleared after recording_stop")
+		}
+	})
+}
+
+func TestRecording_StateErrors(t *testing.T) {
+	s := newRecordingTestServer(nil)
+
+	result, err := s.handleRecordingStop(&ToolCall{Arguments: json.RawMessage(`{}`)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !resultIsError(result) || !resultContains(result, "No recording in progress") {
+		t.Errorf("expected no recording error, got: %s", resultText(result))
+	}
+
+	if result, _ := s.handleRecordingStart(&ToolCall{Arguments: json.RawMessage(`{}`)}); resultIsError(result) {
+		t.Fatalf("unexpected error result: %s", resultText(result))
+	}
+	result, _ = s.handleRecordingStart(&ToolCall{Arguments: json.RawMessage(`{}`)})
+	if !resultIsError(result) || !resultContains(result, "already in progress") {
+		t.Errorf("expected already in progress error, got: %s", resultText(result))
+	}
+
+	s.recordToolCall("", "screenshot", json.RawMessage(`{}`))
+	s.recordToolCall("", "wait_for_element", json.RawMessage(`{"parent":"applications/1","selector":"role=AXButton"}`))
+	s.recordToolCall("", "wait_for_state", json.RawMessage(`{"element":"e1","state":"enabled"}`))
+	result, _ = s.handleRecordingStop(&ToolCall{Arguments: json.RawMessage(`{}`)})
+	if !resultIsError(result) || !resultContains(result, "Nothing recordable was captured") || resultContains(result, "not recordable") {
+		t.Errorf("expected nothing recordable error without notes, got: %s", resultText(result))
+	}
+}
+
+func TestRecording_StopWithoutSaveReturnsDefinition(t *testing.T) {
+	s := newRecordingTestServer(nil)
+
+	if result, _ := s.handleRecordingStart(&ToolCall{Arguments: json.RawMessage(`{}`)}); resultIsError(result) {
+		t.Fatalf("unexpected error result: %s", resultText(result))
+	}
+	s.recordToolCall("", "type", json.RawMessage(`{"text":"hello"}`))
+
+	result, err := s.handleRecordingStop(&ToolCall{Arguments: json.RawMessage(`{"save":false,"parameterize":false,"display_name":"Greet"}`)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resultIsError(result) {
+		t.Fatalf("unexpected error result: %s", resultText(result))
+	}
+	text := resultText(result)
+	for _, want := range []string{"Macro not saved", "Greet", "Definition (JSON):", `"hello"`} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in result, got:\n%s", want, text)
+		}
+	}
+}
+
+func TestRecordedCallToActions(t *testing.T) {
+	tests := []struct {
+		name        string
+		tool        string
+		args        string
+		wantActions int
+		wantWarning string
+		check       func(t *testing.T, actions []*pb.MacroAction)
+	}{
+		{
+			name: "keypress with modifiers", tool: "keypress", args: `{"keys":["cmd","shift","s"]}`, wantActions: 1,
+			check: func(t *testing.T, actions []*pb.MacroAction) {
+				key := actions[0].GetInput().GetPressKey()
+				if key.GetKey() != "s" || len(key.GetModifiers()) != 2 {
+					t.Errorf("unexpected key press: %v", key)
+				}
+			},
+		},
+		{
+			name: "click with modifiers uses button down/up", tool: "click", args: `{"x":5,"y":6,"click_count":2,"keys":["shift"]}`, wantActions: 4,
+			check: func(t *testing.T, actions []*pb.MacroAction) {
+				if actions[0].GetInput().GetButtonDown() == nil || actions[1].GetInput().GetButtonUp() == nil {
+					t.Errorf("expected button down/up pair, got %v", actions)
+				}
+			},
+		},
+		{
+			name: "double click", tool: "double_click", args: `{"x":5,"y":6,"button":"right"}`, wantActions: 1,
+			check: func(t *testing.T, actions []*pb.MacroAction) {
+				click := actions[0].GetInput().GetClick()
+				if click.GetClickCount() != 2 || click.GetClickType() != pb.MouseClick_CLICK_TYPE_RIGHT {
+					t.Errorf("unexpected click: %v", click)
+				}
+			},
+		},
+		{
+			name: "scroll inverts vertical delta", tool: "scroll", args: `{"x":1,"y":2,"scroll_y":3,"keys":["ctrl"]}`, wantActions: 1,
+			wantWarning: "modifier keys are not recorded",
+			check: func(t *testing.T, actions []*pb.MacroAction) {
+				if v := actions[0].GetInput().GetScroll().GetVertical(); v != -3 {
+					t.Errorf("vertical = %v, want -3", v)
+				}
+			},
+		},
+		{
+			name: "drag", tool: "drag", args: `{"path":[{"x":0,"y":0},{"x":10,"y":20}],"button":"right"}`, wantActions: 1,
+			check: func(t *testing.T, actions []*pb.MacroAction) {
+				drag := actions[0].GetInput().GetDrag()
+				if drag.GetEndPosition().GetX() != 10 || drag.GetEndPosition().GetY() != 20 || drag.GetButton() != pb.MouseClick_CLICK_TYPE_RIGHT {
+					t.Errorf("unexpected drag: %v", drag)
+				}
+			},
+		},
+		{
+			name: "three-point drag warns of dropped waypoint", tool: "drag", args: `{"path":[{"x":0,"y":0},{"x":5,"y":5},{"x":10,"y":20}],"keys":["shift"]}`, wantActions: 1,
+			wantWarning: "1 intermediate waypoint(s) dropped; replays as a straight drag; modifier keys are not recorded",
+			check: func(t *testing.T, actions []*pb.MacroAction) {
+				drag := actions[0].GetInput().GetDrag()
+				if drag.GetStartPosition().GetX() != 0 || drag.GetEndPosition().GetX() != 10 || drag.GetEndPosition().GetY() != 20 {
+					t.Errorf("unexpected drag: %v", drag)
+				}
+			},
+		},
+		{
+			name: "wait defaults duration", tool: "wait", args: `{}`, wantActions: 1,
+			check: func(t *testing.T, actions []*pb.MacroAction) {
+				if d := actions[0].GetWait().GetDuration(); d != 1 {
+					t.Errorf("duration = %v, want 1", d)
+				}
+			},
+		},
+		{
+			name: "click element by ID", tool: "click_element", args: `{"element":"elem-1"}`, wantActions: 1,
+			wantWarning: "element IDs are ephemeral",
+			check: func(t *testing.T, actions []*pb.MacroAction) {
+				call := actions[0].GetMethodCall()
+				if call.GetMethod() != "ClickElement" || call.GetArgs()["elementId"] != "elem-1" {
+					t.Errorf("unexpected method call: %v", call)
+				}
+			},
+		},
+		{name: "click element by selector", tool: "click_element", args: `{"selector":"role:AXButton"}`, wantWarning: "selector-based clicks cannot be replayed"},
+		{name: "type with parent", tool: "type", args: `{"text":"x","parent":"applications/1"}`, wantActions: 1, wantWarning: "parent is not recorded"},
+		{name: "invalid click", tool: "click", args: `{"x":1}`, wantWarning: "invalid arguments"},
+		{name: "unsupported tool", tool: "close_app", args: `{}`, wantWarning: "not recordable"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actions, warning := recordedCallToActions(recordedCall{name: tc.tool, arguments: json.RawMessage(tc.args)})
+			if len(actions) != tc.wantActions {
+				t.Fatalf("actions = %d, want %d: %v", len(actions), tc.wantActions, actions)
+			}
+			if tc.wantWarning == "" && warning != "" || !strings.Contains(warning, tc.wantWarning) {
+				t.Errorf("warning = %q, want %q", warning, tc.wantWarning)
+			}
+			if err := validateMacroActions(actions, "actions"); err != nil {
+				t.Errorf("converted actions are invalid: %v", err)
+			}
+			if tc.check != nil {
+				tc.check(t, actions)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	pb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/v1"
@@ -53,9 +54,12 @@ func (s *MCPServer) handleRun(call *ToolCall) (*ToolResult, error) {
 	if scriptTimeout > 0 && scriptTimeout < requestTimeout {
 		effectiveTimeout = scriptTimeout
 	}
-	ctx, cancel := context.WithTimeout(s.ctx, effectiveTimeout)
+	ctx, cancel := context.WithTimeout(call.Context(), effectiveTimeout)
 	defer cancel()
 
+	stop := trackProgress(ctx, effectiveTimeout, fmt.Sprintf("Running %s", params.Type))
+	defer stop()
+
 	switch params.Type {
 	case "shell":
 		return s.runShell(ctx, params.Command, timeout, effectiveTimeout)
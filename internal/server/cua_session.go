@@ -0,0 +1,381 @@
+// Copyright 2025 Joseph Cumines
+//
+// Session tool handlers — session lifecycle, transactions whose rollback
+// discards recorded history (not application state), and session snapshots
+// with operation history
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	pb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// defaultSnapshotHistoryLimit is the default number of history entries rendered by session_snapshot.
+const defaultSnapshotHistoryLimit = 50
+
+// handleSession handles the session tool — unified session lifecycle.
+// Action discriminator: create, get, list, delete.
+func (s *MCPServer) handleSession(call *ToolCall) (*ToolResult, error) {
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
+	defer cancel()
+
+	var params struct {
+		Metadata    map[string]string `json:"metadata"`
+		Action      string            `json:"action"`
+		Name        string            `json:"name"`
+		DisplayName string            `json:"display_name"`
+		SessionID   string            `json:"session_id"`
+		PageToken   string            `json:"page_token"`
+		PageSize    int32             `json:"page_size"`
+		Force       bool              `json:"force"`
+	}
+
+	if err := json.Unmarshal(call.Arguments, &params); err != nil {
+		return errorResultf("Invalid parameters: %v", err), nil
+	}
+
+	if params.Action == "" {
+		return errorResult("action parameter is required (create, get, list, delete)"), nil
+	}
+
+	switch params.Action {
+	case "create":
+		session, err := s.client.CreateSession(ctx, &pb.CreateSessionRequest{
+			Session: &pb.Session{
+				DisplayName: params.DisplayName,
+				Metadata:    params.Metadata,
+			},
+			SessionId: params.SessionID,
+		})
+		if err != nil {
+			return grpcErrorResult(err, "session"), nil
+		}
+		return textResult("Session created\n" + formatSession(session)), nil
+
+	case "get":
+		if params.Name == "" {
+			return errorResult("name parameter is required for get action"), nil
+		}
+		session, err := s.client.GetSession(ctx, &pb.GetSessionRequest{Name: params.Name})
+		if err != nil {
+			return grpcErrorResult(err, "session"), nil
+		}
+		return textResult(formatSession(session)), nil
+
+	case "list":
+		if params.PageSize < 0 {
+			return errorResult("page_size must be non-negative"), nil
+		}
+		resp, err := s.client.ListSessions(ctx, &pb.ListSessionsRequest{
+			PageSize:  params.PageSize,
+			PageToken: params.PageToken,
+		})
+		if err != nil {
+			return grpcErrorResult(err, "session"), nil
+		}
+		if len(resp.Sessions) == 0 {
+			return textResult("No sessions found"), nil
+		}
+		var lines []string
+		for i, session := range resp.Sessions {
+			line := fmt.Sprintf("%d. %s (%s)", i+1, session.Name, sessionStateName(session.State))
+			if session.DisplayName != "" {
+				line += fmt.Sprintf(" %q", session.DisplayName)
+			}
+			if session.TransactionId != "" {
+				line += " transaction: " + session.TransactionId
+			}
+			lines = append(lines, line)
+		}
+		result := fmt.Sprintf("Found %d sessions:\n%s", len(resp.Sessions), strings.Join(lines, "\n"))
+		if resp.NextPageToken != "" {
+			result += fmt.Sprintf("\n\nMore results available. Use page_token: %s", resp.NextPageToken)
+		}
+		return textResult(result), nil
+
+	case "delete":
+		if params.Name == "" {
+			return errorResult("name parameter is required for delete action"), nil
+		}
+		if _, err := s.client.DeleteSession(ctx, &pb.DeleteSessionRequest{Name: params.Name, Force: params.Force}); err != nil {
+			return grpcErrorResult(err, "session"), nil
+		}
+		return textResultf("Session deleted: %s", params.Name), nil
+
+	default:
+		return errorResultf("Unknown action: %s. Valid: create, get, list, delete", params.Action), nil
+	}
+}
+
+// handleTransaction handles the transaction tool — begin, commit, or roll back
+// a transaction within a session. Rolling back truncates the session's
+// operation history to a revision; nothing done to applications is undone.
+func (s *MCPServer) handleTransaction(call *ToolCall) (*ToolResult, error) {
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
+	defer cancel()
+
+	var params struct {
+		Action         string  `json:"action"`
+		Session        string  `json:"session"`
+		TransactionID  string  `json:"transaction_id"`
+		RevisionID     string  `json:"revision_id"`
+		IsolationLevel string  `json:"isolation_level"`
+		Timeout        float64 `json:"timeout"`
+	}
+
+	if err := json.Unmarshal(call.Arguments, &params); err != nil {
+		return errorResultf("Invalid parameters: %v", err), nil
+	}
+
+	if params.Action == "" {
+		return errorResult("action parameter is required (begin, commit, rollback)"), nil
+	}
+	if params.Session == "" {
+		return errorResult("session parameter is required"), nil
+	}
+
+	switch params.Action {
+	case "begin":
+		var isolation pb.BeginTransactionRequest_IsolationLevel
+		switch params.IsolationLevel {
+		case "", "serializable":
+			// Serializable isolation snapshots the session, which is what rollback restores to.
+			isolation = pb.BeginTransactionRequest_ISOLATION_LEVEL_SERIALIZABLE
+		case "read_committed":
+			isolation = pb.BeginTransactionRequest_ISOLATION_LEVEL_READ_COMMITTED
+		default:
+			return errorResultf("Unknown isolation_level: %s. Valid: serializable, read_committed", params.IsolationLevel), nil
+		}
+		if params.Timeout < 0 {
+			return errorResult("timeout must be non-negative"), nil
+		}
+
+		resp, err := s.client.BeginTransaction(ctx, &pb.BeginTransactionRequest{
+			Session:        params.Session,
+			IsolationLevel: isolation,
+			Timeout:        params.Timeout,
+		})
+		if err != nil {
+			return grpcErrorResult(err, "transaction"), nil
+		}
+
+		result := fmt.Sprintf("Transaction started: %s\n  Session: %s", resp.TransactionId, params.Session)
+		if isolation == pb.BeginTransactionRequest_ISOLATION_LEVEL_SERIALIZABLE {
+			result += fmt.Sprintf("\n  Rollback revision: %s", defaultRevisionID(resp.TransactionId))
+		} else {
+			result += "\n  Note: read_committed transactions have no snapshot and cannot be rolled back"
+		}
+		return textResult(result), nil
+
+	case "commit", "rollback":
+		transactionID := params.TransactionID
+		if transactionID == "" {
+			// Default to the session's active transaction.
+			session, err := s.client.GetSession(ctx, &pb.GetSessionRequest{Name: params.Session})
+			if err != nil {
+				return grpcErrorResult(err, "transaction"), nil
+			}
+			if session.TransactionId == "" {
+				return errorResultf("Session %s has no active transaction", params.Session), nil
+			}
+			transactionID = session.TransactionId
+		}
+
+		var tx *pb.Transaction
+		var err error
+		if params.Action == "commit" {
+			tx, err = s.client.CommitTransaction(ctx, &pb.CommitTransactionRequest{
+				Name:          params.Session,
+				TransactionId: transactionID,
+			})
+		} else {
+			revisionID := params.RevisionID
+			if revisionID == "" {
+				revisionID = defaultRevisionID(transactionID)
+			}
+			tx, err = s.client.RollbackTransaction(ctx, &pb.RollbackTransactionRequest{
+				Name:          params.Session,
+				TransactionId: transactionID,
+				RevisionId:    revisionID,
+			})
+		}
+		if err != nil {
+			return grpcErrorResult(err, "transaction"), nil
+		}
+
+		verb := "committed"
+		if params.Action == "rollback" {
+			verb = "rolled back"
+		}
+		result := fmt.Sprintf("Transaction %s: %s\n  State: %s\n  Operations: %d",
+			verb, tx.TransactionId, transactionStateName(tx.State), tx.OperationsCount)
+		if updated := tx.UpdatedSession; updated != nil {
+			result += fmt.Sprintf("\n  Session: %s (%s)", updated.Name, sessionStateName(updated.State))
+		}
+		if params.Action == "rollback" {
+			result += "\n  Note: recorded history was discarded; application state was not reverted"
+		}
+		return textResult(result), nil
+
+	default:
+		return errorResultf("Unknown action: %s. Valid: begin, commit, rollback", params.Action), nil
+	}
+}
+
+// handleSessionSnapshot handles the session_snapshot tool — renders the session
+// state along with its OperationRecord history.
+func (s *MCPServer) handleSessionSnapshot(call *ToolCall) (*ToolResult, error) {
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
+	defer cancel()
+
+	var params struct {
+		Name          string `json:"name"`
+		TransactionID string `json:"transaction_id"`
+		Limit         int    `json:"limit"`
+	}
+
+	if err := json.Unmarshal(call.Arguments, &params); err != nil {
+		return errorResultf("Invalid parameters: %v", err), nil
+	}
+
+	if params.Name == "" {
+		return errorResult("name parameter is required"), nil
+	}
+	if params.Limit < 0 {
+		return errorResult("limit must be non-negative"), nil
+	}
+	if params.Limit == 0 {
+		params.Limit = defaultSnapshotHistoryLimit
+	}
+
+	snapshot, err := s.client.GetSessionSnapshot(ctx, &pb.GetSessionSnapshotRequest{Name: params.Name})
+	if err != nil {
+		return grpcErrorResult(err, "session_snapshot"), nil
+	}
+
+	var sb strings.Builder
+	if snapshot.Session != nil {
+		sb.WriteString(formatSession(snapshot.Session))
+	} else {
+		fmt.Fprintf(&sb, "Session: %s", params.Name)
+	}
+
+	fmt.Fprintf(&sb, "\nApplications (%d)", len(snapshot.Applications))
+	if len(snapshot.Applications) > 0 {
+		sb.WriteString(": " + strings.Join(snapshot.Applications, ", "))
+	}
+	fmt.Fprintf(&sb, "\nObservations (%d)", len(snapshot.Observations))
+	if len(snapshot.Observations) > 0 {
+		sb.WriteString(": " + strings.Join(snapshot.Observations, ", "))
+	}
+
+	history := snapshot.History
+	if params.TransactionID != "" {
+		history = slices.DeleteFunc(slices.Clone(history), func(r *pb.OperationRecord) bool {
+			return r.TransactionId != params.TransactionID
+		})
+	}
+
+	if len(history) == 0 {
+		sb.WriteString("\nHistory: no operations recorded")
+		return textResult(sb.String()), nil
+	}
+
+	failed := 0
+	for _, r := range history {
+		if !r.Success {
+			failed++
+		}
+	}
+
+	// Show the most recent entries; numbering preserves the position in the full history.
+	start := max(0, len(history)-params.Limit)
+	fmt.Fprintf(&sb, "\nHistory (%d operations, %d failed", len(history), failed)
+	if start > 0 {
+		fmt.Fprintf(&sb, ", showing last %d", len(history)-start)
+	}
+	sb.WriteString("):")
+	for i := start; i < len(history); i++ {
+		fmt.Fprintf(&sb, "\n%d. %s", i+1, formatOperationRecord(history[i]))
+	}
+
+	return textResult(sb.String()), nil
+}
+
+// defaultRevisionID returns the snapshot revision created by BeginTransaction
+// for serializable transactions.
+func defaultRevisionID(transactionID string) string {
+	return "snapshot-" + transactionID
+}
+
+// formatSession renders a session as a multi-line summary.
+func formatSession(session *pb.Session) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Session: %s\n  State: %s", session.Name, sessionStateName(session.State))
+	if session.DisplayName != "" {
+		fmt.Fprintf(&sb, "\n  Display name: %s", session.DisplayName)
+	}
+	if session.TransactionId != "" {
+		fmt.Fprintf(&sb, "\n  Transaction: %s", session.TransactionId)
+	}
+	if session.CreateTime != nil {
+		fmt.Fprintf(&sb, "\n  Created: %s", formatTimestamp(session.CreateTime))
+	}
+	if session.LastAccessTime != nil {
+		fmt.Fprintf(&sb, "\n  Last access: %s", formatTimestamp(session.LastAccessTime))
+	}
+	if session.ExpireTime != nil {
+		fmt.Fprintf(&sb, "\n  Expires: %s", formatTimestamp(session.ExpireTime))
+	}
+	if len(session.Metadata) > 0 {
+		var pairs []string
+		for _, k := range slices.Sorted(maps.Keys(session.Metadata)) {
+			pairs = append(pairs, k+"="+session.Metadata[k])
+		}
+		fmt.Fprintf(&sb, "\n  Metadata: %s", strings.Join(pairs, ", "))
+	}
+	return sb.String()
+}
+
+// formatOperationRecord renders a single OperationRecord history entry.
+func formatOperationRecord(r *pb.OperationRecord) string {
+	status := "OK"
+	if !r.Success {
+		status = "FAILED"
+	}
+	line := fmt.Sprintf("[%s] %s %s", status, r.OperationType, r.Resource)
+	if r.OperationTime != nil {
+		line = formatTimestamp(r.OperationTime) + " " + line
+	}
+	if r.TransactionId != "" {
+		line += " (transaction " + r.TransactionId + ")"
+	}
+	if r.Error != "" {
+		line += " — " + truncateText(r.Error)
+	}
+	return line
+}
+
+// formatTimestamp renders a protobuf timestamp as RFC 3339 in UTC.
+func formatTimestamp(ts *timestamppb.Timestamp) string {
+	return ts.AsTime().UTC().Format(time.RFC3339)
+}
+
+// sessionStateName returns the short name of a session state (e.g. "IN_TRANSACTION").
+func sessionStateName(state pb.Session_State) string {
+	return strings.TrimPrefix(state.String(), "STATE_")
+}
+
+// transactionStateName returns the short name of a transaction state (e.g. "ROLLED_BACK").
+func transactionStateName(state pb.Transaction_State) string {
+	return strings.TrimPrefix(state.String(), "STATE_")
+}
@@ -0,0 +1,250 @@
+// Copyright 2025 Joseph Cumines
+//
+// Tests for the session tool handlers (session, transaction, session_snapshot).
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// mockSessionClient implements only the gRPC methods used by the session tools.
+type mockSessionClient struct {
+	pb.MacosUseClient
+
+	createSessionFunc       func(ctx context.Context, req *pb.CreateSessionRequest) (*pb.Session, error)
+	getSessionFunc          func(ctx context.Context, req *pb.GetSessionRequest) (*pb.Session, error)
+	listSessionsFunc        func(ctx context.Context, req *pb.ListSessionsRequest) (*pb.ListSessionsResponse, error)
+	deleteSessionFunc       func(ctx context.Context, req *pb.DeleteSessionRequest) (*emptypb.Empty, error)
+	beginTransactionFunc    func(ctx context.Context, req *pb.BeginTransactionRequest) (*pb.BeginTransactionResponse, error)
+	commitTransactionFunc   func(ctx context.Context, req *pb.CommitTransactionRequest) (*pb.Transaction, error)
+	rollbackTransactionFunc func(ctx context.Context, req *pb.RollbackTransactionRequest) (*pb.Transaction, error)
+	getSessionSnapshotFunc  func(ctx context.Context, req *pb.GetSessionSnapshotRequest) (*pb.SessionSnapshot, error)
+}
+
+func (m *mockSessionClient) CreateSession(ctx context.Context, req *pb.CreateSessionRequest, opts ...grpc.CallOption) (*pb.Session, error) {
+	return m.createSessionFunc(ctx, req)
+}
+
+func (m *mockSessionClient) GetSession(ctx context.Context, req *pb.GetSessionRequest, opts ...grpc.CallOption) (*pb.Session, error) {
+	return m.getSessionFunc(ctx, req)
+}
+
+func (m *mockSessionClient) ListSessions(ctx context.Context, req *pb.ListSessionsRequest, opts ...grpc.CallOption) (*pb.ListSessionsResponse, error) {
+	return m.listSessionsFunc(ctx, req)
+}
+
+func (m *mockSessionClient) DeleteSession(ctx context.Context, req *pb.DeleteSessionRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	return m.deleteSessionFunc(ctx, req)
+}
+
+func (m *mockSessionClient) BeginTransaction(ctx context.Context, req *pb.BeginTransactionRequest, opts ...grpc.CallOption) (*pb.BeginTransactionResponse, error) {
+	return m.beginTransactionFunc(ctx, req)
+}
+
+func (m *mockSessionClient) CommitTransaction(ctx context.Context, req *pb.CommitTransactionRequest, opts ...grpc.CallOption) (*pb.Transaction, error) {
+	return m.commitTransactionFunc(ctx, req)
+}
+
+func (m *mockSessionClient) RollbackTransaction(ctx context.Context, req *pb.RollbackTransactionRequest, opts ...grpc.CallOption) (*pb.Transaction, error) {
+	return m.rollbackTransactionFunc(ctx, req)
+}
+
+func (m *mockSessionClient) GetSessionSnapshot(ctx context.Context, req *pb.GetSessionSnapshotRequest, opts ...grpc.CallOption) (*pb.SessionSnapshot, error) {
+	return m.getSessionSnapshotFunc(ctx, req)
+}
+
+func TestSessionHandlers_InvalidParams(t *testing.T) {
+	s := newTestServer()
+
+	tests := []struct {
+		name       string
+		handler    func(*ToolCall) (*ToolResult, error)
+		args       string
+		wantSubstr string
+	}{
+		{"session missing action", s.handleSession, `{}`, "action parameter is required"},
+		{"session unknown action", s.handleSession, `{"action":"rename"}`, "Unknown action: rename"},
+		{"session get missing name", s.handleSession, `{"action":"get"}`, "name parameter is required for get action"},
+		{"session delete missing name", s.handleSession, `{"action":"delete"}`, "name parameter is required for delete action"},
+		{"session list negative page size", s.handleSession, `{"action":"list","page_size":-1}`, "page_size must be non-negative"},
+		{"session invalid JSON", s.handleSession, `{bad`, "Invalid parameters"},
+		{"transaction missing action", s.handleTransaction, `{"session":"sessions/a"}`, "action parameter is required"},
+		{"transaction missing session", s.handleTransaction, `{"action":"begin"}`, "session parameter is required"},
+		{"transaction unknown action", s.handleTransaction, `{"action":"abort","session":"sessions/a"}`, "Unknown action: abort"},
+		{"transaction unknown isolation", s.handleTransaction, `{"action":"begin","session":"sessions/a","isolation_level":"chaos"}`, "Unknown isolation_level"},
+		{"transaction negative timeout", s.handleTransaction, `{"action":"begin","session":"sessions/a","timeout":-1}`, "timeout must be non-negative"},
+		{"snapshot missing name", s.handleSessionSnapshot, `{}`, "name parameter is required"},
+		{"snapshot negative limit", s.handleSessionSnapshot, `{"name":"sessions/a","limit":-1}`, "limit must be non-negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.handler(&ToolCall{Arguments: json.RawMessage(tt.args)})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !resultIsError(result) {
+				t.Errorf("expected error result, got: %+v", result)
+			}
+			if !resultContains(result, tt.wantSubstr) {
+				t.Errorf("expected result to contain %q, got: %q", tt.wantSubstr, resultText(result))
+			}
+		})
+	}
+}
+
+func TestHandleSession_CreateAndList(t *testing.T) {
+	client := &mockSessionClient{
+		createSessionFunc: func(ctx context.Context, req *pb.CreateSessionRequest) (*pb.Session, error) {
+			if req.SessionId != "form-fill" || req.GetSession().GetMetadata()["ticket"] != "42" {
+				t.Errorf("unexpected request: %+v", req)
+			}
+			return &pb.Session{
+				Name:        "sessions/form-fill",
+				DisplayName: req.GetSession().GetDisplayName(),
+				State:       pb.Session_STATE_ACTIVE,
+				Metadata:    req.GetSession().GetMetadata(),
+			}, nil
+		},
+		listSessionsFunc: func(ctx context.Context, req *pb.ListSessionsRequest) (*pb.ListSessionsResponse, error) {
+			return &pb.ListSessionsResponse{Sessions: []*pb.Session{
+				{Name: "sessions/form-fill", State: pb.Session_STATE_IN_TRANSACTION, TransactionId: "tx-1"},
+			}}, nil
+		},
+	}
+	s := newTestMCPServer(client)
+
+	result, err := s.handleSession(&ToolCall{Arguments: json.RawMessage(`{"action":"create","session_id":"form-fill","display_name":"Form fill","metadata":{"ticket":"42"}}`)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	text := resultText(result)
+	for _, want := range []string{"Session created", "sessions/form-fill", "State: ACTIVE", "Display name: Form fill", "Metadata: ticket=42"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in create result, got: %s", want, text)
+		}
+	}
+
+	result, _ = s.handleSession(&ToolCall{Arguments: json.RawMessage(`{"action":"list"}`)})
+	if !resultContains(result, "1. sessions/form-fill (IN_TRANSACTION) transaction: tx-1") {
+		t.Errorf("unexpected list result: %s", resultText(result))
+	}
+}
+
+func TestHandleTransaction_BeginAndRollback(t *testing.T) {
+	var rollbackReq *pb.RollbackTransactionRequest
+	client := &mockSessionClient{
+		beginTransactionFunc: func(ctx context.Context, req *pb.BeginTransactionRequest) (*pb.BeginTransactionResponse, error) {
+			if req.IsolationLevel != pb.BeginTransactionRequest_ISOLATION_LEVEL_SERIALIZABLE {
+				t.Errorf("isolation = %v, want SERIALIZABLE by default", req.IsolationLevel)
+			}
+			return &pb.BeginTransactionResponse{TransactionId: "tx-1"}, nil
+		},
+		getSessionFunc: func(ctx context.Context, req *pb.GetSessionRequest) (*pb.Session, error) {
+			return &pb.Session{Name: req.Name, State: pb.Session_STATE_IN_TRANSACTION, TransactionId: "tx-1"}, nil
+		},
+		rollbackTransactionFunc: func(ctx context.Context, req *pb.RollbackTransactionRequest) (*pb.Transaction, error) {
+			rollbackReq = req
+			return &pb.Transaction{
+				TransactionId:   req.TransactionId,
+				State:           pb.Transaction_STATE_ROLLED_BACK,
+				OperationsCount: 3,
+				UpdatedSession:  &pb.Session{Name: req.Name, State: pb.Session_STATE_ACTIVE},
+			}, nil
+		},
+	}
+	s := newTestMCPServer(client)
+
+	result, _ := s.handleTransaction(&ToolCall{Arguments: json.RawMessage(`{"action":"begin","session":"sessions/a"}`)})
+	if !resultContains(result, "Transaction started: tx-1") || !resultContains(result, "Rollback revision: snapshot-tx-1") {
+		t.Errorf("unexpected begin result: %s", resultText(result))
+	}
+
+	// transaction_id and revision_id default to the active transaction and its snapshot.
+	result, _ = s.handleTransaction(&ToolCall{Arguments: json.RawMessage(`{"action":"rollback","session":"sessions/a"}`)})
+	if resultIsError(result) {
+		t.Fatalf("unexpected error result: %s", resultText(result))
+	}
+	if rollbackReq.TransactionId != "tx-1" || rollbackReq.RevisionId != "snapshot-tx-1" {
+		t.Errorf("unexpected rollback request: %+v", rollbackReq)
+	}
+	text := resultText(result)
+	for _, want := range []string{"Transaction rolled back: tx-1", "State: ROLLED_BACK", "Operations: 3", "Session: sessions/a (ACTIVE)", "application state was not reverted"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in rollback result, got: %s", want, text)
+		}
+	}
+}
+
+func TestHandleTransaction_CommitWithoutActiveTransaction(t *testing.T) {
+	client := &mockSessionClient{
+		getSessionFunc: func(ctx context.Context, req *pb.GetSessionRequest) (*pb.Session, error) {
+			return &pb.Session{Name: req.Name, State: pb.Session_STATE_ACTIVE}, nil
+		},
+	}
+	s := newTestMCPServer(client)
+
+	result, _ := s.handleTransaction(&ToolCall{Arguments: json.RawMessage(`{"action":"commit","session":"sessions/a"}`)})
+	if !resultIsError(result) || !resultContains(result, "has no active transaction") {
+		t.Errorf("expected no-active-transaction error, got: %s", resultText(result))
+	}
+}
+
+func TestHandleSessionSnapshot_History(t *testing.T) {
+	ts := timestamppb.New(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	client := &mockSessionClient{
+		getSessionSnapshotFunc: func(ctx context.Context, req *pb.GetSessionSnapshotRequest) (*pb.SessionSnapshot, error) {
+			return &pb.SessionSnapshot{
+				Session:      &pb.Session{Name: req.Name, State: pb.Session_STATE_IN_TRANSACTION, TransactionId: "tx-2"},
+				Applications: []string{"applications/1"},
+				History: []*pb.OperationRecord{
+					{OperationTime: ts, OperationType: "open_app", Resource: "applications/1", Success: true},
+					{OperationType: "type", Resource: "applications/1", Success: true, TransactionId: "tx-2"},
+					{OperationType: "click", Resource: "applications/1", Success: false, Error: "element not found", TransactionId: "tx-2"},
+				},
+			}, nil
+		},
+	}
+	s := newTestMCPServer(client)
+
+	result, _ := s.handleSessionSnapshot(&ToolCall{Arguments: json.RawMessage(`{"name":"sessions/a","limit":2}`)})
+	text := resultText(result)
+	for _, want := range []string{
+		"Session: sessions/a",
+		"Applications (1): applications/1",
+		"Observations (0)",
+		"History (3 operations, 1 failed, showing last 2):",
+		"2. [OK] type applications/1 (transaction tx-2)",
+		"3. [FAILED] click applications/1 (transaction tx-2) — element not found",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in snapshot, got:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "open_app") {
+		t.Errorf("limit should hide the oldest entry, got:\n%s", text)
+	}
+
+	result, _ = s.handleSessionSnapshot(&ToolCall{Arguments: json.RawMessage(`{"name":"sessions/a","transaction_id":"tx-none"}`)})
+	if !resultContains(result, "History: no operations recorded") {
+		t.Errorf("expected empty filtered history, got:\n%s", resultText(result))
+	}
+}
+
+func TestFormatOperationRecord(t *testing.T) {
+	ts := timestamppb.New(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	got := formatOperationRecord(&pb.OperationRecord{OperationTime: ts, OperationType: "click", Resource: "applications/1", Success: true})
+	if want := "2025-01-02T03:04:05Z [OK] click applications/1"; got != want {
+		t.Errorf("formatOperationRecord() = %q, want %q", got, want)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"math"
 	"reflect"
 	"strings"
@@ -21,6 +22,7 @@ import (
 	pb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/v1"
 	"github.com/joeycumines/MacosUseSDK/internal/config"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
 )
 
 // newTestServer creates an MCPServer suitable for validation-only tests.
@@ -549,102 +551,62 @@ func TestCUAHandleFindElements_InvalidParams(t *testing.T) {
 	}
 }
 
-// TestCUAHandleFindElements_MultipleCriteriaWarning verifies H2:
-// when multiple criteria are provided, a warning is included noting
-// only one is used (due to proto oneof).
-func TestCUAHandleFindElements_MultipleCriteriaWarning(t *testing.T) {
+// TestCUAHandleFindElements_MultipleCriteriaCombined verifies that every
+// criterion provided, flat or by selector, is sent combined with AND rather
+// than all but one being dropped.
+func TestCUAHandleFindElements_MultipleCriteriaCombined(t *testing.T) {
+	role := &typepb.ElementSelector{Criteria: &typepb.ElementSelector_Role{Role: "AXButton"}}
+	text := &typepb.ElementSelector{Criteria: &typepb.ElementSelector_Text{Text: "OK"}}
+	textContains := &typepb.ElementSelector{Criteria: &typepb.ElementSelector_TextContains{TextContains: "save"}}
+	regex := &typepb.ElementSelector{Criteria: &typepb.ElementSelector_TextRegex{TextRegex: "^Save"}}
+
 	tests := []struct {
-		name         string
-		role         string
-		text         string
-		textContains string
-		wantCount    int
-		wantWarning  bool
-		wantCriteria string // which criterion is used
+		name string
+		args map[string]string
+		want *typepb.ElementSelector
 	}{
 		{
-			name:        "single role criterion",
-			role:        "AXButton",
-			wantCount:   1,
-			wantWarning: false,
+			name: "no criteria",
+			args: map[string]string{},
 		},
 		{
-			name:        "single text criterion",
-			text:        "OK",
-			wantCount:   1,
-			wantWarning: false,
+			name: "single role criterion",
+			args: map[string]string{"role": "AXButton"},
+			want: role,
 		},
 		{
-			name:         "single text_contains criterion",
-			textContains: "save",
-			wantCount:    1,
-			wantWarning:  false,
+			name: "single text_contains criterion",
+			args: map[string]string{"text_contains": "save"},
+			want: textContains,
 		},
 		{
-			name:         "role and text — uses role (H2 warning)",
-			role:         "AXButton",
-			text:         "OK",
-			wantCount:    2,
-			wantWarning:  true,
-			wantCriteria: "role",
+			name: "single selector",
+			args: map[string]string{"selector": `text~"^Save"`},
+			want: regex,
 		},
 		{
-			name:         "role and text_contains — uses role (H2 warning)",
-			role:         "AXButton",
-			textContains: "save",
-			wantCount:    2,
-			wantWarning:  true,
-			wantCriteria: "role",
+			name: "role and text",
+			args: map[string]string{"role": "AXButton", "text": "OK"},
+			want: combineSelectors(role, text),
 		},
 		{
-			name:         "all three criteria — uses role (H2 warning)",
-			role:         "AXButton",
-			text:         "OK",
-			textContains: "save",
-			wantCount:    3,
-			wantWarning:  true,
-			wantCriteria: "role",
+			name: "all three flat criteria",
+			args: map[string]string{"role": "AXButton", "text": "OK", "text_contains": "save"},
+			want: combineSelectors(role, text, textContains),
 		},
 		{
-			name:        "no criteria",
-			wantCount:   0,
-			wantWarning: false,
+			name: "selector and role",
+			args: map[string]string{"selector": `text~"^Save"`, "role": "AXButton"},
+			want: combineSelectors(regex, role),
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			providedCriteria := 0
-			if tt.role != "" {
-				providedCriteria++
-			}
-			if tt.text != "" {
-				providedCriteria++
-			}
-			if tt.textContains != "" {
-				providedCriteria++
-			}
-
-			if providedCriteria != tt.wantCount {
-				t.Errorf("criteria count = %d, want %d", providedCriteria, tt.wantCount)
-			}
-
-			hasWarning := providedCriteria > 1
-			if hasWarning != tt.wantWarning {
-				t.Errorf("hasWarning = %v, want %v", hasWarning, tt.wantWarning)
-			}
-
-			if !tt.wantWarning {
-				return
-			}
-
-			// Exercise the handler and assert the reported criterion matches the
-			// actual selector priority (role > text > text_contains).
+			var got *typepb.ElementSelector
 			mock := &mockMacosUseClient{
 				findElementsFunc: func(_ context.Context, req *pb.FindElementsRequest) (*pb.FindElementsResponse, error) {
-					if req.Parent != "applications/1" {
-						t.Errorf("FindElements Parent = %q, want applications/1", req.Parent)
-					}
+					got = req.Selector
 					return &pb.FindElementsResponse{
 						Elements: []*typepb.Element{{ElementId: "btn1", Role: "AXButton"}},
 					}, nil
@@ -652,26 +614,38 @@ func TestCUAHandleFindElements_MultipleCriteriaWarning(t *testing.T) {
 			}
 			s := newTestMCPServer(mock)
 
-			args, _ := json.Marshal(map[string]string{
-				"parent":        "applications/1",
-				"role":          tt.role,
-				"text":          tt.text,
-				"text_contains": tt.textContains,
-			})
-			call := &ToolCall{Name: "find_elements", Arguments: args}
-			result, err := s.cuaHandleFindElements(call)
+			args := map[string]string{"parent": "applications/1"}
+			maps.Copy(args, tt.args)
+			raw, _ := json.Marshal(args)
+			result, err := s.cuaHandleFindElements(&ToolCall{Name: "find_elements", Arguments: raw})
 			if err != nil {
 				t.Fatalf("cuaHandleFindElements returned error: %v", err)
 			}
-
-			wantText := "Using " + tt.wantCriteria
-			if !resultContains(result, wantText) {
-				t.Errorf("result does not report %q: %s", wantText, resultText(result))
+			if resultIsError(result) {
+				t.Fatalf("unexpected error result: %s", resultText(result))
+			}
+			if !proto.Equal(got, tt.want) {
+				t.Errorf("FindElements Selector = %v, want %v", got, tt.want)
+			}
+			if resultContains(result, "Other criteria were ignored") {
+				t.Errorf("result reports ignored criteria: %s", resultText(result))
 			}
 		})
 	}
 }
 
+func TestCUAHandleFindElements_InvalidSelector(t *testing.T) {
+	s := newTestMCPServer(&mockMacosUseClient{})
+	call := &ToolCall{Name: "find_elements", Arguments: json.RawMessage(`{"parent":"applications/1","selector":"role:AXButton and"}`)}
+	result, err := s.cuaHandleFindElements(call)
+	if err != nil {
+		t.Fatalf("cuaHandleFindElements returned error: %v", err)
+	}
+	if !resultIsError(result) || !resultContains(result, "Invalid selector") {
+		t.Errorf("expected Invalid selector error, got: %s", resultText(result))
+	}
+}
+
 // --- cuaHandleListWindows — pagination params accepted ---
 
 func TestCUAHandleListWindows_InvalidParams(t *testing.T) {
@@ -1588,7 +1562,7 @@ func TestCUAHandleClickElement_InvalidParams(t *testing.T) {
 			name:       "missing target",
 			args:       `{"parent":"applications/1/windows/1"}`,
 			wantError:  true,
-			wantSubstr: "element or selector parameter is required",
+			wantSubstr: "element, selector or path parameter is required",
 		},
 		{
 			name:       "missing parent only",
@@ -1880,13 +1854,13 @@ func TestCUAHandleReadElement_InvalidParams(t *testing.T) {
 			name:       "missing element parameter",
 			args:       `{}`,
 			wantError:  true,
-			wantSubstr: "element parameter is required",
+			wantSubstr: "element or path parameter is required",
 		},
 		{
 			name:       "empty element parameter",
 			args:       `{"element":""}`,
 			wantError:  true,
-			wantSubstr: "element parameter is required",
+			wantSubstr: "element or path parameter is required",
 		},
 		{
 			name:       "invalid JSON",
@@ -17,7 +17,7 @@ import (
 
 // handleFocusWindow handles the focus_window tool — bring a window to the front.
 func (s *MCPServer) cuaHandleFocusWindow(call *ToolCall) (*ToolResult, error) {
-	ctx, cancel := context.WithTimeout(s.ctx, time.Duration(s.cfg.RequestTimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
 	defer cancel()
 
 	var params struct {
@@ -43,7 +43,7 @@ func (s *MCPServer) cuaHandleFocusWindow(call *ToolCall) (*ToolResult, error) {
 // handleMoveWindow handles the move_window tool — move a window to new coordinates.
 // Coordinates use Global Display Coordinates (top-left origin).
 func (s *MCPServer) cuaHandleMoveWindow(call *ToolCall) (*ToolResult, error) {
-	ctx, cancel := context.WithTimeout(s.ctx, time.Duration(s.cfg.RequestTimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
 	defer cancel()
 
 	var params struct {
@@ -78,7 +78,7 @@ func (s *MCPServer) cuaHandleMoveWindow(call *ToolCall) (*ToolResult, error) {
 
 // handleResizeWindow handles the resize_window tool — resize a window.
 func (s *MCPServer) cuaHandleResizeWindow(call *ToolCall) (*ToolResult, error) {
-	ctx, cancel := context.WithTimeout(s.ctx, time.Duration(s.cfg.RequestTimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
 	defer cancel()
 
 	var params struct {
@@ -116,7 +116,7 @@ func (s *MCPServer) cuaHandleResizeWindow(call *ToolCall) (*ToolResult, error) {
 
 // handleListWindows handles the list_windows tool — list open windows.
 func (s *MCPServer) cuaHandleListWindows(call *ToolCall) (*ToolResult, error) {
-	ctx, cancel := context.WithTimeout(s.ctx, time.Duration(s.cfg.RequestTimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(call.Context(), time.Duration(s.cfg.RequestTimeout)*time.Second)
 	defer cancel()
 
 	var params struct {
@@ -0,0 +1,690 @@
+// Copyright 2025 Joseph Cumines
+//
+// Element path queries — XPath-like paths over the accessibility tree
+
+package server
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	typepb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/type"
+	pb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// elementPathSyntax summarizes the path language for tool schemas.
+const elementPathSyntax = `XPath-like path over the accessibility tree of the parent application or window, e.g. //AXGroup[text:"Shipping Address"]//AXTextField[1]. Steps are a role or *, joined by / (child) or // (descendant); ancestor::ROLE, parent::ROLE and .. go up. [N] picks the Nth match under each parent (nth-of-type); other [...] predicates are selectors`
+
+// defaultPathPageSize is the number of path matches find_elements returns if
+// page_size is unset, matching the FindElements default.
+const defaultPathPageSize = 100
+
+// mainWindowPathIndex is the Element.path index of an application's main
+// window, as assigned by the traversal.
+const mainWindowPathIndex = -10000
+
+// pathAxis is the direction a path step moves in from each context element.
+type pathAxis int
+
+const (
+	axisChild pathAxis = iota
+	axisDescendant
+	axisParent
+	axisAncestor
+)
+
+// pathAxes maps the axis names accepted before "::" to their axes.
+var pathAxes = map[string]pathAxis{
+	"child":      axisChild,
+	"descendant": axisDescendant,
+	"parent":     axisParent,
+	"ancestor":   axisAncestor,
+}
+
+// elementPath is a parsed path query.
+//
+// A path is a sequence of steps, each a role (or * for any) and optional
+// predicates in brackets, joined by / to select children, or by // to select
+// descendants. A step may name its axis, e.g. ancestor::AXGroup, and ".."
+// selects the parent. A path not starting with / matches anywhere in the tree,
+// as if it started with //. Predicates are either a position N, selecting the
+// Nth match under each context element as with XPath (so //AXTextField[2] is
+// every AXTextField that is the second of its parent's), or a selector in the
+// parseElementSelector language. Ancestors are numbered nearest first.
+type elementPath struct {
+	steps []pathStep
+	// regexps holds the compiled text_regex predicates, by pattern.
+	regexps map[string]*regexp.Regexp
+}
+
+// pathStep selects the elements along axis from each context element that
+// have role and satisfy every predicate.
+type pathStep struct {
+	// deep applies the step from every descendant of the context elements, as
+	// well as the elements themselves, as written with //.
+	deep       bool
+	axis       pathAxis
+	role       string // empty matches any role
+	predicates []pathPredicate
+}
+
+// pathPredicate filters a step's matches: to the index'th (1-based), if
+// index is set, otherwise to those matching selector.
+type pathPredicate struct {
+	index    int
+	selector *typepb.ElementSelector
+}
+
+// parseElementPath parses a path query.
+func parseElementPath(path string) (*elementPath, error) {
+	p := &pathParser{src: path}
+	p.skipSpace()
+	if p.pos == len(p.src) {
+		return nil, fmt.Errorf("path is empty; use e.g. //AXWindow//AXButton[text:OK]")
+	}
+
+	ep := &elementPath{regexps: make(map[string]*regexp.Regexp)}
+	deep := true
+	if !p.accept("//") && p.accept("/") {
+		deep = false
+	}
+	for {
+		step, err := p.parseStep(ep)
+		if err != nil {
+			return nil, err
+		}
+		step.deep = deep
+		ep.steps = append(ep.steps, step)
+
+		p.skipSpace()
+		switch {
+		case p.pos == len(p.src):
+			return ep, nil
+		case p.accept("//"):
+			deep = true
+		case p.accept("/"):
+			deep = false
+		default:
+			return nil, p.errorf("unexpected %q; join steps with / or //", p.src[p.pos:])
+		}
+	}
+}
+
+// pathParser parses a path string.
+type pathParser struct {
+	src string
+	pos int
+}
+
+// errorf returns a parse error at the current position (1-based).
+func (p *pathParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid path at position %d: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *pathParser) skipSpace() {
+	for p.pos < len(p.src) && isSelectorSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+// accept consumes s if it is next.
+func (p *pathParser) accept(s string) bool {
+	if !strings.HasPrefix(p.src[p.pos:], s) {
+		return false
+	}
+	p.pos += len(s)
+	return true
+}
+
+// name consumes and returns a role or axis name, which may be empty.
+func (p *pathParser) name() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			break
+		}
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// parseStep parses: ".." | [axis "::"] (role | "*") { "[" predicate "]" }.
+func (p *pathParser) parseStep(ep *elementPath) (pathStep, error) {
+	p.skipSpace()
+	step := pathStep{axis: axisChild}
+	if p.accept("..") {
+		step.axis = axisParent
+		return step, nil
+	}
+
+	start := p.pos
+	name := p.name()
+	if p.accept("::") {
+		axis, ok := pathAxes[strings.ToLower(name)]
+		if !ok {
+			p.pos = start
+			return step, p.errorf("unknown axis %q; use child, descendant, parent or ancestor", name)
+		}
+		step.axis = axis
+		name = p.name()
+	}
+	switch {
+	case name != "":
+		step.role = name
+	case p.accept("*"):
+	case p.pos == len(p.src):
+		return step, p.errorf("expected a role or * after %q", strings.TrimSpace(p.src))
+	default:
+		return step, p.errorf("unexpected %q; expected a role (e.g. AXButton) or *", p.src[p.pos:p.pos+1])
+	}
+
+	for p.pos < len(p.src) && p.src[p.pos] == '[' {
+		predicate, err := p.parsePredicate(ep)
+		if err != nil {
+			return step, err
+		}
+		step.predicates = append(step.predicates, predicate)
+	}
+	return step, nil
+}
+
+// parsePredicate parses a bracketed position or selector. Brackets within
+// quoted selector values are ignored.
+func (p *pathParser) parsePredicate(ep *elementPath) (pathPredicate, error) {
+	open := p.pos
+	var quote byte
+	for i := open + 1; i < len(p.src); i++ {
+		c := p.src[i]
+		switch {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ']':
+			p.pos = i + 1
+			return p.predicate(ep, open, p.src[open+1:i])
+		}
+	}
+	return pathPredicate{}, p.errorf("missing ] to close the [ at position %d", open+1)
+}
+
+// predicate parses the content of the predicate opened at open.
+func (p *pathParser) predicate(ep *elementPath, open int, content string) (pathPredicate, error) {
+	if index, err := strconv.Atoi(strings.TrimSpace(content)); err == nil {
+		if index < 1 {
+			p.pos = open
+			return pathPredicate{}, p.errorf("positions start at 1, got [%d]", index)
+		}
+		return pathPredicate{index: index}, nil
+	}
+	selector, err := parseElementSelector(content)
+	if err == nil {
+		err = compileSelectorRegexps(selector, ep.regexps)
+	}
+	if err != nil {
+		p.pos = open
+		return pathPredicate{}, p.errorf("in [%s]: %v", content, err)
+	}
+	return pathPredicate{selector: selector}, nil
+}
+
+// compileSelectorRegexps compiles the text_regex patterns in selector into
+// regexps, so that invalid patterns are reported up front.
+func compileSelectorRegexps(selector *typepb.ElementSelector, regexps map[string]*regexp.Regexp) error {
+	switch criteria := selector.GetCriteria().(type) {
+	case *typepb.ElementSelector_TextRegex:
+		if _, ok := regexps[criteria.TextRegex]; ok {
+			return nil
+		}
+		re, err := regexp.Compile(criteria.TextRegex)
+		if err != nil {
+			return fmt.Errorf("invalid regular expression %q: %v", criteria.TextRegex, err)
+		}
+		regexps[criteria.TextRegex] = re
+	case *typepb.ElementSelector_Compound:
+		for _, sub := range criteria.Compound.GetSelectors() {
+			if err := compileSelectorRegexps(sub, regexps); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// matches reports whether element matches selector, as the server's
+// ElementLocator would.
+func (ep *elementPath) matches(element *typepb.Element, selector *typepb.ElementSelector) bool {
+	switch criteria := selector.GetCriteria().(type) {
+	case *typepb.ElementSelector_Role:
+		return rolesEqual(element.GetRole(), criteria.Role)
+	case *typepb.ElementSelector_Text:
+		return element.GetText() == criteria.Text
+	case *typepb.ElementSelector_TextContains:
+		return element.Text != nil && strings.Contains(element.GetText(), criteria.TextContains)
+	case *typepb.ElementSelector_TextRegex:
+		return element.Text != nil && ep.regexps[criteria.TextRegex].MatchString(element.GetText())
+	case *typepb.ElementSelector_Position:
+		if element.X == nil || element.Y == nil {
+			return false
+		}
+		x, y := element.GetX(), element.GetY()
+		if element.Width != nil && element.Height != nil {
+			x += element.GetWidth() / 2
+			y += element.GetHeight() / 2
+		}
+		position := criteria.Position
+		return math.Hypot(x-position.GetX(), y-position.GetY()) <= position.GetTolerance()
+	case *typepb.ElementSelector_Attributes:
+		for key, want := range criteria.Attributes.GetAttributes() {
+			if got, ok := element.GetAttributes()[key]; !ok || got != want {
+				return false
+			}
+		}
+		return true
+	case *typepb.ElementSelector_Compound:
+		selectors := criteria.Compound.GetSelectors()
+		switch criteria.Compound.GetOperator() {
+		case typepb.CompoundSelector_OPERATOR_AND, typepb.CompoundSelector_OPERATOR_UNSPECIFIED:
+			for _, sub := range selectors {
+				if !ep.matches(element, sub) {
+					return false
+				}
+			}
+			return true
+		case typepb.CompoundSelector_OPERATOR_OR:
+			for _, sub := range selectors {
+				if ep.matches(element, sub) {
+					return true
+				}
+			}
+			return false
+		case typepb.CompoundSelector_OPERATOR_NOT:
+			// NOT is true if any sub-selector is false.
+			for _, sub := range selectors {
+				if !ep.matches(element, sub) {
+					return true
+				}
+			}
+			return false
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// rolesEqual compares roles by their canonical names, ignoring case and any
+// description the traversal appends, e.g. "AXTextArea (text entry area)".
+func rolesEqual(a, b string) bool {
+	return strings.EqualFold(canonicalRole(a), canonicalRole(b))
+}
+
+// canonicalRole strips the description the traversal appends to roles, e.g.
+// "AXButton (close button)" is "AXButton".
+func canonicalRole(role string) string {
+	role, _, _ = strings.Cut(role, "(")
+	return strings.TrimSpace(role)
+}
+
+// elementTree is the hierarchy of a traversal's elements, rebuilt from their
+// paths.
+type elementTree struct {
+	// root is a virtual parent of the top-level elements.
+	root  *elementNode
+	nodes []*elementNode // in document order
+}
+
+// elementNode is an element in an elementTree.
+type elementNode struct {
+	element  *typepb.Element // nil for the root
+	parent   *elementNode
+	children []*elementNode
+	order    int // index in document order
+}
+
+// newElementTree builds the hierarchy of elements. The traversal leaves out
+// elements it filters, e.g. untitled groups, so each element's parent is its
+// nearest ancestor that was returned.
+func newElementTree(elements []*typepb.Element) *elementTree {
+	sorted := slices.Clone(elements)
+	slices.SortStableFunc(sorted, func(a, b *typepb.Element) int {
+		return comparePaths(a.GetPath(), b.GetPath())
+	})
+
+	t := &elementTree{root: &elementNode{order: -1}}
+	byPath := make(map[string]*elementNode, len(sorted))
+	for _, element := range sorted {
+		key := pathKey(element.GetPath())
+		if _, ok := byPath[key]; ok {
+			continue
+		}
+		node := &elementNode{element: element, parent: t.root, order: len(t.nodes)}
+		for n := len(element.GetPath()) - 1; n >= 0; n-- {
+			if parent, ok := byPath[pathKey(element.GetPath()[:n])]; ok {
+				node.parent = parent
+				break
+			}
+		}
+		node.parent.children = append(node.parent.children, node)
+		byPath[key] = node
+		t.nodes = append(t.nodes, node)
+	}
+	return t
+}
+
+// pathKey encodes an Element.path as a map key.
+func pathKey(path []int32) string {
+	var b strings.Builder
+	for i, index := range path {
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(strconv.Itoa(int(index)))
+	}
+	return b.String()
+}
+
+// comparePaths orders Element.paths in document order: the order the
+// traversal visits elements, parents before their descendants.
+func comparePaths(a, b []int32) int {
+	for i := range min(len(a), len(b)) {
+		if c := cmp.Compare(pathIndexOrder(a[i]), pathIndexOrder(b[i])); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+// pathIndexOrder ranks an Element.path index in the order the traversal
+// visits them: windows (-1, -2, ...), then the main window, then children
+// (0, 1, ...).
+func pathIndexOrder(index int32) int64 {
+	switch {
+	case index == mainWindowPathIndex:
+		return -1
+	case index < 0:
+		return math.MinInt32 - int64(index)
+	default:
+		return int64(index)
+	}
+}
+
+// query returns the elements path matches, in document order.
+func (t *elementTree) query(path *elementPath) []*elementNode {
+	context := []*elementNode{t.root}
+	for _, step := range path.steps {
+		if step.deep {
+			context = withDescendants(context)
+		}
+		var next []*elementNode
+		seen := make(map[*elementNode]bool)
+		for _, node := range context {
+			for _, match := range path.apply(step, node) {
+				if !seen[match] {
+					seen[match] = true
+					next = append(next, match)
+				}
+			}
+		}
+		slices.SortFunc(next, func(a, b *elementNode) int { return cmp.Compare(a.order, b.order) })
+		context = next
+	}
+	// The root is not an element, though parent:: and ancestor:: can reach it.
+	return slices.DeleteFunc(context, func(node *elementNode) bool { return node.element == nil })
+}
+
+// withDescendants returns nodes and all their descendants, without repeats.
+func withDescendants(nodes []*elementNode) []*elementNode {
+	var all []*elementNode
+	seen := make(map[*elementNode]bool)
+	var walk func(node *elementNode)
+	walk = func(node *elementNode) {
+		if seen[node] {
+			return
+		}
+		seen[node] = true
+		all = append(all, node)
+		for _, child := range node.children {
+			walk(child)
+		}
+	}
+	for _, node := range nodes {
+		walk(node)
+	}
+	return all
+}
+
+// apply returns the elements step selects from node, in axis order: document
+// order, or nearest first for ancestors.
+func (ep *elementPath) apply(step pathStep, node *elementNode) []*elementNode {
+	var candidates []*elementNode
+	switch step.axis {
+	case axisChild:
+		candidates = node.children
+	case axisDescendant:
+		candidates = withDescendants(node.children)
+	case axisParent:
+		if node.parent != nil {
+			candidates = []*elementNode{node.parent}
+		}
+	case axisAncestor:
+		for ancestor := node.parent; ancestor != nil; ancestor = ancestor.parent {
+			candidates = append(candidates, ancestor)
+		}
+	}
+
+	var matches []*elementNode
+	for _, candidate := range candidates {
+		if candidate.element != nil && (step.role == "" || rolesEqual(candidate.element.GetRole(), step.role)) {
+			matches = append(matches, candidate)
+		}
+	}
+	for _, predicate := range step.predicates {
+		if predicate.index > 0 {
+			if predicate.index > len(matches) {
+				return nil
+			}
+			matches = matches[predicate.index-1 : predicate.index]
+			continue
+		}
+		matches = slices.DeleteFunc(matches, func(match *elementNode) bool {
+			return !ep.matches(match.element, predicate.selector)
+		})
+	}
+	return matches
+}
+
+// pathMatch is an element matched by a path query, and its ID, which is empty
+// if the element could not be found again to register it.
+type pathMatch struct {
+	element *typepb.Element
+	id      string
+}
+
+// findElementsByPath traverses the application of parent and returns the
+// first limit elements path matches (all if limit is 0), and the total count.
+// If parent is a window, path is matched against the window's subtree alone,
+// so the window is its top-level element.
+func (s *MCPServer) findElementsByPath(ctx context.Context, parent string, path *elementPath, limit int) ([]pathMatch, int, error) {
+	app := fmt.Sprintf("applications/%d", parseParentPID(parent))
+	resp, err := s.client.TraverseAccessibility(ctx, &pb.TraverseAccessibilityRequest{Name: app})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tree := newElementTree(resp.GetElements())
+	if window := extractWindowFromParent(parent); window != "" {
+		// As in get_accessibility_tree, the window's subtree is found by
+		// matching its bounds against the top-level windows.
+		w, err := s.client.GetWindow(ctx, &pb.GetWindowRequest{Name: window})
+		if err != nil {
+			return nil, 0, err
+		}
+		node := windowNode(tree, w)
+		if node == nil {
+			return nil, 0, status.Errorf(codes.NotFound, "window %s was not found in the accessibility tree; it may be minimized or on another Space", window)
+		}
+		subtree := withDescendants([]*elementNode{node})
+		elements := make([]*typepb.Element, len(subtree))
+		for i, n := range subtree {
+			elements[i] = n.element
+		}
+		tree = newElementTree(elements)
+	}
+
+	nodes := tree.query(path)
+	total := len(nodes)
+	if limit > 0 && len(nodes) > limit {
+		nodes = nodes[:limit]
+	}
+	matches := make([]pathMatch, len(nodes))
+	elements := make([]*typepb.Element, len(nodes))
+	for i, node := range nodes {
+		matches[i].element = node.element
+		elements[i] = node.element
+	}
+
+	ids, err := s.resolveElementIDs(ctx, app, elements)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range matches {
+		matches[i].id = ids[pathKey(matches[i].element.GetPath())]
+	}
+	return matches, total, nil
+}
+
+// resolveElementIDs returns the IDs of traversed elements, by path.
+// TraverseAccessibility doesn't register the elements it returns, so they are
+// found again with FindElements, by role and position, and matched by path.
+func (s *MCPServer) resolveElementIDs(ctx context.Context, app string, elements []*typepb.Element) (map[string]string, error) {
+	ids := make(map[string]string, len(elements))
+	if len(elements) == 0 {
+		return ids, nil
+	}
+
+	wanted := make(map[string]bool, len(elements))
+	criteria := make([]*typepb.ElementSelector, 0, len(elements))
+	for _, element := range elements {
+		wanted[pathKey(element.GetPath())] = true
+		criteria = append(criteria, locateSelector(element))
+	}
+	selector := compoundSelector(typepb.CompoundSelector_OPERATOR_OR, criteria)
+
+	var pageToken string
+	for len(ids) < len(wanted) {
+		resp, err := s.client.FindElements(ctx, &pb.FindElementsRequest{
+			Parent:    app,
+			Selector:  selector,
+			PageToken: pageToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, element := range resp.GetElements() {
+			if key := pathKey(element.GetPath()); wanted[key] && ids[key] == "" && element.GetElementId() != "" {
+				ids[key] = element.GetElementId()
+			}
+		}
+		if resp.GetNextPageToken() == "" {
+			break
+		}
+		pageToken = resp.GetNextPageToken()
+	}
+	return ids, nil
+}
+
+// locateSelector returns a selector for finding element again: its role, and
+// where it has bounds, its position as FindElements reports it, which is the
+// center if it has both a width and a height, and otherwise its origin.
+func locateSelector(element *typepb.Element) *typepb.ElementSelector {
+	role := &typepb.ElementSelector{Criteria: &typepb.ElementSelector_Role{Role: element.GetRole()}}
+	w, h := element.GetWidth(), element.GetHeight()
+	if w <= 0 && h <= 0 {
+		return role
+	}
+	x, y := element.GetX(), element.GetY()
+	if w > 0 && h > 0 {
+		x += w / 2
+		y += h / 2
+	}
+	position := &typepb.ElementSelector{Criteria: &typepb.ElementSelector_Position{
+		Position: &typepb.PositionSelector{X: x, Y: y, Tolerance: 1},
+	}}
+	return combineSelectors(role, position)
+}
+
+// elementIDForPath resolves a path to the ID of the one element it matches.
+// If it matches none, or more than one, or the element can't be resolved, it
+// returns an error result for tool.
+func (s *MCPServer) elementIDForPath(ctx context.Context, tool, parent, expr string) (string, *ToolResult) {
+	if parseParentPID(parent) == 0 {
+		return "", errorResult("path requires an application or window parent, e.g. applications/123")
+	}
+	path, err := parseElementPath(expr)
+	if err != nil {
+		return "", errorResultf("Invalid path: %v", err)
+	}
+	matches, total, err := s.findElementsByPath(ctx, parent, path, 1)
+	if err != nil {
+		return "", grpcErrorResult(err, tool)
+	}
+	switch {
+	case total == 0:
+		return "", errorResultf("No element matches path %q. Use find_elements to discover available elements.", expr)
+	case total > 1:
+		return "", errorResultf("Path %q matches %d elements. Add a position such as [1], or a predicate, so that it matches one.", expr, total)
+	case matches[0].id == "":
+		return "", errorResultf("Element matching path %q is no longer available. The UI may have changed; retry, or use find_elements to refresh the reference.", expr)
+	}
+	return matches[0].id, nil
+}
+
+// formatPathMatches formats find_elements results for a path query.
+func formatPathMatches(matches []pathMatch, total int) string {
+	lines := make([]string, 0, len(matches))
+	for i, match := range matches {
+		id := match.id
+		if id == "" {
+			id = "(unresolved)"
+		}
+		lines = append(lines, fmt.Sprintf("%d. %s", i+1, describeElement(id, match.element)))
+	}
+
+	result := fmt.Sprintf("Found %d elements:\n%s", total, strings.Join(lines, "\n"))
+	if len(matches) < total {
+		result += fmt.Sprintf("\n\nShowing the first %d. Refine the path, or raise page_size, to see the rest.", len(matches))
+	}
+	return result
+}
+
+// describeElement formats an element as "ID - text (role)", with its bounds
+// if it has any.
+func describeElement(id string, element *typepb.Element) string {
+	role := element.GetRole()
+	if role == "" {
+		role = "(unknown)"
+	}
+	text := element.GetText()
+	if text == "" {
+		text = "(no text)"
+	}
+	desc := fmt.Sprintf("%s - %s (%s)", id, text, role)
+	if w, h := element.GetWidth(), element.GetHeight(); w > 0 || h > 0 {
+		desc += fmt.Sprintf(" at (%.0f, %.0f) %.0fx%.0f", element.GetX(), element.GetY(), w, h)
+	}
+	return desc
+}
@@ -0,0 +1,315 @@
+// Copyright 2025 Joseph Cumines
+//
+// Element path query tests
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	typepb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/type"
+	pb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+func testElement(role, text string, path ...int32) *typepb.Element {
+	element := &typepb.Element{
+		Role: role,
+		X:    proto.Float64(0), Y: proto.Float64(0),
+		Width: proto.Float64(0), Height: proto.Float64(0),
+		Path: path,
+	}
+	if text != "" {
+		element.Text = proto.String(text)
+	}
+	return element
+}
+
+func withBounds(element *typepb.Element, x, y, w, h float64) *typepb.Element {
+	element.X, element.Y, element.Width, element.Height = &x, &y, &w, &h
+	return element
+}
+
+// testCheckoutElements is a traversal of a checkout window, in no particular
+// order. The group at path -1.0.2 was filtered out by the traversal.
+func testCheckoutElements() []*typepb.Element {
+	street := withBounds(testElement("AXTextField", "", -1, 0, 1), 10, 20, 100, 20)
+	street.Attributes = map[string]string{"AXPlaceholderValue": "Street"}
+	return []*typepb.Element{
+		withBounds(testElement("AXButton (button)", "Pay", -1, 2), 40, 50, 20, 20),
+		testElement("AXTextField", "", -1, 1, 0),
+		testElement("AXGroup", "Billing Address", -1, 1),
+		testElement("AXTextField", "", -1, 0, 2, 0),
+		street,
+		testElement("AXStaticText", "Street", -1, 0, 0),
+		testElement("AXGroup", "Shipping Address", -1, 0),
+		testElement("AXWindow", "Checkout", -1),
+		testElement("AXApplication", "Shop"),
+	}
+}
+
+func TestElementPath_Query(t *testing.T) {
+	tree := newElementTree(testCheckoutElements())
+
+	tests := []struct {
+		path string
+		want []string // path keys
+	}{
+		{`//AXGroup[text:"Shipping Address"]//AXTextField`, []string{"-1.0.1", "-1.0.2.0"}},
+		{`//AXGroup[text:"Shipping Address"]/AXTextField`, []string{"-1.0.1", "-1.0.2.0"}},
+		{`//AXTextField[1]`, []string{"-1.0.1", "-1.1.0"}},
+		{`//AXTextField[2]`, []string{"-1.0.2.0"}},
+		{`/descendant::AXTextField[1]`, []string{"-1.0.1"}},
+		{`/AXApplication/AXWindow/AXButton[text:Pay]`, []string{"-1.2"}},
+		{`/AXWindow`, nil},
+		{`AXWindow`, []string{"-1"}},
+		{`//AXButton/..`, []string{"-1"}},
+		{`//AXTextField/ancestor::AXGroup[text~"^Billing"]`, []string{"-1.1"}},
+		{`//AXTextField/ancestor::*[1]`, []string{"-1.0", "-1.1"}},
+		{`//AXTextField/ancestor::*[3]`, []string{""}},
+		{`//AXStaticText[text:Street]/parent::AXGroup`, []string{"-1.0"}},
+		{`//*[role:AXButton or text:Street]`, []string{"-1.0.0", "-1.2"}},
+		{`//AXTextField[@AXPlaceholderValue=Street]`, []string{"-1.0.1"}},
+		{`//axtextfield[not @AXPlaceholderValue=Street][1]`, []string{"-1.0.2.0", "-1.1.0"}},
+		{`//AXButton[position:50,60,5]`, []string{"-1.2"}},
+		{`//AXButton[position:50,70,5]`, nil},
+		{`//AXWindow/child::*[text_contains:Address][2]`, []string{"-1.1"}},
+		{`//AXGroup[text:"a]b"]`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			path, err := parseElementPath(tt.path)
+			if err != nil {
+				t.Fatalf("parseElementPath(%q) error: %v", tt.path, err)
+			}
+			var got []string
+			for _, node := range tree.query(path) {
+				got = append(got, pathKey(node.element.GetPath()))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("query(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseElementPath_Errors(t *testing.T) {
+	tests := []struct {
+		path    string
+		wantErr string
+	}{
+		{``, "path is empty"},
+		{`//`, "at position 3: expected a role or *"},
+		{`//AXButton/`, "expected a role or *"},
+		{`//AXButton[`, "at position 11: missing ] to close the ["},
+		{`//AXButton[0]`, "positions start at 1"},
+		{`//AXButton[text~"("]`, "invalid regular expression"},
+		{`//AXButton[foo:bar]`, `unsupported selector key "foo"`},
+		{`//sibling::AXButton`, `at position 3: unknown axis "sibling"`},
+		{`//AXButton]`, `at position 11: unexpected "]"; join steps with / or //`},
+		{`//[text:OK]`, "expected a role (e.g. AXButton) or *"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			_, err := parseElementPath(tt.path)
+			if err == nil {
+				t.Fatalf("parseElementPath(%q) = nil error, want %q", tt.path, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseElementPath(%q) error = %q, want it to contain %q", tt.path, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// newPathTestServer returns a server whose application 42 has the checkout
+// elements, which FindElements resolves to IDs "e" and their path keys.
+func newPathTestServer(t *testing.T, mock *mockMacosUseClient) *MCPServer {
+	t.Helper()
+	mock.traverseAccessibilityFunc = func(_ context.Context, req *pb.TraverseAccessibilityRequest) (*pb.TraverseAccessibilityResponse, error) {
+		if req.Name != "applications/42" {
+			t.Errorf("TraverseAccessibility Name = %q, want applications/42", req.Name)
+		}
+		return &pb.TraverseAccessibilityResponse{Elements: testCheckoutElements()}, nil
+	}
+	mock.findElementsFunc = func(_ context.Context, req *pb.FindElementsRequest) (*pb.FindElementsResponse, error) {
+		if req.Parent != "applications/42" {
+			t.Errorf("FindElements Parent = %q, want applications/42", req.Parent)
+		}
+		if req.Selector == nil {
+			t.Error("FindElements Selector is nil, want the matched elements' roles and positions")
+		}
+		var elements []*typepb.Element
+		for _, element := range testCheckoutElements() {
+			element.ElementId = "e" + pathKey(element.Path)
+			elements = append(elements, element)
+		}
+		return &pb.FindElementsResponse{Elements: elements}, nil
+	}
+	return newTestMCPServer(mock)
+}
+
+func TestCUAHandleFindElements_Path(t *testing.T) {
+	s := newPathTestServer(t, &mockMacosUseClient{getWindowFunc: checkoutWindow})
+
+	args, _ := json.Marshal(map[string]any{
+		"parent": "applications/42/windows/7",
+		"path":   `//AXGroup[text:"Shipping Address"]/AXTextField`,
+	})
+	result, err := s.cuaHandleFindElements(&ToolCall{Name: "find_elements", Arguments: args})
+	if err != nil {
+		t.Fatalf("cuaHandleFindElements returned error: %v", err)
+	}
+	if resultIsError(result) {
+		t.Fatalf("unexpected error result: %s", resultText(result))
+	}
+	for _, want := range []string{
+		"Found 2 elements",
+		"1. e-1.0.1 - (no text) (AXTextField) at (10, 20) 100x20",
+		"2. e-1.0.2.0 - (no text) (AXTextField)",
+	} {
+		if !resultContains(result, want) {
+			t.Errorf("result missing %q: %s", want, resultText(result))
+		}
+	}
+
+	args, _ = json.Marshal(map[string]any{"parent": "applications/42", "path": "//AXTextField", "page_size": 1})
+	result, _ = s.cuaHandleFindElements(&ToolCall{Name: "find_elements", Arguments: args})
+	if !resultContains(result, "Found 3 elements") || !resultContains(result, "Showing the first 1") {
+		t.Errorf("expected truncated results, got: %s", resultText(result))
+	}
+}
+
+// checkoutWindow returns the checkout window of the checkout elements.
+func checkoutWindow(_ context.Context, req *pb.GetWindowRequest, _ ...grpc.CallOption) (*pb.Window, error) {
+	return &pb.Window{Name: req.Name, Title: "Checkout", Bounds: &pb.Bounds{}}, nil
+}
+
+func TestCUAHandleFindElements_PathInWindow(t *testing.T) {
+	s := newPathTestServer(t, &mockMacosUseClient{getWindowFunc: checkoutWindow})
+
+	// The window is the top-level element, and nothing outside it matches.
+	tests := []struct {
+		path string
+		want string
+	}{
+		{`/AXWindow`, "Found 1 elements"},
+		{`/AXWindow/AXButton[text:Pay]`, "Found 1 elements"},
+		{`/AXApplication`, "No elements found matching path"},
+		{`//AXButton/ancestor::AXApplication`, "No elements found matching path"},
+	}
+	for _, tt := range tests {
+		args, _ := json.Marshal(map[string]any{"parent": "applications/42/windows/7", "path": tt.path})
+		result, err := s.cuaHandleFindElements(&ToolCall{Name: "find_elements", Arguments: args})
+		if err != nil {
+			t.Fatalf("cuaHandleFindElements returned error: %v", err)
+		}
+		if resultIsError(result) || !resultContains(result, tt.want) {
+			t.Errorf("%s: expected %q, got: %s", tt.path, tt.want, resultText(result))
+		}
+	}
+
+	s.client.(*mockMacosUseClient).getWindowFunc = func(_ context.Context, req *pb.GetWindowRequest, _ ...grpc.CallOption) (*pb.Window, error) {
+		return &pb.Window{Name: req.Name, Bounds: &pb.Bounds{X: 5, Y: 5, Width: 10, Height: 10}}, nil
+	}
+	args, _ := json.Marshal(map[string]any{"parent": "applications/42/windows/9", "path": "//AXButton"})
+	result, _ := s.cuaHandleFindElements(&ToolCall{Name: "find_elements", Arguments: args})
+	if !resultIsError(result) || !resultContains(result, "window applications/42/windows/9 was not found in the accessibility tree") {
+		t.Errorf("expected window not found error, got: %s", resultText(result))
+	}
+}
+
+func TestCUAHandleFindElements_PathErrors(t *testing.T) {
+	s := newTestServer()
+	tests := []struct {
+		args    string
+		wantErr string
+	}{
+		{`{"parent":"applications/42","path":"//AXButton","role":"AXButton"}`, "path can't be combined"},
+		{`{"parent":"applications/42","path":"//AXButton","page_token":"x"}`, "page_token isn't supported with path"},
+		{`{"parent":"applications/-","path":"//AXButton"}`, "path requires an application or window parent"},
+		{`{"parent":"applications/42","path":"//AXButton["}`, "Invalid path"},
+	}
+	for _, tt := range tests {
+		result, err := s.cuaHandleFindElements(&ToolCall{Name: "find_elements", Arguments: json.RawMessage(tt.args)})
+		if err != nil {
+			t.Fatalf("cuaHandleFindElements returned error: %v", err)
+		}
+		if !resultIsError(result) || !resultContains(result, tt.wantErr) {
+			t.Errorf("%s: expected error containing %q, got: %s", tt.args, tt.wantErr, resultText(result))
+		}
+	}
+}
+
+func TestCUAHandleClickElement_Path(t *testing.T) {
+	var clicked string
+	s := newPathTestServer(t, &mockMacosUseClient{
+		clickElementFunc: func(_ context.Context, req *pb.ClickElementRequest, _ ...grpc.CallOption) (*pb.ClickElementResponse, error) {
+			clicked = req.GetElementId()
+			return &pb.ClickElementResponse{Success: true, Element: &typepb.Element{ElementId: clicked, Role: "AXButton"}}, nil
+		},
+	})
+
+	result, err := s.cuaHandleClickElement(&ToolCall{Name: "click_element", Arguments: json.RawMessage(
+		`{"parent":"applications/42","path":"//AXWindow/AXButton[text:Pay]"}`)})
+	if err != nil {
+		t.Fatalf("cuaHandleClickElement returned error: %v", err)
+	}
+	if resultIsError(result) || clicked != "e-1.2" {
+		t.Errorf("clicked %q, want e-1.2; result: %s", clicked, resultText(result))
+	}
+
+	tests := []struct {
+		args    string
+		wantErr string
+	}{
+		{`{"parent":"applications/42","path":"//AXTextField"}`, "matches 3 elements"},
+		{`{"parent":"applications/42","path":"//AXSlider"}`, "No element matches path"},
+		{`{"parent":"applications/42","path":"//AXButton","selector":"role:AXButton"}`, "provide either path or element/selector, not both"},
+	}
+	for _, tt := range tests {
+		clicked = ""
+		result, _ := s.cuaHandleClickElement(&ToolCall{Name: "click_element", Arguments: json.RawMessage(tt.args)})
+		if !resultIsError(result) || !resultContains(result, tt.wantErr) {
+			t.Errorf("%s: expected error containing %q, got: %s", tt.args, tt.wantErr, resultText(result))
+		}
+		if clicked != "" {
+			t.Errorf("%s: clicked %q, want no click", tt.args, clicked)
+		}
+	}
+}
+
+func TestHandleReadElement_Path(t *testing.T) {
+	s := newPathTestServer(t, &mockMacosUseClient{
+		getElementFunc: func(_ context.Context, req *pb.GetElementRequest) (*typepb.Element, error) {
+			if req.Name != "applications/42/elements/e-1.0.1" {
+				t.Errorf("GetElement Name = %q, want applications/42/elements/e-1.0.1", req.Name)
+			}
+			return &typepb.Element{ElementId: "e-1.0.1", Role: "AXTextField"}, nil
+		},
+		getElementActionsFunc: func(_ context.Context, _ *pb.GetElementActionsRequest, _ ...grpc.CallOption) (*pb.ElementActions, error) {
+			return &pb.ElementActions{}, nil
+		},
+	})
+
+	result, err := s.handleReadElement(&ToolCall{Name: "read_element", Arguments: json.RawMessage(
+		`{"parent":"applications/42","path":"//AXTextField[@AXPlaceholderValue=Street]"}`)})
+	if err != nil {
+		t.Fatalf("handleReadElement returned error: %v", err)
+	}
+	if resultIsError(result) || !resultContains(result, "Element: e-1.0.1") {
+		t.Errorf("unexpected result: %s", resultText(result))
+	}
+
+	result, _ = s.handleReadElement(&ToolCall{Name: "read_element", Arguments: json.RawMessage(`{"path":"//AXTextField"}`)})
+	if !resultIsError(result) || !resultContains(result, "path requires a parent") {
+		t.Errorf("expected parent error, got: %s", resultText(result))
+	}
+}
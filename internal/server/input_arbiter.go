@@ -0,0 +1,320 @@
+// Copyright 2025 Joseph Cumines
+//
+// Input arbitration — serialises mouse and keyboard input across clients, with
+// leases for exclusive multi-step control
+
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// inputTools are the tools that synthesise mouse and keyboard input with
+// CreateInput, or run macros that do. All calls to them pass through the input
+// arbiter, so events from concurrent calls never interleave on the desktop.
+var inputTools = map[string]bool{
+	"click":                  true,
+	"double_click":           true,
+	"drag":                   true,
+	"type":                   true,
+	"keypress":               true,
+	"scroll":                 true,
+	"move":                   true,
+	"click_element":          true,
+	"type_element":           true,
+	"perform_element_action": true,
+	"macro_execute":          true,
+}
+
+const (
+	// defaultInputLeaseDuration is the input_lease duration when none is given.
+	defaultInputLeaseDuration = 30 * time.Second
+
+	// defaultInputLeaseMax bounds input_lease durations when MCP_INPUT_LEASE_MAX is unset.
+	defaultInputLeaseMax = 5 * time.Minute
+)
+
+// inputArbiter admits one input tool call at a time. While a lease is held,
+// only calls presenting the lease are admitted; others wait until it is
+// released or expires.
+//
+// In FIFO mode waiting calls are admitted strictly in arrival order. Otherwise
+// any eligible call may be admitted next, including one that has just arrived.
+type inputArbiter struct {
+	lease    *inputLease
+	queue    []*inputWaiter
+	maxLease time.Duration
+	mu       sync.Mutex
+	fifo     bool
+	busy     bool
+}
+
+// inputWaiter is a call waiting to be admitted by the arbiter.
+type inputWaiter struct {
+	wake  chan struct{}
+	lease string
+}
+
+// inputLease is exclusive control of input, granted by input_lease.
+type inputLease struct {
+	expires time.Time
+	timer   *time.Timer
+	id      string
+}
+
+func newInputArbiter(fairness string, maxLease time.Duration) *inputArbiter {
+	if maxLease <= 0 {
+		maxLease = defaultInputLeaseMax
+	}
+	return &inputArbiter{fifo: fairness != "none", maxLease: maxLease}
+}
+
+// inputArbiter returns the server's input arbiter, configured on first use.
+func (s *MCPServer) inputArbiter() *inputArbiter {
+	s.inputOnce.Do(func() {
+		var fairness string
+		var maxLease time.Duration
+		if s.cfg != nil {
+			fairness, maxLease = s.cfg.InputFairness, s.cfg.InputLeaseMax
+		}
+		s.input = newInputArbiter(fairness, maxLease)
+	})
+	return s.input
+}
+
+// acquire waits until a call presenting lease (or "" for none) may send input,
+// and returns the function that ends its turn. It fails immediately if lease
+// is not the active lease, and with an error describing what it waited on if
+// ctx is done first.
+func (a *inputArbiter) acquire(ctx context.Context, lease string) (release func(), err error) {
+	a.mu.Lock()
+	if lease != "" && (a.lease == nil || a.lease.id != lease) {
+		a.mu.Unlock()
+		return nil, fmt.Errorf("input lease %s is not held (it may have expired or been released)", lease)
+	}
+	if a.canRunLocked(lease, nil) {
+		a.busy = true
+		a.mu.Unlock()
+		return a.release, nil
+	}
+	w := &inputWaiter{lease: lease, wake: make(chan struct{}, 1)}
+	a.queue = append(a.queue, w)
+	a.mu.Unlock()
+
+	for {
+		select {
+		case <-w.wake:
+			a.mu.Lock()
+			if a.canRunLocked(lease, w) {
+				a.busy = true
+				a.removeLocked(w)
+				a.mu.Unlock()
+				return a.release, nil
+			}
+			a.mu.Unlock()
+
+		case <-ctx.Done():
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			a.removeLocked(w)
+			// Pass on any wake-up this waiter consumed.
+			a.wakeLocked()
+			if a.lease != nil && a.lease.id != lease {
+				return nil, fmt.Errorf("input is leased by another client until %s: %w", a.lease.expires.Format(time.RFC3339), context.Cause(ctx))
+			}
+			return nil, fmt.Errorf("waiting for input: %w", context.Cause(ctx))
+		}
+	}
+}
+
+// release ends the current call's turn.
+func (a *inputArbiter) release() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.busy = false
+	a.wakeLocked()
+}
+
+// leaseAllowsLocked reports whether a call presenting lease may run under the
+// current lease, if any.
+func (a *inputArbiter) leaseAllowsLocked(lease string) bool {
+	return a.lease == nil || a.lease.id == lease
+}
+
+// canRunLocked reports whether the call presenting lease may run now: w is
+// its queue entry, or nil if it has just arrived.
+func (a *inputArbiter) canRunLocked(lease string, w *inputWaiter) bool {
+	if a.busy || !a.leaseAllowsLocked(lease) {
+		return false
+	}
+	if !a.fifo {
+		return true
+	}
+	for _, q := range a.queue {
+		if a.leaseAllowsLocked(q.lease) {
+			return q == w
+		}
+	}
+	return true
+}
+
+// wakeLocked wakes the waiters that may now run: in FIFO mode the first
+// eligible waiter, otherwise all of them, to race for the arbiter.
+func (a *inputArbiter) wakeLocked() {
+	if a.busy {
+		return
+	}
+	for _, w := range a.queue {
+		if !a.leaseAllowsLocked(w.lease) {
+			continue
+		}
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+		if a.fifo {
+			return
+		}
+	}
+}
+
+func (a *inputArbiter) removeLocked(w *inputWaiter) {
+	for i, q := range a.queue {
+		if q == w {
+			a.queue = append(a.queue[:i], a.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// leaseDuration clamps a requested lease duration to the configured maximum.
+func (a *inputArbiter) leaseDuration(d time.Duration) time.Duration {
+	if d <= 0 {
+		d = defaultInputLeaseDuration
+	}
+	return min(d, a.maxLease)
+}
+
+// acquireLease waits its turn like an input call, including for any current
+// lease to end, then grants a new lease for d.
+func (a *inputArbiter) acquireLease(ctx context.Context, d time.Duration) (id string, expires time.Time, err error) {
+	release, err := a.acquire(ctx, "")
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer release()
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate lease ID: %w", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	l := &inputLease{id: "lease-" + hex.EncodeToString(b)}
+	a.lease = l
+	a.extendLocked(l, d)
+	return l.id, l.expires, nil
+}
+
+// renewLease extends the lease id to expire d from now.
+func (a *inputArbiter) renewLease(id string, d time.Duration) (time.Time, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.lease == nil || a.lease.id != id {
+		return time.Time{}, fmt.Errorf("input lease %s is not held (it may have expired or been released)", id)
+	}
+	a.extendLocked(a.lease, d)
+	return a.lease.expires, nil
+}
+
+// releaseLease ends the lease id early.
+func (a *inputArbiter) releaseLease(id string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.lease == nil || a.lease.id != id {
+		return fmt.Errorf("input lease %s is not held (it may have expired or been released)", id)
+	}
+	a.endLeaseLocked()
+	return nil
+}
+
+// extendLocked sets l to expire d from now, clamped to the maximum.
+func (a *inputArbiter) extendLocked(l *inputLease, d time.Duration) {
+	d = a.leaseDuration(d)
+	l.expires = time.Now().Add(d)
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+	l.timer = time.AfterFunc(d, func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if a.lease == l && !time.Now().Before(l.expires) {
+			a.endLeaseLocked()
+		}
+	})
+}
+
+func (a *inputArbiter) endLeaseLocked() {
+	a.lease.timer.Stop()
+	a.lease = nil
+	a.wakeLocked()
+}
+
+// inputArbiterStatus describes the arbiter for input_lease status.
+type inputArbiterStatus struct {
+	LeaseExpires *time.Time `json:"lease_expires,omitempty"`
+	Fairness     string     `json:"fairness"`
+	Waiting      int        `json:"waiting"`
+	Leased       bool       `json:"leased"`
+	Busy         bool       `json:"busy"`
+}
+
+func (a *inputArbiter) status() inputArbiterStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	st := inputArbiterStatus{Fairness: "fifo", Waiting: len(a.queue), Busy: a.busy}
+	if !a.fifo {
+		st.Fairness = "none"
+	}
+	if a.lease != nil {
+		expires := a.lease.expires
+		st.Leased, st.LeaseExpires = true, &expires
+	}
+	return st
+}
+
+// inputMiddleware admits calls to the input tools through the input arbiter,
+// so only one sends input at a time. A call may present an input_lease with
+// its "lease" argument. Calls that can't be admitted within the request
+// timeout return a soft error without running.
+func (s *MCPServer) inputMiddleware(next ToolHandler) ToolHandler {
+	return func(call *ToolCall) (*ToolResult, error) {
+		if !inputTools[call.Name] {
+			return next(call)
+		}
+		var params struct {
+			Lease string `json:"lease"`
+		}
+		_ = json.Unmarshal(call.Arguments, &params)
+
+		ctx := call.Context()
+		if s.cfg != nil && s.cfg.RequestTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(s.cfg.RequestTimeout)*time.Second)
+			defer cancel()
+		}
+		release, err := s.inputArbiter().acquire(ctx, params.Lease)
+		if err != nil {
+			return errorResultf("%s was not run: %v", call.Name, err), nil
+		}
+		defer release()
+		return next(call)
+	}
+}
@@ -0,0 +1,256 @@
+// Copyright 2025 Joseph Cumines
+//
+// Tests for input arbitration and the input_lease tool.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitQueued waits until n calls are waiting on the arbiter.
+func waitQueued(t *testing.T, a *inputArbiter, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for a.status().Waiting != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d waiting calls, got %d", n, a.status().Waiting)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestInputArbiter_FIFO(t *testing.T) {
+	a := newInputArbiter("fifo", time.Minute)
+	release, err := a.acquire(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i := range 5 {
+		wg.Go(func() {
+			release, err := a.acquire(context.Background(), "")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			release()
+		})
+		waitQueued(t, a, i+1)
+	}
+
+	release()
+	wg.Wait()
+	for i, got := range order {
+		if got != i {
+			t.Fatalf("calls ran in order %v, want arrival order", order)
+		}
+	}
+}
+
+func TestInputArbiter_Exclusive(t *testing.T) {
+	for _, fairness := range []string{"fifo", "none"} {
+		t.Run(fairness, func(t *testing.T) {
+			a := newInputArbiter(fairness, time.Minute)
+			var active, overlaps int
+			var mu sync.Mutex
+			var wg sync.WaitGroup
+			for range 20 {
+				wg.Go(func() {
+					release, err := a.acquire(context.Background(), "")
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					mu.Lock()
+					active++
+					if active > 1 {
+						overlaps++
+					}
+					mu.Unlock()
+					time.Sleep(time.Millisecond)
+					mu.Lock()
+					active--
+					mu.Unlock()
+					release()
+				})
+			}
+			wg.Wait()
+			if overlaps != 0 {
+				t.Errorf("%d overlapping input calls", overlaps)
+			}
+		})
+	}
+}
+
+func TestInputArbiter_Lease(t *testing.T) {
+	a := newInputArbiter("fifo", time.Minute)
+	id, _, err := a.acquireLease(context.Background(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The holder's calls run; others wait.
+	release, err := a.acquire(context.Background(), id)
+	if err != nil {
+		t.Fatalf("lease holder blocked: %v", err)
+	}
+	release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := a.acquire(ctx, ""); err == nil || !strings.Contains(err.Error(), "leased by another client") {
+		t.Errorf("acquire() error = %v, want leased error", err)
+	}
+	if _, err := a.acquire(context.Background(), "lease-bogus"); err == nil || !strings.Contains(err.Error(), "not held") {
+		t.Errorf("acquire() error = %v, want not held error", err)
+	}
+
+	// Releasing the lease admits waiting calls.
+	admitted := make(chan struct{})
+	go func() {
+		release, err := a.acquire(context.Background(), "")
+		if err == nil {
+			release()
+		}
+		close(admitted)
+	}()
+	waitQueued(t, a, 1)
+	if err := a.releaseLease(id); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-admitted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("waiting call not admitted after lease release")
+	}
+	if err := a.releaseLease(id); err == nil {
+		t.Error("releasing a released lease should fail")
+	}
+}
+
+func TestInputArbiter_LeaseExpiry(t *testing.T) {
+	a := newInputArbiter("fifo", 30*time.Millisecond)
+	id, expires, err := a.acquireLease(context.Background(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Until(expires) > 30*time.Millisecond {
+		t.Errorf("lease duration not capped: expires %v", expires)
+	}
+	if _, err := a.renewLease(id, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	release, err := a.acquire(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if _, err := a.renewLease(id, time.Second); err == nil {
+		t.Error("renewing an expired lease should fail")
+	}
+}
+
+func TestInputLease_Tool(t *testing.T) {
+	s := newTestMCPServer(nil)
+	s.registerTools()
+
+	result := callToolOn(s, nil, "input_lease", `{"action":"acquire","duration":60}`)
+	if result.IsError {
+		t.Fatalf("acquire failed: %+v", result)
+	}
+	text := resultText(result)
+	start := strings.Index(text, "lease-")
+	id := text[start : start+len("lease-")+16]
+
+	result = callToolOn(s, nil, "input_lease", `{"action":"status"}`)
+	var st inputArbiterStatus
+	if err := json.Unmarshal([]byte(resultText(result)), &st); err != nil {
+		t.Fatalf("invalid status %q: %v", resultText(result), err)
+	}
+	if !st.Leased || st.LeaseExpires == nil || st.Fairness != "fifo" {
+		t.Errorf("unexpected status: %+v", st)
+	}
+
+	// Input tools must present the lease, and the schema advertises it.
+	if _, ok := s.tools["click"].InputSchema["properties"].(map[string]any)["lease"]; !ok {
+		t.Error("click schema should accept a lease")
+	}
+	s.cfg.RequestTimeout = 0
+	ran := false
+	s.tools["click"].Handler = func(*ToolCall) (*ToolResult, error) {
+		ran = true
+		return textResult("clicked"), nil
+	}
+	args, _ := json.Marshal(map[string]any{"x": 1, "y": 1, "lease": id})
+	if result := callToolOn(s, nil, "click", string(args)); result.IsError || !ran {
+		t.Errorf("lease holder's click did not run: %+v", result)
+	}
+	if result := callToolOn(s, nil, "click", `{"x":1,"y":1,"lease":"lease-0000000000000000"}`); !result.IsError || !resultContains(result, "not held") {
+		t.Errorf("unexpected result for unknown lease: %+v", result)
+	}
+
+	for _, tc := range []struct{ args, want string }{
+		{`{"action":"release"}`, "lease parameter is required"},
+		{`{"action":"bogus"}`, "Invalid action"},
+		{`{"action":"acquire","duration":-1}`, "non-negative"},
+		{`{"action":"renew","lease":"lease-x"}`, "not held"},
+	} {
+		if result := callToolOn(s, nil, "input_lease", tc.args); !result.IsError || !resultContains(result, tc.want) {
+			t.Errorf("%s: unexpected result: %+v", tc.args, result)
+		}
+	}
+
+	result = callToolOn(s, nil, "input_lease", `{"action":"release","lease":"`+id+`"}`)
+	if result.IsError {
+		t.Errorf("release failed: %+v", result)
+	}
+}
+
+func TestInputMiddleware_TimesOutWhileLeased(t *testing.T) {
+	s := newTestMCPServer(nil)
+	s.cfg.RequestTimeout = 1
+	if _, _, err := s.inputArbiter().acquireLease(context.Background(), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	time.AfterFunc(20*time.Millisecond, func() { cancel(errRequestCancelled) })
+	ran := false
+	result, err := s.inputMiddleware(func(*ToolCall) (*ToolResult, error) {
+		ran = true
+		return textResult("typed"), nil
+	})(&ToolCall{Name: "type", Arguments: json.RawMessage(`{"text":"hi"}`), ctx: ctx})
+	if err != nil || ran || !result.IsError || !resultContains(result, "type was not run: input is leased by another client") {
+		t.Errorf("unexpected result: %+v, ran = %v, err = %v", result, ran, err)
+	}
+
+	// Macros send input too, so they wait for the lease like input tools.
+	result, _ = s.inputMiddleware(func(*ToolCall) (*ToolResult, error) {
+		ran = true
+		return textResult("ran macro"), nil
+	})(&ToolCall{Name: "macro_execute", Arguments: json.RawMessage(`{"macro":"macros/login"}`)})
+	if ran || !result.IsError || !resultContains(result, "macro_execute was not run") {
+		t.Errorf("unexpected macro_execute result: %+v, ran = %v", result, ran)
+	}
+
+	// Other tools are not arbitrated.
+	result, _ = s.inputMiddleware(func(*ToolCall) (*ToolResult, error) {
+		return textResult("shot"), nil
+	})(&ToolCall{Name: "screenshot"})
+	if result.IsError {
+		t.Errorf("screenshot should not be arbitrated: %+v", result)
+	}
+}
@@ -1,12 +1,13 @@
 // Copyright 2025 Joseph Cumines
 
 // Package server implements a Model Context Protocol (MCP) server that proxies
-// macOS automation requests to a gRPC backend. It exposes 23 CUA-aligned tools
-// across 5 categories: core CUA input, application management, element interaction,
-// window management, and utility (clipboard, scripting, display).
+// macOS automation requests to a gRPC backend. It exposes 44 CUA-aligned tools
+// across 10 categories: core CUA input, application management, element interaction,
+// window management, utility (clipboard, scripting, display), observation,
+// sessions, macros, macro recording, and input arbitration.
 //
-// The server supports both stdio (for MCP clients like Claude Desktop) and
-// HTTP/SSE transports (for web-based integrations). All tools follow MCP
+// The server supports stdio (for MCP clients like Claude Desktop), HTTP/SSE
+// (for web-based integrations) and WebSocket transports. All tools follow MCP
 // specification version 2025-11-25 with soft-error semantics (isError field
 // in ToolResult rather than RPC-level failures).
 //
@@ -41,14 +42,15 @@ const (
 )
 
 // MCPServer implements the Model Context Protocol (MCP) server.
-// It connects to a gRPC backend and exposes 23 CUA-aligned MCP tools for macOS automation.
-// The server supports both stdio and HTTP/SSE transports.
+// It connects to a gRPC backend and exposes 44 CUA-aligned MCP tools for macOS automation.
+// The server supports stdio, HTTP/SSE and WebSocket transports.
 //
 //lint:ignore BETTERALIGN struct is intentionally ordered for clarity
 type MCPServer struct {
 	client        pb.MacosUseClient
 	opsClient     longrunningpb.OperationsClient
 	httpTransport *transport.HTTPTransport
+	wsTransport   *transport.WebSocketTransport
 	auditLogger   *AuditLogger
 	ctx           context.Context
 	cfg           *config.Config
@@ -56,6 +58,42 @@ type MCPServer struct {
 	tools         map[string]*Tool
 	cancel        context.CancelFunc
 	mu            sync.RWMutex
+
+	// observations tracks StreamObservations consumers started by observe_start/observe_poll.
+	observations   map[string]*observationStream
+	observationsMu sync.Mutex
+
+	// sessions holds per-client state, keyed by session ID; see clientSession.
+	sessions   map[string]*clientSession
+	sessionsMu sync.Mutex
+
+	// subscriptions tracks resources/subscribe watches, keyed by resource URI.
+	subscriptions   map[string]*resourceSubscription
+	subscriptionsMu sync.Mutex
+
+	// methods routes JSON-RPC methods for every transport; see router.
+	methods     *methodRouter
+	methodsOnce sync.Once
+
+	// metrics records tool call metrics. ServeHTTP replaces it with the HTTP
+	// transport's registry so calls are exposed on /metrics.
+	metrics *transport.MetricsRegistry
+
+	// middlewares wrap every tool handler; see Use.
+	middlewares []ToolMiddleware
+
+	// inflight tracks tools/call requests in progress, keyed by client session
+	// and JSON-RPC ID, so notifications/cancelled can cancel them.
+	inflight   map[inflightKey]*inflightRequest
+	inflightMu sync.Mutex
+
+	// toolLocks serialises calls to the tools in MCP_SERIAL_TOOLS; see serialMiddleware.
+	toolLocks   map[string]chan struct{}
+	toolLocksMu sync.Mutex
+
+	// input arbitrates the input tools; see inputArbiter.
+	input     *inputArbiter
+	inputOnce sync.Once
 }
 
 // Tool represents an MCP tool with its handler, schema, and metadata.
@@ -63,7 +101,7 @@ type MCPServer struct {
 //
 //lint:ignore BETTERALIGN struct is intentionally ordered for clarity
 type Tool struct {
-	Handler     func(*ToolCall) (*ToolResult, error)
+	Handler     ToolHandler
 	InputSchema map[string]any
 	Name        string
 	Description string
@@ -74,6 +112,27 @@ type Tool struct {
 type ToolCall struct {
 	Name      string          `json:"name"`
 	Arguments json.RawMessage `json:"arguments"`
+
+	// transport is the transport the call arrived on, used for
+	// server-initiated messages to the client. It may be nil.
+	transport transport.Transport
+
+	// progressToken is the request's _meta.progressToken, if the client
+	// asked for progress notifications; see withProgress.
+	progressToken json.RawMessage
+
+	// ctx is the call's context; see Context.
+	ctx context.Context
+}
+
+// Context returns the context for the call. It is cancelled when the client
+// cancels the request with notifications/cancelled, or the server shuts down.
+// Handlers should derive the contexts of their gRPC calls from it.
+func (c *ToolCall) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
 }
 
 // ToolResult represents the result of an MCP tool invocation.
@@ -81,6 +140,10 @@ type ToolCall struct {
 type ToolResult struct {
 	Content []Content `json:"content"`
 	IsError bool      `json:"isError,omitempty"`
+
+	// status, if set, overrides the status the call is audited and counted
+	// with, e.g. "denied" for calls rejected before reaching the tool.
+	status string
 }
 
 // Content represents a content item in an MCP tool result.
@@ -137,6 +200,7 @@ func NewMCPServer(cfg *config.Config) (*MCPServer, error) {
 		cancel:      cancel,
 		tools:       make(map[string]*Tool),
 		auditLogger: auditLogger,
+		metrics:     transport.NewMetricsRegistry(),
 	}
 
 	// Initialize gRPC connection
@@ -194,17 +258,23 @@ func (s *MCPServer) initGRPC() error {
 }
 
 // Shutdown gracefully shuts down the server and releases all resources.
-// It closes the HTTP transport, audit logger, and gRPC connection.
+// It closes the HTTP or WebSocket transport, audit logger, and gRPC connection.
 func (s *MCPServer) Shutdown() {
-	// Close HTTP transport if active
+	// Close HTTP or WebSocket transport if active
 	s.mu.RLock()
 	httpTransport := s.httpTransport
+	wsTransport := s.wsTransport
 	s.mu.RUnlock()
 	if httpTransport != nil {
 		if err := httpTransport.Close(); err != nil {
 			log.Printf("Error closing HTTP transport: %v", err)
 		}
 	}
+	if wsTransport != nil {
+		if err := wsTransport.Close(); err != nil {
+			log.Printf("Error closing WebSocket transport: %v", err)
+		}
+	}
 
 	// Close audit logger
 	if s.auditLogger != nil {
@@ -222,9 +292,10 @@ func (s *MCPServer) Shutdown() {
 }
 
 // registerTools initializes all MCP tool handlers for the server.
-// This registers 23 CUA-aligned tools across categories: core CUA (9),
-// application management (3), element interaction (4), window management (4),
-// clipboard (1), scripting (1), display (1).
+// This registers 44 CUA-aligned tools across categories: core CUA (9),
+// application management (3), element interaction (9), window management (4),
+// clipboard (1), scripting (1), display (1), observation (4), session (3),
+// macro (6), recording (2), input arbitration (1).
 func (s *MCPServer) registerTools() {
 	s.tools = map[string]*Tool{
 		// === CATEGORY 1: CORE CUA (9 tools — OpenAI CUA aligned) ===
@@ -406,11 +477,11 @@ func (s *MCPServer) registerTools() {
 			Handler: s.handleCloseApp,
 		},
 
-		// === CATEGORY 3: ELEMENT INTERACTION (4 tools) ===
+		// === CATEGORY 3: ELEMENT INTERACTION (9 tools) ===
 
 		"find_elements": {
 			Name:        "find_elements",
-			Description: "Find UI elements by criteria. Returns accessibility tree elements with role, text, position, and available actions.",
+			Description: "Find UI elements by criteria; all given criteria must match. Returns accessibility tree elements with role, text, position, and available actions.",
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
@@ -418,6 +489,8 @@ func (s *MCPServer) registerTools() {
 					"role":          map[string]any{"type": "string", "description": "Element role (e.g., button, textField, checkBox)"},
 					"text":          map[string]any{"type": "string", "description": "Element text content (exact match)"},
 					"text_contains": map[string]any{"type": "string", "description": "Element text contains substring"},
+					"selector":      map[string]any{"type": "string", "description": "Selector, " + selectorSyntax + ". Combined with role, text and text_contains; all must match"},
+					"path":          map[string]any{"type": "string", "description": elementPathSyntax + ". Alternative to role, text, text_contains and selector; results include bounds"},
 					"force_refresh": map[string]any{"type": "boolean", "description": "Discard cached data (default: false)"},
 					"page_size":     map[string]any{"type": "integer", "description": "Maximum elements to return"},
 					"page_token":    map[string]any{"type": "string", "description": "Opaque page token from previous response"},
@@ -428,13 +501,14 @@ func (s *MCPServer) registerTools() {
 		},
 		"click_element": {
 			Name:        "click_element",
-			Description: "Click a UI element via accessibility APIs. Automatically clicks element center and acquires focus for reliability. Use either element ID or selector; selector is preferred because element IDs from find_elements are ephemeral.",
+			Description: "Click a UI element via accessibility APIs. Automatically clicks element center and acquires focus for reliability. Use one of element ID, selector or path; selector or path is preferred because element IDs from find_elements are ephemeral.",
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
 					"parent":   map[string]any{"type": "string", "description": "Parent context"},
 					"element":  map[string]any{"type": "string", "description": "Element ID from find_elements (ephemeral, prefer selector)"},
-					"selector": map[string]any{"type": "string", "description": "Stable selector in key:value form, e.g. role:AXButton, text:Save, text_contains:submit"},
+					"selector": map[string]any{"type": "string", "description": "Stable selector, " + selectorSyntax},
+					"path":     map[string]any{"type": "string", "description": elementPathSyntax + ". Must match exactly one element"},
 				},
 				"required": []string{"parent"},
 			},
@@ -448,7 +522,7 @@ func (s *MCPServer) registerTools() {
 				"properties": map[string]any{
 					"parent":       map[string]any{"type": "string", "description": "Parent context"},
 					"element":      map[string]any{"type": "string", "description": "Element ID from find_elements (ephemeral, prefer selector)"},
-					"selector":     map[string]any{"type": "string", "description": "Stable selector in key:value form, e.g. role:AXTextArea, text:hello, text_contains:world"},
+					"selector":     map[string]any{"type": "string", "description": "Stable selector, " + selectorSyntax},
 					"text":         map[string]any{"type": "string", "description": "Text to enter"},
 					"input_method": map[string]any{"type": "string", "description": "Input delivery method: 'ax' (default) uses direct AX value mutation; 'keystrokes' sends physical keyboard events for web/Electron DOM-event compatibility", "enum": []string{"ax", "keystrokes"}},
 				},
@@ -464,11 +538,92 @@ func (s *MCPServer) registerTools() {
 				"properties": map[string]any{
 					"parent":  map[string]any{"type": "string", "description": "Parent context (e.g. applications/123 or applications/123/windows/456). Required when element is a bare ID from find_elements."},
 					"element": map[string]any{"type": "string", "description": "Element resource name or bare element ID from find_elements"},
+					"path":    map[string]any{"type": "string", "description": "Alternative to element: " + elementPathSyntax + ". Must match exactly one element; requires parent"},
 				},
-				"required": []string{"element"},
 			},
 			Handler: s.handleReadElement,
 		},
+		"element_actions": {
+			Name:        "element_actions",
+			Description: "List the accessibility actions a UI element supports, such as AXPress, AXShowMenu, AXIncrement, AXConfirm, AXCancel and AXRaise. Target the element by ID, selector or path.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"parent":   map[string]any{"type": "string", "description": "Parent context (e.g. applications/123 or applications/123/windows/456)"},
+					"element":  map[string]any{"type": "string", "description": "Element ID from find_elements (ephemeral, prefer selector)"},
+					"selector": map[string]any{"type": "string", "description": "Stable selector, " + selectorSyntax + ". The first match is used"},
+					"path":     map[string]any{"type": "string", "description": elementPathSyntax + ". Must match exactly one element"},
+				},
+				"required": []string{"parent"},
+			},
+			Handler: s.handleElementActions,
+		},
+		"perform_element_action": {
+			Name:        "perform_element_action",
+			Description: "Invoke an accessibility action on a UI element, e.g. AXShowMenu to open a context menu, AXIncrement on a slider, or AXConfirm in a dialog. Acquires focus first, and falls back to clicking if the action fails. Use element_actions to list the actions an element supports. Target the element by ID, selector or path.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"parent":   map[string]any{"type": "string", "description": "Parent context (e.g. applications/123 or applications/123/windows/456)"},
+					"element":  map[string]any{"type": "string", "description": "Element ID from find_elements (ephemeral, prefer selector)"},
+					"selector": map[string]any{"type": "string", "description": "Stable selector, " + selectorSyntax},
+					"path":     map[string]any{"type": "string", "description": elementPathSyntax + ". Must match exactly one element"},
+					"action":   map[string]any{"type": "string", "description": "Accessibility action name, e.g. AXPress, AXShowMenu, AXIncrement, AXDecrement, AXConfirm, AXCancel, AXRaise"},
+				},
+				"required": []string{"parent", "action"},
+			},
+			Handler: s.handlePerformElementAction,
+		},
+		"wait_for_element": {
+			Name:        "wait_for_element",
+			Description: "Wait for an element matching a selector to appear or disappear, instead of sleeping for a fixed time. Returns the matched element and how long the wait took.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"parent":   map[string]any{"type": "string", "description": "Parent context (e.g. applications/123 or applications/123/windows/456)"},
+					"selector": map[string]any{"type": "string", "description": "Selector, " + selectorSyntax},
+					"state":    map[string]any{"type": "string", "enum": []string{"appear", "disappear"}, "description": "Wait for a visible match to appear, or for none to remain (default: appear)"},
+					"timeout":  map[string]any{"type": "number", "description": "Maximum time to wait in seconds (default: 10, capped at the request timeout)"},
+				},
+				"required": []string{"parent", "selector"},
+			},
+			Handler: s.handleWaitForElement,
+		},
+		"wait_for_state": {
+			Name:        "wait_for_state",
+			Description: "Wait for an element to become enabled, disabled or focused, or for its value to equal or contain a string. Target the element by ID, selector or path; prefer a selector, as the element is found again on each check. Returns the element and how long the wait took.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"parent":   map[string]any{"type": "string", "description": "Parent context (e.g. applications/123 or applications/123/windows/456)"},
+					"element":  map[string]any{"type": "string", "description": "Element ID from find_elements (ephemeral, prefer selector)"},
+					"selector": map[string]any{"type": "string", "description": "Stable selector, " + selectorSyntax},
+					"path":     map[string]any{"type": "string", "description": elementPathSyntax + ". Must match exactly one element"},
+					"state":    map[string]any{"type": "string", "enum": []string{"enabled", "disabled", "focused", "value_equals", "value_contains"}, "description": "State to wait for"},
+					"value":    map[string]any{"type": "string", "description": "Expected value, for value_equals and value_contains"},
+					"timeout":  map[string]any{"type": "number", "description": "Maximum time to wait in seconds (default: 10, capped at the request timeout)"},
+				},
+				"required": []string{"parent", "state"},
+			},
+			Handler: s.handleWaitForState,
+		},
+		"get_accessibility_tree": {
+			Name:        "get_accessibility_tree",
+			Description: "Get the accessibility tree of an application or one of its windows, as indented text, a compact outline, or JSON, with traversal statistics. Large trees are truncated to the most relevant branches: those leading to the focused element, interactive elements and text.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"parent":       map[string]any{"type": "string", "description": "Application or window (e.g. applications/123 or applications/123/windows/456)"},
+					"max_depth":    map[string]any{"type": "integer", "description": "Maximum depth to include, where top-level elements (or the window) are depth 1 (default: no limit)"},
+					"visible_only": map[string]any{"type": "boolean", "description": "Only include elements with on-screen bounds (default: false)"},
+					"format":       map[string]any{"type": "string", "enum": []string{"text", "outline", "json"}, "description": "Output format: indented text, a compact outline, or JSON (default: text)"},
+					"max_elements": map[string]any{"type": "integer", "description": fmt.Sprintf("Maximum number of elements to show (default: %d)", defaultTreeMaxElements)},
+					"element_ids":  map[string]any{"type": "boolean", "description": "Resolve element IDs for the elements shown, for use with other element tools. Slower (default: false)"},
+				},
+				"required": []string{"parent"},
+			},
+			Handler: s.handleGetAccessibilityTree,
+		},
 
 		// === CATEGORY 4: WINDOW MANAGEMENT (4 tools) ===
 
@@ -565,10 +720,264 @@ func (s *MCPServer) registerTools() {
 			},
 			Handler: s.cuaHandleGetDisplay,
 		},
+
+		// === CATEGORY 6: OBSERVATION (4 tools) ===
+
+		"observe_start": {
+			Name:        "observe_start",
+			Description: "Start observing an application for UI changes. Events are buffered for observe_poll and, over the HTTP transport, pushed to SSE clients as 'observation' events.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"parent":        map[string]any{"type": "string", "description": "Application resource name (e.g. applications/123)"},
+					"type":          map[string]any{"type": "string", "description": "element_changes (default), window_changes, application_changes, attribute_changes, tree_changes", "enum": []string{"element_changes", "window_changes", "application_changes", "attribute_changes", "tree_changes"}},
+					"poll_interval": map[string]any{"type": "number", "description": "Server-side poll interval in seconds (default: server-defined)"},
+					"visible_only":  map[string]any{"type": "boolean", "description": "Only observe visible elements (default: false)"},
+					"roles":         map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Only observe elements with these roles (e.g. AXButton)"},
+					"attributes":    map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Only report changes to these attributes (e.g. AXValue)"},
+					"activate":      map[string]any{"type": "boolean", "description": "Activate the application before observing (default: false)"},
+				},
+				"required": []string{"parent"},
+			},
+			Handler: s.handleObserveStart,
+		},
+		"observe_poll": {
+			Name:        "observe_poll",
+			Description: "Retrieve buffered events for an observation. Optionally wait for the first event to arrive.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"name":       map[string]any{"type": "string", "description": "Observation resource name (from observe_start)"},
+					"max_events": map[string]any{"type": "integer", "description": "Maximum events to return (default: 100)"},
+					"wait":       map[string]any{"type": "number", "description": "Seconds to wait for an event if none are buffered (default: 0, capped at request timeout)"},
+				},
+				"required": []string{"name"},
+			},
+			Handler: s.handleObservePoll,
+		},
+		"observe_list": {
+			Name:        "observe_list",
+			Description: "List observations for an application.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"parent":     map[string]any{"type": "string", "description": "Application resource name (e.g. applications/123)"},
+					"page_size":  map[string]any{"type": "integer", "description": "Maximum results per page"},
+					"page_token": map[string]any{"type": "string", "description": "Pagination token from previous response"},
+				},
+				"required": []string{"parent"},
+			},
+			Handler: s.handleObserveList,
+		},
+		"observe_cancel": {
+			Name:        "observe_cancel",
+			Description: "Cancel an observation and stop streaming its events.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"name": map[string]any{"type": "string", "description": "Observation resource name"},
+				},
+				"required": []string{"name"},
+			},
+			Handler: s.handleObserveCancel,
+		},
+
+		// === CATEGORY 7: SESSION (3 tools) ===
+
+		"session": {
+			Name:        "session",
+			Description: "Unified session lifecycle: create, get, list, or delete sessions. Sessions record operation history and scope transactions.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"action":       map[string]any{"type": "string", "description": "create, get, list, delete", "enum": []string{"create", "get", "list", "delete"}},
+					"name":         map[string]any{"type": "string", "description": "Session resource name, e.g. sessions/abc (required for get, delete)"},
+					"display_name": map[string]any{"type": "string", "description": "Human-readable name (create)"},
+					"session_id":   map[string]any{"type": "string", "description": "Optional ID for the new session (create)"},
+					"metadata":     map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}, "description": "String key/value metadata (create)"},
+					"force":        map[string]any{"type": "boolean", "description": "Delete even if a transaction is active (delete, default: false)"},
+					"page_size":    map[string]any{"type": "integer", "description": "Maximum results per page (list)"},
+					"page_token":   map[string]any{"type": "string", "description": "Pagination token from previous response (list)"},
+				},
+				"required": []string{"action"},
+			},
+			Handler: s.handleSession,
+		},
+		"transaction": {
+			Name:        "transaction",
+			Description: "Begin, commit, or roll back a transaction in a session. Rollback only discards the session's recorded operation history back to a revision; it does not revert application or UI state.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"action":          map[string]any{"type": "string", "description": "begin, commit, rollback", "enum": []string{"begin", "commit", "rollback"}},
+					"session":         map[string]any{"type": "string", "description": "Session resource name (e.g. sessions/abc)"},
+					"transaction_id":  map[string]any{"type": "string", "description": "Transaction to commit or roll back (default: the session's active transaction)"},
+					"revision_id":     map[string]any{"type": "string", "description": "Revision to roll back to (default: the snapshot taken at begin)"},
+					"isolation_level": map[string]any{"type": "string", "description": "serializable (default, supports rollback), read_committed", "enum": []string{"serializable", "read_committed"}},
+					"timeout":         map[string]any{"type": "number", "description": "Transaction timeout in seconds (begin)"},
+				},
+				"required": []string{"action", "session"},
+			},
+			Handler: s.handleTransaction,
+		},
+		"session_snapshot": {
+			Name:        "session_snapshot",
+			Description: "Show a session's state, applications, observations, and operation history. History reflects rollbacks, which discard recorded operations without reverting application state.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"name":           map[string]any{"type": "string", "description": "Session resource name (e.g. sessions/abc)"},
+					"transaction_id": map[string]any{"type": "string", "description": "Only show operations recorded in this transaction"},
+					"limit":          map[string]any{"type": "integer", "description": "Maximum history entries to show, most recent first (default: 50)"},
+				},
+				"required": []string{"name"},
+			},
+			Handler: s.handleSessionSnapshot,
+		},
+
+		// === CATEGORY 8: MACRO (6 tools) ===
+
+		"macro_create": {
+			Name:        "macro_create",
+			Description: "Create a reusable macro from a JSON definition (protojson form of the Macro message: display_name, description, actions, parameters, tags). Typed text may reference parameters as ${key}.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"macro":    map[string]any{"type": "object", "description": "Macro definition, e.g. {\"display_name\": \"Save\", \"actions\": [{\"input\": {\"press_key\": {\"key\": \"s\", \"modifiers\": [\"MODIFIER_COMMAND\"]}}}]}"},
+					"macro_id": map[string]any{"type": "string", "description": "Optional macro ID (default: server-generated)"},
+				},
+				"required": []string{"macro"},
+			},
+			Handler: s.handleMacroCreate,
+		},
+		"macro_list": {
+			Name:        "macro_list",
+			Description: "List saved macros.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"page_size":  map[string]any{"type": "integer", "description": "Maximum results per page"},
+					"page_token": map[string]any{"type": "string", "description": "Pagination token from previous response"},
+				},
+			},
+			Handler: s.handleMacroList,
+		},
+		"macro_get": {
+			Name:        "macro_get",
+			Description: "Get a macro's parameters, action outline, and full JSON definition.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"name": map[string]any{"type": "string", "description": "Macro resource name (e.g. macros/abc)"},
+				},
+				"required": []string{"name"},
+			},
+			Handler: s.handleMacroGet,
+		},
+		"macro_update": {
+			Name:        "macro_update",
+			Description: "Update a macro. Only the fields present in the macro JSON are changed unless update_mask is given.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"name":        map[string]any{"type": "string", "description": "Macro resource name (e.g. macros/abc)"},
+					"macro":       map[string]any{"type": "object", "description": "Partial macro definition with the fields to change"},
+					"update_mask": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Fields to update, e.g. [\"description\"]; required to clear a field"},
+				},
+				"required": []string{"name", "macro"},
+			},
+			Handler: s.handleMacroUpdate,
+		},
+		"macro_delete": {
+			Name:        "macro_delete",
+			Description: "Delete a macro.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"name":  map[string]any{"type": "string", "description": "Macro resource name (e.g. macros/abc)"},
+					"force": map[string]any{"type": "boolean", "description": "Also delete executions and logs (default: false)"},
+				},
+				"required": []string{"name"},
+			},
+			Handler: s.handleMacroDelete,
+		},
+		"macro_execute": {
+			Name:        "macro_execute",
+			Description: "Execute a macro and wait for it to finish, reporting the per-action execution log.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"name":              map[string]any{"type": "string", "description": "Macro resource name (e.g. macros/abc)"},
+					"parameter_values":  map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}, "description": "Values for macro parameters, keyed by parameter key"},
+					"application":       map[string]any{"type": "string", "description": "Application context (e.g. applications/123)"},
+					"speed":             map[string]any{"type": "number", "description": "Speed multiplier (default: 1.0)"},
+					"continue_on_error": map[string]any{"type": "boolean", "description": "Keep going after a failed action (default: false)"},
+					"timeout":           map[string]any{"type": "number", "description": "Maximum execution time in seconds (capped at request timeout)"},
+				},
+				"required": []string{"name"},
+			},
+			Handler: s.handleMacroExecute,
+		},
+
+		// === CATEGORY 9: RECORDING (2 tools) ===
+
+		"recording_start": {
+			Name:        "recording_start",
+			Description: "Start recording successful input tool calls (click, double_click, type, keypress, scroll, drag, move, wait, click_element) for conversion into a macro.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"display_name": map[string]any{"type": "string", "description": "Display name for the recorded macro"},
+					"description":  map[string]any{"type": "string", "description": "Description for the recorded macro"},
+				},
+			},
+			Handler: s.handleRecordingStart,
+		},
+		"recording_stop": {
+			Name:        "recording_stop",
+			Description: "Stop recording and save the captured calls as a macro. Typed text becomes macro parameters (text_1, text_2, ...) defaulting to the recorded values.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"save":         map[string]any{"type": "boolean", "description": "Save the macro via macro_create (default: true); otherwise return the JSON definition"},
+					"parameterize": map[string]any{"type": "boolean", "description": "Turn typed text into macro parameters (default: true)"},
+					"macro_id":     map[string]any{"type": "string", "description": "Optional macro ID (default: server-generated)"},
+					"display_name": map[string]any{"type": "string", "description": "Display name (overrides recording_start)"},
+					"description":  map[string]any{"type": "string", "description": "Description (overrides recording_start)"},
+					"tags":         map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Tags for categorization"},
+				},
+			},
+			Handler: s.handleRecordingStop,
+		},
+
+		// === CATEGORY 10: INPUT ARBITRATION (1 tool) ===
+
+		"input_lease": {
+			Name:        "input_lease",
+			Description: "Hold exclusive control of mouse and keyboard input for a multi-step sequence. Input tool calls run one at a time; while a lease is held, only calls passing its ID as \"lease\" run, and others wait until it is released or expires.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"action":   map[string]any{"type": "string", "enum": []string{"acquire", "renew", "release", "status"}, "description": "acquire (wait for input, then hold it), renew, release, or status"},
+					"lease":    map[string]any{"type": "string", "description": "Lease ID returned by acquire (required for renew and release)"},
+					"duration": map[string]any{"type": "number", "description": "Lease duration in seconds for acquire and renew (default: 30, capped by MCP_INPUT_LEASE_MAX)"},
+				},
+				"required": []string{"action"},
+			},
+			Handler: s.handleInputLease,
+		},
+	}
+
+	// Input tools accept the ID of a held input_lease.
+	for name := range inputTools {
+		properties := s.tools[name].InputSchema["properties"].(map[string]any)
+		properties["lease"] = map[string]any{"type": "string", "description": "ID of the input_lease held by the caller, if any"}
 	}
 }
 
 // Serve starts serving MCP requests over the given stdio transport.
+// Requests are handled concurrently by up to MCP_MAX_CONCURRENCY workers, and
+// their responses written as they complete.
 // It blocks until the transport is closed or the server context is cancelled.
 func (s *MCPServer) Serve(tr *transport.StdioTransport) error {
 	log.Println("MCP server starting...")
@@ -580,6 +989,15 @@ func (s *MCPServer) Serve(tr *transport.StdioTransport) error {
 	}
 	msgCh := make(chan readResult)
 
+	// Requests wait in queue for one of the workers, while the reader keeps
+	// going, so that cancellations and responses to server-initiated requests
+	// reach the requests that are running. Each worker signals done once, so
+	// it never blocks after Serve returns.
+	workers := s.maxConcurrency()
+	done := make(chan struct{}, workers)
+	var queue []*transport.Message
+	running := 0
+
 	go func() {
 		for {
 			msg, err := tr.ReadMessage()
@@ -600,6 +1018,8 @@ func (s *MCPServer) Serve(tr *transport.StdioTransport) error {
 			log.Println("MCP server stopping (context cancelled)")
 			tr.Close() // Close transport to unblock reader goroutine
 			return nil
+		case <-done:
+			running--
 		case result := <-msgCh:
 			if result.err != nil {
 				if result.err == io.EOF || strings.Contains(result.err.Error(), "stdin closed") {
@@ -609,25 +1029,59 @@ func (s *MCPServer) Serve(tr *transport.StdioTransport) error {
 				log.Printf("Error reading message: %v", result.err)
 				continue
 			}
-			// Handle the message
-			go s.handleMessage(tr, result.msg)
+			// Notifications, such as notifications/cancelled, are handled
+			// inline so they are never queued behind the requests they affect.
+			if isNotification(result.msg) {
+				s.handleMessage(tr, result.msg)
+				continue
+			}
+			queue = append(queue, result.msg)
+		}
+
+		// Responses are written as each request completes, so they may be
+		// out of order.
+		for running < workers && len(queue) > 0 {
+			msg := queue[0]
+			queue[0] = nil
+			queue = queue[1:]
+			running++
+			go func() {
+				defer func() { done <- struct{}{} }()
+				s.handleMessage(tr, msg)
+			}()
 		}
 	}
 }
 
-// ServeHTTP starts serving MCP requests over the HTTP/SSE transport.
+// ServeHTTP starts serving MCP requests over the HTTP transport.
 // It blocks until the transport is closed or an error occurs.
 func (s *MCPServer) ServeHTTP(tr *transport.HTTPTransport) error {
-	log.Println("MCP server starting with HTTP/SSE transport...")
+	log.Println("MCP server starting with HTTP transport...")
 	s.mu.Lock()
 	s.httpTransport = tr
+	s.metrics = tr.Metrics()
+	s.mu.Unlock()
+	tr.OnSessionClose(s.endSession)
+	return tr.ServeScoped(s.handleScopedMessage)
+}
+
+// ServeWebSocket starts serving MCP requests over the WebSocket transport.
+// Each connection is a separate client session.
+// It blocks until the transport is closed or an error occurs.
+func (s *MCPServer) ServeWebSocket(tr *transport.WebSocketTransport) error {
+	log.Println("MCP server starting with WebSocket transport...")
+	s.mu.Lock()
+	s.wsTransport = tr
+	s.metrics = tr.Metrics()
 	s.mu.Unlock()
-	return tr.Serve(s.handleHTTPMessage)
+	tr.OnSessionClose(s.endSession)
+	return tr.ServeScoped(s.handleScopedMessage)
 }
 
 // validateAndProcessInitialize validates initialize params and returns the response or an error.
+// The client's capabilities are recorded in the client session identified by session.
 // This is shared between HTTP and stdio transports for consistency.
-func (s *MCPServer) validateAndProcessInitialize(msg *transport.Message) (*transport.Message, error) {
+func (s *MCPServer) validateAndProcessInitialize(msg *transport.Message, session string) (*transport.Message, error) {
 	// Parse the initialize params
 	var params MCPInitializeParams
 	if len(msg.Params) > 0 {
@@ -662,6 +1116,11 @@ func (s *MCPServer) validateAndProcessInitialize(msg *transport.Message) (*trans
 	}
 	log.Printf("INFO: MCP client connected: %s v%s (protocol: %s)", clientName, clientVersion, protocolVersion)
 
+	// Server-initiated elicitation requests require the client capability.
+	capabilities, _ := params.Capabilities.(map[string]any)
+	_, elicitation := capabilities["elicitation"]
+	s.withSession(session, func(c *clientSession) { c.elicitation = elicitation })
+
 	// Get display information for grounding
 	displayInfo := s.getDisplayGroundingInfo()
 
@@ -670,7 +1129,7 @@ func (s *MCPServer) validateAndProcessInitialize(msg *transport.Message) (*trans
 		"protocolVersion": protocolVersion,
 		"capabilities": map[string]any{
 			"tools":     map[string]any{},
-			"resources": map[string]any{"subscribe": false, "listChanged": false},
+			"resources": map[string]any{"subscribe": true, "listChanged": false},
 			"prompts":   map[string]any{},
 		},
 		"serverInfo":  map[string]any{"name": "macos-use-sdk", "version": "0.1.0"},
@@ -686,795 +1145,63 @@ func (s *MCPServer) validateAndProcessInitialize(msg *transport.Message) (*trans
 	}, nil
 }
 
-// handleHTTPMessage handles a single MCP message from HTTP transport
-func (s *MCPServer) handleHTTPMessage(msg *transport.Message) (*transport.Message, error) {
-	// Handle initialize request
-	if msg.Method == "initialize" {
-		return s.validateAndProcessInitialize(msg)
-	}
-
-	// Handle notifications/initialized - client acknowledgment of successful initialization
-	// Per MCP spec: clients send this notification after receiving initialize response
-	if msg.Method == "notifications/initialized" {
-		// This is a notification, no response required
-		return nil, nil
-	}
-
-	// Handle ping request.
-	// Per MCP 2025-11-25 basic/utilities/ping: both parties MUST respond promptly
-	// with an empty result.
-	if msg.Method == "ping" {
-		return &transport.Message{
-			JSONRPC: "2.0",
-			ID:      msg.ID,
-			Result:  []byte(`{}`),
-		}, nil
-	}
-
-	// Handle list_tools request
-	if msg.Method == "tools/list" {
-		s.mu.RLock()
-		tools := make([]map[string]any, 0, len(s.tools))
-		for _, tool := range s.tools {
-			tools = append(tools, map[string]any{
-				"name":        tool.Name,
-				"description": tool.Description,
-				"inputSchema": tool.InputSchema,
-			})
-		}
-		s.mu.RUnlock()
-
-		result, err := json.Marshal(map[string]any{"tools": tools})
-		if err != nil {
-			return &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeInternalError,
-					Message: "failed to marshal tools list",
-				},
-			}, nil
-		}
-		return &transport.Message{
-			JSONRPC: "2.0",
-			ID:      msg.ID,
-			Result:  result,
-		}, nil
-	}
-
-	// Handle resources/list request
-	if msg.Method == "resources/list" {
-		resources := []map[string]any{
-			{
-				"uri":         "screen://main",
-				"name":        "Main Display Screenshot",
-				"description": "Current screenshot of the main display",
-				"mimeType":    "image/png",
-			},
-			{
-				"uri":         "accessibility://",
-				"name":        "Accessibility Tree Template",
-				"description": "Use accessibility://{pid} to get element tree for an application",
-				"mimeType":    "application/json",
-			},
-			{
-				"uri":         "clipboard://current",
-				"name":        "Current Clipboard",
-				"description": "Current clipboard contents as text",
-				"mimeType":    "text/plain",
-			},
-		}
-		result, err := json.Marshal(map[string]any{"resources": resources})
-		if err != nil {
-			return &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeInternalError,
-					Message: fmt.Sprintf("internal error: %v", err),
-				},
-			}, nil
-		}
-		return &transport.Message{
-			JSONRPC: "2.0",
-			ID:      msg.ID,
-			Result:  result,
-		}, nil
-	}
-
-	// Handle resources/read request
-	if msg.Method == "resources/read" {
-		var params struct {
-			URI string `json:"uri"`
-		}
-		if err := json.Unmarshal(msg.Params, &params); err != nil {
-			return &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeInvalidParams,
-					Message: fmt.Sprintf("invalid params: %v", err),
-				},
-			}, nil
-		}
-
-		contents, err := s.readResource(params.URI)
-		if err != nil {
-			return &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeInternalError,
-					Message: err.Error(),
-				},
-			}, nil
-		}
-
-		result, err := json.Marshal(map[string]any{"contents": contents})
-		if err != nil {
-			return &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeInternalError,
-					Message: fmt.Sprintf("internal error: %v", err),
-				},
-			}, nil
-		}
-		return &transport.Message{
-			JSONRPC: "2.0",
-			ID:      msg.ID,
-			Result:  result,
-		}, nil
-	}
-
-	// Handle prompts/list request
-	if msg.Method == "prompts/list" {
-		prompts := s.listPrompts()
-		result, err := json.Marshal(map[string]any{"prompts": prompts})
-		if err != nil {
-			return &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeInternalError,
-					Message: fmt.Sprintf("internal error: %v", err),
-				},
-			}, nil
-		}
-		return &transport.Message{
-			JSONRPC: "2.0",
-			ID:      msg.ID,
-			Result:  result,
-		}, nil
-	}
-
-	// Handle prompts/get request
-	if msg.Method == "prompts/get" {
-		var params struct {
-			Arguments map[string]any `json:"arguments"`
-			Name      string         `json:"name"`
-		}
-		if err := json.Unmarshal(msg.Params, &params); err != nil {
-			return &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeInvalidParams,
-					Message: fmt.Sprintf("invalid params: %v", err),
-				},
-			}, nil
-		}
-
-		prompt, err := s.getPrompt(params.Name, params.Arguments)
-		if err != nil {
-			return &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeInvalidParams, // Unknown prompt name is invalid params per MCP spec
-					Message: err.Error(),
-				},
-			}, nil
-		}
-
-		result, err := json.Marshal(prompt)
-		if err != nil {
-			return &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeInternalError,
-					Message: fmt.Sprintf("internal error: %v", err),
-				},
-			}, nil
-		}
-		return &transport.Message{
-			JSONRPC: "2.0",
-			ID:      msg.ID,
-			Result:  result,
-		}, nil
-	}
-
-	// Handle tool call
-	if msg.Method == "tools/call" {
-		var params struct {
-			Name      string          `json:"name"`
-			Arguments json.RawMessage `json:"arguments"`
-		}
-		if err := json.Unmarshal(msg.Params, &params); err != nil {
-			return &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeInvalidParams,
-					Message: fmt.Sprintf("Invalid params: %v", err),
-				},
-			}, nil
-		}
-
-		s.mu.RLock()
-		tool, ok := s.tools[params.Name]
-		s.mu.RUnlock()
-
-		if !ok {
-			return &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeMethodNotFound,
-					Message: fmt.Sprintf("Tool not found: %s", params.Name),
-				},
-			}, nil
-		}
-
-		// Validate tool input against schema before calling handler
-		var args map[string]any
-		if len(params.Arguments) > 0 {
-			if err := json.Unmarshal(params.Arguments, &args); err != nil {
-				return &transport.Message{
-					JSONRPC: "2.0",
-					ID:      msg.ID,
-					Error: &transport.ErrorObj{
-						Code:    transport.ErrCodeInvalidParams,
-						Message: fmt.Sprintf("Invalid arguments JSON: %v", err),
-					},
-				}, nil
-			}
-		} else {
-			args = make(map[string]any)
-		}
-
-		s.mu.RLock()
-		validationErr := validateToolInput(params.Name, args, s.tools)
-		s.mu.RUnlock()
-		if validationErr != nil {
-			validationErr.ID = msg.ID
-			return validationErr, nil
-		}
-
-		call := &ToolCall{
-			Name:      params.Name,
-			Arguments: params.Arguments,
-		}
-
-		// Track start time for metrics
-		startTime := time.Now()
-
-		result, err := tool.Handler(call)
-
-		// Calculate duration
-		duration := time.Since(startTime)
-
-		// Determine status and record metrics
-		status := "ok"
-		if err != nil {
-			status = "error"
-		} else if result != nil && result.IsError {
-			status = "error"
-		}
-
-		// Record metrics if HTTP transport is available
-		s.mu.RLock()
-		httpTransport := s.httpTransport
-		s.mu.RUnlock()
-		if httpTransport != nil {
-			httpTransport.Metrics().RecordRequest(params.Name, status, duration)
-		}
-
-		// Record audit log
-		if s.auditLogger != nil {
-			s.auditLogger.LogToolCall(params.Name, params.Arguments, status, duration)
-		}
-
-		if err != nil {
-			return &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeInternalError,
-					Message: err.Error(),
-				},
-			}, nil
-		}
-
-		resultJSON, err := json.Marshal(result)
-		if err != nil {
-			return &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeInternalError,
-					Message: "failed to marshal tool result",
-				},
-			}, nil
-		}
-		return &transport.Message{
-			JSONRPC: "2.0",
-			ID:      msg.ID,
-			Result:  resultJSON,
-		}, nil
-	}
+// currentHTTPTransport returns the HTTP transport, or nil when serving over stdio.
+func (s *MCPServer) currentHTTPTransport() *transport.HTTPTransport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.httpTransport
+}
 
-	// Unknown method.
-	// Notifications (messages without an ID) MUST NOT receive a response.
-	if len(msg.ID) == 0 || string(msg.ID) == "null" {
-		return nil, nil
+// handleHTTPMessage handles a single MCP message from the legacy HTTP endpoints.
+// Server-initiated messages are broadcast to the legacy SSE clients.
+func (s *MCPServer) handleHTTPMessage(msg *transport.Message) (*transport.Message, error) {
+	req := &MethodRequest{Message: msg}
+	if tr := s.currentHTTPTransport(); tr != nil {
+		req.Transport = tr
 	}
+	return s.dispatch(req), nil
+}
 
-	return &transport.Message{
-		JSONRPC: "2.0",
-		ID:      msg.ID,
-		Error: &transport.ErrorObj{
-			Code:    transport.ErrCodeMethodNotFound,
-			Message: fmt.Sprintf("Method not found: %s", msg.Method),
-		},
-	}, nil
+// handleScopedMessage handles a single MCP message from the HTTP or WebSocket
+// transport. Server-initiated messages are sent to tr, which delivers them to
+// the client that sent msg.
+func (s *MCPServer) handleScopedMessage(tr transport.Transport, msg *transport.Message) (*transport.Message, error) {
+	return s.dispatch(&MethodRequest{Message: msg, Transport: tr}), nil
 }
 
-// handleMessage handles a single MCP message
+// handleMessage handles a single MCP message from stdio transport, writing
+// any response back to it.
 func (s *MCPServer) handleMessage(tr *transport.StdioTransport, msg *transport.Message) {
-	// Handle initialize request
-	if msg.Method == "initialize" {
-		response, err := s.validateAndProcessInitialize(msg)
-		if err != nil {
-			log.Printf("Error processing initialize: %v", err)
-			return
-		}
-		if response != nil {
-			if err := tr.WriteMessage(response); err != nil {
-				log.Printf("Error writing response: %v", err)
-			}
-		}
+	response := s.dispatch(&MethodRequest{Message: msg, Transport: tr})
+	if response == nil {
 		return
 	}
-
-	// Handle notifications/initialized - client acknowledgment of successful initialization
-	// Per MCP spec: clients send this notification after receiving initialize response
-	if msg.Method == "notifications/initialized" {
-		// This is a notification, no response required
-		return
-	}
-
-	// Handle ping request.
-	// Per MCP 2025-11-25 basic/utilities/ping: the receiver MUST respond with an empty result.
-	if msg.Method == "ping" {
-		response := &transport.Message{
-			JSONRPC: "2.0",
-			ID:      msg.ID,
-			Result:  []byte(`{}`),
-		}
-		if err := tr.WriteMessage(response); err != nil {
-			log.Printf("Error writing response: %v", err)
-		}
-		return
-	}
-
-	// Handle list_tools request
-	if msg.Method == "tools/list" {
-		s.mu.RLock()
-		tools := make([]map[string]any, 0, len(s.tools))
-		for _, tool := range s.tools {
-			tools = append(tools, map[string]any{
-				"name":        tool.Name,
-				"description": tool.Description,
-				"inputSchema": tool.InputSchema,
-			})
-		}
-		s.mu.RUnlock()
-
-		result, err := json.Marshal(map[string]any{"tools": tools})
-		if err != nil {
-			log.Printf("Error marshaling tools list: %v", err)
-			response := &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeInternalError,
-					Message: "failed to marshal tools list",
-				},
-			}
-			if err := tr.WriteMessage(response); err != nil {
-				log.Printf("Error writing response: %v", err)
-			}
-			return
-		}
-		response := &transport.Message{
-			JSONRPC: "2.0",
-			ID:      msg.ID,
-			Result:  result,
-		}
-		if err := tr.WriteMessage(response); err != nil {
-			log.Printf("Error writing response: %v", err)
-		}
-		return
-	}
-
-	// Handle resources/list request
-	if msg.Method == "resources/list" {
-		resources := []map[string]any{
-			{
-				"uri":         "screen://main",
-				"name":        "Main Display Screenshot",
-				"description": "Current screenshot of the main display",
-				"mimeType":    "image/png",
-			},
-			{
-				"uri":         "accessibility://",
-				"name":        "Accessibility Tree Template",
-				"description": "Use accessibility://{pid} to get element tree for an application",
-				"mimeType":    "application/json",
-			},
-			{
-				"uri":         "clipboard://current",
-				"name":        "Current Clipboard",
-				"description": "Current clipboard contents as text",
-				"mimeType":    "text/plain",
-			},
-		}
-		result, err := json.Marshal(map[string]any{"resources": resources})
-		if err != nil {
-			response := &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeInternalError,
-					Message: fmt.Sprintf("internal error: %v", err),
-				},
-			}
-			if writeErr := tr.WriteMessage(response); writeErr != nil {
-				log.Printf("Error writing error response: %v", writeErr)
-			}
-			return
-		}
-		response := &transport.Message{
-			JSONRPC: "2.0",
-			ID:      msg.ID,
-			Result:  result,
-		}
-		if err := tr.WriteMessage(response); err != nil {
-			log.Printf("Error writing response: %v", err)
-		}
-		return
-	}
-
-	// Handle resources/read request
-	if msg.Method == "resources/read" {
-		var params struct {
-			URI string `json:"uri"`
-		}
-		if err := json.Unmarshal(msg.Params, &params); err != nil {
-			response := &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeInvalidParams,
-					Message: fmt.Sprintf("invalid params: %v", err),
-				},
-			}
-			if err := tr.WriteMessage(response); err != nil {
-				log.Printf("Error writing response: %v", err)
-			}
-			return
-		}
-
-		contents, err := s.readResource(params.URI)
-		if err != nil {
-			response := &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeInternalError,
-					Message: err.Error(),
-				},
-			}
-			if err := tr.WriteMessage(response); err != nil {
-				log.Printf("Error writing response: %v", err)
-			}
-			return
-		}
-
-		result, err := json.Marshal(map[string]any{"contents": contents})
-		if err != nil {
-			response := &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeInternalError,
-					Message: fmt.Sprintf("internal error: %v", err),
-				},
-			}
-			if writeErr := tr.WriteMessage(response); writeErr != nil {
-				log.Printf("Error writing error response: %v", writeErr)
-			}
-			return
-		}
-		response := &transport.Message{
-			JSONRPC: "2.0",
-			ID:      msg.ID,
-			Result:  result,
-		}
-		if err := tr.WriteMessage(response); err != nil {
-			log.Printf("Error writing response: %v", err)
-		}
-		return
-	}
-
-	// Handle prompts/list request
-	if msg.Method == "prompts/list" {
-		prompts := s.listPrompts()
-		result, err := json.Marshal(map[string]any{"prompts": prompts})
-		if err != nil {
-			response := &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeInternalError,
-					Message: fmt.Sprintf("internal error: %v", err),
-				},
-			}
-			if writeErr := tr.WriteMessage(response); writeErr != nil {
-				log.Printf("Error writing error response: %v", writeErr)
-			}
-			return
-		}
-		response := &transport.Message{
-			JSONRPC: "2.0",
-			ID:      msg.ID,
-			Result:  result,
-		}
-		if err := tr.WriteMessage(response); err != nil {
-			log.Printf("Error writing response: %v", err)
-		}
-		return
-	}
-
-	// Handle prompts/get request
-	if msg.Method == "prompts/get" {
-		var params struct {
-			Arguments map[string]any `json:"arguments"`
-			Name      string         `json:"name"`
-		}
-		if err := json.Unmarshal(msg.Params, &params); err != nil {
-			response := &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeInvalidParams,
-					Message: fmt.Sprintf("invalid params: %v", err),
-				},
-			}
-			if err := tr.WriteMessage(response); err != nil {
-				log.Printf("Error writing response: %v", err)
-			}
-			return
-		}
-
-		prompt, err := s.getPrompt(params.Name, params.Arguments)
-		if err != nil {
-			response := &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeInvalidParams, // Unknown prompt name is invalid params per MCP spec
-					Message: err.Error(),
-				},
-			}
-			if err := tr.WriteMessage(response); err != nil {
-				log.Printf("Error writing response: %v", err)
-			}
-			return
-		}
-
-		result, err := json.Marshal(prompt)
-		if err != nil {
-			response := &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeInternalError,
-					Message: fmt.Sprintf("internal error: %v", err),
-				},
-			}
-			if writeErr := tr.WriteMessage(response); writeErr != nil {
-				log.Printf("Error writing error response: %v", writeErr)
-			}
-			return
-		}
-		response := &transport.Message{
-			JSONRPC: "2.0",
-			ID:      msg.ID,
-			Result:  result,
-		}
-		if err := tr.WriteMessage(response); err != nil {
-			log.Printf("Error writing response: %v", err)
-		}
-		return
-	}
-
-	// Handle tool call request
-	if msg.Method == "tools/call" {
-		var params struct {
-			Name      string          `json:"name"`
-			Arguments json.RawMessage `json:"arguments"`
-		}
-		if err := json.Unmarshal(msg.Params, &params); err != nil {
-			response := &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeInvalidParams,
-					Message: fmt.Sprintf("Invalid params: %v", err),
-				},
-			}
-			if err := tr.WriteMessage(response); err != nil {
-				log.Printf("Error writing response: %v", err)
-			}
-			return
-		}
-
-		s.mu.RLock()
-		tool, exists := s.tools[params.Name]
-		s.mu.RUnlock()
-
-		if !exists {
-			response := &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeMethodNotFound,
-					Message: fmt.Sprintf("Tool not found: %s", params.Name),
-				},
-			}
-			if err := tr.WriteMessage(response); err != nil {
-				log.Printf("Error writing response: %v", err)
-			}
-			return
-		}
-
-		// Validate tool input against schema before calling handler
-		var args map[string]any
-		if len(params.Arguments) > 0 {
-			if err := json.Unmarshal(params.Arguments, &args); err != nil {
-				response := &transport.Message{
-					JSONRPC: "2.0",
-					ID:      msg.ID,
-					Error: &transport.ErrorObj{
-						Code:    transport.ErrCodeInvalidParams,
-						Message: fmt.Sprintf("Invalid arguments JSON: %v", err),
-					},
-				}
-				if err := tr.WriteMessage(response); err != nil {
-					log.Printf("Error writing response: %v", err)
-				}
-				return
-			}
-		} else {
-			args = make(map[string]any)
-		}
-
-		s.mu.RLock()
-		validationErr := validateToolInput(params.Name, args, s.tools)
-		s.mu.RUnlock()
-		if validationErr != nil {
-			validationErr.ID = msg.ID
-			if err := tr.WriteMessage(validationErr); err != nil {
-				log.Printf("Error writing response: %v", err)
-			}
-			return
-		}
-
-		// Track start time for metrics
-		startTime := time.Now()
-
-		// Call the tool handler
-		result, err := tool.Handler(&ToolCall{
-			Name:      params.Name,
-			Arguments: params.Arguments,
-		})
-
-		// Calculate duration
-		duration := time.Since(startTime)
-
-		// Determine status
-		status := "ok"
-		if err != nil {
-			status = "error"
-		} else if result != nil && result.IsError {
-			status = "error"
-		}
-
-		// Record audit log (stdio transport has no metrics registry)
-		if s.auditLogger != nil {
-			s.auditLogger.LogToolCall(params.Name, params.Arguments, status, duration)
-		}
-
-		if err != nil {
-			response := &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeInternalError,
-					Message: err.Error(),
-				},
-			}
-			if err := tr.WriteMessage(response); err != nil {
-				log.Printf("Error writing response: %v", err)
-			}
-			return
-		}
-
-		// Format the result as content array
-		resultMap := map[string]any{
-			"content": result.Content,
-		}
-		if result.IsError {
-			resultMap["isError"] = true
-		}
-
-		resultBytes, err := json.Marshal(resultMap)
-		if err != nil {
-			log.Printf("Error marshaling tool result: %v", err)
-			response := &transport.Message{
-				JSONRPC: "2.0",
-				ID:      msg.ID,
-				Error: &transport.ErrorObj{
-					Code:    transport.ErrCodeInternalError,
-					Message: "failed to marshal tool result",
-				},
-			}
-			if err := tr.WriteMessage(response); err != nil {
-				log.Printf("Error writing response: %v", err)
-			}
-			return
-		}
-		response := &transport.Message{
-			JSONRPC: "2.0",
-			ID:      msg.ID,
-			Result:  resultBytes,
-		}
-		if err := tr.WriteMessage(response); err != nil {
-			log.Printf("Error writing response: %v", err)
-		}
-		return
+	if err := tr.WriteMessage(response); err != nil {
+		log.Printf("Error writing response: %v", err)
 	}
+}
 
-	// Handle unknown method.
-	// Per MCP 2025-11-25 base protocol, notifications (messages without an ID)
-	// MUST NOT receive a response.
-	if len(msg.ID) == 0 || string(msg.ID) == "null" {
-		return
-	}
-	response := &transport.Message{
-		JSONRPC: "2.0",
-		ID:      msg.ID,
-		Error: &transport.ErrorObj{
-			Code:    transport.ErrCodeMethodNotFound,
-			Message: fmt.Sprintf("Method not found: %s", msg.Method),
+// listResources returns the resources advertised by resources/list.
+func listResources() []map[string]any {
+	return []map[string]any{
+		{
+			"uri":         "screen://main",
+			"name":        "Main Display Screenshot",
+			"description": "Current screenshot of the main display",
+			"mimeType":    "image/png",
+		},
+		{
+			"uri":         "accessibility://",
+			"name":        "Accessibility Tree Template",
+			"description": "Use accessibility://{pid} to get element tree for an application",
+			"mimeType":    "application/json",
+		},
+		{
+			"uri":         "clipboard://current",
+			"name":        "Current Clipboard",
+			"description": "Current clipboard contents as text",
+			"mimeType":    "text/plain",
 		},
-	}
-	if err := tr.WriteMessage(response); err != nil {
-		log.Printf("Error writing response: %v", err)
 	}
 }
 
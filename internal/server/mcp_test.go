@@ -495,11 +495,16 @@ func TestAllToolsExist(t *testing.T) {
 		"open_app",
 		"list_apps",
 		"close_app",
-		// Element Interaction (4)
+		// Element Interaction (9)
 		"find_elements",
 		"click_element",
 		"type_element",
 		"read_element",
+		"element_actions",
+		"perform_element_action",
+		"wait_for_element",
+		"wait_for_state",
+		"get_accessibility_tree",
 		// Window Management (4)
 		"focus_window",
 		"move_window",
@@ -509,10 +514,31 @@ func TestAllToolsExist(t *testing.T) {
 		"clipboard",
 		"run",
 		"get_display",
-	}
-
-	if len(expectedTools) != 23 {
-		t.Errorf("Expected 23 tools but defined %d in test", len(expectedTools))
+		// Observation (4)
+		"observe_start",
+		"observe_poll",
+		"observe_list",
+		"observe_cancel",
+		// Session (3)
+		"session",
+		"transaction",
+		"session_snapshot",
+		// Macro (6)
+		"macro_create",
+		"macro_list",
+		"macro_get",
+		"macro_update",
+		"macro_delete",
+		"macro_execute",
+		// Recording (2)
+		"recording_start",
+		"recording_stop",
+		// Input Arbitration (1)
+		"input_lease",
+	}
+
+	if len(expectedTools) != 44 {
+		t.Errorf("Expected 44 tools but defined %d in test", len(expectedTools))
 	}
 
 	server := &MCPServer{tools: make(map[string]*Tool)}
@@ -556,6 +582,11 @@ func TestToolNaming(t *testing.T) {
 		"click_element",
 		"type_element",
 		"read_element",
+		"element_actions",
+		"perform_element_action",
+		"wait_for_element",
+		"wait_for_state",
+		"get_accessibility_tree",
 		"focus_window",
 		"move_window",
 		"resize_window",
@@ -563,6 +594,22 @@ func TestToolNaming(t *testing.T) {
 		"clipboard",
 		"run",
 		"get_display",
+		"observe_start",
+		"observe_poll",
+		"observe_list",
+		"observe_cancel",
+		"session",
+		"transaction",
+		"session_snapshot",
+		"macro_create",
+		"macro_list",
+		"macro_get",
+		"macro_update",
+		"macro_delete",
+		"macro_execute",
+		"recording_start",
+		"recording_stop",
+		"input_lease",
 	}
 
 	for _, toolName := range tools {
@@ -1204,6 +1251,32 @@ func TestMCPServer_HandleHTTPMessage_Ping(t *testing.T) {
 	}
 }
 
+// TestMCPServer_HandleScopedMessage verifies tool calls from a Streamable
+// HTTP session or WebSocket connection see the transport scoped to the request, so server-initiated
+// messages reach the client that made the call.
+func TestMCPServer_HandleScopedMessage(t *testing.T) {
+	s := newTestMCPServer(nil)
+	var got transport.Transport
+	s.tools["probe"] = &Tool{Name: "probe", Handler: func(call *ToolCall) (*ToolResult, error) {
+		got = call.transport
+		return textResult("ok"), nil
+	}}
+
+	sink := newChanSink()
+	resp, err := s.handleScopedMessage(sink, &transport.Message{
+		JSONRPC: "2.0",
+		ID:      json.RawMessage(`1`),
+		Method:  "tools/call",
+		Params:  json.RawMessage(`{"name":"probe","arguments":{}}`),
+	})
+	if err != nil || resp == nil || resp.Error != nil {
+		t.Fatalf("unexpected response %+v: %v", resp, err)
+	}
+	if got != sink {
+		t.Errorf("tool call transport = %v, want the scoped transport", got)
+	}
+}
+
 // TestMCPServer_HandleStdioMessage_Ping verifies the stdio handler responds to
 // ping with an empty result per MCP 2025-11-25.
 func TestMCPServer_HandleStdioMessage_Ping(t *testing.T) {
@@ -1314,7 +1387,7 @@ func TestValidateAndProcessInitialize_ProtocolVersions(t *testing.T) {
 				Params:  paramsJSON,
 			}
 
-			resp, err := s.validateAndProcessInitialize(msg)
+			resp, err := s.validateAndProcessInitialize(msg, "")
 
 			if err != nil {
 				t.Fatalf("validateAndProcessInitialize returned Go error: %v", err)
@@ -1422,7 +1495,7 @@ func TestValidateAndProcessInitialize_ClientInfo(t *testing.T) {
 				Params:  paramsJSON,
 			}
 
-			resp, err := s.validateAndProcessInitialize(msg)
+			resp, err := s.validateAndProcessInitialize(msg, "")
 
 			if err != nil {
 				t.Fatalf("validateAndProcessInitialize returned Go error: %v", err)
@@ -1465,7 +1538,7 @@ func TestValidateAndProcessInitialize_ResponseFormat(t *testing.T) {
 		Params:  paramsJSON,
 	}
 
-	resp, err := s.validateAndProcessInitialize(msg)
+	resp, err := s.validateAndProcessInitialize(msg, "")
 	if err != nil {
 		t.Fatalf("validateAndProcessInitialize returned Go error: %v", err)
 	}
@@ -1541,7 +1614,7 @@ func TestValidateAndProcessInitialize_UnsupportedVersionNegotiation(t *testing.T
 		Params:  paramsJSON,
 	}
 
-	resp, err := s.validateAndProcessInitialize(msg)
+	resp, err := s.validateAndProcessInitialize(msg, "")
 	if err != nil {
 		t.Fatalf("validateAndProcessInitialize returned Go error: %v", err)
 	}
@@ -2519,7 +2592,7 @@ func TestMCPResourcesCapabilityAnnouncement(t *testing.T) {
 		"capabilities": {
 			"tools": {},
 			"resources": {
-				"subscribe": false,
+				"subscribe": true,
 				"listChanged": false
 			}
 		},
@@ -2550,8 +2623,8 @@ func TestMCPResourcesCapabilityAnnouncement(t *testing.T) {
 	if !ok {
 		t.Error("Resources should have 'subscribe' boolean field")
 	}
-	if subscribe != false {
-		t.Errorf("Resources subscribe = %v, want false (not implemented)", subscribe)
+	if subscribe != true {
+		t.Errorf("Resources subscribe = %v, want true", subscribe)
 	}
 
 	listChanged, ok := resources["listChanged"].(bool)
@@ -3351,7 +3424,7 @@ func TestMCPPromptsCapabilityAnnouncement(t *testing.T) {
 			"capabilities": {
 				"tools": {},
 				"resources": {
-					"subscribe": false,
+					"subscribe": true,
 					"listChanged": false
 				},
 				"prompts": {}
@@ -3722,7 +3795,7 @@ func TestMCPServer_HandleHTTPMessage_PromptsList(t *testing.T) {
 // ============================================================================
 
 // getTestToolRegistry creates a minimal MCPServer and returns its tools map for testing.
-// This allows us to programmatically validate all 23 registered tool schemas.
+// This allows us to programmatically validate all 39 registered tool schemas.
 func getTestToolRegistry(t *testing.T) map[string]*Tool {
 	t.Helper()
 	ctx := context.Background()
@@ -3743,9 +3816,9 @@ func getTestToolRegistry(t *testing.T) map[string]*Tool {
 func TestToolSchemaCompleteness(t *testing.T) {
 	tools := getTestToolRegistry(t)
 
-	// Verify we have exactly 23 tools
-	if len(tools) != 23 {
-		t.Errorf("Expected 23 tools, got %d", len(tools))
+	// Verify we have exactly 44 tools
+	if len(tools) != 44 {
+		t.Errorf("Expected 44 tools, got %d", len(tools))
 	}
 
 	var issues []string
@@ -4186,18 +4259,18 @@ func TestToolSchemaDescriptionQuality(t *testing.T) {
 	}
 }
 
-// TestToolSchemaToolCount validates that exactly 23 tools are registered.
+// TestToolSchemaToolCount validates that exactly 44 tools are registered.
 // This ensures no tools are accidentally removed or duplicated.
 func TestToolSchemaToolCount(t *testing.T) {
 	tools := getTestToolRegistry(t)
 
-	if len(tools) != 23 {
+	if len(tools) != 44 {
 		// List all tool names for debugging
 		var names []string
 		for name := range tools {
 			names = append(names, name)
 		}
-		t.Errorf("Expected 23 tools, got %d. Tools: %v", len(tools), names)
+		t.Errorf("Expected 44 tools, got %d. Tools: %v", len(tools), names)
 	}
 }
 
@@ -4227,6 +4300,11 @@ func TestToolSchemaToolCategories(t *testing.T) {
 			"click_element",
 			"type_element",
 			"read_element",
+			"element_actions",
+			"perform_element_action",
+			"wait_for_element",
+			"wait_for_state",
+			"get_accessibility_tree",
 		},
 		"Window": {
 			"focus_window",
@@ -4239,6 +4317,32 @@ func TestToolSchemaToolCategories(t *testing.T) {
 			"run",
 			"get_display",
 		},
+		"Observation": {
+			"observe_start",
+			"observe_poll",
+			"observe_list",
+			"observe_cancel",
+		},
+		"Session": {
+			"session",
+			"transaction",
+			"session_snapshot",
+		},
+		"Macro": {
+			"macro_create",
+			"macro_list",
+			"macro_get",
+			"macro_update",
+			"macro_delete",
+			"macro_execute",
+		},
+		"Recording": {
+			"recording_start",
+			"recording_stop",
+		},
+		"Input Arbitration": {
+			"input_lease",
+		},
 	}
 
 	tools := getTestToolRegistry(t)
@@ -0,0 +1,126 @@
+// Copyright 2025 Joseph Cumines
+//
+// Tool middleware — composable wrappers around Tool handlers, including the
+// built-in metrics, audit and recording middlewares
+
+package server
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/joeycumines/MacosUseSDK/internal/transport"
+)
+
+// ToolHandler is the signature of a tool implementation.
+type ToolHandler func(*ToolCall) (*ToolResult, error)
+
+// ToolMiddleware wraps a ToolHandler to add behaviour around tool calls, such
+// as approval gates, argument rewriting, result redaction or caching. A
+// middleware may call next zero or more times, and may alter the call before
+// it or the result after it.
+type ToolMiddleware func(next ToolHandler) ToolHandler
+
+// Use appends middlewares to the server's tool middleware chain. Middlewares
+// apply to tools/call on every transport, in the order given: the first is
+// outermost. The built-in metrics, audit, cancellation, scope, policy,
+// confirmation, recording, serialisation and input arbitration middlewares always run
+// outside of any added with Use, so they observe the final outcome of a call.
+func (s *MCPServer) Use(middlewares ...ToolMiddleware) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.middlewares = append(s.middlewares, middlewares...)
+}
+
+// chainToolHandler wraps handler with the built-in middlewares followed by
+// those added with Use.
+func (s *MCPServer) chainToolHandler(handler ToolHandler) ToolHandler {
+	s.mu.RLock()
+	chain := []ToolMiddleware{
+		MetricsMiddleware(s.metrics),
+		AuditMiddleware(s.auditLogger),
+		cancellationMiddleware,
+		s.scopeMiddleware,
+		s.policyMiddleware,
+		s.confirmationMiddleware,
+		s.recordingMiddleware,
+		s.serialMiddleware,
+		s.inputMiddleware,
+	}
+	chain = append(chain, s.middlewares...)
+	s.mu.RUnlock()
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		handler = chain[i](handler)
+	}
+	return handler
+}
+
+// toolCallStatus classifies the outcome of a tool call as "ok", "error", or
+// the status set on the result, such as "denied" (rejected by the tool
+// policy), "declined" (not approved by the operator) or "cancelled" (cancelled
+// by the client).
+func toolCallStatus(result *ToolResult, err error) string {
+	if err == nil && result != nil && result.status != "" {
+		return result.status
+	}
+	if err != nil || (result != nil && result.IsError) {
+		return "error"
+	}
+	return "ok"
+}
+
+// MetricsMiddleware records the count and latency of tool calls in metrics.
+// A nil registry disables recording.
+func MetricsMiddleware(metrics *transport.MetricsRegistry) ToolMiddleware {
+	return func(next ToolHandler) ToolHandler {
+		if metrics == nil {
+			return next
+		}
+		return func(call *ToolCall) (*ToolResult, error) {
+			name := call.Name
+			startTime := time.Now()
+			result, err := next(call)
+			metrics.RecordRequest(name, toolCallStatus(result, err), time.Since(startTime))
+			return result, err
+		}
+	}
+}
+
+// AuditMiddleware writes an audit log entry for every tool call, with the
+// name of the client's API key, or the subject of its OAuth access token, and
+// the subject of its TLS client certificate, if it authenticated. A nil or
+// disabled logger disables auditing.
+func AuditMiddleware(logger *AuditLogger) ToolMiddleware {
+	return func(next ToolHandler) ToolHandler {
+		if !logger.IsEnabled() {
+			return next
+		}
+		return func(call *ToolCall) (*ToolResult, error) {
+			// Audit the call as received, before inner middlewares rewrite it.
+			name, args := call.Name, call.Arguments
+			startTime := time.Now()
+			result, err := next(call)
+			var attrs []slog.Attr
+			if principal := principalOf(call.transport); principal != nil {
+				attrs = principalAttrs(principal)
+			}
+			logger.LogToolCall(name, args, toolCallStatus(result, err), time.Since(startTime), attrs...)
+			return result, err
+		}
+	}
+}
+
+// recordingMiddleware captures successful tool calls into the calling
+// client's active recording_start session, if any.
+func (s *MCPServer) recordingMiddleware(next ToolHandler) ToolHandler {
+	return func(call *ToolCall) (*ToolResult, error) {
+		name, args := call.Name, call.Arguments
+		session, _ := sessionOf(call.transport)
+		result, err := next(call)
+		if toolCallStatus(result, err) == "ok" {
+			s.recordToolCall(session, name, args)
+		}
+		return result, err
+	}
+}
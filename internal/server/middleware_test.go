@@ -0,0 +1,184 @@
+// Copyright 2025 Joseph Cumines
+//
+// Tests for the tool middleware chain and the built-in middlewares.
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joeycumines/MacosUseSDK/internal/transport"
+)
+
+func callToolMethod(t *testing.T, s *MCPServer, name, args string) *transport.Message {
+	t.Helper()
+	params, err := json.Marshal(map[string]any{"name": name, "arguments": json.RawMessage(args)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.dispatch(&MethodRequest{Message: &transport.Message{
+		JSONRPC: "2.0",
+		ID:      json.RawMessage(`1`),
+		Method:  "tools/call",
+		Params:  params,
+	}})
+}
+
+func TestUse_MiddlewareOrderAndRewriting(t *testing.T) {
+	s := newTestMCPServer(nil)
+
+	var order []string
+	var gotArgs string
+	s.tools["echo"] = &Tool{Name: "echo", Handler: func(call *ToolCall) (*ToolResult, error) {
+		order = append(order, "handler")
+		gotArgs = string(call.Arguments)
+		return textResult("secret value"), nil
+	}}
+
+	trace := func(name string) ToolMiddleware {
+		return func(next ToolHandler) ToolHandler {
+			return func(call *ToolCall) (*ToolResult, error) {
+				order = append(order, name+":before")
+				result, err := next(call)
+				order = append(order, name+":after")
+				return result, err
+			}
+		}
+	}
+	rewrite := func(next ToolHandler) ToolHandler {
+		return func(call *ToolCall) (*ToolResult, error) {
+			call.Arguments = json.RawMessage(`{"rewritten":true}`)
+			return next(call)
+		}
+	}
+	redact := func(next ToolHandler) ToolHandler {
+		return func(call *ToolCall) (*ToolResult, error) {
+			result, err := next(call)
+			if result != nil {
+				for i := range result.Content {
+					result.Content[i].Text = strings.ReplaceAll(result.Content[i].Text, "secret", "[REDACTED]")
+				}
+			}
+			return result, err
+		}
+	}
+	s.Use(trace("outer"), trace("inner"))
+	s.Use(rewrite, redact)
+
+	resp := callToolMethod(t, s, "echo", `{"original":true}`)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %+v", resp.Error)
+	}
+
+	wantOrder := []string{"outer:before", "inner:before", "handler", "inner:after", "outer:after"}
+	if strings.Join(order, ",") != strings.Join(wantOrder, ",") {
+		t.Errorf("order = %v, want %v", order, wantOrder)
+	}
+	if gotArgs != `{"rewritten":true}` {
+		t.Errorf("handler arguments = %s, want rewritten", gotArgs)
+	}
+	if !strings.Contains(string(resp.Result), "[REDACTED] value") {
+		t.Errorf("result not redacted: %s", resp.Result)
+	}
+}
+
+func TestUse_MiddlewareCanShortCircuit(t *testing.T) {
+	s := newTestMCPServer(nil)
+	s.metrics = transport.NewMetricsRegistry()
+
+	called := false
+	s.tools["close_app"] = &Tool{Name: "close_app", Handler: func(call *ToolCall) (*ToolResult, error) {
+		called = true
+		return textResult("closed"), nil
+	}}
+	s.tools["boom"] = &Tool{Name: "boom", Handler: func(call *ToolCall) (*ToolResult, error) {
+		return textResult("unreachable"), nil
+	}}
+	s.Use(func(next ToolHandler) ToolHandler {
+		return func(call *ToolCall) (*ToolResult, error) {
+			switch call.Name {
+			case "close_app":
+				return errorResult("approval required"), nil
+			case "boom":
+				return nil, errors.New("middleware failure")
+			}
+			return next(call)
+		}
+	})
+
+	resp := callToolMethod(t, s, "close_app", `{}`)
+	if called {
+		t.Error("handler should not run when middleware short-circuits")
+	}
+	var result ToolResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsError || !resultContains(&result, "approval required") {
+		t.Errorf("unexpected result: %s", resp.Result)
+	}
+
+	resp = callToolMethod(t, s, "boom", `{}`)
+	if resp.Error == nil || resp.Error.Code != transport.ErrCodeInternalError || resp.Error.Message != "middleware failure" {
+		t.Errorf("expected internal error from middleware, got %+v", resp)
+	}
+
+	// Built-in metrics observe the final outcome, including short-circuits.
+	var out bytes.Buffer
+	if err := s.metrics.WritePrometheus(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`mcp_requests_total{tool="close_app",status="error"} 1`, `mcp_requests_total{tool="boom",status="error"} 1`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in metrics, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestAuditMiddleware_LogsCallAsReceived(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "audit.log")
+	logger, err := NewAuditLogger(logPath)
+	if err != nil {
+		t.Fatalf("NewAuditLogger error = %v", err)
+	}
+	defer logger.Close()
+
+	handler := AuditMiddleware(logger)(func(call *ToolCall) (*ToolResult, error) {
+		call.Arguments = json.RawMessage(`{"rewritten":true}`)
+		return errorResult("failed"), nil
+	})
+	if _, err := handler(&ToolCall{Name: "type", Arguments: json.RawMessage(`{"text":"hi"}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry map[string]any
+	if err := json.Unmarshal(bytes.TrimSpace(data), &entry); err != nil {
+		t.Fatalf("invalid audit entry %q: %v", data, err)
+	}
+	if entry["tool"] != "type" || entry["status"] != "error" || !strings.Contains(entry["arguments"].(string), `"text"`) {
+		t.Errorf("unexpected audit entry: %v", entry)
+	}
+}
+
+func TestBuiltinMiddlewares_NilDependencies(t *testing.T) {
+	next := func(call *ToolCall) (*ToolResult, error) { return textResult("ok"), nil }
+	for name, mw := range map[string]ToolMiddleware{
+		"metrics": MetricsMiddleware(nil),
+		"audit":   AuditMiddleware(nil),
+	} {
+		result, err := mw(next)(&ToolCall{Name: "x"})
+		if err != nil || resultText(result) != "ok" {
+			t.Errorf("%s middleware with nil dependency: result=%v err=%v", name, result, err)
+		}
+	}
+}
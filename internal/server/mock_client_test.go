@@ -31,6 +31,8 @@ type mockMacosUseClient struct {
 	captureCursorPositionFunc func(ctx context.Context, req *pb.CaptureCursorPositionRequest) (*pb.CaptureCursorPositionResponse, error)
 	// ExecuteShellCommand mock
 	executeShellCommandFunc func(ctx context.Context, req *pb.ExecuteShellCommandRequest) (*pb.ExecuteShellCommandResponse, error)
+	// TraverseAccessibility mock
+	traverseAccessibilityFunc func(ctx context.Context, req *pb.TraverseAccessibilityRequest) (*pb.TraverseAccessibilityResponse, error)
 	// FindElements mock
 	findElementsFunc func(ctx context.Context, req *pb.FindElementsRequest) (*pb.FindElementsResponse, error)
 	// FocusWindow mock
@@ -43,6 +45,14 @@ type mockMacosUseClient struct {
 	getElementActionsFunc func(ctx context.Context, req *pb.GetElementActionsRequest, opts ...grpc.CallOption) (*pb.ElementActions, error)
 	// WriteElementValue mock
 	writeElementValueFunc func(ctx context.Context, req *pb.WriteElementValueRequest, opts ...grpc.CallOption) (*pb.WriteElementValueResponse, error)
+	// GetWindow mock
+	getWindowFunc func(ctx context.Context, req *pb.GetWindowRequest, opts ...grpc.CallOption) (*pb.Window, error)
+	// WaitElement mock
+	waitElementFunc func(ctx context.Context, req *pb.WaitElementRequest, opts ...grpc.CallOption) (*longrunningpb.Operation, error)
+	// WaitElementState mock
+	waitElementStateFunc func(ctx context.Context, req *pb.WaitElementStateRequest, opts ...grpc.CallOption) (*longrunningpb.Operation, error)
+	// PerformElementAction mock
+	performElementActionFunc func(ctx context.Context, req *pb.PerformElementActionRequest, opts ...grpc.CallOption) (*pb.PerformElementActionResponse, error)
 	// ClickElement mock
 	clickElementFunc func(ctx context.Context, req *pb.ClickElementRequest, opts ...grpc.CallOption) (*pb.ClickElementResponse, error)
 }
@@ -103,6 +113,9 @@ func (m *mockMacosUseClient) ListInputs(ctx context.Context, in *pb.ListInputsRe
 }
 
 func (m *mockMacosUseClient) TraverseAccessibility(ctx context.Context, in *pb.TraverseAccessibilityRequest, opts ...grpc.CallOption) (*pb.TraverseAccessibilityResponse, error) {
+	if m.traverseAccessibilityFunc != nil {
+		return m.traverseAccessibilityFunc(ctx, in)
+	}
 	panic("TraverseAccessibility not expected to be called in display tests")
 }
 
@@ -111,6 +124,9 @@ func (m *mockMacosUseClient) WatchAccessibility(ctx context.Context, in *pb.Watc
 }
 
 func (m *mockMacosUseClient) GetWindow(ctx context.Context, in *pb.GetWindowRequest, opts ...grpc.CallOption) (*pb.Window, error) {
+	if m.getWindowFunc != nil {
+		return m.getWindowFunc(ctx, in, opts...)
+	}
 	panic("GetWindow not expected to be called in display tests")
 }
 
@@ -189,14 +205,23 @@ func (m *mockMacosUseClient) GetElementActions(ctx context.Context, in *pb.GetEl
 }
 
 func (m *mockMacosUseClient) PerformElementAction(ctx context.Context, in *pb.PerformElementActionRequest, opts ...grpc.CallOption) (*pb.PerformElementActionResponse, error) {
+	if m.performElementActionFunc != nil {
+		return m.performElementActionFunc(ctx, in, opts...)
+	}
 	panic("PerformElementAction not expected to be called in display tests")
 }
 
 func (m *mockMacosUseClient) WaitElement(ctx context.Context, in *pb.WaitElementRequest, opts ...grpc.CallOption) (*longrunningpb.Operation, error) {
+	if m.waitElementFunc != nil {
+		return m.waitElementFunc(ctx, in, opts...)
+	}
 	panic("WaitElement not expected to be called in display tests")
 }
 
 func (m *mockMacosUseClient) WaitElementState(ctx context.Context, in *pb.WaitElementStateRequest, opts ...grpc.CallOption) (*longrunningpb.Operation, error) {
+	if m.waitElementStateFunc != nil {
+		return m.waitElementStateFunc(ctx, in, opts...)
+	}
 	panic("WaitElementState not expected to be called in display tests")
 }
 
@@ -0,0 +1,49 @@
+// Copyright 2025 Joseph Cumines
+//
+// Long-running operation helpers shared between tool handlers.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	longrunningpb "cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"github.com/joeycumines/MacosUseSDK/internal/server/tools"
+)
+
+// operationPollInterval is the interval between GetOperation calls while
+// waiting for a long-running operation to complete.
+const operationPollInterval = 100 * time.Millisecond
+
+// awaitOperation polls op until it is done and returns the final operation.
+// An operation that completed with an error status is reported as an error.
+// Progress is reported while polling if ctx carries a progress reporter.
+func (s *MCPServer) awaitOperation(ctx context.Context, op *longrunningpb.Operation) (*longrunningpb.Operation, error) {
+	if !op.Done {
+		var total time.Duration
+		if deadline, ok := ctx.Deadline(); ok {
+			total = time.Until(deadline)
+		}
+		stop := trackProgress(ctx, total, fmt.Sprintf("Waiting for operation %s", op.Name))
+		defer stop()
+
+		opsClient := &tools.OperationClient{Client: s.opsClient}
+		if err := tools.PollUntilComplete(ctx, opsClient, op.Name, operationPollInterval); err != nil {
+			return nil, fmt.Errorf("polling failed: %w", err)
+		}
+
+		latestOp, err := s.opsClient.GetOperation(ctx, &longrunningpb.GetOperationRequest{Name: op.Name})
+		if err != nil {
+			return nil, fmt.Errorf("get operation failed: %w", err)
+		}
+		op = latestOp
+	}
+
+	if opErr := op.GetError(); opErr != nil {
+		return nil, fmt.Errorf("operation error: %s", opErr.Message)
+	}
+
+	return op, nil
+}
@@ -0,0 +1,194 @@
+// Copyright 2025 Joseph Cumines
+//
+// Tool policy enforcement — allow/deny rules loaded from MCP_POLICY_FILE
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	pb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/v1"
+	"github.com/joeycumines/MacosUseSDK/internal/config"
+)
+
+// policyPoint is a screen coordinate in tool arguments.
+type policyPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// deniedResult creates an error result for a call rejected by the tool policy.
+func deniedResult(reason string) *ToolResult {
+	result := errorResultf("Denied by policy: %s", reason)
+	result.status = "denied"
+	return result
+}
+
+// policyMiddleware rejects tool calls not permitted by the configured policy.
+// Denied calls return a soft error result and are audited as "denied".
+func (s *MCPServer) policyMiddleware(next ToolHandler) ToolHandler {
+	if s.cfg == nil || s.cfg.Policy == nil {
+		return next
+	}
+	policy := s.cfg.Policy
+	return func(call *ToolCall) (*ToolResult, error) {
+		if reason := s.checkPolicy(policy, call); reason != "" {
+			return deniedResult(reason), nil
+		}
+		return next(call)
+	}
+}
+
+// checkPolicy returns the reason the call is denied by policy, or "" if it is
+// permitted. Arguments that fail to parse are left for the tool to report.
+func (s *MCPServer) checkPolicy(policy *config.Policy, call *ToolCall) string {
+	if !policy.ToolAllowed(call.Name) {
+		return fmt.Sprintf("tool %s is not permitted", call.Name)
+	}
+
+	switch call.Name {
+	case "open_app":
+		var params struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal(call.Arguments, &params) != nil || params.ID == "" {
+			return ""
+		}
+		// Names and paths can't be verified against the allowlist, so an
+		// allowlist requires open_app to be given a bundle ID.
+		if !policy.BundleIDAllowed(params.ID) {
+			return fmt.Sprintf("application %s is not in the bundle ID allowlist", params.ID)
+		}
+
+	case "macro_execute":
+		// A macro's actions are only known to the server executing it, and
+		// may depend on its parameters, so they can't be checked here.
+		if len(policy.Applications.AllowedBundleIDs) > 0 || len(policy.Displays.Allowed) > 0 {
+			return "macro_execute is not permitted while the bundle ID or display allowlist is set, as macro actions can't be checked against them"
+		}
+
+	case "close_app":
+		if len(policy.Applications.AllowedBundleIDs) == 0 {
+			return ""
+		}
+		var params struct {
+			App string `json:"app"`
+		}
+		if json.Unmarshal(call.Arguments, &params) != nil || params.App == "" {
+			return ""
+		}
+		return s.checkCloseAppPolicy(call.Context(), policy, params.App)
+
+	case "run":
+		var params struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(call.Arguments, &params) != nil {
+			return ""
+		}
+		if params.Type == "" {
+			params.Type = "shell"
+		}
+		if !policy.RunTypeAllowed(params.Type) {
+			return fmt.Sprintf("run type %s is not permitted (allowed: %s)", params.Type, strings.Join(policy.Run.AllowedTypes, ", "))
+		}
+
+	case "click", "double_click", "scroll", "move", "move_window":
+		if len(policy.Displays.Allowed) == 0 {
+			return ""
+		}
+		var point policyPoint
+		if json.Unmarshal(call.Arguments, &point) != nil {
+			return ""
+		}
+		return s.checkDisplayPolicy(call.Context(), policy, []policyPoint{point})
+
+	case "drag":
+		if len(policy.Displays.Allowed) == 0 {
+			return ""
+		}
+		var params struct {
+			Path []policyPoint `json:"path"`
+		}
+		if json.Unmarshal(call.Arguments, &params) != nil {
+			return ""
+		}
+		return s.checkDisplayPolicy(call.Context(), policy, params.Path)
+	}
+
+	return ""
+}
+
+// checkCloseAppPolicy resolves the application close_app would terminate and
+// checks its bundle ID against the allowlist. Application names that match no
+// running application are left for close_app to report as not found; any
+// other failure to resolve the application denies the call.
+func (s *MCPServer) checkCloseAppPolicy(ctx context.Context, policy *config.Policy, app string) string {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(s.cfg.RequestTimeout)*time.Second)
+	defer cancel()
+
+	var pid int32
+	if strings.HasPrefix(app, "applications/") {
+		appResp, err := s.client.GetApplication(ctx, &pb.GetApplicationRequest{Name: app})
+		if err != nil {
+			return fmt.Sprintf("cannot resolve application %s for policy check: %v", app, err)
+		}
+		pid = appResp.Pid
+	} else {
+		listResp, err := s.client.ListApplications(ctx, &pb.ListApplicationsRequest{})
+		if err != nil {
+			return fmt.Sprintf("cannot resolve application %s for policy check: %v", app, err)
+		}
+		for _, a := range listResp.Applications {
+			if strings.EqualFold(a.DisplayName, app) || strings.EqualFold(a.Name, app) {
+				pid = a.Pid
+				break
+			}
+		}
+		if pid == 0 {
+			return ""
+		}
+	}
+
+	bundleID, err := s.lookupBundleID(ctx, pid)
+	if err != nil {
+		return fmt.Sprintf("could not determine the bundle ID of %s: %v", app, err)
+	}
+	if bundleID == "" || !policy.BundleIDAllowed(bundleID) {
+		return fmt.Sprintf("application %s (bundle ID %q) is not in the bundle ID allowlist", app, bundleID)
+	}
+	return ""
+}
+
+// checkDisplayPolicy checks that every point falls within an allowed display.
+func (s *MCPServer) checkDisplayPolicy(ctx context.Context, policy *config.Policy, points []policyPoint) string {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(s.cfg.RequestTimeout)*time.Second)
+	defer cancel()
+
+	resp, err := s.client.ListDisplays(ctx, &pb.ListDisplaysRequest{})
+	if err != nil {
+		return fmt.Sprintf("could not verify display bounds: %v", err)
+	}
+
+	for _, p := range points {
+		allowed := false
+		for _, d := range resp.Displays {
+			f := d.Frame
+			if f == nil || !policy.DisplayAllowed(d.DisplayId, d.IsMain) {
+				continue
+			}
+			if p.X >= f.X && p.X < f.X+f.Width && p.Y >= f.Y && p.Y < f.Y+f.Height {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Sprintf("coordinates (%.0f, %.0f) are outside the permitted displays", p.X, p.Y)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,194 @@
+// Copyright 2025 Joseph Cumines
+//
+// Tests for tool policy enforcement.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_type "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/type"
+	pb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/v1"
+	"github.com/joeycumines/MacosUseSDK/internal/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// mockPolicyClient implements only the gRPC methods used by policy checks.
+type mockPolicyClient struct {
+	pb.MacosUseClient
+
+	apps      []*pb.Application
+	bundleIDs map[int32]string
+	displays  []*pb.Display
+}
+
+func (m *mockPolicyClient) ListApplications(ctx context.Context, req *pb.ListApplicationsRequest, opts ...grpc.CallOption) (*pb.ListApplicationsResponse, error) {
+	return &pb.ListApplicationsResponse{Applications: m.apps}, nil
+}
+
+func (m *mockPolicyClient) GetApplication(ctx context.Context, req *pb.GetApplicationRequest, opts ...grpc.CallOption) (*pb.Application, error) {
+	for _, app := range m.apps {
+		if app.Name == req.Name {
+			return app, nil
+		}
+	}
+	return nil, status.Error(codes.NotFound, "application not found")
+}
+
+func (m *mockPolicyClient) ExecuteShellCommand(ctx context.Context, req *pb.ExecuteShellCommandRequest, opts ...grpc.CallOption) (*pb.ExecuteShellCommandResponse, error) {
+	for pid, id := range m.bundleIDs {
+		if strings.HasSuffix(req.Args[len(req.Args)-1], fmt.Sprintf(" %d", pid)) {
+			return &pb.ExecuteShellCommandResponse{Stdout: id + "\n"}, nil
+		}
+	}
+	return &pb.ExecuteShellCommandResponse{Stdout: "missing value\n"}, nil
+}
+
+func (m *mockPolicyClient) ListDisplays(ctx context.Context, req *pb.ListDisplaysRequest, opts ...grpc.CallOption) (*pb.ListDisplaysResponse, error) {
+	return &pb.ListDisplaysResponse{Displays: m.displays}, nil
+}
+
+func newPolicyTestServer(t *testing.T, policy *config.Policy) (*MCPServer, *[]string) {
+	t.Helper()
+	client := &mockPolicyClient{
+		apps: []*pb.Application{
+			{Name: "applications/100", Pid: 100, DisplayName: "TextEdit"},
+			{Name: "applications/200", Pid: 200, DisplayName: "Terminal"},
+		},
+		bundleIDs: map[int32]string{100: "com.apple.TextEdit", 200: "com.apple.Terminal"},
+		displays: []*pb.Display{
+			{DisplayId: 1, IsMain: true, Frame: &_type.Region{X: 0, Y: 0, Width: 1920, Height: 1080}},
+			{DisplayId: 2, Frame: &_type.Region{X: 1920, Y: 0, Width: 1280, Height: 1024}},
+		},
+	}
+	s := newTestMCPServer(client)
+	s.cfg.Policy = policy
+
+	var called []string
+	for _, name := range []string{"open_app", "close_app", "run", "click", "drag", "type", "macro_execute"} {
+		s.tools[name] = &Tool{Name: name, Handler: func(call *ToolCall) (*ToolResult, error) {
+			called = append(called, call.Name)
+			return textResult("ok"), nil
+		}}
+	}
+	return s, &called
+}
+
+func TestPolicyMiddleware(t *testing.T) {
+	s, called := newPolicyTestServer(t, &config.Policy{
+		Tools:        config.ToolPolicy{Deny: []string{"type"}},
+		Applications: config.ApplicationPolicy{AllowedBundleIDs: []string{"com.apple.TextEdit"}},
+		Run:          config.RunPolicy{AllowedTypes: []string{"applescript"}},
+		Displays:     config.DisplayPolicy{Allowed: []string{"main"}},
+	})
+
+	tests := []struct {
+		tool       string
+		args       string
+		wantDenied string
+	}{
+		{"type", `{"text":"hi"}`, "tool type is not permitted"},
+		{"open_app", `{"id":"com.apple.textedit"}`, ""},
+		{"open_app", `{"id":"Terminal"}`, "application Terminal is not in the bundle ID allowlist"},
+		{"close_app", `{"app":"TextEdit"}`, ""},
+		{"close_app", `{"app":"applications/200"}`, `bundle ID "com.apple.Terminal"`},
+		{"close_app", `{"app":"Terminal"}`, `bundle ID "com.apple.Terminal"`},
+		{"close_app", `{"app":"applications/300"}`, "cannot resolve application applications/300 for policy check"},
+		{"macro_execute", `{"name":"macros/quit-terminal","application":"applications/200"}`, "macro_execute is not permitted while the bundle ID or display allowlist is set"},
+		{"run", `{"command":"beep","type":"applescript"}`, ""},
+		{"run", `{"command":"ls"}`, "run type shell is not permitted (allowed: applescript)"},
+		{"click", `{"x":100,"y":100}`, ""},
+		{"click", `{"x":2000,"y":100}`, "coordinates (2000, 100) are outside the permitted displays"},
+		{"drag", `{"path":[{"x":10,"y":10},{"x":1919,"y":1079}]}`, ""},
+		{"drag", `{"path":[{"x":10,"y":10},{"x":1920,"y":10}]}`, "outside the permitted displays"},
+	}
+	for _, tc := range tests {
+		*called = nil
+		resp := callToolMethod(t, s, tc.tool, tc.args)
+		if resp.Error != nil {
+			t.Fatalf("%s %s: unexpected error %+v", tc.tool, tc.args, resp.Error)
+		}
+		var result ToolResult
+		if err := json.Unmarshal(resp.Result, &result); err != nil {
+			t.Fatal(err)
+		}
+		if tc.wantDenied == "" {
+			if result.IsError || len(*called) != 1 {
+				t.Errorf("%s %s: expected call to be permitted, got %s", tc.tool, tc.args, resp.Result)
+			}
+			continue
+		}
+		if !result.IsError || !resultContains(&result, "Denied by policy") || !resultContains(&result, tc.wantDenied) {
+			t.Errorf("%s %s: expected denial containing %q, got %s", tc.tool, tc.args, tc.wantDenied, resp.Result)
+		}
+		if len(*called) != 0 {
+			t.Errorf("%s %s: handler should not run for denied calls", tc.tool, tc.args)
+		}
+	}
+}
+
+func TestPolicyMiddleware_AuditsDeniedStatus(t *testing.T) {
+	s, _ := newPolicyTestServer(t, &config.Policy{Tools: config.ToolPolicy{Allow: []string{"click"}}})
+
+	logPath := filepath.Join(t.TempDir(), "audit.log")
+	logger, err := NewAuditLogger(logPath)
+	if err != nil {
+		t.Fatalf("NewAuditLogger error = %v", err)
+	}
+	defer logger.Close()
+	s.auditLogger = logger
+
+	callToolMethod(t, s, "run", `{"command":"ls"}`)
+	callToolMethod(t, s, "click", `{"x":1,"y":1}`)
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 audit entries, got %d: %s", len(lines), data)
+	}
+	for i, want := range []struct{ tool, status string }{{"run", "denied"}, {"click", "ok"}} {
+		var entry map[string]any
+		if err := json.Unmarshal(lines[i], &entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry["tool"] != want.tool || entry["status"] != want.status {
+			t.Errorf("entry %d = %v, want tool=%s status=%s", i, entry, want.tool, want.status)
+		}
+	}
+}
+
+func TestPolicyMiddleware_MacroExecute(t *testing.T) {
+	// Macros are permitted while no bundle ID or display allowlist is set.
+	s, called := newPolicyTestServer(t, &config.Policy{Run: config.RunPolicy{AllowedTypes: []string{"applescript"}}})
+	resp := callToolMethod(t, s, "macro_execute", `{"name":"macros/login"}`)
+	if resp.Error != nil || len(*called) != 1 {
+		t.Errorf("expected macro_execute to be permitted, got %+v", resp)
+	}
+
+	// A bundle ID allowlist alone denies them.
+	s, called = newPolicyTestServer(t, &config.Policy{Applications: config.ApplicationPolicy{AllowedBundleIDs: []string{"com.apple.TextEdit"}}})
+	resp = callToolMethod(t, s, "macro_execute", `{"name":"macros/quit-terminal"}`)
+	if resp.Error != nil || len(*called) != 0 || !strings.Contains(string(resp.Result), "Denied by policy") {
+		t.Errorf("expected macro_execute to be denied, got %+v", resp)
+	}
+}
+
+func TestPolicyMiddleware_NoPolicy(t *testing.T) {
+	s, called := newPolicyTestServer(t, nil)
+	resp := callToolMethod(t, s, "run", `{"command":"ls"}`)
+	if resp.Error != nil || len(*called) != 1 {
+		t.Errorf("expected call to pass through without a policy, got %+v", resp)
+	}
+}
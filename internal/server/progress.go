@@ -0,0 +1,105 @@
+// Copyright 2025 Joseph Cumines
+//
+// MCP progress notifications for long-running tool calls
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/joeycumines/MacosUseSDK/internal/transport"
+)
+
+// progressInterval is the interval between progress notifications while a
+// tool call is waiting.
+var progressInterval = time.Second
+
+// progressKey is the context key for the call's progressReporter.
+type progressKey struct{}
+
+// progressReporter sends notifications/progress for a tool call whose request
+// included _meta.progressToken.
+type progressReporter struct {
+	tr    transport.Transport
+	token json.RawMessage
+	mu    sync.Mutex
+	last  float64
+}
+
+// withProgress returns a context carrying a progress reporter for call, if the
+// client requested progress notifications for it.
+func withProgress(ctx context.Context, call *ToolCall) context.Context {
+	if len(call.progressToken) == 0 || call.transport == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, progressKey{}, &progressReporter{tr: call.transport, token: call.progressToken})
+}
+
+// report sends a progress notification. Per the MCP spec progress must
+// increase with each notification, so non-increasing values are dropped.
+func (p *progressReporter) report(progress, total float64, message string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if progress <= p.last {
+		return
+	}
+	p.last = progress
+
+	params := map[string]any{"progressToken": p.token, "progress": progress}
+	if total > 0 {
+		params["total"] = total
+	}
+	if message != "" {
+		params["message"] = message
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return
+	}
+	if err := p.tr.WriteMessage(&transport.Message{JSONRPC: "2.0", Method: "notifications/progress", Params: data}); err != nil {
+		log.Printf("Error writing progress notification: %v", err)
+	}
+}
+
+// trackProgress reports the seconds elapsed, out of total if known, every
+// progressInterval until the returned stop function is called. It does
+// nothing if ctx carries no progress reporter. No notifications are sent
+// after stop returns.
+func trackProgress(ctx context.Context, total time.Duration, message string) (stop func()) {
+	p, _ := ctx.Value(progressKey{}).(*progressReporter)
+	if p == nil {
+		return func() {}
+	}
+
+	start := time.Now()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.report(time.Since(start).Seconds(), total.Seconds(), message)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
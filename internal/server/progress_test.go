@@ -0,0 +1,159 @@
+// Copyright 2025 Joseph Cumines
+//
+// Tests for MCP progress notifications.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	longrunningpb "cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"github.com/joeycumines/MacosUseSDK/internal/transport"
+	"google.golang.org/grpc"
+)
+
+// mockOperationsClient reports operations as done after a number of polls.
+type mockOperationsClient struct {
+	longrunningpb.OperationsClient
+	polls     atomic.Int32
+	doneAfter int32
+}
+
+func (m *mockOperationsClient) GetOperation(ctx context.Context, req *longrunningpb.GetOperationRequest, opts ...grpc.CallOption) (*longrunningpb.Operation, error) {
+	return &longrunningpb.Operation{Name: req.Name, Done: m.polls.Add(1) >= m.doneAfter}, nil
+}
+
+type progressParams struct {
+	ProgressToken json.RawMessage `json:"progressToken"`
+	Message       string          `json:"message"`
+	Progress      float64         `json:"progress"`
+	Total         float64         `json:"total"`
+}
+
+// drainProgress returns the progress notifications written to sink.
+func drainProgress(t *testing.T, sink *chanSink) []progressParams {
+	t.Helper()
+	var out []progressParams
+	for {
+		select {
+		case msg := <-sink.messages:
+			if msg.Method != "notifications/progress" || len(msg.ID) != 0 {
+				t.Fatalf("unexpected message: %+v", msg)
+			}
+			var p progressParams
+			if err := json.Unmarshal(msg.Params, &p); err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, p)
+		default:
+			return out
+		}
+	}
+}
+
+func setProgressInterval(t *testing.T, d time.Duration) {
+	t.Helper()
+	old := progressInterval
+	progressInterval = d
+	t.Cleanup(func() { progressInterval = old })
+}
+
+func TestProgress_Wait(t *testing.T) {
+	setProgressInterval(t, 20*time.Millisecond)
+	s := newTestMCPServer(nil)
+	s.tools["wait"] = &Tool{Name: "wait", Handler: s.handleWait}
+
+	sink := newChanSink()
+	resp := s.dispatch(&MethodRequest{Transport: sink, Message: &transport.Message{
+		JSONRPC: "2.0",
+		ID:      json.RawMessage(`1`),
+		Method:  "tools/call",
+		Params:  json.RawMessage(`{"name":"wait","arguments":{"duration":0.1},"_meta":{"progressToken":"tok-1"}}`),
+	}})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %+v", resp.Error)
+	}
+
+	notifications := drainProgress(t, sink)
+	if len(notifications) == 0 {
+		t.Fatal("expected progress notifications")
+	}
+	var last float64
+	for _, p := range notifications {
+		if string(p.ProgressToken) != `"tok-1"` || p.Total != 0.1 || p.Message != "Waiting" {
+			t.Errorf("unexpected notification: %+v", p)
+		}
+		if p.Progress <= last {
+			t.Errorf("progress did not increase: %v after %v", p.Progress, last)
+		}
+		last = p.Progress
+	}
+
+	// Without a progress token nothing is sent.
+	s.dispatch(&MethodRequest{Transport: sink, Message: &transport.Message{
+		JSONRPC: "2.0",
+		ID:      json.RawMessage(`2`),
+		Method:  "tools/call",
+		Params:  json.RawMessage(`{"name":"wait","arguments":{"duration":0.05}}`),
+	}})
+	if n := drainProgress(t, sink); len(n) != 0 {
+		t.Errorf("unexpected notifications without progressToken: %+v", n)
+	}
+}
+
+func TestProgress_AwaitOperation(t *testing.T) {
+	setProgressInterval(t, 100*time.Millisecond)
+	s := newTestMCPServer(nil)
+	s.opsClient = &mockOperationsClient{doneAfter: 5}
+
+	sink := newChanSink()
+	call := &ToolCall{transport: sink, progressToken: json.RawMessage(`7`)}
+	ctx, cancel := context.WithTimeout(withProgress(context.Background(), call), 10*time.Second)
+	defer cancel()
+
+	if _, err := s.awaitOperation(ctx, &longrunningpb.Operation{Name: "operations/open"}); err != nil {
+		t.Fatalf("awaitOperation error = %v", err)
+	}
+
+	notifications := drainProgress(t, sink)
+	if len(notifications) == 0 {
+		t.Fatal("expected progress notifications while polling")
+	}
+	p := notifications[0]
+	if string(p.ProgressToken) != "7" || p.Message != "Waiting for operation operations/open" || p.Total <= 9 || p.Total > 10 {
+		t.Errorf("unexpected notification: %+v", p)
+	}
+}
+
+func TestTrackProgress_StopsAndDropsNonIncreasing(t *testing.T) {
+	setProgressInterval(t, 2*time.Millisecond)
+	sink := newChanSink()
+	ctx := withProgress(context.Background(), &ToolCall{transport: sink, progressToken: json.RawMessage(`"t"`)})
+
+	stop := trackProgress(ctx, 0, "")
+	time.Sleep(10 * time.Millisecond)
+	stop()
+	stop()
+	drainProgress(t, sink)
+	sink.expectNone(t, 10*time.Millisecond)
+
+	reporter := ctx.Value(progressKey{}).(*progressReporter)
+	last := reporter.last
+	reporter.report(last, 0, "")
+	sink.expectNone(t, 0)
+	reporter.report(last+1, 0, "")
+	var params map[string]any
+	if err := json.Unmarshal(sink.next(t).Params, &params); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := params["total"]; ok {
+		t.Errorf("total should be omitted when unknown: %v", params)
+	}
+
+	// No reporter: no goroutine, no messages.
+	trackProgress(context.Background(), time.Second, "x")()
+}
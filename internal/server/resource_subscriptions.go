@@ -0,0 +1,293 @@
+// Copyright 2025 Joseph Cumines
+//
+// MCP resources/subscribe support — watches subscribed resources and sends
+// notifications/resources/updated to the subscribing transport
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/v1"
+	"github.com/joeycumines/MacosUseSDK/internal/transport"
+	"google.golang.org/protobuf/proto"
+)
+
+// Resource watch intervals. These are variables so tests can shorten them.
+var (
+	// accessibilityWatchPollInterval is the WatchAccessibility poll interval, in seconds.
+	accessibilityWatchPollInterval = 1.0
+
+	// clipboardWatchPollInterval is how often GetClipboardHistory is polled.
+	clipboardWatchPollInterval = time.Second
+
+	// resourceWatchRetryInterval is the delay before restarting a failed watch.
+	resourceWatchRetryInterval = 5 * time.Second
+)
+
+// resourceSubscription is a running watch for one resource URI, shared by
+// every transport subscribed to it.
+type resourceSubscription struct {
+	cancel context.CancelFunc
+	sinks  map[transport.Transport]struct{}
+}
+
+// handleResourceSubscribe handles resources/subscribe. Notifications for the
+// resource are written to sink until it unsubscribes.
+func (s *MCPServer) handleResourceSubscribe(sink transport.Transport, msg *transport.Message) *transport.Message {
+	uri, errResp := parseResourceURIParams(msg)
+	if errResp != nil {
+		return errResp
+	}
+	if sink == nil {
+		return rpcError(msg, transport.ErrCodeInternalError, "resource notifications are not available on this transport")
+	}
+	if err := s.subscribeResource(sink, uri); err != nil {
+		return rpcError(msg, transport.ErrCodeInvalidParams, err.Error())
+	}
+	return &transport.Message{
+		JSONRPC: "2.0",
+		ID:      msg.ID,
+		Result:  []byte(`{}`),
+	}
+}
+
+// handleResourceUnsubscribe handles resources/unsubscribe. Unsubscribing from
+// a resource that has no subscription is not an error.
+func (s *MCPServer) handleResourceUnsubscribe(sink transport.Transport, msg *transport.Message) *transport.Message {
+	uri, errResp := parseResourceURIParams(msg)
+	if errResp != nil {
+		return errResp
+	}
+	s.unsubscribeResource(sink, uri)
+	return &transport.Message{
+		JSONRPC: "2.0",
+		ID:      msg.ID,
+		Result:  []byte(`{}`),
+	}
+}
+
+// parseResourceURIParams extracts the uri parameter shared by the resources/* methods.
+func parseResourceURIParams(msg *transport.Message) (string, *transport.Message) {
+	var params struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return "", rpcError(msg, transport.ErrCodeInvalidParams, fmt.Sprintf("invalid params: %v", err))
+	}
+	if params.URI == "" {
+		return "", rpcError(msg, transport.ErrCodeInvalidParams, "uri is required")
+	}
+	return params.URI, nil
+}
+
+// subscribeResource adds sink as a subscriber of uri, starting a watch for
+// the resource if it is not already being watched.
+func (s *MCPServer) subscribeResource(sink transport.Transport, uri string) error {
+	watch, err := s.resourceWatcher(uri)
+	if err != nil {
+		return err
+	}
+
+	s.subscriptionsMu.Lock()
+	defer s.subscriptionsMu.Unlock()
+
+	if sub, ok := s.subscriptions[uri]; ok {
+		sub.sinks[sink] = struct{}{}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	if s.subscriptions == nil {
+		s.subscriptions = make(map[string]*resourceSubscription)
+	}
+	s.subscriptions[uri] = &resourceSubscription{
+		cancel: cancel,
+		sinks:  map[transport.Transport]struct{}{sink: {}},
+	}
+	go watch(ctx)
+	return nil
+}
+
+// unsubscribeResource removes sink as a subscriber of uri, stopping the watch
+// once no subscribers remain. It reports whether sink was subscribed.
+func (s *MCPServer) unsubscribeResource(sink transport.Transport, uri string) bool {
+	s.subscriptionsMu.Lock()
+	defer s.subscriptionsMu.Unlock()
+
+	sub, ok := s.subscriptions[uri]
+	if !ok {
+		return false
+	}
+	if _, ok := sub.sinks[sink]; !ok {
+		return false
+	}
+	delete(sub.sinks, sink)
+	if len(sub.sinks) == 0 {
+		sub.cancel()
+		delete(s.subscriptions, uri)
+	}
+	return true
+}
+
+// unsubscribeSession removes the client session id from every subscription,
+// stopping watches that have no subscribers left.
+func (s *MCPServer) unsubscribeSession(id string) {
+	s.subscriptionsMu.Lock()
+	defer s.subscriptionsMu.Unlock()
+
+	for uri, sub := range s.subscriptions {
+		for sink := range sub.sinks {
+			if session, _ := sessionOf(sink); session == id {
+				delete(sub.sinks, sink)
+			}
+		}
+		if len(sub.sinks) == 0 {
+			sub.cancel()
+			delete(s.subscriptions, uri)
+		}
+	}
+}
+
+// notifyResourceUpdated sends notifications/resources/updated for uri to
+// every subscribed transport.
+func (s *MCPServer) notifyResourceUpdated(uri string) {
+	s.subscriptionsMu.Lock()
+	var sinks []transport.Transport
+	if sub, ok := s.subscriptions[uri]; ok {
+		for sink := range sub.sinks {
+			sinks = append(sinks, sink)
+		}
+	}
+	s.subscriptionsMu.Unlock()
+
+	if len(sinks) == 0 {
+		return
+	}
+
+	params, err := json.Marshal(map[string]any{"uri": uri})
+	if err != nil {
+		log.Printf("Error marshaling resource update notification: %v", err)
+		return
+	}
+	notification := &transport.Message{
+		JSONRPC: "2.0",
+		Method:  "notifications/resources/updated",
+		Params:  params,
+	}
+	for _, sink := range sinks {
+		if err := sink.WriteMessage(notification); err != nil {
+			log.Printf("Error writing resource update notification for %s: %v", uri, err)
+		}
+	}
+}
+
+// resourceWatcher returns the watch loop for a subscribable resource URI.
+// Supported URIs are accessibility://{pid} and clipboard://current.
+func (s *MCPServer) resourceWatcher(uri string) (func(ctx context.Context), error) {
+	if pidStr, ok := strings.CutPrefix(uri, "accessibility://"); ok {
+		pid, err := strconv.ParseInt(pidStr, 10, 32)
+		if err != nil || pid <= 0 {
+			return nil, fmt.Errorf("invalid PID in accessibility URI: %s", pidStr)
+		}
+		name := fmt.Sprintf("applications/%d", pid)
+		return func(ctx context.Context) { s.watchAccessibilityResource(ctx, uri, name) }, nil
+	}
+	if uri == "clipboard://current" {
+		return func(ctx context.Context) { s.watchClipboardResource(ctx, uri) }, nil
+	}
+	return nil, fmt.Errorf("subscriptions are not supported for resource %s (supported: accessibility://{pid}, clipboard://current)", uri)
+}
+
+// watchAccessibilityResource notifies subscribers whenever WatchAccessibility
+// reports added, removed or modified elements. Failed streams are restarted
+// after resourceWatchRetryInterval until the subscription is cancelled.
+func (s *MCPServer) watchAccessibilityResource(ctx context.Context, uri, name string) {
+	for {
+		err := s.streamAccessibilityChanges(ctx, uri, name)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Accessibility watch for %s ended: %v (retrying in %s)", uri, err, resourceWatchRetryInterval)
+
+		timer := time.NewTimer(resourceWatchRetryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func (s *MCPServer) streamAccessibilityChanges(ctx context.Context, uri, name string) error {
+	stream, err := s.client.WatchAccessibility(ctx, &pb.WatchAccessibilityRequest{
+		Name:         name,
+		PollInterval: accessibilityWatchPollInterval,
+	})
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return errors.New("stream closed by server")
+			}
+			return err
+		}
+		if len(resp.GetAdded()) > 0 || len(resp.GetRemoved()) > 0 || len(resp.GetModified()) > 0 {
+			s.notifyResourceUpdated(uri)
+		}
+	}
+}
+
+// watchClipboardResource polls GetClipboardHistory and notifies subscribers
+// when the most recent entry changes. The first poll only records a baseline.
+func (s *MCPServer) watchClipboardResource(ctx context.Context, uri string) {
+	ticker := time.NewTicker(clipboardWatchPollInterval)
+	defer ticker.Stop()
+
+	var (
+		latest   *pb.ClipboardHistoryEntry
+		baseline bool
+		failing  bool
+	)
+	for {
+		history, err := s.client.GetClipboardHistory(ctx, &pb.GetClipboardHistoryRequest{Name: "clipboard/history"})
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return
+			}
+			if !failing {
+				log.Printf("Clipboard watch for %s failed: %v", uri, err)
+				failing = true
+			}
+		default:
+			failing = false
+			var current *pb.ClipboardHistoryEntry
+			if entries := history.GetEntries(); len(entries) > 0 {
+				current = entries[0]
+			}
+			if baseline && !proto.Equal(current, latest) {
+				s.notifyResourceUpdated(uri)
+			}
+			latest, baseline = current, true
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright 2025 Joseph Cumines
+//
+// Tests for resources/subscribe, resources/unsubscribe and resource update
+// notifications.
+
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	typepb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/type"
+	pb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/v1"
+	"github.com/joeycumines/MacosUseSDK/internal/transport"
+	"google.golang.org/grpc"
+)
+
+// mockResourceWatchClient implements only the gRPC methods used by resource watches.
+type mockResourceWatchClient struct {
+	pb.MacosUseClient
+
+	watchAccessibilityFunc  func(ctx context.Context, req *pb.WatchAccessibilityRequest) (grpc.ServerStreamingClient[pb.WatchAccessibilityResponse], error)
+	getClipboardHistoryFunc func(ctx context.Context, req *pb.GetClipboardHistoryRequest) (*pb.ClipboardHistory, error)
+}
+
+func (m *mockResourceWatchClient) WatchAccessibility(ctx context.Context, req *pb.WatchAccessibilityRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[pb.WatchAccessibilityResponse], error) {
+	return m.watchAccessibilityFunc(ctx, req)
+}
+
+func (m *mockResourceWatchClient) GetClipboardHistory(ctx context.Context, req *pb.GetClipboardHistoryRequest, opts ...grpc.CallOption) (*pb.ClipboardHistory, error) {
+	return m.getClipboardHistoryFunc(ctx, req)
+}
+
+// chanAccessibilityStream is a WatchAccessibility client stream fed from a channel.
+type chanAccessibilityStream struct {
+	grpc.ClientStream
+	ctx     context.Context
+	changes <-chan *pb.WatchAccessibilityResponse
+}
+
+func (c *chanAccessibilityStream) Recv() (*pb.WatchAccessibilityResponse, error) {
+	select {
+	case resp, ok := <-c.changes:
+		if !ok {
+			return nil, io.EOF
+		}
+		return resp, nil
+	case <-c.ctx.Done():
+		return nil, c.ctx.Err()
+	}
+}
+
+// chanSink is a transport that delivers written messages to a channel.
+type chanSink struct {
+	messages chan *transport.Message
+}
+
+func newChanSink() *chanSink {
+	return &chanSink{messages: make(chan *transport.Message, 16)}
+}
+
+func (c *chanSink) ReadMessage() (*transport.Message, error) { return nil, io.EOF }
+func (c *chanSink) WriteMessage(msg *transport.Message) error {
+	c.messages <- msg
+	return nil
+}
+func (c *chanSink) Close() error   { return nil }
+func (c *chanSink) IsClosed() bool { return false }
+
+func (c *chanSink) next(t *testing.T) *transport.Message {
+	t.Helper()
+	select {
+	case msg := <-c.messages:
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for notification")
+		return nil
+	}
+}
+
+func (c *chanSink) expectNone(t *testing.T, wait time.Duration) {
+	t.Helper()
+	select {
+	case msg := <-c.messages:
+		t.Fatalf("unexpected message: %s %s", msg.Method, msg.Params)
+	case <-time.After(wait):
+	}
+}
+
+func assertResourceUpdated(t *testing.T, msg *transport.Message, uri string) {
+	t.Helper()
+	if msg.Method != "notifications/resources/updated" || len(msg.ID) != 0 {
+		t.Fatalf("expected resources/updated notification, got method=%q id=%s", msg.Method, msg.ID)
+	}
+	var params struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		t.Fatalf("failed to decode params: %v", err)
+	}
+	if params.URI != uri {
+		t.Errorf("uri = %q, want %q", params.URI, uri)
+	}
+}
+
+func subscribeMessage(method, uri string) *transport.Message {
+	params, _ := json.Marshal(map[string]string{"uri": uri})
+	return &transport.Message{JSONRPC: "2.0", ID: json.RawMessage(`7`), Method: method, Params: params}
+}
+
+func TestResourceSubscribe_Accessibility(t *testing.T) {
+	changes := make(chan *pb.WatchAccessibilityResponse)
+	var watchCtx context.Context
+	var gotName string
+	client := &mockResourceWatchClient{
+		watchAccessibilityFunc: func(ctx context.Context, req *pb.WatchAccessibilityRequest) (grpc.ServerStreamingClient[pb.WatchAccessibilityResponse], error) {
+			watchCtx, gotName = ctx, req.GetName()
+			return &chanAccessibilityStream{ctx: ctx, changes: changes}, nil
+		},
+	}
+	s := newTestMCPServer(client)
+	sink := newChanSink()
+
+	resp := s.handleResourceSubscribe(sink, subscribeMessage("resources/subscribe", "accessibility://123"))
+	if resp.Error != nil || string(resp.Result) != "{}" || string(resp.ID) != "7" {
+		t.Fatalf("unexpected subscribe response: %+v", resp)
+	}
+
+	changes <- &pb.WatchAccessibilityResponse{}
+	changes <- &pb.WatchAccessibilityResponse{Added: []*typepb.Element{{Role: "AXButton"}}}
+	assertResourceUpdated(t, sink.next(t), "accessibility://123")
+	if gotName != "applications/123" {
+		t.Errorf("WatchAccessibility name = %q, want applications/123", gotName)
+	}
+
+	// A second subscriber shares the watch; both receive updates.
+	other := newChanSink()
+	if resp := s.handleResourceSubscribe(other, subscribeMessage("resources/subscribe", "accessibility://123")); resp.Error != nil {
+		t.Fatalf("unexpected error: %+v", resp.Error)
+	}
+	changes <- &pb.WatchAccessibilityResponse{Modified: []*pb.ModifiedElement{{}}}
+	assertResourceUpdated(t, sink.next(t), "accessibility://123")
+	assertResourceUpdated(t, other.next(t), "accessibility://123")
+
+	s.handleResourceUnsubscribe(other, subscribeMessage("resources/unsubscribe", "accessibility://123"))
+	if watchCtx.Err() != nil {
+		t.Fatal("watch should keep running while a subscriber remains")
+	}
+	resp = s.handleResourceUnsubscribe(sink, subscribeMessage("resources/unsubscribe", "accessibility://123"))
+	if resp.Error != nil || string(resp.Result) != "{}" {
+		t.Fatalf("unexpected unsubscribe response: %+v", resp)
+	}
+	select {
+	case <-watchCtx.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("watch was not cancelled after the last unsubscribe")
+	}
+}
+
+func TestResourceSubscribe_ClipboardChanges(t *testing.T) {
+	defer func(d time.Duration) { clipboardWatchPollInterval = d }(clipboardWatchPollInterval)
+	clipboardWatchPollInterval = 5 * time.Millisecond
+
+	var polls atomic.Int32
+	client := &mockResourceWatchClient{
+		getClipboardHistoryFunc: func(ctx context.Context, req *pb.GetClipboardHistoryRequest) (*pb.ClipboardHistory, error) {
+			text := "first"
+			if polls.Add(1) >= 3 {
+				text = "second"
+			}
+			return &pb.ClipboardHistory{Entries: []*pb.ClipboardHistoryEntry{
+				{Content: &pb.ClipboardContent{Content: &pb.ClipboardContent_Text{Text: text}}},
+			}}, nil
+		},
+	}
+	s := newTestMCPServer(client)
+	sink := newChanSink()
+
+	if resp := s.handleResourceSubscribe(sink, subscribeMessage("resources/subscribe", "clipboard://current")); resp.Error != nil {
+		t.Fatalf("unexpected error: %+v", resp.Error)
+	}
+	defer s.unsubscribeResource(sink, "clipboard://current")
+
+	assertResourceUpdated(t, sink.next(t), "clipboard://current")
+	sink.expectNone(t, 50*time.Millisecond)
+}
+
+func TestResourceSubscribe_Errors(t *testing.T) {
+	s := newTestMCPServer(nil)
+	sink := newChanSink()
+
+	tests := []struct {
+		name     string
+		msg      *transport.Message
+		wantCode int
+		wantMsg  string
+	}{
+		{"missing uri", &transport.Message{ID: json.RawMessage(`1`), Params: json.RawMessage(`{}`)}, transport.ErrCodeInvalidParams, "uri is required"},
+		{"malformed params", &transport.Message{ID: json.RawMessage(`1`), Params: json.RawMessage(`[]`)}, transport.ErrCodeInvalidParams, "invalid params"},
+		{"unsupported resource", subscribeMessage("resources/subscribe", "screen://main"), transport.ErrCodeInvalidParams, "not supported for resource screen://main"},
+		{"invalid pid", subscribeMessage("resources/subscribe", "accessibility://abc"), transport.ErrCodeInvalidParams, "invalid PID"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := s.handleResourceSubscribe(sink, tc.msg)
+			if resp.Error == nil {
+				t.Fatalf("expected error, got result %s", resp.Result)
+			}
+			if resp.Error.Code != tc.wantCode || !strings.Contains(resp.Error.Message, tc.wantMsg) {
+				t.Errorf("error = %d %q, want %d containing %q", resp.Error.Code, resp.Error.Message, tc.wantCode, tc.wantMsg)
+			}
+		})
+	}
+
+	// Without an active HTTP transport there is nowhere to deliver notifications.
+	resp, err := s.handleHTTPMessage(subscribeMessage("resources/subscribe", "clipboard://current"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Error == nil || resp.Error.Code != transport.ErrCodeInternalError {
+		t.Errorf("expected internal error without HTTP transport, got %+v", resp)
+	}
+	if len(s.subscriptions) != 0 {
+		t.Errorf("failed subscriptions should not be tracked: %v", s.subscriptions)
+	}
+}
+
+// TestResourceSubscribe_Stdio verifies the subscribe response and subsequent
+// update notifications are written to the stdio transport.
+func TestResourceSubscribe_Stdio(t *testing.T) {
+	changes := make(chan *pb.WatchAccessibilityResponse)
+	client := &mockResourceWatchClient{
+		watchAccessibilityFunc: func(ctx context.Context, req *pb.WatchAccessibilityRequest) (grpc.ServerStreamingClient[pb.WatchAccessibilityResponse], error) {
+			return &chanAccessibilityStream{ctx: ctx, changes: changes}, nil
+		},
+	}
+	s := newTestMCPServer(client)
+
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	tr := transport.NewStdioTransport(strings.NewReader(""), pw)
+	lines := bufio.NewScanner(pr)
+	readMessage := func() *transport.Message {
+		t.Helper()
+		if !lines.Scan() {
+			t.Fatalf("failed to read message: %v", lines.Err())
+		}
+		var msg transport.Message
+		if err := json.Unmarshal(lines.Bytes(), &msg); err != nil {
+			t.Fatalf("failed to decode message: %v", err)
+		}
+		return &msg
+	}
+
+	go s.handleMessage(tr, subscribeMessage("resources/subscribe", "accessibility://42"))
+	if resp := readMessage(); resp.Error != nil || string(resp.ID) != "7" {
+		t.Fatalf("unexpected subscribe response: %+v", resp)
+	}
+
+	go func() { changes <- &pb.WatchAccessibilityResponse{Removed: []*typepb.Element{{}}} }()
+	assertResourceUpdated(t, readMessage(), "accessibility://42")
+
+	go s.handleMessage(tr, subscribeMessage("resources/unsubscribe", "accessibility://42"))
+	if resp := readMessage(); resp.Error != nil || string(resp.Result) != "{}" {
+		t.Fatalf("unexpected unsubscribe response: %+v", resp)
+	}
+}
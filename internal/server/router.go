@@ -0,0 +1,306 @@
+// Copyright 2025 Joseph Cumines
+//
+// Transport-agnostic JSON-RPC method routing shared by the stdio, HTTP and WebSocket transports
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/joeycumines/MacosUseSDK/internal/config"
+	"github.com/joeycumines/MacosUseSDK/internal/transport"
+)
+
+// MethodHandler handles a single JSON-RPC method. It returns the response to
+// send, or nil when no response is due (e.g. for notifications).
+type MethodHandler func(req *MethodRequest) *transport.Message
+
+// MethodRequest is a JSON-RPC message being dispatched to a MethodHandler.
+type MethodRequest struct {
+	// Message is the incoming request or notification.
+	Message *transport.Message
+
+	// Transport is the transport the message arrived on. Server-initiated
+	// messages for the client, such as notifications, are written to it.
+	// It is nil when no transport is available.
+	Transport transport.Transport
+}
+
+// methodRouter maps JSON-RPC method names to handlers.
+type methodRouter struct {
+	handlers map[string]MethodHandler
+	mu       sync.RWMutex
+}
+
+func newMethodRouter() *methodRouter {
+	return &methodRouter{handlers: make(map[string]MethodHandler)}
+}
+
+// handle registers handler for method, replacing any existing handler.
+func (r *methodRouter) handle(method string, handler MethodHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[method] = handler
+}
+
+// lookup returns the handler registered for method.
+func (r *methodRouter) lookup(method string) (MethodHandler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	handler, ok := r.handlers[method]
+	return handler, ok
+}
+
+// RegisterMethod registers a handler for a JSON-RPC method, replacing any
+// existing handler (including the built-in MCP methods). Registered methods
+// are served by every transport.
+func (s *MCPServer) RegisterMethod(method string, handler MethodHandler) {
+	s.router().handle(method, handler)
+}
+
+// router returns the server's method router, registering the built-in MCP
+// methods on first use.
+func (s *MCPServer) router() *methodRouter {
+	s.methodsOnce.Do(func() {
+		s.methods = newMethodRouter()
+		s.registerMethods()
+	})
+	return s.methods
+}
+
+// registerMethods registers the MCP methods supported by the server.
+func (s *MCPServer) registerMethods() {
+	r := s.methods
+
+	// Lifecycle
+	r.handle("initialize", s.handleInitializeMethod)
+	// Per MCP spec: clients send notifications/initialized after receiving the
+	// initialize response. No response is required.
+	r.handle("notifications/initialized", func(*MethodRequest) *transport.Message { return nil })
+	// Per MCP 2025-11-25 basic/utilities/ping: the receiver MUST respond
+	// promptly with an empty result.
+	r.handle("ping", func(req *MethodRequest) *transport.Message {
+		return &transport.Message{JSONRPC: "2.0", ID: req.Message.ID, Result: []byte(`{}`)}
+	})
+	r.handle("notifications/cancelled", s.handleCancelledNotification)
+
+	// Tools
+	r.handle("tools/list", s.handleToolsListMethod)
+	r.handle("tools/call", s.handleToolsCallMethod)
+
+	// Resources
+	r.handle("resources/list", func(req *MethodRequest) *transport.Message {
+		return rpcResult(req.Message, map[string]any{"resources": listResources()})
+	})
+	r.handle("resources/read", requireScope(config.ScopeRead, s.handleResourcesReadMethod))
+	// Subscriptions belong to the client session, not the request, so they
+	// outlive it and can be cancelled by a later request.
+	r.handle("resources/subscribe", requireScope(config.ScopeRead, func(req *MethodRequest) *transport.Message {
+		_, sink := sessionOf(req.Transport)
+		return s.handleResourceSubscribe(sink, req.Message)
+	}))
+	r.handle("resources/unsubscribe", func(req *MethodRequest) *transport.Message {
+		_, sink := sessionOf(req.Transport)
+		return s.handleResourceUnsubscribe(sink, req.Message)
+	})
+
+	// Prompts
+	r.handle("prompts/list", func(req *MethodRequest) *transport.Message {
+		return rpcResult(req.Message, map[string]any{"prompts": s.listPrompts()})
+	})
+	r.handle("prompts/get", s.handlePromptsGetMethod)
+}
+
+// dispatch routes a message to its registered handler and returns the
+// response, or nil if none is due.
+func (s *MCPServer) dispatch(req *MethodRequest) *transport.Message {
+	msg := req.Message
+	handler, ok := s.router().lookup(msg.Method)
+	if ok {
+		return handler(req)
+	}
+
+	// Unknown method.
+	// Per MCP 2025-11-25 base protocol, notifications (messages without an ID)
+	// MUST NOT receive a response.
+	if isNotification(msg) {
+		return nil
+	}
+	return rpcError(msg, transport.ErrCodeMethodNotFound, fmt.Sprintf("Method not found: %s", msg.Method))
+}
+
+// isNotification reports whether msg is a JSON-RPC notification (has no ID).
+func isNotification(msg *transport.Message) bool {
+	return len(msg.ID) == 0 || string(msg.ID) == "null"
+}
+
+// rpcResult builds a JSON-RPC success response to msg with result marshaled as JSON.
+func rpcResult(msg *transport.Message, result any) *transport.Message {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return rpcError(msg, transport.ErrCodeInternalError, fmt.Sprintf("internal error: %v", err))
+	}
+	return &transport.Message{
+		JSONRPC: "2.0",
+		ID:      msg.ID,
+		Result:  data,
+	}
+}
+
+// rpcError builds a JSON-RPC error response to msg.
+func rpcError(msg *transport.Message, code int, message string) *transport.Message {
+	return &transport.Message{
+		JSONRPC: "2.0",
+		ID:      msg.ID,
+		Error: &transport.ErrorObj{
+			Code:    code,
+			Message: message,
+		},
+	}
+}
+
+// handleInitializeMethod handles the initialize request.
+func (s *MCPServer) handleInitializeMethod(req *MethodRequest) *transport.Message {
+	session, _ := sessionOf(req.Transport)
+	response, err := s.validateAndProcessInitialize(req.Message, session)
+	if err != nil {
+		return rpcError(req.Message, transport.ErrCodeInternalError, err.Error())
+	}
+	return response
+}
+
+// handleToolsListMethod handles the tools/list request.
+func (s *MCPServer) handleToolsListMethod(req *MethodRequest) *transport.Message {
+	s.mu.RLock()
+	tools := make([]map[string]any, 0, len(s.tools))
+	for _, tool := range s.tools {
+		tools = append(tools, map[string]any{
+			"name":        tool.Name,
+			"description": tool.Description,
+			"inputSchema": tool.InputSchema,
+		})
+	}
+	s.mu.RUnlock()
+
+	return rpcResult(req.Message, map[string]any{"tools": tools})
+}
+
+// handleToolsCallMethod handles the tools/call request: it validates the
+// arguments against the tool's schema, then invokes the handler through the
+// tool middleware chain.
+func (s *MCPServer) handleToolsCallMethod(req *MethodRequest) *transport.Message {
+	msg := req.Message
+
+	var params struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+		Meta      struct {
+			ProgressToken json.RawMessage `json:"progressToken"`
+		} `json:"_meta"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return rpcError(msg, transport.ErrCodeInvalidParams, fmt.Sprintf("Invalid params: %v", err))
+	}
+
+	s.mu.RLock()
+	tool, ok := s.tools[params.Name]
+	s.mu.RUnlock()
+
+	if !ok {
+		return rpcError(msg, transport.ErrCodeMethodNotFound, fmt.Sprintf("Tool not found: %s", params.Name))
+	}
+
+	// Validate tool input against schema before calling handler
+	var args map[string]any
+	if len(params.Arguments) > 0 {
+		if err := json.Unmarshal(params.Arguments, &args); err != nil {
+			return rpcError(msg, transport.ErrCodeInvalidParams, fmt.Sprintf("Invalid arguments JSON: %v", err))
+		}
+	} else {
+		args = make(map[string]any)
+	}
+
+	s.mu.RLock()
+	validationErr := validateToolInput(params.Name, args, s.tools)
+	s.mu.RUnlock()
+	if validationErr != nil {
+		validationErr.ID = msg.ID
+		return validationErr
+	}
+
+	ctx, cancel := context.WithCancelCause(s.ctx)
+	defer cancel(nil)
+
+	call := &ToolCall{
+		Name:      params.Name,
+		Arguments: params.Arguments,
+		transport: req.Transport,
+	}
+	if token := params.Meta.ProgressToken; len(token) > 0 && string(token) != "null" {
+		call.progressToken = token
+	}
+	call.ctx = withProgress(ctx, call)
+	if !isNotification(msg) {
+		session, _ := sessionOf(req.Transport)
+		defer s.trackRequest(session, msg.ID, cancel)()
+	}
+	result, err := s.chainToolHandler(tool.Handler)(call)
+
+	// Per MCP 2025-11-25 basic/utilities/cancellation, no response is sent
+	// for a request the client cancelled.
+	if cancelledByClient(call) {
+		return nil
+	}
+	if err != nil {
+		return rpcError(msg, transport.ErrCodeInternalError, err.Error())
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return rpcError(msg, transport.ErrCodeInternalError, "failed to marshal tool result")
+	}
+	return &transport.Message{
+		JSONRPC: "2.0",
+		ID:      msg.ID,
+		Result:  resultJSON,
+	}
+}
+
+// handleResourcesReadMethod handles the resources/read request.
+func (s *MCPServer) handleResourcesReadMethod(req *MethodRequest) *transport.Message {
+	var params struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(req.Message.Params, &params); err != nil {
+		return rpcError(req.Message, transport.ErrCodeInvalidParams, fmt.Sprintf("invalid params: %v", err))
+	}
+
+	contents, err := s.readResource(params.URI)
+	if err != nil {
+		return rpcError(req.Message, transport.ErrCodeInternalError, err.Error())
+	}
+
+	return rpcResult(req.Message, map[string]any{"contents": contents})
+}
+
+// handlePromptsGetMethod handles the prompts/get request.
+func (s *MCPServer) handlePromptsGetMethod(req *MethodRequest) *transport.Message {
+	var params struct {
+		Arguments map[string]any `json:"arguments"`
+		Name      string         `json:"name"`
+	}
+	if err := json.Unmarshal(req.Message.Params, &params); err != nil {
+		return rpcError(req.Message, transport.ErrCodeInvalidParams, fmt.Sprintf("invalid params: %v", err))
+	}
+
+	prompt, err := s.getPrompt(params.Name, params.Arguments)
+	if err != nil {
+		// Unknown prompt name is invalid params per MCP spec
+		return rpcError(req.Message, transport.ErrCodeInvalidParams, err.Error())
+	}
+
+	return rpcResult(req.Message, prompt)
+}
@@ -0,0 +1,173 @@
+// Copyright 2025 Joseph Cumines
+//
+// Tests for the transport-agnostic JSON-RPC method router.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/joeycumines/MacosUseSDK/internal/config"
+	"github.com/joeycumines/MacosUseSDK/internal/transport"
+)
+
+// dispatchBoth sends msg through the HTTP and stdio entry points and returns
+// the raw JSON of each response ("" when no response was produced).
+func dispatchBoth(t *testing.T, s *MCPServer, msg *transport.Message) (httpJSON, stdioJSON string) {
+	t.Helper()
+
+	resp, err := s.handleHTTPMessage(msg)
+	if err != nil {
+		t.Fatalf("handleHTTPMessage error: %v", err)
+	}
+	if resp != nil {
+		data, err := json.Marshal(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		httpJSON = string(data)
+	}
+
+	var buf bytes.Buffer
+	s.handleMessage(transport.NewStdioTransport(strings.NewReader(""), &buf), msg)
+	stdioJSON = strings.TrimSpace(buf.String())
+	return httpJSON, stdioJSON
+}
+
+func TestMethodRouter_TransportParity(t *testing.T) {
+	s := &MCPServer{
+		cfg: &config.Config{RequestTimeout: 30},
+		ctx: context.Background(),
+		tools: map[string]*Tool{
+			"echo": {
+				Name:        "echo",
+				InputSchema: map[string]any{"type": "object", "properties": map[string]any{"text": map[string]any{"type": "string"}}, "required": []string{"text"}},
+				Handler: func(call *ToolCall) (*ToolResult, error) {
+					return textResult(string(call.Arguments)), nil
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		msg    string
+		wantNo bool
+	}{
+		{name: "ping", msg: `{"jsonrpc":"2.0","id":1,"method":"ping"}`},
+		{name: "initialized notification", msg: `{"jsonrpc":"2.0","method":"notifications/initialized"}`, wantNo: true},
+		{name: "unknown notification", msg: `{"jsonrpc":"2.0","method":"notifications/unknown"}`, wantNo: true},
+		{name: "unknown method", msg: `{"jsonrpc":"2.0","id":2,"method":"bogus/method"}`},
+		{name: "tools/list", msg: `{"jsonrpc":"2.0","id":3,"method":"tools/list"}`},
+		{name: "tools/call", msg: `{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"echo","arguments":{"text":"hi"}}}`},
+		{name: "tools/call missing required", msg: `{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"echo","arguments":{}}}`},
+		{name: "tools/call unknown tool", msg: `{"jsonrpc":"2.0","id":6,"method":"tools/call","params":{"name":"nope"}}`},
+		{name: "resources/list", msg: `{"jsonrpc":"2.0","id":7,"method":"resources/list"}`},
+		{name: "resources/unsubscribe", msg: `{"jsonrpc":"2.0","id":8,"method":"resources/unsubscribe","params":{"uri":"clipboard://current"}}`},
+		{name: "prompts/list", msg: `{"jsonrpc":"2.0","id":9,"method":"prompts/list"}`},
+		{name: "prompts/get unknown", msg: `{"jsonrpc":"2.0","id":10,"method":"prompts/get","params":{"name":"nope"}}`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var msg transport.Message
+			if err := json.Unmarshal([]byte(tc.msg), &msg); err != nil {
+				t.Fatal(err)
+			}
+			httpJSON, stdioJSON := dispatchBoth(t, s, &msg)
+			if tc.wantNo {
+				if httpJSON != "" || stdioJSON != "" {
+					t.Fatalf("expected no response, got http=%s stdio=%s", httpJSON, stdioJSON)
+				}
+				return
+			}
+			if httpJSON == "" {
+				t.Fatal("expected a response")
+			}
+			// tools/list iterates a map, so compare decoded values rather than bytes.
+			var httpResp, stdioResp any
+			if err := json.Unmarshal([]byte(httpJSON), &httpResp); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(stdioJSON), &stdioResp); err != nil {
+				t.Fatalf("invalid stdio response %q: %v", stdioJSON, err)
+			}
+			if a, b := mustMarshal(t, httpResp), mustMarshal(t, stdioResp); a != b {
+				t.Errorf("responses differ:\nhttp:  %s\nstdio: %s", a, b)
+			}
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRegisterMethod_ServedByAllTransports(t *testing.T) {
+	s := newTestMCPServer(nil)
+
+	var gotTransport transport.Transport
+	s.RegisterMethod("custom/echo", func(req *MethodRequest) *transport.Message {
+		gotTransport = req.Transport
+		return rpcResult(req.Message, map[string]any{"params": req.Message.Params})
+	})
+
+	msg := &transport.Message{JSONRPC: "2.0", ID: json.RawMessage(`1`), Method: "custom/echo", Params: json.RawMessage(`{"a":1}`)}
+	httpJSON, stdioJSON := dispatchBoth(t, s, msg)
+	want := `{"jsonrpc":"2.0","id":1,"result":{"params":{"a":1}}}`
+	if httpJSON != want {
+		t.Errorf("http response = %s, want %s", httpJSON, want)
+	}
+	if stdioJSON != want {
+		t.Errorf("stdio response = %s, want %s", stdioJSON, want)
+	}
+	if _, ok := gotTransport.(*transport.StdioTransport); !ok {
+		t.Errorf("stdio request transport = %T, want *transport.StdioTransport", gotTransport)
+	}
+
+	// Built-in methods can be overridden.
+	s.RegisterMethod("ping", func(req *MethodRequest) *transport.Message {
+		return rpcResult(req.Message, map[string]string{"pong": "yes"})
+	})
+	resp, _ := s.handleHTTPMessage(&transport.Message{JSONRPC: "2.0", ID: json.RawMessage(`2`), Method: "ping"})
+	if string(resp.Result) != `{"pong":"yes"}` {
+		t.Errorf("overridden ping result = %s", resp.Result)
+	}
+}
+
+func TestToolsCall_RecordsMetricsOnStdio(t *testing.T) {
+	s := newTestMCPServer(nil)
+	s.metrics = transport.NewMetricsRegistry()
+	s.tools["fails"] = &Tool{
+		Name: "fails",
+		Handler: func(call *ToolCall) (*ToolResult, error) {
+			return errorResult("nope"), nil
+		},
+	}
+
+	var buf bytes.Buffer
+	tr := transport.NewStdioTransport(strings.NewReader(""), &buf)
+	s.handleMessage(tr, &transport.Message{
+		JSONRPC: "2.0",
+		ID:      json.RawMessage(`1`),
+		Method:  "tools/call",
+		Params:  json.RawMessage(`{"name":"fails"}`),
+	})
+
+	var out bytes.Buffer
+	if err := s.metrics.WritePrometheus(&out); err != nil {
+		t.Fatal(err)
+	}
+	if want := `mcp_requests_total{tool="fails",status="error"} 1`; !strings.Contains(out.String(), want) {
+		t.Errorf("expected %q in metrics, got:\n%s", want, out.String())
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2025 Joseph Cumines
+//
+// API key and OAuth scopes — the tools each scope granted to an authenticated
+// client allows
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/joeycumines/MacosUseSDK/internal/config"
+	"github.com/joeycumines/MacosUseSDK/internal/transport"
+)
+
+// readScopeTools are the tools that only observe the desktop, granted by the
+// read scope.
+var readScopeTools = map[string]bool{
+	"screenshot":             true,
+	"wait":                   true,
+	"list_apps":              true,
+	"find_elements":          true,
+	"read_element":           true,
+	"element_actions":        true,
+	"get_accessibility_tree": true,
+	"wait_for_element":       true,
+	"wait_for_state":         true,
+	"list_windows":           true,
+	"get_display":            true,
+	"observe_start":          true,
+	"observe_poll":           true,
+	"observe_list":           true,
+	"observe_cancel":         true,
+	"session_snapshot":       true,
+	"macro_list":             true,
+	"macro_get":              true,
+}
+
+// readScopeActions are the actions of multi-action tools that only observe,
+// granted by the read scope. Their other actions need the input scope.
+var readScopeActions = map[string][]string{
+	"clipboard": {"get"},
+	"session":   {"get", "list"},
+}
+
+// runScopeTools are the tools that execute scripts, or macros of arbitrary
+// actions, granted by the run scope.
+var runScopeTools = map[string]bool{
+	"run":           true,
+	"macro_execute": true,
+}
+
+// toolScope returns the scope needed to make call. Tools not known to only
+// observe or to execute scripts, including any added later, need the input
+// scope.
+func toolScope(call *ToolCall) string {
+	switch {
+	case readScopeTools[call.Name]:
+		return config.ScopeRead
+	case runScopeTools[call.Name]:
+		return config.ScopeRun
+	}
+	if actions, ok := readScopeActions[call.Name]; ok {
+		var params struct {
+			Action string `json:"action"`
+		}
+		if json.Unmarshal(call.Arguments, &params) == nil && slices.Contains(actions, params.Action) {
+			return config.ScopeRead
+		}
+	}
+	return config.ScopeInput
+}
+
+// principalOf returns the authenticated client of tr, or nil if the
+// transport does not authenticate its clients.
+func principalOf(tr transport.Transport) *transport.Principal {
+	if a, ok := tr.(transport.Authenticated); ok {
+		return a.Principal()
+	}
+	return nil
+}
+
+// describePrincipal names p's credential in denial messages.
+func describePrincipal(p *transport.Principal) string {
+	switch p.Method {
+	case transport.AuthOAuth:
+		return fmt.Sprintf("access token for %q", p.Name)
+	case transport.AuthMTLS:
+		return fmt.Sprintf("client certificate %q", p.CertSubject)
+	}
+	return fmt.Sprintf("API key %q", p.Name)
+}
+
+// principalAttrs identify p in the audit log: key_name for an API key, or
+// subject for an OAuth access token, and cert_subject for a client
+// certificate.
+func principalAttrs(p *transport.Principal) []slog.Attr {
+	var attrs []slog.Attr
+	switch p.Method {
+	case transport.AuthAPIKey:
+		attrs = append(attrs, slog.String("key_name", p.Name))
+	case transport.AuthOAuth:
+		attrs = append(attrs, slog.String("subject", p.Name))
+	}
+	if p.CertSubject != "" {
+		attrs = append(attrs, slog.String("cert_subject", p.CertSubject))
+	}
+	return attrs
+}
+
+// scopeMiddleware rejects tool calls that the client's API key or access token
+// does not have the scope for. Rejected calls return a soft error result and
+// are audited as "denied".
+func (s *MCPServer) scopeMiddleware(next ToolHandler) ToolHandler {
+	return func(call *ToolCall) (*ToolResult, error) {
+		principal := principalOf(call.transport)
+		if scope := toolScope(call); principal != nil && !principal.HasScope(scope) {
+			result := errorResultf("Denied: %s does not have the %s scope required by %s", describePrincipal(principal), scope, call.Name)
+			result.status = "denied"
+			return result, nil
+		}
+		return next(call)
+	}
+}
+
+// requireScope wraps handler so that it rejects requests from clients whose
+// API key or token does not have scope.
+func requireScope(scope string, handler MethodHandler) MethodHandler {
+	return func(req *MethodRequest) *transport.Message {
+		if principal := principalOf(req.Transport); principal != nil && !principal.HasScope(scope) {
+			return rpcError(req.Message, transport.ErrCodeForbidden, fmt.Sprintf("%s does not have the %s scope required by %s", describePrincipal(principal), scope, req.Message.Method))
+		}
+		return handler(req)
+	}
+}
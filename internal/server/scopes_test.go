@@ -0,0 +1,191 @@
+// Copyright 2025 Joseph Cumines
+//
+// Tests for API key scope enforcement.
+
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joeycumines/MacosUseSDK/internal/config"
+	"github.com/joeycumines/MacosUseSDK/internal/transport"
+)
+
+// keySink is the transport of a request from a client authenticated with an
+// API key.
+type keySink struct {
+	*chanSink
+	principal *transport.Principal
+}
+
+func newKeySink(name string, scopes ...string) *keySink {
+	return &keySink{chanSink: newChanSink(), principal: &transport.Principal{Name: name, Method: transport.AuthAPIKey, Scopes: scopes}}
+}
+
+func (k *keySink) Principal() *transport.Principal { return k.principal }
+
+func TestToolScope(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{"screenshot", `{}`, config.ScopeRead},
+		{"list_windows", `{}`, config.ScopeRead},
+		{"clipboard", `{"action":"get"}`, config.ScopeRead},
+		{"clipboard", `{"action":"set","text":"x"}`, config.ScopeInput},
+		{"session", `{"action":"list"}`, config.ScopeRead},
+		{"session", `{"action":"delete"}`, config.ScopeInput},
+		{"click", `{}`, config.ScopeInput},
+		{"move_window", `{}`, config.ScopeInput},
+		{"run", `{}`, config.ScopeRun},
+		{"macro_execute", `{}`, config.ScopeRun},
+		{"some_new_tool", `{}`, config.ScopeInput},
+	}
+	for _, tt := range tests {
+		if got := toolScope(&ToolCall{Name: tt.name, Arguments: json.RawMessage(tt.args)}); got != tt.want {
+			t.Errorf("toolScope(%s %s) = %q, want %q", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestScopes_DenyToolsOutsideScope(t *testing.T) {
+	s, called := newConfirmationTestServer()
+	reader := newKeySink("dashboard", config.ScopeRead)
+
+	if result := callToolOn(s, reader, "clipboard", `{"action":"get"}`); result.IsError {
+		t.Errorf("read scope denied clipboard get: %s", resultText(result))
+	}
+	result := callToolOn(s, reader, "run", `{"command":"ls"}`)
+	if !result.IsError || result.status != "denied" || !resultContains(result, `API key "dashboard" does not have the run scope`) {
+		t.Errorf("read scope permitted run: %+v", result)
+	}
+	if result := callToolOn(s, reader, "clipboard", `{"action":"set","text":"x"}`); !result.IsError || result.status != "denied" {
+		t.Errorf("read scope permitted clipboard set: %+v", result)
+	}
+	if len(*called) != 1 {
+		t.Errorf("called = %v, want only the permitted call", *called)
+	}
+
+	// Keys without scopes, and unauthenticated clients, are unrestricted.
+	if result := callToolOn(s, newKeySink("default"), "run", `{"command":"ls"}`); result.IsError {
+		t.Errorf("unscoped key denied run: %s", resultText(result))
+	}
+	if result := callToolOn(s, newChanSink(), "run", `{"command":"ls"}`); result.IsError {
+		t.Errorf("unauthenticated client denied run: %s", resultText(result))
+	}
+}
+
+func TestScopes_ResourcesNeedReadScope(t *testing.T) {
+	s := newTestMCPServer(nil)
+	subscribe := map[string]string{"uri": "clipboard://current"}
+
+	for _, method := range []string{"resources/read", "resources/subscribe"} {
+		response := dispatchOn(s, newKeySink("bot", config.ScopeInput), method, subscribe)
+		if response.Error == nil || response.Error.Code != transport.ErrCodeForbidden || !strings.Contains(response.Error.Message, "read scope") {
+			t.Errorf("%s without read scope: %+v", method, response)
+		}
+	}
+}
+
+func TestScopes_AuditLogsKeyName(t *testing.T) {
+	s, _ := newConfirmationTestServer()
+	logPath := filepath.Join(t.TempDir(), "audit.log")
+	logger, err := NewAuditLogger(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.auditLogger = logger
+
+	callToolOn(s, newKeySink("alice", config.ScopeRead), "run", `{"command":"ls"}`)
+	logger.Close()
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry map[string]any
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("invalid audit entry %q: %v", data, err)
+	}
+	if entry["key_name"] != "alice" || entry["status"] != "denied" {
+		t.Errorf("unexpected audit entry: %v", entry)
+	}
+}
+
+func TestScopes_OAuthToken(t *testing.T) {
+	s, _ := newConfirmationTestServer()
+	logPath := filepath.Join(t.TempDir(), "audit.log")
+	logger, err := NewAuditLogger(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.auditLogger = logger
+
+	// A token without scopes grants none.
+	token := &keySink{chanSink: newChanSink(), principal: &transport.Principal{Name: "bob", Method: transport.AuthOAuth, Scopes: []string{}}}
+	result := callToolOn(s, token, "run", `{"command":"ls"}`)
+	logger.Close()
+	if !result.IsError || !resultContains(result, `access token for "bob" does not have the run scope`) {
+		t.Errorf("unscoped token permitted run: %+v", result)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry map[string]any
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("invalid audit entry %q: %v", data, err)
+	}
+	if entry["subject"] != "bob" || entry["key_name"] != nil {
+		t.Errorf("unexpected audit entry: %v", entry)
+	}
+}
+
+func TestScopes_ClientCertificate(t *testing.T) {
+	s, _ := newConfirmationTestServer()
+	logPath := filepath.Join(t.TempDir(), "audit.log")
+	logger, err := NewAuditLogger(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.auditLogger = logger
+
+	// The certificate is recorded alongside the key that authenticated.
+	tr := newKeySink("alice", config.ScopeRead)
+	tr.principal.CertSubject = "CN=alice-laptop,O=Example"
+	callToolOn(s, tr, "run", `{"command":"ls"}`)
+	// A certificate alone grants every scope.
+	cert := &keySink{chanSink: newChanSink(), principal: &transport.Principal{Name: "ci", Method: transport.AuthMTLS, CertSubject: "CN=ci"}}
+	if result := callToolOn(s, cert, "run", `{"command":"ls"}`); result.IsError {
+		t.Errorf("client certificate denied run: %s", resultText(result))
+	}
+	logger.Close()
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("audit log = %q, want 2 entries", data)
+	}
+	var keyEntry, certEntry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &keyEntry); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &certEntry); err != nil {
+		t.Fatal(err)
+	}
+	if keyEntry["key_name"] != "alice" || keyEntry["cert_subject"] != "CN=alice-laptop,O=Example" {
+		t.Errorf("unexpected audit entry: %v", keyEntry)
+	}
+	if certEntry["cert_subject"] != "CN=ci" || certEntry["key_name"] != nil {
+		t.Errorf("unexpected audit entry: %v", certEntry)
+	}
+}
@@ -0,0 +1,355 @@
+// Copyright 2025 Joseph Cumines
+//
+// Element selector language — parses selector strings into ElementSelector protos
+
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	typepb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/type"
+)
+
+// selectorKeys lists the keys a selector term may use, for error messages.
+const selectorKeys = "role, text, text_contains, text_regex, position or @attribute"
+
+// selectorSyntax summarizes the selector language for tool schemas.
+const selectorSyntax = `e.g. role:AXButton and text~"^Save". Terms: role:R, text:T (exact), text~RE (regex), text_contains:S, position:X,Y[,TOLERANCE], @AXAttribute=V; combine with and, or, not and parentheses. Quote values containing spaces around and/or`
+
+// parseElementSelector parses a selector string into an ElementSelector.
+//
+// A selector is one or more terms combined with and, or and not, and grouped
+// with parentheses; not binds tightest, then and, then or. Terms are:
+//
+//	role:AXButton        role (exact)
+//	text:Save            text (exact)
+//	text~"^Save( As)?$"  text matching a regular expression
+//	text_contains:save   text containing a substring
+//	text_regex:^Save     text matching a regular expression
+//	position:100,200,5   element at (100, 200), within 5 pixels
+//	@AXEnabled=true      accessibility attribute value
+//
+// Keys are case-insensitive, and "=" may be used in place of ":". Unquoted
+// values run to the next and, or or closing parenthesis. Quote values with
+// "..." or '...' to include those, or leading and trailing spaces; inside
+// quotes, a backslash escapes the quote character and a backslash, and is
+// otherwise kept, so regular expressions need no double escaping.
+func parseElementSelector(selector string) (*typepb.ElementSelector, error) {
+	p := &selectorParser{src: selector}
+	p.skipSpace()
+	if p.pos == len(p.src) {
+		return nil, fmt.Errorf("selector is empty; use e.g. role:AXButton")
+	}
+	sel, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q; combine terms with and, or and not", p.src[p.pos:])
+	}
+	return sel, nil
+}
+
+// combineSelectors returns a selector matching all of selectors, or nil if
+// there are none.
+func combineSelectors(selectors ...*typepb.ElementSelector) *typepb.ElementSelector {
+	return compoundSelector(typepb.CompoundSelector_OPERATOR_AND, selectors)
+}
+
+// compoundSelector combines selectors with op, or returns the only selector.
+func compoundSelector(op typepb.CompoundSelector_Operator, selectors []*typepb.ElementSelector) *typepb.ElementSelector {
+	switch len(selectors) {
+	case 0:
+		return nil
+	case 1:
+		if op != typepb.CompoundSelector_OPERATOR_NOT {
+			return selectors[0]
+		}
+	}
+	return &typepb.ElementSelector{Criteria: &typepb.ElementSelector_Compound{
+		Compound: &typepb.CompoundSelector{Operator: op, Selectors: selectors},
+	}}
+}
+
+// selectorParser is a recursive descent parser over a selector string.
+type selectorParser struct {
+	src   string
+	pos   int
+	depth int // open parentheses
+}
+
+// errorf returns a parse error at the current position (1-based).
+func (p *selectorParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid selector at position %d: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *selectorParser) skipSpace() {
+	for p.pos < len(p.src) && isSelectorSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+func isSelectorSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// keywordAt reports whether the keyword (and, or, not) is at i, as a whole
+// word followed by a space, a parenthesis or the end of the selector.
+func (p *selectorParser) keywordAt(i int, keyword string) bool {
+	end := i + len(keyword)
+	if end > len(p.src) || !strings.EqualFold(p.src[i:end], keyword) {
+		return false
+	}
+	return end == len(p.src) || isSelectorSpace(p.src[end]) || p.src[end] == '('
+}
+
+// acceptKeyword consumes the keyword if it is next.
+func (p *selectorParser) acceptKeyword(keyword string) bool {
+	p.skipSpace()
+	if !p.keywordAt(p.pos, keyword) {
+		return false
+	}
+	p.pos += len(keyword)
+	return true
+}
+
+// parseOr parses: and-expression { "or" and-expression }.
+func (p *selectorParser) parseOr() (*typepb.ElementSelector, error) {
+	var selectors []*typepb.ElementSelector
+	for {
+		sel, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, sel)
+		if !p.acceptKeyword("or") {
+			return compoundSelector(typepb.CompoundSelector_OPERATOR_OR, selectors), nil
+		}
+	}
+}
+
+// parseAnd parses: unary { "and" unary }.
+func (p *selectorParser) parseAnd() (*typepb.ElementSelector, error) {
+	var selectors []*typepb.ElementSelector
+	for {
+		sel, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, sel)
+		if !p.acceptKeyword("and") {
+			return compoundSelector(typepb.CompoundSelector_OPERATOR_AND, selectors), nil
+		}
+	}
+}
+
+// parseUnary parses: "not" unary | "(" or-expression ")" | term.
+func (p *selectorParser) parseUnary() (*typepb.ElementSelector, error) {
+	if p.acceptKeyword("not") {
+		sel, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return compoundSelector(typepb.CompoundSelector_OPERATOR_NOT, []*typepb.ElementSelector{sel}), nil
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) && p.src[p.pos] == '(' {
+		open := p.pos
+		p.pos++
+		p.depth++
+		sel, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.pos == len(p.src) || p.src[p.pos] != ')' {
+			return nil, p.errorf("missing ) to close the ( at position %d", open+1)
+		}
+		p.pos++
+		p.depth--
+		return sel, nil
+	}
+	return p.parseTerm()
+}
+
+// parseTerm parses: key operator value, where key is a name or @attribute.
+func (p *selectorParser) parseTerm() (*typepb.ElementSelector, error) {
+	if p.pos == len(p.src) {
+		return nil, p.errorf("expected a term (%s) after %q", selectorKeys, strings.TrimSpace(p.src))
+	}
+
+	for _, keyword := range []string{"and", "or"} {
+		if p.keywordAt(p.pos, keyword) {
+			return nil, p.errorf("expected a term (%s) before %s", selectorKeys, keyword)
+		}
+	}
+
+	start := p.pos
+	attribute := p.src[p.pos] == '@'
+	if attribute {
+		p.pos++
+	}
+	keyStart := p.pos
+	for p.pos < len(p.src) && isSelectorKeyChar(p.src[p.pos], attribute) {
+		p.pos++
+	}
+	key := p.src[keyStart:p.pos]
+	if key == "" {
+		if attribute {
+			return nil, p.errorf("expected an attribute name after @, e.g. @AXEnabled=true")
+		}
+		return nil, p.errorf("unexpected %q; expected a term (%s)", p.src[p.pos:p.pos+1], selectorKeys)
+	}
+
+	p.skipSpace()
+	if p.pos == len(p.src) || !strings.ContainsRune(":=~", rune(p.src[p.pos])) {
+		p.pos = start
+		return nil, p.errorf("selector must be in the form key:value (e.g. role:AXTextArea), but %q has no value", key)
+	}
+	op := p.src[p.pos]
+	p.pos++
+	valueStart := p.pos
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	if attribute {
+		if op == '~' {
+			p.pos = start
+			return nil, p.errorf("attributes can't be matched by regular expression; use @%s=VALUE", key)
+		}
+		return &typepb.ElementSelector{Criteria: &typepb.ElementSelector_Attributes{
+			Attributes: &typepb.AttributeSelector{Attributes: map[string]string{key: value}},
+		}}, nil
+	}
+
+	// Empty values are intentionally allowed for role and text: locating an
+	// AXTextField whose current value is empty is a valid UI state, and
+	// role:"" will simply fail to match against real role strings.
+	switch lower := strings.ToLower(key); {
+	case lower == "text" && op == '~', lower == "text_regex" || lower == "textregex":
+		if value == "" {
+			p.pos = valueStart
+			return nil, p.errorf("%s needs a regular expression", key)
+		}
+		return &typepb.ElementSelector{Criteria: &typepb.ElementSelector_TextRegex{TextRegex: value}}, nil
+	case op == '~':
+		p.pos = start
+		return nil, p.errorf("%s can't be matched by regular expression; only text~ can", key)
+	case lower == "role":
+		return &typepb.ElementSelector{Criteria: &typepb.ElementSelector_Role{Role: value}}, nil
+	case lower == "text":
+		return &typepb.ElementSelector{Criteria: &typepb.ElementSelector_Text{Text: value}}, nil
+	case lower == "text_contains" || lower == "textcontains":
+		if value == "" {
+			p.pos = valueStart
+			return nil, p.errorf("%s needs a substring", key)
+		}
+		return &typepb.ElementSelector{Criteria: &typepb.ElementSelector_TextContains{TextContains: value}}, nil
+	case lower == "position" || lower == "pos":
+		position, err := parsePositionSelector(value)
+		if err != nil {
+			p.pos = valueStart
+			return nil, p.errorf("%v", err)
+		}
+		return &typepb.ElementSelector{Criteria: &typepb.ElementSelector_Position{Position: position}}, nil
+	default:
+		p.pos = start
+		return nil, p.errorf("unsupported selector key %q; use %s", key, selectorKeys)
+	}
+}
+
+// isSelectorKeyChar reports whether c may appear in a key, or if attribute,
+// in an attribute name such as AXValue or AXDOMIdentifier.
+func isSelectorKeyChar(c byte, attribute bool) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+		return true
+	case attribute:
+		return (c >= '0' && c <= '9') || c == '-' || c == '.'
+	}
+	return false
+}
+
+// parseValue parses a quoted value, or an unquoted value running to the next
+// and, or or closing parenthesis.
+func (p *selectorParser) parseValue() (string, error) {
+	p.skipSpace()
+	if p.pos < len(p.src) && (p.src[p.pos] == '"' || p.src[p.pos] == '\'') {
+		return p.parseQuoted()
+	}
+
+	start := p.pos
+	end := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == ')' && p.depth > 0 {
+			break
+		}
+		if isSelectorSpace(c) {
+			// A value ends before a following and/or.
+			next := p.pos
+			for next < len(p.src) && isSelectorSpace(p.src[next]) {
+				next++
+			}
+			if p.keywordAt(next, "and") || p.keywordAt(next, "or") {
+				break
+			}
+			p.pos = next
+			continue
+		}
+		p.pos++
+		end = p.pos
+	}
+	p.pos = end
+	return p.src[start:end], nil
+}
+
+// parseQuoted parses a value in double or single quotes.
+func (p *selectorParser) parseQuoted() (string, error) {
+	open := p.pos
+	quote := p.src[p.pos]
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return b.String(), nil
+		case c == '\\' && p.pos+1 < len(p.src) && (p.src[p.pos+1] == quote || p.src[p.pos+1] == '\\'):
+			b.WriteByte(p.src[p.pos+1])
+			p.pos += 2
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+	p.pos = open
+	return "", p.errorf("unterminated quoted value; add a closing %c", quote)
+}
+
+// parsePositionSelector parses "X,Y" or "X,Y,TOLERANCE" in Global Display
+// Coordinates.
+func parsePositionSelector(value string) (*typepb.PositionSelector, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil, fmt.Errorf("position must be X,Y or X,Y,TOLERANCE, got %q", value)
+	}
+	var coords [3]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("position must be X,Y or X,Y,TOLERANCE, got %q", value)
+		}
+		coords[i] = v
+	}
+	if coords[2] < 0 {
+		return nil, fmt.Errorf("position tolerance can't be negative")
+	}
+	return &typepb.PositionSelector{X: coords[0], Y: coords[1], Tolerance: coords[2]}, nil
+}
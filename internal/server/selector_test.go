@@ -0,0 +1,139 @@
+// Copyright 2025 Joseph Cumines
+//
+// Element selector language tests
+
+package server
+
+import (
+	"strings"
+	"testing"
+
+	typepb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/type"
+	"google.golang.org/protobuf/proto"
+)
+
+func roleSel(role string) *typepb.ElementSelector {
+	return &typepb.ElementSelector{Criteria: &typepb.ElementSelector_Role{Role: role}}
+}
+
+func textSel(text string) *typepb.ElementSelector {
+	return &typepb.ElementSelector{Criteria: &typepb.ElementSelector_Text{Text: text}}
+}
+
+func regexSel(re string) *typepb.ElementSelector {
+	return &typepb.ElementSelector{Criteria: &typepb.ElementSelector_TextRegex{TextRegex: re}}
+}
+
+func compoundSel(op typepb.CompoundSelector_Operator, selectors ...*typepb.ElementSelector) *typepb.ElementSelector {
+	return &typepb.ElementSelector{Criteria: &typepb.ElementSelector_Compound{
+		Compound: &typepb.CompoundSelector{Operator: op, Selectors: selectors},
+	}}
+}
+
+const (
+	opAnd = typepb.CompoundSelector_OPERATOR_AND
+	opOr  = typepb.CompoundSelector_OPERATOR_OR
+	opNot = typepb.CompoundSelector_OPERATOR_NOT
+)
+
+func TestParseElementSelector_Grammar(t *testing.T) {
+	tests := []struct {
+		input string
+		want  *typepb.ElementSelector
+	}{
+		{`role:AXButton`, roleSel("AXButton")},
+		{`  role = AXButton  `, roleSel("AXButton")},
+		{`ROLE:AXButton`, roleSel("AXButton")},
+		{`text:Save As`, textSel("Save As")},
+		{`text:"  padded "`, textSel("  padded ")},
+		{`text:'Rock and Roll'`, textSel("Rock and Roll")},
+		{`text:"say \"hi\""`, textSel(`say "hi"`)},
+		{`text:do not disturb`, textSel("do not disturb")},
+		{`text:Save(1)`, textSel("Save(1)")},
+		{`text~"^Save\s+As$"`, regexSel(`^Save\s+As$`)},
+		{`text_regex:^Save`, regexSel("^Save")},
+		{`text_contains:save`, &typepb.ElementSelector{Criteria: &typepb.ElementSelector_TextContains{TextContains: "save"}}},
+		{`position:100,200`, &typepb.ElementSelector{Criteria: &typepb.ElementSelector_Position{
+			Position: &typepb.PositionSelector{X: 100, Y: 200},
+		}}},
+		{`pos: -10.5, 20, 4`, &typepb.ElementSelector{Criteria: &typepb.ElementSelector_Position{
+			Position: &typepb.PositionSelector{X: -10.5, Y: 20, Tolerance: 4},
+		}}},
+		{`@AXEnabled=true`, &typepb.ElementSelector{Criteria: &typepb.ElementSelector_Attributes{
+			Attributes: &typepb.AttributeSelector{Attributes: map[string]string{"AXEnabled": "true"}},
+		}}},
+		{`@AXDOMIdentifier:login-button`, &typepb.ElementSelector{Criteria: &typepb.ElementSelector_Attributes{
+			Attributes: &typepb.AttributeSelector{Attributes: map[string]string{"AXDOMIdentifier": "login-button"}},
+		}}},
+		{`role:AXButton and text~"^Save"`, compoundSel(opAnd, roleSel("AXButton"), regexSel("^Save"))},
+		{`role:AXButton AND text:OK and text:Cancel`, compoundSel(opAnd, roleSel("AXButton"), textSel("OK"), textSel("Cancel"))},
+		{`text:OK or text:Cancel`, compoundSel(opOr, textSel("OK"), textSel("Cancel"))},
+		{`role:AXButton and text:OK or text:Cancel`, compoundSel(opOr,
+			compoundSel(opAnd, roleSel("AXButton"), textSel("OK")),
+			textSel("Cancel"),
+		)},
+		{`role:AXButton and (text:OK or text:Cancel)`, compoundSel(opAnd,
+			roleSel("AXButton"),
+			compoundSel(opOr, textSel("OK"), textSel("Cancel")),
+		)},
+		{`not role:AXButton`, compoundSel(opNot, roleSel("AXButton"))},
+		{`role:AXButton and not(text:OK)`, compoundSel(opAnd, roleSel("AXButton"), compoundSel(opNot, textSel("OK")))},
+		{`not not role:AXButton`, compoundSel(opNot, compoundSel(opNot, roleSel("AXButton")))},
+		{`((role:AXButton))`, roleSel("AXButton")},
+		{`role:`, roleSel("")},
+		{`text:"" and role:AXTextField`, compoundSel(opAnd, textSel(""), roleSel("AXTextField"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseElementSelector(tt.input)
+			if err != nil {
+				t.Fatalf("parseElementSelector(%q) error: %v", tt.input, err)
+			}
+			if !proto.Equal(got, tt.want) {
+				t.Errorf("parseElementSelector(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseElementSelector_Errors(t *testing.T) {
+	tests := []struct {
+		input   string
+		wantErr string
+	}{
+		{``, "selector is empty"},
+		{`   `, "selector is empty"},
+		{`AXButton`, "at position 1: selector must be in the form key:value"},
+		{`role:AXButton and AXButton`, "at position 19: selector must be in the form key:value"},
+		{`role:AXButton and`, `at position 18: expected a term`},
+		{`not`, "expected a term"},
+		{`foo:bar`, `unsupported selector key "foo"`},
+		{`(role:AXButton`, "missing ) to close the ( at position 1"},
+		{`(role:AXButton))`, `at position 16: unexpected ")"`},
+		{`text:"unterminated`, "at position 6: unterminated quoted value"},
+		{`text:"a" text:"b"`, `unexpected "text:\"b\""`},
+		{`role~^AX`, "role can't be matched by regular expression"},
+		{`@AXEnabled~true`, "attributes can't be matched by regular expression"},
+		{`@=true`, "expected an attribute name after @"},
+		{`text~""`, "text needs a regular expression"},
+		{`text_contains:`, "text_contains needs a substring"},
+		{`position:100`, "position must be X,Y or X,Y,TOLERANCE"},
+		{`position:a,b`, "position must be X,Y or X,Y,TOLERANCE"},
+		{`position:1,2,-1`, "position tolerance can't be negative"},
+		{`and role:AXButton`, "at position 1: expected a term"},
+		{`role:AXButton or or text:OK`, "at position 18: expected a term"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseElementSelector(tt.input)
+			if err == nil {
+				t.Fatalf("parseElementSelector(%q) = %v, want error containing %q", tt.input, got, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseElementSelector(%q) error = %q, want it to contain %q", tt.input, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2025 Joseph Cumines
+//
+// Client sessions — server state held separately for each connected client
+
+package server
+
+import (
+	"github.com/joeycumines/MacosUseSDK/internal/transport"
+)
+
+// clientSession is the server state for one client: its initialize handshake
+// and any active recording. Resource subscriptions are held per session too,
+// by subscribing the session's transport; see sessionOf.
+//
+// Streamable HTTP and WebSocket clients each have their own session, as does
+// each legacy SSE connection, whose requests name it with their sessionId
+// parameter; other legacy requests have a session per client. Stdio and tools
+// called without a transport share the default session, "".
+type clientSession struct {
+	// recording is the active recording_start capture, or nil when not recording.
+	recording *macroRecording
+
+	// elicitation records whether the client declared the elicitation
+	// capability in initialize.
+	elicitation bool
+}
+
+// sessionOf returns the ID of the client session tr belongs to, and the
+// transport for server-initiated messages to it, which may be nil. For a
+// transport scoped to one request, that is the session's transport, so it
+// stays valid after the request completes.
+func sessionOf(tr transport.Transport) (id string, sink transport.Transport) {
+	if st, ok := tr.(transport.SessionTransport); ok {
+		return st.SessionID(), st.Session()
+	}
+	return "", tr
+}
+
+// withSession calls fn with the state of the session id, creating it if
+// needed. The state must not be retained after fn returns.
+func (s *MCPServer) withSession(id string, fn func(session *clientSession)) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	if s.sessions == nil {
+		s.sessions = make(map[string]*clientSession)
+	}
+	session, ok := s.sessions[id]
+	if !ok {
+		session = &clientSession{}
+		s.sessions[id] = session
+	}
+	fn(session)
+}
+
+// endSession releases the state of the session id when its client
+// disconnects: its recording is discarded and its subscriptions cancelled.
+func (s *MCPServer) endSession(id string) {
+	s.sessionsMu.Lock()
+	delete(s.sessions, id)
+	s.sessionsMu.Unlock()
+
+	s.unsubscribeSession(id)
+}
@@ -0,0 +1,162 @@
+// Copyright 2025 Joseph Cumines
+//
+// Tests for per-client session isolation.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	pb "github.com/joeycumines/MacosUseSDK/gen/go/macosusesdk/v1"
+	"github.com/joeycumines/MacosUseSDK/internal/transport"
+)
+
+// sessionSink is the transport of a client session, as passed by a
+// multi-client transport.
+type sessionSink struct {
+	*chanSink
+	events chan string
+	id     string
+}
+
+func newSessionSink(id string) *sessionSink {
+	return &sessionSink{chanSink: newChanSink(), events: make(chan string, 16), id: id}
+}
+
+func (s *sessionSink) SessionID() string             { return s.id }
+func (s *sessionSink) Session() transport.Transport  { return s }
+func (s *sessionSink) SendEvent(eventType, _ string) { s.events <- eventType }
+
+// requestScope is the transport for one request of a session.
+type requestScope struct {
+	*chanSink
+	session *sessionSink
+}
+
+func (r *requestScope) SessionID() string            { return r.session.id }
+func (r *requestScope) Session() transport.Transport { return r.session }
+
+// elicitingScope is a request of a session whose client answers elicitation
+// requests.
+type elicitingScope struct {
+	*elicitingSink
+	session *sessionSink
+}
+
+func (e *elicitingScope) SessionID() string            { return e.session.id }
+func (e *elicitingScope) Session() transport.Transport { return e.session }
+
+func dispatchOn(s *MCPServer, tr transport.Transport, method string, params any) *transport.Message {
+	data, _ := json.Marshal(params)
+	return s.dispatch(&MethodRequest{
+		Message:   &transport.Message{JSONRPC: "2.0", ID: json.RawMessage(`1`), Method: method, Params: data},
+		Transport: tr,
+	})
+}
+
+func TestSessions_InitializeStateIsolated(t *testing.T) {
+	s, called := newConfirmationTestServer("run")
+	s.withSession("", func(c *clientSession) { c.elicitation = false })
+	a, b := newSessionSink("a"), newSessionSink("b")
+
+	dispatchOn(s, a, "initialize", map[string]any{"capabilities": map[string]any{"elicitation": map[string]any{}}})
+	dispatchOn(s, b, "initialize", map[string]any{"capabilities": map[string]any{}})
+
+	// Only session a declared elicitation, so only its calls can be confirmed.
+	approving := &elicitingSink{result: `{"action":"accept","content":{"approve":true}}`}
+	result := callToolOn(s, &elicitingScope{approving, b}, "run", `{"command":"ls"}`)
+	if !result.IsError || !resultContains(result, "does not support elicitation") {
+		t.Errorf("session b: unexpected result: %+v", result)
+	}
+	result = callToolOn(s, &elicitingScope{approving, a}, "run", `{"command":"ls"}`)
+	if result.IsError || len(*called) != 1 {
+		t.Errorf("session a: unexpected result: %+v, called = %v", result, *called)
+	}
+}
+
+func TestSessions_RecordingIsolated(t *testing.T) {
+	s := newRecordingTestServer(nil)
+	a, b := newSessionSink("a"), newSessionSink("b")
+
+	callToolOn(s, a, "recording_start", `{}`)
+	callToolOn(s, b, "type", `{"text":"from b"}`)
+	callToolOn(s, a, "type", `{"text":"from a"}`)
+
+	if result := callToolOn(s, b, "recording_stop", `{}`); !result.IsError || !resultContains(result, "No recording in progress") {
+		t.Errorf("session b should have no recording: %+v", result)
+	}
+	result := callToolOn(s, a, "recording_stop", `{"save":false,"parameterize":false}`)
+	if result.IsError || !resultContains(result, "1 tool calls captured") || !resultContains(result, "from a") || resultContains(result, "from b") {
+		t.Errorf("session a recorded the wrong calls: %s", resultText(result))
+	}
+}
+
+func TestSessions_CancellationScoped(t *testing.T) {
+	s := newTestMCPServer(nil)
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer s.trackRequest("a", json.RawMessage(`1`), cancel)()
+
+	// Another client can't cancel a's request 1 by reusing its ID.
+	dispatchOn(s, newSessionSink("b"), "notifications/cancelled", map[string]any{"requestId": 1})
+	if ctx.Err() != nil {
+		t.Fatal("request cancelled by another session")
+	}
+	dispatchOn(s, &requestScope{session: newSessionSink("a")}, "notifications/cancelled", map[string]any{"requestId": 1})
+	if ctx.Err() == nil {
+		t.Error("request not cancelled by its own session")
+	}
+}
+
+func TestSessions_SubscriptionsOutliveRequests(t *testing.T) {
+	client := &mockResourceWatchClient{
+		getClipboardHistoryFunc: func(ctx context.Context, req *pb.GetClipboardHistoryRequest) (*pb.ClipboardHistory, error) {
+			return &pb.ClipboardHistory{}, nil
+		},
+	}
+	s := newTestMCPServer(client)
+	a, b := newSessionSink("a"), newSessionSink("b")
+	subscribe := map[string]string{"uri": "clipboard://current"}
+
+	// Subscribe and unsubscribe in different requests of the same session.
+	dispatchOn(s, &requestScope{chanSink: newChanSink(), session: a}, "resources/subscribe", subscribe)
+	dispatchOn(s, &requestScope{chanSink: newChanSink(), session: b}, "resources/subscribe", subscribe)
+	dispatchOn(s, &requestScope{chanSink: newChanSink(), session: a}, "resources/unsubscribe", subscribe)
+
+	s.subscriptionsMu.Lock()
+	sub := s.subscriptions["clipboard://current"]
+	if _, ok := sub.sinks[a]; ok || len(sub.sinks) != 1 {
+		t.Errorf("unexpected subscribers after unsubscribe: %v", sub.sinks)
+	}
+	s.subscriptionsMu.Unlock()
+
+	// Ending a session cancels its subscriptions.
+	s.endSession("b")
+	s.subscriptionsMu.Lock()
+	defer s.subscriptionsMu.Unlock()
+	if len(s.subscriptions) != 0 {
+		t.Errorf("subscriptions remain after their session ended: %v", s.subscriptions)
+	}
+}
+
+func TestSessions_ObservationEventsTargeted(t *testing.T) {
+	a, b := newSessionSink("a"), newSessionSink("b")
+	stream := newObservationStream(func() {}, a)
+
+	pushObservationEvent(stream, &pb.ObservationEvent{})
+	select {
+	case event := <-a.events:
+		if event != observationSSEEvent {
+			t.Errorf("event type = %q, want %q", event, observationSSEEvent)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("owning session did not receive the event")
+	}
+	select {
+	case event := <-b.events:
+		t.Errorf("other session received %q", event)
+	default:
+	}
+}
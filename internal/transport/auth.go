@@ -0,0 +1,301 @@
+// Copyright 2025 Joseph Cumines
+//
+// API key and OAuth authentication shared by the HTTP and WebSocket transports
+
+package transport
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// defaultAPIKeyName is the name of the key configured by HTTPTransportConfig.APIKey.
+const defaultAPIKeyName = "default"
+
+// Authentication methods, as reported by Principal.Method.
+const (
+	// AuthAPIKey authenticates clients by a configured API key.
+	AuthAPIKey = "api_key"
+	// AuthOAuth authenticates clients by an OAuth access token.
+	AuthOAuth = "oauth"
+	// AuthMTLS authenticates clients by their TLS client certificate alone,
+	// when mutual TLS is configured without API keys or OAuth.
+	AuthMTLS = "mtls"
+)
+
+// APIKey is a named API key accepted by the HTTP and WebSocket transports.
+type APIKey struct {
+	// Name identifies the key's holder, e.g. in the audit log.
+	Name string
+	// Key is the secret the client sends as its Bearer token.
+	Key string
+	// Scopes lists the scopes the key grants; see Principal.
+	Scopes []string
+}
+
+// Principal is the authenticated client of a request.
+type Principal struct {
+	// Name is the name of the client's API key, the subject of its access
+	// token, or the common name of its client certificate.
+	Name string
+	// Method is how the client authenticated: AuthAPIKey, AuthOAuth or
+	// AuthMTLS.
+	Method string
+	// CertSubject is the subject of the client's verified TLS certificate,
+	// if mutual TLS is configured.
+	CertSubject string
+	// Scopes lists the scopes granted to the client. The server decides what
+	// each scope permits. Nil grants every scope.
+	Scopes []string
+	// id identifies the client for rate limiting.
+	id string
+}
+
+// HasScope reports whether p grants scope.
+func (p *Principal) HasScope(scope string) bool {
+	return p.Scopes == nil || slices.Contains(p.Scopes, scope)
+}
+
+// Authenticated is implemented by the transports the HTTP and WebSocket
+// transports pass to their ScopedHandler, so servers can authorize requests
+// by their client.
+type Authenticated interface {
+	// Principal returns the client that sent the request, or nil if
+	// authentication is disabled.
+	Principal() *Principal
+}
+
+// apiKeyStore holds the API keys a transport accepts. They can be replaced
+// while serving, e.g. when reloaded from a file.
+type apiKeyStore struct {
+	keys atomic.Pointer[[]storedKey]
+}
+
+// storedKey is an accepted API key. Only its digest is kept, so tokens of any
+// length are compared in constant time.
+type storedKey struct {
+	principal *Principal
+	digest    [sha256.Size]byte
+}
+
+// newAPIKeyStore returns a store of the keys in config, or nil if it has none
+// and authentication is disabled.
+func newAPIKeyStore(config *HTTPTransportConfig) *apiKeyStore {
+	if config.APIKey == "" && len(config.APIKeys) == 0 {
+		return nil
+	}
+	s := &apiKeyStore{}
+	s.set(config, config.APIKeys)
+	return s
+}
+
+// set replaces the accepted keys with keys, plus config's APIKey if set.
+func (s *apiKeyStore) set(config *HTTPTransportConfig, keys []APIKey) {
+	if config.APIKey != "" {
+		keys = append([]APIKey{{Name: defaultAPIKeyName, Key: config.APIKey}}, keys...)
+	}
+	stored := make([]storedKey, 0, len(keys))
+	for _, key := range keys {
+		if key.Key == "" {
+			continue
+		}
+		digest := sha256.Sum256([]byte(key.Key))
+		stored = append(stored, storedKey{
+			principal: &Principal{
+				Name:   key.Name,
+				Method: AuthAPIKey,
+				Scopes: slices.Clone(key.Scopes),
+				id:     "key:" + hex.EncodeToString(digest[:8]),
+			},
+			digest: digest,
+		})
+	}
+	s.keys.Store(&stored)
+}
+
+// authenticate returns the client whose key is token, or nil if token is not
+// an accepted key. Every key is compared, so the time taken does not reveal
+// which matched.
+func (s *apiKeyStore) authenticate(token string) *Principal {
+	digest := sha256.Sum256([]byte(token))
+	var principal *Principal
+	for _, key := range *s.keys.Load() {
+		if subtle.ConstantTimeCompare(digest[:], key.digest[:]) == 1 {
+			principal = key.principal
+		}
+	}
+	return principal
+}
+
+// lookup returns the current client for the key that authenticated p, or nil
+// if the key is no longer accepted. Clients that did not authenticate by API
+// key are returned as is.
+func (s *apiKeyStore) lookup(p *Principal) *Principal {
+	if p.Method != AuthAPIKey {
+		return p
+	}
+	for _, key := range *s.keys.Load() {
+		if key.principal.id == p.id {
+			return key.principal.withCertSubject(p.CertSubject)
+		}
+	}
+	return nil
+}
+
+// withCertSubject returns p with the client certificate subject, copying it
+// so that stored principals are not modified.
+func (p *Principal) withCertSubject(subject string) *Principal {
+	if subject == "" {
+		return p
+	}
+	withSubject := *p
+	withSubject.CertSubject = subject
+	return &withSubject
+}
+
+// authMiddleware validates Bearer token authentication against keys, and
+// if oauth is set, OAuth access tokens. Either may be nil. Requests must
+// include an Authorization header with an accepted key or valid token.
+// Rejected requests are challenged with a WWW-Authenticate header, which
+// points OAuth clients to the resource metadata.
+// The /health endpoint is exempt from authentication for load balancer health checks,
+// as is the resource metadata, which clients need before they have a token.
+// It is shared by the HTTP and WebSocket transports.
+func authMiddleware(keys *apiKeyStore, oauth *OAuthValidator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Health check endpoint is exempt from authentication
+		if r.URL.Path == "/health" || (oauth != nil && r.URL.Path == ProtectedResourceMetadataPath) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		authHeader := r.Header.Get("Authorization")
+		if authHeader == "" {
+			challenge(w, r, oauth, "", "")
+			http.Error(w, "Authorization header required", http.StatusUnauthorized)
+			return
+		}
+
+		// Expect "Bearer <token>" format
+		const bearerPrefix = "Bearer "
+		if !strings.HasPrefix(authHeader, bearerPrefix) {
+			challenge(w, r, oauth, "invalid_request", "expected Bearer token")
+			http.Error(w, "Invalid authorization format, expected Bearer token", http.StatusUnauthorized)
+			return
+		}
+		token := strings.TrimPrefix(authHeader, bearerPrefix)
+
+		var principal *Principal
+		if keys != nil {
+			principal = keys.authenticate(token)
+		}
+		if principal == nil && oauth != nil {
+			var err error
+			if principal, err = oauth.Validate(r.Context(), token); err != nil {
+				challenge(w, r, oauth, "invalid_token", err.Error())
+				http.Error(w, "Invalid access token: "+err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+		if principal == nil {
+			challenge(w, r, oauth, "invalid_token", "")
+			http.Error(w, "Invalid API key", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+	})
+}
+
+// challenge sets the WWW-Authenticate header of a 401 response (RFC 6750),
+// with the error code and description if set, and the resource metadata URL
+// if oauth is set (RFC 9728).
+func challenge(w http.ResponseWriter, r *http.Request, oauth *OAuthValidator, code, description string) {
+	var params []string
+	if code != "" {
+		params = append(params, fmt.Sprintf("error=%q", code))
+	}
+	if description != "" {
+		// Quoted strings can't hold quotes or backslashes unescaped.
+		description = strings.NewReplacer(`"`, "'", `\`, "/").Replace(description)
+		params = append(params, fmt.Sprintf(`error_description="%s"`, description))
+	}
+	if oauth != nil {
+		params = append(params, fmt.Sprintf("resource_metadata=%q", oauth.resourceMetadataURL(r)))
+	}
+	value := "Bearer"
+	if len(params) > 0 {
+		value += " " + strings.Join(params, ", ")
+	}
+	w.Header().Set("WWW-Authenticate", value)
+}
+
+// clientCertMiddleware identifies requests by their verified TLS client
+// certificate. It adds the certificate's subject to the client authenticated
+// by authMiddleware, or if there is none, as authentication is disabled,
+// makes the certificate the client's identity, with the scopes its subject is
+// granted by scopes, or none. It runs after authMiddleware, and before the
+// rate limit, which then applies per certificate.
+func clientCertMiddleware(scopes map[string][]string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cert := r.TLS.VerifiedChains[0][0]
+		subject := cert.Subject.String()
+		principal := principalOf(r)
+		if principal != nil {
+			principal = principal.withCertSubject(subject)
+		} else {
+			name := cert.Subject.CommonName
+			if name == "" {
+				name = subject
+			}
+			sum := sha256.Sum256([]byte(subject))
+			principal = &Principal{
+				Name:        name,
+				Method:      AuthMTLS,
+				CertSubject: subject,
+				Scopes:      append([]string{}, scopes[subject]...), // never nil, which grants every scope
+				id:          "cert:" + hex.EncodeToString(sum[:8]),
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+	})
+}
+
+// principalKey is the request context key for the client identified by
+// authMiddleware or clientCertMiddleware.
+type principalKey struct{}
+
+// principalOf returns the authenticated client making r, or nil if
+// authentication is disabled.
+func principalOf(r *http.Request) *Principal {
+	principal, _ := r.Context().Value(principalKey{}).(*Principal)
+	return principal
+}
+
+// clientID identifies the client making r, for per-client rate limiting:
+// "key:" and its API key's fingerprint, "oauth:" and a fingerprint of its
+// token's issuer and subject, or "cert:" and a fingerprint of its client
+// certificate's subject, once authenticated, otherwise "addr:" and its
+// remote address.
+func clientID(r *http.Request) string {
+	if principal := principalOf(r); principal != nil {
+		return principal.id
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "addr:" + host
+}
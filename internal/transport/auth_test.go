@@ -24,7 +24,7 @@ func TestAuthMiddleware_ValidBearerToken(t *testing.T) {
 		APIKey: apiKey,
 	})
 
-	handler := tr.authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	handler := authMiddleware(tr.apiKeys, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("authenticated"))
 	}))
@@ -55,7 +55,7 @@ func TestAuthMiddleware_InvalidBearerToken(t *testing.T) {
 		APIKey: "correct-secret-key",
 	})
 
-	handler := tr.authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	handler := authMiddleware(tr.apiKeys, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		t.Error("Handler should not be called for invalid token")
 	}))
 
@@ -97,7 +97,7 @@ func TestAuthMiddleware_MissingAuthorizationHeader(t *testing.T) {
 		APIKey: "test-secret-key",
 	})
 
-	handler := tr.authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	handler := authMiddleware(tr.apiKeys, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		t.Error("Handler should not be called for missing auth")
 	}))
 
@@ -127,7 +127,7 @@ func TestAuthMiddleware_HealthEndpointExempt(t *testing.T) {
 		APIKey: "test-secret-key",
 	})
 
-	handler := tr.authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	handler := authMiddleware(tr.apiKeys, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("health ok"))
 	}))
@@ -168,7 +168,7 @@ func TestAuthMiddleware_MetricsEndpointRequiresAuth(t *testing.T) {
 		APIKey: "test-secret-key",
 	})
 
-	handler := tr.authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	handler := authMiddleware(tr.apiKeys, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("metrics data"))
 	}))
@@ -206,7 +206,7 @@ func TestAuthMiddleware_MalformedAuthorizationHeader(t *testing.T) {
 		APIKey: "test-secret-key",
 	})
 
-	handler := tr.authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	handler := authMiddleware(tr.apiKeys, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		t.Error("Handler should not be called for malformed auth")
 	}))
 
@@ -257,7 +257,7 @@ func TestAuthMiddleware_CaseSensitivity(t *testing.T) {
 		APIKey: apiKey,
 	})
 
-	handler := tr.authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	handler := authMiddleware(tr.apiKeys, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	}))
 
@@ -513,7 +513,7 @@ func TestAuthIntegration_FullServerWithoutAuth(t *testing.T) {
 }
 
 // TestAuthIntegration_CORSWithAuth verifies that CORS preflight with auth enabled.
-// Note: The current middleware chain is: RateLimit -> Auth -> CORS -> mux,
+// Note: The current middleware chain is: Auth -> RateLimit -> CORS -> mux,
 // which means Auth checks run BEFORE CORS. This is intentional for security:
 // auth is enforced on all endpoints (except explicitly exempted ones like /health).
 // CORS preflight (OPTIONS) requests WITHOUT auth will be rejected with 401.
@@ -605,7 +605,7 @@ func TestAuthMiddleware_ConstantTimeComparison(t *testing.T) {
 		APIKey: apiKey,
 	})
 
-	handler := tr.authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	handler := authMiddleware(tr.apiKeys, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	}))
 
@@ -644,3 +644,102 @@ func TestAuthMiddleware_EmptyAPIKey(t *testing.T) {
 		t.Error("IsAuthEnabled() = true, want false when APIKey is empty")
 	}
 }
+
+// =============================================================================
+// Named API Keys
+// =============================================================================
+
+func TestAuthMiddleware_NamedKeys(t *testing.T) {
+	tr := NewHTTPTransport(&HTTPTransportConfig{
+		APIKeys: []APIKey{
+			{Name: "alice", Key: "alice-secret", Scopes: []string{"read", "input"}},
+			{Name: "ci", Key: "ci-secret", Scopes: []string{"run"}},
+		},
+	})
+	if !tr.IsAuthEnabled() {
+		t.Fatal("IsAuthEnabled() = false, want true with named keys")
+	}
+
+	var got *Principal
+	handler := authMiddleware(tr.apiKeys, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = principalOf(r)
+	}))
+	serve := func(key string) int {
+		got = nil
+		req := httptest.NewRequest("POST", MCPEndpoint, nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := serve("ci-secret"); code != http.StatusOK || got == nil || got.Name != "ci" {
+		t.Fatalf("ci key: status = %d, principal = %+v", code, got)
+	}
+	if !got.HasScope("run") || got.HasScope("read") {
+		t.Errorf("ci scopes = %v, want run only", got.Scopes)
+	}
+	if code := serve("alice-secret"); code != http.StatusOK || got == nil || got.Name != "alice" {
+		t.Errorf("alice key: status = %d, principal = %+v", code, got)
+	}
+	if code := serve("bob-secret"); code != http.StatusUnauthorized {
+		t.Errorf("unknown key: status = %d, want 401", code)
+	}
+}
+
+func TestHTTPTransport_SetAPIKeys(t *testing.T) {
+	tr := NewHTTPTransport(&HTTPTransportConfig{
+		APIKey:  "legacy-secret",
+		APIKeys: []APIKey{{Name: "alice", Key: "alice-secret", Scopes: []string{"read"}}},
+	})
+	if err := tr.SetAPIKeys([]APIKey{{Name: "bob", Key: "bob-secret", Scopes: []string{"input"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.apiKeys.authenticate("alice-secret") != nil {
+		t.Error("removed key still accepted")
+	}
+	if p := tr.apiKeys.authenticate("bob-secret"); p == nil || p.Name != "bob" {
+		t.Errorf("added key: principal = %+v", p)
+	}
+	// The configured key survives reloads, with every scope.
+	if p := tr.apiKeys.authenticate("legacy-secret"); p == nil || p.Name != defaultAPIKeyName || !p.HasScope("run") {
+		t.Errorf("configured key: principal = %+v", p)
+	}
+
+	if err := NewHTTPTransport(nil).SetAPIKeys(nil); err == nil {
+		t.Error("SetAPIKeys() should fail when authentication is disabled")
+	}
+}
+
+func TestHTTPTransport_ScopedTransportsAuthenticated(t *testing.T) {
+	principals := make(chan *Principal, 2)
+	tr, ts := newStreamableServer(t, &HTTPTransportConfig{
+		APIKeys:   []APIKey{{Name: "alice", Key: "alice-secret", Scopes: []string{"read"}}},
+		LegacySSE: true,
+	}, echoResult)
+	tr.scopedHandler = func(scoped Transport, msg *Message) (*Message, error) {
+		if a, ok := scoped.(Authenticated); ok {
+			principals <- a.Principal()
+		} else {
+			principals <- nil
+		}
+		return echoResult(scoped, msg)
+	}
+	tr.handler = func(msg *Message) (*Message, error) { return tr.scopedHandler(tr, msg) }
+
+	for _, path := range []string{MCPEndpoint, "/message"} {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+path, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize"}`))
+		req.Header.Set("Authorization", "Bearer alice-secret")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", acceptBoth)
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if p := <-principals; p == nil || p.Name != "alice" {
+			t.Errorf("%s: handler's transport principal = %+v, want alice", path, p)
+		}
+	}
+}
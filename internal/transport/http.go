@@ -1,13 +1,15 @@
 // Copyright 2025 Joseph Cumines
 //
-// HTTP/SSE transport for JSON-RPC 2.0 communication
+// HTTP transport for JSON-RPC 2.0 communication — Streamable HTTP, plus the
+// legacy HTTP/SSE endpoints
 
 package transport
 
 import (
 	"context"
-	"crypto/subtle"
+	"crypto/rand"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -27,8 +29,18 @@ const (
 	maxEventStoreSize = 1000
 	// sseClientBufferSize is the buffer size for SSE client response channels.
 	sseClientBufferSize = 100
+	// legacySessionParam is the /message query parameter naming the legacy SSE
+	// connection a request belongs to, as announced by its endpoint event.
+	legacySessionParam = "sessionId"
 	// serverShutdownTimeout is the timeout for graceful HTTP server shutdown.
 	serverShutdownTimeout = 5 * time.Second
+	// defaultSessionIdleTimeout is how long a Streamable HTTP session may go unused.
+	defaultSessionIdleTimeout = 30 * time.Minute
+	// defaultMaxSessions is the default limit on open Streamable HTTP sessions.
+	defaultMaxSessions = 100
+	// defaultMaxConcurrency is the default limit on requests handled at once
+	// for each WebSocket connection.
+	defaultMaxConcurrency = 8
 )
 
 // HTTPTransportConfig holds configuration for HTTP transport.
@@ -41,19 +53,39 @@ const (
 // Note: WriteTimeout is disabled by default because SSE streams require long-lived connections.
 // TLSCertFile is the path to the TLS certificate file (optional, enables TLS if set).
 // TLSKeyFile is the path to the TLS private key file (optional, required if TLSCertFile is set).
-// APIKey is the API key for Bearer token authentication (optional, no auth if empty).
-// RateLimit is the rate limit in requests per second (0 = disabled).
+// TLSClientCAFile is the path to a PEM bundle of CAs that must sign client certificates (optional,
+// enables mutual TLS; requires TLSCertFile and TLSKeyFile).
+// ClientCertScopes are the scopes granted to clients identified by their certificate alone, by
+// certificate subject (optional). Other certificates grant no scopes.
+// APIKey is the API key for Bearer token authentication, named "default" (optional).
+// APIKeys are named API keys with scopes (optional, no auth if empty along with APIKey).
+// OAuth validates OAuth access tokens, alongside any API keys (optional).
+// RateLimit is the rate limit in requests per second for each client (0 = disabled).
+// ToolCosts weights tools/call requests by tool name, in requests (default: 1).
+// LegacySSE also serves the legacy POST /message and GET /events endpoints.
+// SessionIdleTimeout ends Streamable HTTP sessions unused for this long (default: 30m).
+// MaxSessions limits the open Streamable HTTP sessions (default: 100).
+// MaxConcurrency limits the requests handled at once on each WebSocket connection (default: 8).
 type HTTPTransportConfig struct {
-	Address           string
-	SocketPath        string
-	CORSOrigin        string
-	TLSCertFile       string
-	TLSKeyFile        string
-	APIKey            string
-	HeartbeatInterval time.Duration
-	ReadTimeout       time.Duration
-	WriteTimeout      time.Duration
-	RateLimit         float64
+	Address            string
+	SocketPath         string
+	CORSOrigin         string
+	TLSCertFile        string
+	TLSKeyFile         string
+	TLSClientCAFile    string
+	ClientCertScopes   map[string][]string
+	APIKey             string
+	APIKeys            []APIKey
+	OAuth              *OAuthValidator
+	HeartbeatInterval  time.Duration
+	ReadTimeout        time.Duration
+	WriteTimeout       time.Duration
+	ToolCosts          map[string]float64
+	RateLimit          float64
+	SessionIdleTimeout time.Duration
+	MaxSessions        int
+	MaxConcurrency     int
+	LegacySSE          bool
 }
 
 // DefaultHTTPConfig returns the default HTTP transport configuration.
@@ -61,28 +93,37 @@ type HTTPTransportConfig struct {
 // CORS allows all origins, and read timeout is 30 seconds.
 func DefaultHTTPConfig() *HTTPTransportConfig {
 	return &HTTPTransportConfig{
-		Address:           ":8080",
-		HeartbeatInterval: 15 * time.Second,
-		CORSOrigin:        "*",
-		ReadTimeout:       30 * time.Second,
-		WriteTimeout:      0, // Disabled for SSE compatibility
-	}
-}
-
-// HTTPTransport implements HTTP/SSE transport for MCP.
-// It provides POST /message for JSON-RPC requests, GET /events for SSE streaming,
-// GET /health for server health checks, and GET /metrics for Prometheus-style metrics.
-// This is a non-standard MCP transport extension documented in docs/ai-artifacts/05-mcp-integration.md.
+		Address:            ":8080",
+		HeartbeatInterval:  15 * time.Second,
+		CORSOrigin:         "*",
+		ReadTimeout:        30 * time.Second,
+		WriteTimeout:       0, // Disabled for SSE compatibility
+		SessionIdleTimeout: defaultSessionIdleTimeout,
+		MaxSessions:        defaultMaxSessions,
+		MaxConcurrency:     defaultMaxConcurrency,
+	}
+}
+
+// HTTPTransport implements the MCP Streamable HTTP transport at /mcp, with
+// GET /health for server health checks and GET /metrics for Prometheus-style
+// metrics. With LegacySSE, it also provides POST /message for JSON-RPC requests
+// and GET /events for SSE streaming, a non-standard MCP transport extension
+// documented in docs/ai-artifacts/05-mcp-integration.md.
 type HTTPTransport struct {
-	config      *HTTPTransportConfig
-	server      *http.Server
-	handler     func(*Message) (*Message, error)
-	clients     *ClientRegistry
-	metrics     *MetricsRegistry
-	rateLimiter *RateLimiter
-	shutdownCh  chan struct{}
-	eventID     atomic.Uint64
-	closed      atomic.Bool
+	config         *HTTPTransportConfig
+	server         *http.Server
+	handler        func(*Message) (*Message, error)
+	scopedHandler  ScopedHandler
+	clients        *ClientRegistry
+	sessions       *sessionRegistry
+	legacySessions map[string]string // legacy SSE session ID -> owning client
+	metrics        *MetricsRegistry
+	rateLimiter    *ClientRateLimiter
+	apiKeys        *apiKeyStore
+	shutdownCh     chan struct{}
+	eventID        atomic.Uint64
+	closed         atomic.Bool
+	legacyMu       sync.Mutex
 }
 
 // ClientRegistry manages connected SSE clients and event distribution.
@@ -237,6 +278,31 @@ func (r *ClientRegistry) Broadcast(event *SSEEvent) {
 	}
 }
 
+// Send sends an event to a single client, the most recently connected, and
+// stores it for replay. With no clients connected, the event is only stored,
+// so a client reconnecting with Last-Event-ID receives it.
+func (r *ClientRegistry) Send(event *SSEEvent) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	r.eventStore.Add(event)
+
+	var newest *SSEClient
+	for _, client := range r.clients {
+		if newest == nil || client.CreatedAt.After(newest.CreatedAt) {
+			newest = client
+		}
+	}
+	if newest == nil {
+		return
+	}
+	select {
+	case newest.ResponseChan <- event:
+	default:
+		log.Printf("Warning: dropping event %s for client %s (buffer full)", event.ID, newest.ID)
+	}
+}
+
 // Count returns the current number of connected SSE clients.
 func (r *ClientRegistry) Count() int {
 	r.mu.RLock()
@@ -244,47 +310,59 @@ func (r *ClientRegistry) Count() int {
 	return len(r.clients)
 }
 
-// NewHTTPTransport creates a new HTTP/SSE transport with the given configuration.
+// all returns the connected clients.
+func (r *ClientRegistry) all() []*SSEClient {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	clients := make([]*SSEClient, 0, len(r.clients))
+	for _, client := range r.clients {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// NewHTTPTransport creates a new HTTP transport with the given configuration.
 // If config is nil, default configuration is used. The transport sets up routes
-// for /message, /events, /health, and /metrics endpoints.
+// for the /mcp, /health, and /metrics endpoints, and /message and /events if
+// LegacySSE is set.
 func NewHTTPTransport(config *HTTPTransportConfig) *HTTPTransport {
-	if config == nil {
-		config = DefaultHTTPConfig()
-	}
-	if config.HeartbeatInterval == 0 {
-		config.HeartbeatInterval = 15 * time.Second
-	}
-	if config.CORSOrigin == "" {
-		config.CORSOrigin = "*"
-	}
-	if config.ReadTimeout == 0 {
-		config.ReadTimeout = 30 * time.Second
-	}
-	// Note: WriteTimeout defaults to 0 (disabled) for SSE compatibility.
-	// SSE streams require long-lived connections, so we don't force a default.
+	config = withHTTPDefaults(config)
 
 	t := &HTTPTransport{
-		config:      config,
-		clients:     NewClientRegistry(),
-		metrics:     NewMetricsRegistry(),
-		rateLimiter: NewRateLimiter(config.RateLimit),
-		shutdownCh:  make(chan struct{}),
+		config:         config,
+		clients:        NewClientRegistry(),
+		sessions:       newSessionRegistry(config.SessionIdleTimeout, config.MaxSessions),
+		legacySessions: make(map[string]string),
+		metrics:        NewMetricsRegistry(),
+		rateLimiter:    NewClientRateLimiter(config.RateLimit, config.ToolCosts),
+		apiKeys:        newAPIKeyStore(config),
+		shutdownCh:     make(chan struct{}),
 	}
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/message", t.handleMessage)
-	mux.HandleFunc("/events", t.handleSSE)
+	mux.HandleFunc(MCPEndpoint, t.handleMCP)
+	if config.LegacySSE {
+		mux.HandleFunc("/message", t.handleMessage)
+		mux.HandleFunc("/events", t.handleSSE)
+	}
 	mux.HandleFunc("/health", t.handleHealth)
 	mux.HandleFunc("/metrics", t.handleMetrics)
+	if config.OAuth != nil {
+		mux.HandleFunc(ProtectedResourceMetadataPath, config.OAuth.handleProtectedResourceMetadata)
+	}
 
-	// Build middleware chain: CORS wrapper -> Auth wrapper -> Rate limit wrapper -> mux
+	// Build middleware chain: CORS wrapper -> Rate limit wrapper -> Client cert wrapper -> Auth wrapper -> mux
+	// Auth runs first, so requests are rate limited by authenticated client.
 	var handler http.Handler = mux
-	handler = t.corsMiddleware(handler)
-	if config.APIKey != "" {
-		handler = t.authMiddleware(handler)
-	}
+	handler = corsMiddleware(config, handler)
 	if t.rateLimiter != nil {
-		handler = RateLimitMiddleware(t.rateLimiter, handler)
+		handler = clientRateLimitMiddleware(t.rateLimiter, t.metrics, handler, MCPEndpoint, "/message")
+	}
+	if config.TLSClientCAFile != "" {
+		handler = clientCertMiddleware(config.ClientCertScopes, handler)
+	}
+	if t.apiKeys != nil || config.OAuth != nil {
+		handler = authMiddleware(t.apiKeys, config.OAuth, handler)
 	}
 
 	t.server = &http.Server{
@@ -296,13 +374,43 @@ func NewHTTPTransport(config *HTTPTransportConfig) *HTTPTransport {
 	return t
 }
 
-// corsMiddleware adds CORS headers to all responses
-func (t *HTTPTransport) corsMiddleware(next http.Handler) http.Handler {
+// withHTTPDefaults returns config, or the default configuration if it is nil,
+// with defaults applied to unset fields.
+func withHTTPDefaults(config *HTTPTransportConfig) *HTTPTransportConfig {
+	if config == nil {
+		config = DefaultHTTPConfig()
+	}
+	if config.HeartbeatInterval == 0 {
+		config.HeartbeatInterval = 15 * time.Second
+	}
+	if config.CORSOrigin == "" {
+		config.CORSOrigin = "*"
+	}
+	if config.ReadTimeout == 0 {
+		config.ReadTimeout = 30 * time.Second
+	}
+	if config.SessionIdleTimeout == 0 {
+		config.SessionIdleTimeout = defaultSessionIdleTimeout
+	}
+	if config.MaxSessions == 0 {
+		config.MaxSessions = defaultMaxSessions
+	}
+	if config.MaxConcurrency == 0 {
+		config.MaxConcurrency = defaultMaxConcurrency
+	}
+	// Note: WriteTimeout defaults to 0 (disabled) for SSE compatibility.
+	// SSE streams require long-lived connections, so we don't force a default.
+	return config
+}
+
+// corsMiddleware adds CORS headers to all responses. It is shared by the HTTP
+// and WebSocket transports.
+func corsMiddleware(config *HTTPTransportConfig, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", t.config.CORSOrigin)
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Last-Event-ID, Authorization")
-		w.Header().Set("Access-Control-Expose-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Origin", config.CORSOrigin)
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Last-Event-ID, Authorization, "+SessionIDHeader+", "+ProtocolVersionHeader)
+		w.Header().Set("Access-Control-Expose-Headers", "Content-Type, "+SessionIDHeader)
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusNoContent)
@@ -313,39 +421,68 @@ func (t *HTTPTransport) corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// authMiddleware validates Bearer token authentication.
-// If the APIKey is configured, requests must include a valid Authorization header.
-// The /health endpoint is exempt from authentication for load balancer health checks.
-func (t *HTTPTransport) authMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Health check endpoint is exempt from authentication
-		if r.URL.Path == "/health" {
-			next.ServeHTTP(w, r)
-			return
-		}
+// legacyRequest is the Transport scoped to a request on the legacy
+// endpoints: the HTTPTransport itself, on behalf of the request's client.
+type legacyRequest struct {
+	*HTTPTransport
+	principal *Principal
+	session   string
+}
 
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Authorization header required", http.StatusUnauthorized)
-			return
-		}
+// SessionID returns the session of the request: that of the SSE connection
+// named by its sessionId parameter, or else one for its client.
+func (r *legacyRequest) SessionID() string {
+	return r.session
+}
 
-		// Expect "Bearer <token>" format
-		const bearerPrefix = "Bearer "
-		if !strings.HasPrefix(authHeader, bearerPrefix) {
-			http.Error(w, "Invalid authorization format, expected Bearer token", http.StatusUnauthorized)
-			return
-		}
+// Session returns the HTTPTransport.
+func (r *legacyRequest) Session() Transport {
+	return r.HTTPTransport
+}
 
-		token := strings.TrimPrefix(authHeader, bearerPrefix)
-		// Use constant-time comparison to prevent timing attacks
-		if subtle.ConstantTimeCompare([]byte(token), []byte(t.config.APIKey)) != 1 {
-			http.Error(w, "Invalid API key", http.StatusUnauthorized)
-			return
-		}
+// Principal returns the client that sent the request.
+func (r *legacyRequest) Principal() *Principal {
+	return r.principal
+}
 
-		next.ServeHTTP(w, r)
-	})
+// openLegacySession starts the session of a legacy SSE connection, owned by
+// the client owner, and returns its ID.
+func (t *HTTPTransport) openLegacySession(owner string) string {
+	id := "legacy-" + rand.Text()
+	t.legacyMu.Lock()
+	defer t.legacyMu.Unlock()
+	t.legacySessions[id] = owner
+	return id
+}
+
+// closeLegacySession ends the session of a legacy SSE connection, reporting
+// its end to the OnSessionClose callback.
+func (t *HTTPTransport) closeLegacySession(id string) {
+	t.legacyMu.Lock()
+	delete(t.legacySessions, id)
+	t.legacyMu.Unlock()
+
+	t.sessions.mu.RLock()
+	onClose := t.sessions.onClose
+	t.sessions.mu.RUnlock()
+	if onClose != nil {
+		onClose(id)
+	}
+}
+
+// legacySession returns the session of a /message request. A request naming
+// an SSE connection with the sessionId parameter belongs to its session, if
+// the connection is open and its client sent the request. Other requests
+// belong to a session per client, which is never ended.
+func (t *HTTPTransport) legacySession(r *http.Request) (string, bool) {
+	id := r.URL.Query().Get(legacySessionParam)
+	if id == "" {
+		return "legacy:" + clientID(r), true
+	}
+	t.legacyMu.Lock()
+	owner, ok := t.legacySessions[id]
+	t.legacyMu.Unlock()
+	return id, ok && owner == clientID(r)
 }
 
 // handleMessage handles POST /message for JSON-RPC requests
@@ -361,12 +498,37 @@ func (t *HTTPTransport) handleMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The legacy endpoints send no server-initiated requests: /message can't
+	// tell which SSE client POSTed a response, so one client could answer a
+	// request meant for another.
+	if IsResponse(&msg) {
+		http.Error(w, "Unknown request ID", http.StatusBadRequest)
+		return
+	}
+
 	if t.handler == nil {
 		http.Error(w, "Handler not set", http.StatusInternalServerError)
 		return
 	}
 
-	response, err := t.handler(&msg)
+	session, ok := t.legacySession(r)
+	if !ok {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	if response, retryAfter := admit(t.rateLimiter, t.metrics, clientID(r), &msg); response != nil {
+		writeRateLimited(w, response, retryAfter)
+		return
+	}
+
+	var response *Message
+	var err error
+	if t.scopedHandler != nil {
+		response, err = t.scopedHandler(&legacyRequest{HTTPTransport: t, principal: principalOf(r), session: session}, &msg)
+	} else {
+		response, err = t.handler(&msg)
+	}
 	if err != nil {
 		response = &Message{
 			JSONRPC: "2.0",
@@ -384,25 +546,12 @@ func (t *HTTPTransport) handleMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The response goes only to the client that sent the request; it is not
+	// broadcast to SSE clients, which may belong to other users.
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		log.Printf("Error encoding response: %v", err)
 	}
-
-	// Also broadcast the response as an SSE event for streaming clients
-	if response != nil {
-		eventData, err := json.Marshal(response)
-		if err != nil {
-			log.Printf("Error marshaling SSE event data: %v", err)
-		} else {
-			t.clients.Broadcast(&SSEEvent{
-				ID:    fmt.Sprintf("%d", t.eventID.Add(1)),
-				Event: "message",
-				Data:  string(eventData),
-			})
-			t.metrics.RecordSSEEvent()
-		}
-	}
 }
 
 // handleSSE handles GET /events for SSE streaming
@@ -412,6 +561,18 @@ func (t *HTTPTransport) handleSSE(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Each connection has its own session, which requests join by POSTing
+	// to the endpoint announced first on the stream.
+	session := t.openLegacySession(clientID(r))
+	defer t.closeLegacySession(session)
+	t.serveSSE(w, r, t.clients, "/message?"+legacySessionParam+"="+session)
+}
+
+// serveSSE streams the events sent to clients over w until the client
+// disconnects, the registry removes it, or the transport shuts down. If
+// endpoint is set, it is announced to the client in an endpoint event before
+// any other.
+func (t *HTTPTransport) serveSSE(w http.ResponseWriter, r *http.Request, clients *ClientRegistry, endpoint string) {
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		http.Error(w, "SSE not supported", http.StatusInternalServerError)
@@ -425,20 +586,31 @@ func (t *HTTPTransport) handleSSE(w http.ResponseWriter, r *http.Request) {
 	// Handle Last-Event-ID for reconnection
 	lastEventID := r.Header.Get("Last-Event-ID")
 
-	client := t.clients.Add(lastEventID)
+	client := clients.Add(lastEventID)
 	defer func() {
-		t.clients.Remove(client.ID)
-		t.metrics.SetSSEConnections(t.clients.Count())
+		clients.Remove(client.ID)
+		t.metrics.SetSSEConnections(t.sseConnections())
 	}()
 
 	// Update active connection count
-	t.metrics.SetSSEConnections(t.clients.Count())
+	t.metrics.SetSSEConnections(t.sseConnections())
 
 	log.Printf("SSE client connected: %s", client.ID)
 
+	// Send the headers now, so the client sees the stream is open. The
+	// endpoint event has no ID, so it doesn't affect Last-Event-ID.
+	w.WriteHeader(http.StatusOK)
+	if endpoint != "" {
+		if _, err := fmt.Fprintf(w, "event: endpoint\ndata: %s\n\n", endpoint); err != nil {
+			log.Printf("SSE client %s: write error: %v", client.ID, err)
+			return
+		}
+	}
+	flusher.Flush()
+
 	// Send any missed events if reconnecting
 	if lastEventID != "" {
-		missedEvents := t.clients.eventStore.GetSince(lastEventID)
+		missedEvents := clients.eventStore.GetSince(lastEventID)
 		for _, event := range missedEvents {
 			if err := writeSSEEvent(w, event); err != nil {
 				log.Printf("SSE client %s: write error during reconnect replay: %v", client.ID, err)
@@ -512,7 +684,8 @@ func (t *HTTPTransport) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(map[string]any{
 		"status":      "ok",
-		"clients":     t.clients.Count(),
+		"clients":     t.sseConnections(),
+		"sessions":    t.sessions.Count(),
 		"server_time": time.Now().UTC().Format(time.RFC3339),
 	}); err != nil {
 		log.Printf("Error encoding health response: %v", err)
@@ -527,58 +700,113 @@ func (t *HTTPTransport) handleMetrics(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	writeMetrics(w, t.metrics)
+}
+
+// writeMetrics writes metrics in Prometheus text format.
+func writeMetrics(w http.ResponseWriter, metrics *MetricsRegistry) {
 	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
-	if err := t.metrics.WritePrometheus(w); err != nil {
+	if err := metrics.WritePrometheus(w); err != nil {
 		log.Printf("Error writing metrics: %v", err)
 	}
 }
 
+// allowedOrigin reports whether the request's Origin header, if any, matches
+// the configured CORS origin. Per MCP 2025-11-25 basic/transports, servers
+// MUST validate the Origin header to prevent DNS rebinding attacks.
+func allowedOrigin(config *HTTPTransportConfig, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return origin == "" || config.CORSOrigin == "*" || origin == config.CORSOrigin
+}
+
 // Serve starts the HTTP server and handles messages.
 // If TLSCertFile and TLSKeyFile are configured, the server uses TLS.
 // Otherwise, it serves plain HTTP.
+// Messages written to the transport are broadcast; use ServeScoped to deliver
+// them to the client whose request is being handled.
 func (t *HTTPTransport) Serve(handler func(*Message) (*Message, error)) error {
 	t.handler = handler
 
+	listener, err := listen(t.config, "HTTP")
+	if err != nil {
+		return err
+	}
+	go t.expireSessions()
+	if err := t.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// listen opens the listener for the named transport: the Unix socket if
+// SocketPath is set, otherwise the TCP address, wrapped in TLS if configured.
+func listen(config *HTTPTransportConfig, name string) (net.Listener, error) {
 	var listener net.Listener
 	var err error
 
-	if t.config.SocketPath != "" {
+	if config.SocketPath != "" {
 		// Use Unix domain socket - remove stale socket file if it exists
-		if err := os.Remove(t.config.SocketPath); err != nil && !os.IsNotExist(err) {
-			log.Printf("Warning: failed to remove stale socket %s: %v", t.config.SocketPath, err)
+		if err := os.Remove(config.SocketPath); err != nil && !os.IsNotExist(err) {
+			log.Printf("Warning: failed to remove stale socket %s: %v", config.SocketPath, err)
 		}
-		listener, err = net.Listen("unix", t.config.SocketPath)
+		listener, err = net.Listen("unix", config.SocketPath)
 		if err != nil {
-			return fmt.Errorf("failed to listen on socket %s: %w", t.config.SocketPath, err)
+			return nil, fmt.Errorf("failed to listen on socket %s: %w", config.SocketPath, err)
 		}
-		log.Printf("HTTP/SSE transport listening on unix:%s", t.config.SocketPath)
+		log.Printf("%s transport listening on unix:%s", name, config.SocketPath)
 	} else {
 		// Use TCP
-		listener, err = net.Listen("tcp", t.config.Address)
+		listener, err = net.Listen("tcp", config.Address)
 		if err != nil {
-			return fmt.Errorf("failed to listen on %s: %w", t.config.Address, err)
+			return nil, fmt.Errorf("failed to listen on %s: %w", config.Address, err)
 		}
-		log.Printf("HTTP/SSE transport listening on %s", t.config.Address)
+		log.Printf("%s transport listening on %s", name, config.Address)
 	}
 
 	// If TLS is configured, wrap the listener with TLS
-	if t.config.TLSCertFile != "" && t.config.TLSKeyFile != "" {
-		cert, err := tls.LoadX509KeyPair(t.config.TLSCertFile, t.config.TLSKeyFile)
+	if config.TLSCertFile != "" && config.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.TLSCertFile, config.TLSKeyFile)
 		if err != nil {
-			return fmt.Errorf("failed to load TLS certificate: %w", err)
+			listener.Close()
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
 		}
 		tlsConfig := &tls.Config{
 			Certificates: []tls.Certificate{cert},
 			MinVersion:   tls.VersionTLS12,
 		}
+		if config.TLSClientCAFile != "" {
+			pool, err := loadCertPool(config.TLSClientCAFile)
+			if err != nil {
+				listener.Close()
+				return nil, err
+			}
+			tlsConfig.ClientCAs = pool
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
 		listener = tls.NewListener(listener, tlsConfig)
-		log.Printf("TLS enabled with certificate: %s", t.config.TLSCertFile)
+		log.Printf("TLS enabled with certificate: %s", config.TLSCertFile)
+		if config.TLSClientCAFile != "" {
+			log.Printf("Client certificates required, signed by: %s", config.TLSClientCAFile)
+		}
+	} else if config.TLSClientCAFile != "" {
+		listener.Close()
+		return nil, fmt.Errorf("client certificate verification requires a TLS certificate and key")
 	}
 
-	if err := t.server.Serve(listener); err != nil && err != http.ErrServerClosed {
-		return err
+	return listener, nil
+}
+
+// loadCertPool reads a PEM bundle of CA certificates.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client CA bundle: %w", err)
 	}
-	return nil
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("failed to load client CA bundle: no certificates found in %s", path)
+	}
+	return pool, nil
 }
 
 // IsTLSEnabled returns true if TLS is configured for this transport.
@@ -586,9 +814,14 @@ func (t *HTTPTransport) IsTLSEnabled() bool {
 	return t.config.TLSCertFile != "" && t.config.TLSKeyFile != ""
 }
 
-// IsAuthEnabled returns true if API key authentication is configured.
+// IsMutualTLSEnabled returns true if client certificates are required.
+func (t *HTTPTransport) IsMutualTLSEnabled() bool {
+	return t.IsTLSEnabled() && t.config.TLSClientCAFile != ""
+}
+
+// IsAuthEnabled returns true if API key or OAuth authentication is configured.
 func (t *HTTPTransport) IsAuthEnabled() bool {
-	return t.config.APIKey != ""
+	return t.apiKeys != nil || t.config.OAuth != nil
 }
 
 // Metrics returns the metrics registry for this transport.
@@ -597,6 +830,18 @@ func (t *HTTPTransport) Metrics() *MetricsRegistry {
 	return t.metrics
 }
 
+// SetAPIKeys replaces the named API keys the transport accepts, e.g. when
+// they are reloaded from a file; the configured APIKey remains valid. It
+// returns an error if the transport was created without keys, as
+// authentication is then disabled.
+func (t *HTTPTransport) SetAPIKeys(keys []APIKey) error {
+	if t.apiKeys == nil {
+		return fmt.Errorf("authentication is not enabled")
+	}
+	t.apiKeys.set(t.config, keys)
+	return nil
+}
+
 // IsRateLimitEnabled returns true if rate limiting is configured.
 func (t *HTTPTransport) IsRateLimitEnabled() bool {
 	return t.rateLimiter != nil
@@ -613,8 +858,10 @@ func (t *HTTPTransport) ReadMessage() (*Message, error) {
 	return nil, fmt.Errorf("ReadMessage is not supported by HTTPTransport: use Serve(handler) callback pattern instead")
 }
 
-// WriteMessage broadcasts a message to all connected SSE clients.
-// The message is serialized to JSON and sent as an SSE event.
+// WriteMessage broadcasts a message to all connected legacy SSE clients.
+// The message is serialized to JSON and sent as an SSE event. Messages for a
+// Streamable HTTP session are written to the transport passed to the
+// ScopedHandler instead.
 func (t *HTTPTransport) WriteMessage(msg *Message) error {
 	if t.closed.Load() {
 		return fmt.Errorf("transport is closed")
@@ -626,7 +873,7 @@ func (t *HTTPTransport) WriteMessage(msg *Message) error {
 	}
 
 	t.clients.Broadcast(&SSEEvent{
-		ID:    fmt.Sprintf("%d", t.eventID.Add(1)),
+		ID:    t.nextEventID(),
 		Event: "message",
 		Data:  string(data),
 	})
@@ -643,6 +890,9 @@ func (t *HTTPTransport) Close() error {
 	}
 
 	close(t.shutdownCh)
+	for _, session := range t.sessions.all() {
+		t.sessions.remove(session.id)
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
 	defer cancel()
@@ -666,7 +916,8 @@ func (t *HTTPTransport) IsClosed() bool {
 	return t.closed.Load()
 }
 
-// BroadcastEvent sends a custom SSE event to all connected clients.
+// BroadcastEvent sends a custom SSE event to all connected clients: every
+// legacy SSE client, and one stream of each Streamable HTTP session.
 // The event type is used for client-side filtering (e.g., "observation", "heartbeat").
 // This is used by observation streaming to broadcast events to all SSE clients.
 func (t *HTTPTransport) BroadcastEvent(eventType string, data string) {
@@ -674,14 +925,39 @@ func (t *HTTPTransport) BroadcastEvent(eventType string, data string) {
 		return
 	}
 
+	event := &SSEEvent{
+		ID:    t.nextEventID(),
+		Event: eventType,
+		Data:  data,
+	}
+	t.clients.Broadcast(event)
+	for _, session := range t.sessions.all() {
+		session.streams.Send(event)
+	}
+	t.metrics.RecordSSEEvent()
+}
+
+// SendEvent sends a custom SSE event to the legacy SSE clients only. Use
+// BroadcastEvent to reach Streamable HTTP sessions too.
+func (t *HTTPTransport) SendEvent(eventType string, data string) {
+	if t.closed.Load() {
+		return
+	}
+
 	t.clients.Broadcast(&SSEEvent{
-		ID:    fmt.Sprintf("%d", t.eventID.Add(1)),
+		ID:    t.nextEventID(),
 		Event: eventType,
 		Data:  data,
 	})
 	t.metrics.RecordSSEEvent()
 }
 
+// nextEventID allocates the ID of an SSE event. IDs are unique across all
+// streams, so Last-Event-ID identifies the stream being resumed.
+func (t *HTTPTransport) nextEventID() string {
+	return fmt.Sprintf("%d", t.eventID.Add(1))
+}
+
 // ShutdownChan returns a channel that is closed when the transport is shutting down.
 // This allows handlers to detect shutdown and clean up gracefully.
 func (t *HTTPTransport) ShutdownChan() <-chan struct{} {
@@ -5,7 +5,9 @@
 package transport
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -105,6 +107,25 @@ func TestHTTPTransport_HandleMessage(t *testing.T) {
 	}
 }
 
+func TestHTTPTransport_HandleMessage_ResponseNotBroadcast(t *testing.T) {
+	tr := NewHTTPTransport(nil)
+	tr.handler = func(msg *Message) (*Message, error) {
+		return &Message{JSONRPC: "2.0", ID: msg.ID, Result: json.RawMessage(`{"ok":true}`)}, nil
+	}
+	other := tr.clients.Add("")
+
+	req := httptest.NewRequest("POST", "/message", bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	req.Header.Set("Content-Type", "application/json")
+	tr.handleMessage(httptest.NewRecorder(), req)
+
+	// The response belongs to the POSTing client alone.
+	select {
+	case event := <-other.ResponseChan:
+		t.Errorf("SSE client received another client's response: %+v", event)
+	default:
+	}
+}
+
 func TestHTTPTransport_HandleMessage_MethodNotAllowed(t *testing.T) {
 	tr := NewHTTPTransport(nil)
 
@@ -190,7 +211,7 @@ func TestHTTPTransport_CORS(t *testing.T) {
 		CORSOrigin: "https://allowed.com",
 	})
 
-	handler := tr.corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	handler := corsMiddleware(tr.config, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	}))
 
@@ -404,7 +425,7 @@ func TestHTTPTransport_AuthMiddleware_ValidToken(t *testing.T) {
 		APIKey: "test-secret-key",
 	})
 
-	handler := tr.authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	handler := authMiddleware(tr.apiKeys, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("authenticated"))
 	}))
@@ -425,7 +446,7 @@ func TestHTTPTransport_AuthMiddleware_InvalidToken(t *testing.T) {
 		APIKey: "test-secret-key",
 	})
 
-	handler := tr.authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	handler := authMiddleware(tr.apiKeys, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	}))
 
@@ -448,7 +469,7 @@ func TestHTTPTransport_AuthMiddleware_MissingHeader(t *testing.T) {
 		APIKey: "test-secret-key",
 	})
 
-	handler := tr.authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	handler := authMiddleware(tr.apiKeys, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	}))
 
@@ -470,7 +491,7 @@ func TestHTTPTransport_AuthMiddleware_InvalidFormat(t *testing.T) {
 		APIKey: "test-secret-key",
 	})
 
-	handler := tr.authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	handler := authMiddleware(tr.apiKeys, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	}))
 
@@ -493,7 +514,7 @@ func TestHTTPTransport_AuthMiddleware_HealthExempt(t *testing.T) {
 		APIKey: "test-secret-key",
 	})
 
-	handler := tr.authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	handler := authMiddleware(tr.apiKeys, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("health ok"))
 	}))
@@ -586,7 +607,7 @@ func TestHTTPTransport_CORS_AuthorizationHeader(t *testing.T) {
 		CORSOrigin: "https://allowed.com",
 	})
 
-	handler := tr.corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	handler := corsMiddleware(tr.config, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	}))
 
@@ -746,7 +767,7 @@ func TestCORS_Preflight_ValidOrigin(t *testing.T) {
 				CORSOrigin: tt.configOrigin,
 			})
 
-			handler := tr.corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler := corsMiddleware(tr.config, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				t.Error("Next handler should not be called for OPTIONS")
 			}))
 
@@ -776,7 +797,7 @@ func TestCORS_Preflight_InvalidOrigin(t *testing.T) {
 		CORSOrigin: "https://allowed.example.com",
 	})
 
-	handler := tr.corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	handler := corsMiddleware(tr.config, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		t.Error("Next handler should not be called for OPTIONS")
 	}))
 
@@ -843,7 +864,7 @@ func TestCORS_ActualRequest_ValidOrigin(t *testing.T) {
 			})
 
 			handlerCalled := false
-			handler := tr.corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler := corsMiddleware(tr.config, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				handlerCalled = true
 				w.WriteHeader(http.StatusOK)
 			}))
@@ -876,7 +897,7 @@ func TestCORS_ActualRequest_InvalidOrigin(t *testing.T) {
 	})
 
 	handlerCalled := false
-	handler := tr.corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	handler := corsMiddleware(tr.config, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		handlerCalled = true
 		w.WriteHeader(http.StatusOK)
 	}))
@@ -913,7 +934,7 @@ func TestCORS_AllowMethods(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tr := NewHTTPTransport(nil)
 
-			handler := tr.corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler := corsMiddleware(tr.config, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				w.WriteHeader(http.StatusOK)
 			}))
 
@@ -938,7 +959,7 @@ func TestCORS_AllowMethods(t *testing.T) {
 func TestCORS_AllowHeaders(t *testing.T) {
 	tr := NewHTTPTransport(nil)
 
-	handler := tr.corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	handler := corsMiddleware(tr.config, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	}))
 
@@ -961,7 +982,7 @@ func TestCORS_AllowHeaders(t *testing.T) {
 func TestCORS_ExposeHeaders(t *testing.T) {
 	tr := NewHTTPTransport(nil)
 
-	handler := tr.corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	handler := corsMiddleware(tr.config, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	}))
 
@@ -997,7 +1018,7 @@ func TestCORS_WildcardOriginHandling(t *testing.T) {
 				CORSOrigin: "*",
 			})
 
-			handler := tr.corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler := corsMiddleware(tr.config, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				w.WriteHeader(http.StatusOK)
 			}))
 
@@ -1019,7 +1040,7 @@ func TestCORS_WildcardOriginHandling(t *testing.T) {
 func TestCORS_DefaultConfig(t *testing.T) {
 	tr := NewHTTPTransport(nil) // Uses default config with CORSOrigin: "*"
 
-	handler := tr.corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	handler := corsMiddleware(tr.config, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	}))
 
@@ -1045,7 +1066,7 @@ func TestCORS_PreflightDoesNotCallNextHandler(t *testing.T) {
 	tr := NewHTTPTransport(nil)
 
 	handlerCalled := false
-	handler := tr.corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	handler := corsMiddleware(tr.config, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		handlerCalled = true
 	}))
 
@@ -1070,7 +1091,7 @@ func TestCORS_HeadersPresentOnAllEndpoints(t *testing.T) {
 				CORSOrigin: "https://test.example.com",
 			})
 
-			handler := tr.corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler := corsMiddleware(tr.config, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				w.WriteHeader(http.StatusOK)
 			}))
 
@@ -1433,3 +1454,113 @@ func (r *pipeResponseRecorder) Flush() {
 	default:
 	}
 }
+
+// TestHTTPTransport_NoServerRequests verifies the legacy endpoints send no
+// server-initiated requests, since /message can't tell which SSE client a
+// response came from, and reject responses POSTed to /message.
+func TestHTTPTransport_NoServerRequests(t *testing.T) {
+	tr := NewHTTPTransport(nil)
+	tr.handler = func(msg *Message) (*Message, error) {
+		t.Errorf("handler should not receive responses, got %+v", msg)
+		return nil, nil
+	}
+
+	var scoped Transport = &legacyRequest{HTTPTransport: tr}
+	if _, ok := scoped.(Requester); ok {
+		t.Error("legacy requests should not implement Requester")
+	}
+
+	w := httptest.NewRecorder()
+	tr.handleMessage(w, httptest.NewRequest("POST", "/message", strings.NewReader(`{"jsonrpc":"2.0","id":"srv-1","result":{}}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("response status = %d, want 400", w.Code)
+	}
+}
+
+// TestHTTPTransport_LegacySessions verifies each legacy SSE connection has its
+// own session, which only its client may use and which ends when it closes.
+func TestHTTPTransport_LegacySessions(t *testing.T) {
+	tr := NewHTTPTransport(&HTTPTransportConfig{LegacySSE: true})
+	sessions := make(chan string, 1)
+	tr.handler = func(msg *Message) (*Message, error) { return echoResult(tr, msg) }
+	tr.scopedHandler = func(scoped Transport, msg *Message) (*Message, error) {
+		sessions <- scoped.(SessionTransport).SessionID()
+		return echoResult(scoped, msg)
+	}
+	closed := make(chan string, 2)
+	tr.OnSessionClose(func(id string) { closed <- id })
+	ts := httptest.NewServer(tr.server.Handler)
+	defer ts.Close()
+
+	// connect opens an SSE connection and returns the endpoint it announces.
+	connect := func() (string, func()) {
+		t.Helper()
+		ctx, cancel := context.WithCancel(context.Background())
+		req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/events", nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := bufio.NewScanner(resp.Body)
+		if !lines.Scan() || lines.Text() != "event: endpoint" || !lines.Scan() {
+			t.Fatalf("expected an endpoint event first, got %q", lines.Text())
+		}
+		endpoint, ok := strings.CutPrefix(lines.Text(), "data: ")
+		if !ok {
+			t.Fatalf("unexpected endpoint data %q", lines.Text())
+		}
+		return endpoint, func() {
+			cancel()
+			resp.Body.Close()
+		}
+	}
+	post := func(endpoint string) (int, string) {
+		t.Helper()
+		resp, err := http.Post(ts.URL+endpoint, "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		select {
+		case session := <-sessions:
+			return resp.StatusCode, session
+		default:
+			return resp.StatusCode, ""
+		}
+	}
+
+	endpointA, closeA := connect()
+	defer closeA()
+	endpointB, closeB := connect()
+	defer closeB()
+
+	_, sessionA := post(endpointA)
+	_, sessionB := post(endpointB)
+	_, sessionless := post("/message")
+	if sessionA == "" || sessionB == "" || sessionA == sessionB || sessionless == "" || sessionless == sessionA || sessionless == sessionB {
+		t.Fatalf("sessions not isolated: a = %q, b = %q, without sessionId = %q", sessionA, sessionB, sessionless)
+	}
+
+	// Another client can't use a's session.
+	req := httptest.NewRequest("POST", endpointA, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+	req.RemoteAddr = "192.0.2.1:1234"
+	w := httptest.NewRecorder()
+	tr.handleMessage(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("other client's request status = %d, want 404", w.Code)
+	}
+
+	// Closing a's connection ends its session.
+	closeA()
+	select {
+	case id := <-closed:
+		if id != sessionA {
+			t.Errorf("closed session %q, want %q", id, sessionA)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("session not closed with its connection")
+	}
+	if code, _ := post(endpointA); code != http.StatusNotFound {
+		t.Errorf("closed session request status = %d, want 404", code)
+	}
+}
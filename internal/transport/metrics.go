@@ -61,8 +61,10 @@ func NewMetricsRegistry() *MetricsRegistry {
 	// Pre-register standard metrics
 	m.registerCounter("mcp_requests_total")
 	m.registerCounter("mcp_sse_events_sent_total")
+	m.registerCounter("mcp_rate_limited_total")
 	m.registerHistogram("mcp_request_duration_seconds", defaultLatencyBuckets)
 	m.registerGauge("mcp_sse_connections_active")
+	m.registerGauge("mcp_websocket_connections_active")
 
 	return m
 }
@@ -340,11 +342,23 @@ func (m *MetricsRegistry) RecordSSEEvent() {
 	m.IncrementCounter("mcp_sse_events_sent_total", "")
 }
 
+// RecordRateLimited records a request rejected by the rate limiter. request
+// is the tool name for tool calls, the JSON-RPC method for other messages,
+// or "http" for HTTP requests that carry no message.
+func (m *MetricsRegistry) RecordRateLimited(request string) {
+	m.IncrementCounter("mcp_rate_limited_total", fmt.Sprintf(`request="%s"`, request))
+}
+
 // SetSSEConnections sets the current number of active SSE connections.
 func (m *MetricsRegistry) SetSSEConnections(count int) {
 	m.SetGauge("mcp_sse_connections_active", "", float64(count))
 }
 
+// SetWebSocketConnections sets the current number of open WebSocket connections.
+func (m *MetricsRegistry) SetWebSocketConnections(count int) {
+	m.SetGauge("mcp_websocket_connections_active", "", float64(count))
+}
+
 // Global metrics registry instance
 var defaultMetrics = NewMetricsRegistry()
 
@@ -0,0 +1,560 @@
+// Copyright 2025 Joseph Cumines
+//
+// OAuth 2.1 access token validation — JWTs signed by an authorization server,
+// verified against a JWKS file or the server's published key set
+
+package transport
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProtectedResourceMetadataPath is where the OAuth 2.0 Protected Resource
+// Metadata (RFC 9728) is served when OAuth is configured.
+const ProtectedResourceMetadataPath = "/.well-known/oauth-protected-resource"
+
+const (
+	// jwksRefreshInterval is how long a key set is used before it is reloaded.
+	jwksRefreshInterval = time.Hour
+	// jwksMinRefreshInterval limits reloads prompted by tokens signed with an
+	// unknown key, so forged tokens can't make the server hammer the issuer.
+	jwksMinRefreshInterval = time.Minute
+	// jwtClockSkew is the leeway allowed when checking token lifetimes.
+	jwtClockSkew = time.Minute
+	// maxOAuthDocumentSize bounds the metadata and key set documents fetched.
+	maxOAuthDocumentSize = 1 << 20
+)
+
+// OAuthConfig configures validation of OAuth 2.1 access tokens, as issued to
+// MCP clients by an authorization server. Tokens must be JWTs (RFC 9068).
+type OAuthConfig struct {
+	// Issuer is the authorization server's issuer identifier. If set, tokens
+	// must have it as their iss claim. Unless JWKSFile is set, the server's
+	// signing keys are found through its metadata (RFC 8414, or OpenID
+	// Connect discovery).
+	Issuer string
+	// Audience is the canonical URI of this MCP server. Tokens must include
+	// it in their aud claim, so tokens issued for other services are refused.
+	Audience string
+	// JWKSFile is the path to a JSON Web Key Set of the issuer's signing
+	// keys. It takes precedence over Issuer metadata.
+	JWKSFile string
+	// ScopesSupported lists the scopes advertised in the resource metadata.
+	ScopesSupported []string
+	// HTTPClient fetches the issuer's metadata and keys (default: a client
+	// with a 10 second timeout).
+	HTTPClient *http.Client
+}
+
+// OAuthValidator validates OAuth access tokens for the HTTP and WebSocket
+// transports. It is safe for concurrent use.
+type OAuthValidator struct {
+	config  OAuthConfig
+	client  *http.Client
+	keys    map[string]jwk // by key ID
+	fetched time.Time      // when keys were loaded
+	tried   time.Time      // when keys were last loaded, or failed to load
+	mu      sync.Mutex
+}
+
+// jwk is a public signing key from a key set.
+type jwk struct {
+	key crypto.PublicKey
+	alg string // the key's required algorithm, if any
+}
+
+// NewOAuthValidator returns a validator for config. The JWKSFile, if any, is
+// loaded immediately, so a missing or invalid file is reported at startup;
+// keys published by the Issuer are fetched when the first token arrives.
+func NewOAuthValidator(config OAuthConfig) (*OAuthValidator, error) {
+	if config.Audience == "" {
+		return nil, fmt.Errorf("oauth: audience is required")
+	}
+	if config.Issuer == "" && config.JWKSFile == "" {
+		return nil, fmt.Errorf("oauth: an issuer or a JWKS file is required")
+	}
+	v := &OAuthValidator{config: config, client: config.HTTPClient}
+	if v.client == nil {
+		v.client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if config.JWKSFile != "" {
+		if err := v.refresh(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// jwtHeader is the JOSE header of a JWT.
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// jwtClaims are the claims of an access token checked by Validate.
+type jwtClaims struct {
+	Issuer    string          `json:"iss"`
+	Subject   string          `json:"sub"`
+	ClientID  string          `json:"client_id"`
+	Audience  json.RawMessage `json:"aud"`
+	Scope     string          `json:"scope"`
+	Expiry    *float64        `json:"exp"`
+	NotBefore *float64        `json:"nbf"`
+}
+
+// Validate verifies token's signature and claims, and returns the client it
+// was issued to. The client's scopes are those of the token's scope claim.
+func (v *OAuthValidator) Validate(ctx context.Context, token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature")
+	}
+	key, err := v.key(ctx, header)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyJWS(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+	if err := v.checkClaims(&claims, time.Now()); err != nil {
+		return nil, err
+	}
+
+	name := claims.Subject
+	if name == "" {
+		name = claims.ClientID
+	}
+	sum := sha256.Sum256([]byte(claims.Issuer + "\x00" + name))
+	return &Principal{
+		Name:   name,
+		Method: AuthOAuth,
+		// A token without scopes grants none, unlike a key without scopes.
+		Scopes: append([]string{}, strings.Fields(claims.Scope)...),
+		id:     "oauth:" + hex.EncodeToString(sum[:8]),
+	}, nil
+}
+
+// checkClaims checks the token is for this server, from the issuer, and
+// currently valid.
+func (v *OAuthValidator) checkClaims(claims *jwtClaims, now time.Time) error {
+	if v.config.Issuer != "" && claims.Issuer != v.config.Issuer {
+		return fmt.Errorf("token issuer %q is not trusted", claims.Issuer)
+	}
+	var audiences []string
+	if json.Unmarshal(claims.Audience, &audiences) != nil {
+		var audience string
+		if json.Unmarshal(claims.Audience, &audience) == nil {
+			audiences = []string{audience}
+		}
+	}
+	found := false
+	for _, audience := range audiences {
+		found = found || audience == v.config.Audience
+	}
+	if !found {
+		return fmt.Errorf("token audience does not include %s", v.config.Audience)
+	}
+	if claims.Expiry == nil {
+		return fmt.Errorf("token has no expiry")
+	}
+	if now.Add(-jwtClockSkew).After(time.Unix(int64(*claims.Expiry), 0)) {
+		return fmt.Errorf("token expired")
+	}
+	if claims.NotBefore != nil && now.Add(jwtClockSkew).Before(time.Unix(int64(*claims.NotBefore), 0)) {
+		return fmt.Errorf("token not yet valid")
+	}
+	return nil
+}
+
+// key returns the key that signed a token with header, reloading the key set
+// if it is stale or does not have the key.
+func (v *OAuthValidator) key(ctx context.Context, header jwtHeader) (jwk, error) {
+	if _, ok := jwsAlgorithms[header.Alg]; !ok {
+		return jwk{}, fmt.Errorf("unsupported token algorithm %q", header.Alg)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := time.Now()
+	key, ok := v.lookup(header)
+	if (!ok || now.Sub(v.fetched) >= jwksRefreshInterval) && now.Sub(v.tried) >= jwksMinRefreshInterval {
+		if err := v.refreshLocked(ctx); err != nil {
+			if !ok {
+				return jwk{}, err
+			}
+			// Keep using the stale keys while the issuer is unavailable.
+			log.Printf("OAuth: %v", err)
+		}
+		key, ok = v.lookup(header)
+	}
+	if !ok {
+		return jwk{}, fmt.Errorf("token signing key %q not found", header.Kid)
+	}
+	return key, nil
+}
+
+// lookup returns the key with the header's ID, or if it has none, the only
+// key usable with its algorithm. The caller must hold mu.
+func (v *OAuthValidator) lookup(header jwtHeader) (jwk, bool) {
+	if header.Kid != "" {
+		key, ok := v.keys[header.Kid]
+		return key, ok
+	}
+	var found []jwk
+	for _, key := range v.keys {
+		if jwsKeyUsable(header.Alg, key) {
+			found = append(found, key)
+		}
+	}
+	if len(found) != 1 {
+		return jwk{}, false
+	}
+	return found[0], true
+}
+
+// refresh reloads the key set.
+func (v *OAuthValidator) refresh(ctx context.Context) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.refreshLocked(ctx)
+}
+
+// refreshLocked reloads the key set from the JWKS file, or the issuer's
+// jwks_uri. The caller must hold mu.
+func (v *OAuthValidator) refreshLocked(ctx context.Context) error {
+	v.tried = time.Now()
+	var data []byte
+	var err error
+	if v.config.JWKSFile != "" {
+		data, err = os.ReadFile(v.config.JWKSFile)
+	} else {
+		data, err = v.fetchIssuerKeys(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load token signing keys: %w", err)
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("invalid token signing keys: %w", err)
+	}
+	v.keys, v.fetched = keys, v.tried
+	return nil
+}
+
+// fetchIssuerKeys fetches the issuer's metadata, then the key set it names.
+func (v *OAuthValidator) fetchIssuerKeys(ctx context.Context) ([]byte, error) {
+	issuer, err := url.Parse(v.config.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid issuer: %w", err)
+	}
+	// RFC 8414 inserts the well-known path before the issuer's path; OpenID
+	// Connect Discovery appends it.
+	oauthMetadata := *issuer
+	oauthMetadata.Path = "/.well-known/oauth-authorization-server" + strings.TrimSuffix(issuer.Path, "/")
+	openIDMetadata := strings.TrimSuffix(v.config.Issuer, "/") + "/.well-known/openid-configuration"
+
+	var metadata struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	data, err := v.fetch(ctx, oauthMetadata.String())
+	if err != nil {
+		data, err = v.fetch(ctx, openIDMetadata)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("invalid issuer metadata: %w", err)
+	}
+	if metadata.Issuer != v.config.Issuer {
+		return nil, fmt.Errorf("issuer metadata is for %q", metadata.Issuer)
+	}
+	if metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("issuer metadata has no jwks_uri")
+	}
+	return v.fetch(ctx, metadata.JWKSURI)
+}
+
+// fetch GETs a JSON document.
+func (v *OAuthValidator) fetch(ctx context.Context, target string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", target, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxOAuthDocumentSize))
+}
+
+// decodeJWTPart decodes a base64url-encoded JSON part of a JWT into v.
+func decodeJWTPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// parseJWKS parses the signing keys of a JSON Web Key Set (RFC 7517). Keys
+// for encryption, and of unsupported types, are ignored.
+func parseJWKS(data []byte) (map[string]jwk, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			Alg string `json:"alg"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]jwk, len(set.Keys))
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var key crypto.PublicKey
+		var err error
+		switch k.Kty {
+		case "RSA":
+			key, err = parseRSAKey(k.N, k.E)
+		case "EC":
+			key, err = parseECKey(k.Crv, k.X, k.Y)
+		case "OKP":
+			key, err = parseEd25519Key(k.Crv, k.X)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("keys[%d]: %w", i, err)
+		}
+		keys[k.Kid] = jwk{key: key, alg: k.Alg}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no signing keys")
+	}
+	return keys, nil
+}
+
+func parseRSAKey(n, e string) (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil || len(nBytes) == 0 {
+		return nil, fmt.Errorf("invalid RSA modulus")
+	}
+	eBytes, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil || len(eBytes) == 0 || len(eBytes) > 4 {
+		return nil, fmt.Errorf("invalid RSA exponent")
+	}
+	key := &rsa.PublicKey{N: new(big.Int).SetBytes(nBytes), E: int(new(big.Int).SetBytes(eBytes).Int64())}
+	if key.N.BitLen() < 2048 {
+		return nil, fmt.Errorf("RSA key is shorter than 2048 bits")
+	}
+	return key, nil
+}
+
+func parseECKey(crv, x, y string) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", crv)
+	}
+	xBytes, errX := base64.RawURLEncoding.DecodeString(x)
+	yBytes, errY := base64.RawURLEncoding.DecodeString(y)
+	size := (curve.Params().BitSize + 7) / 8
+	if errX != nil || errY != nil || len(xBytes) != size || len(yBytes) != size {
+		return nil, fmt.Errorf("invalid EC point")
+	}
+	// Parse the uncompressed point, which checks it is on the curve.
+	point := append(append([]byte{4}, xBytes...), yBytes...)
+	key, err := ecdsa.ParseUncompressedPublicKey(curve, point)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EC point: %w", err)
+	}
+	return key, nil
+}
+
+func parseEd25519Key(crv, x string) (ed25519.PublicKey, error) {
+	if crv != "Ed25519" {
+		return nil, fmt.Errorf("unsupported curve %q", crv)
+	}
+	key, err := base64.RawURLEncoding.DecodeString(x)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid Ed25519 key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// jwsAlgorithms are the supported JWS algorithms (RFC 7518), by the hash
+// they sign. EdDSA signs the message itself.
+var jwsAlgorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"PS256": crypto.SHA256,
+	"PS384": crypto.SHA384,
+	"PS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"EdDSA": 0,
+}
+
+// jwsKeyUsable reports whether key can verify signatures made with alg.
+func jwsKeyUsable(alg string, key jwk) bool {
+	if key.alg != "" && key.alg != alg {
+		return false
+	}
+	switch k := key.key.(type) {
+	case *rsa.PublicKey:
+		return alg[:2] == "RS" || alg[:2] == "PS"
+	case *ecdsa.PublicKey:
+		return (alg == "ES256" && k.Curve == elliptic.P256()) || (alg == "ES384" && k.Curve == elliptic.P384())
+	case ed25519.PublicKey:
+		return alg == "EdDSA"
+	}
+	return false
+}
+
+// errInvalidSignature is returned for tokens whose signature does not verify.
+var errInvalidSignature = errors.New("invalid token signature")
+
+// verifyJWS verifies signature over signed with key, using alg.
+func verifyJWS(alg string, key jwk, signed, signature []byte) error {
+	if !jwsKeyUsable(alg, key) {
+		return fmt.Errorf("token algorithm %s does not match its signing key", alg)
+	}
+	if alg == "EdDSA" {
+		if !ed25519.Verify(key.key.(ed25519.PublicKey), signed, signature) {
+			return errInvalidSignature
+		}
+		return nil
+	}
+
+	hashFunc := jwsAlgorithms[alg]
+	var h hash.Hash
+	switch hashFunc {
+	case crypto.SHA256:
+		h = sha256.New()
+	case crypto.SHA384:
+		h = sha512.New384()
+	default:
+		h = sha512.New()
+	}
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	var err error
+	switch k := key.key.(type) {
+	case *rsa.PublicKey:
+		if alg[:2] == "PS" {
+			err = rsa.VerifyPSS(k, hashFunc, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			err = rsa.VerifyPKCS1v15(k, hashFunc, digest, signature)
+		}
+	case *ecdsa.PublicKey:
+		// JWS encodes ECDSA signatures as the fixed-size R and S.
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errInvalidSignature
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			err = errInvalidSignature
+		}
+	}
+	if err != nil {
+		return errInvalidSignature
+	}
+	return nil
+}
+
+// handleProtectedResourceMetadata serves the OAuth 2.0 Protected Resource
+// Metadata (RFC 9728), which tells MCP clients where to get access tokens.
+func (v *OAuthValidator) handleProtectedResourceMetadata(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	metadata := map[string]any{
+		"resource":                 v.config.Audience,
+		"bearer_methods_supported": []string{"header"},
+	}
+	if v.config.Issuer != "" {
+		metadata["authorization_servers"] = []string{v.config.Issuer}
+	}
+	if len(v.config.ScopesSupported) > 0 {
+		metadata["scopes_supported"] = v.config.ScopesSupported
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(metadata); err != nil {
+		log.Printf("Error encoding resource metadata: %v", err)
+	}
+}
+
+// resourceMetadataURL returns the URL of the resource metadata, on the
+// origin of the configured audience if it is a URL, else of r.
+func (v *OAuthValidator) resourceMetadataURL(r *http.Request) string {
+	if u, err := url.Parse(v.config.Audience); err == nil && u.Scheme != "" && u.Host != "" {
+		return u.Scheme + "://" + u.Host + ProtectedResourceMetadataPath
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + ProtectedResourceMetadataPath
+}
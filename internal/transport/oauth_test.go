@@ -0,0 +1,403 @@
+// Copyright 2025 Joseph Cumines
+//
+// OAuth access token validation tests, against a locally generated key set
+
+package transport
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testIssuer   = "https://auth.example.com"
+	testAudience = "https://mcp.example.com/mcp"
+)
+
+// testKeys is a key set of one key of each supported type.
+type testKeys struct {
+	rsa     *rsa.PrivateKey
+	ec      *ecdsa.PrivateKey
+	ed25519 ed25519.PrivateKey
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testKeys{rsa: rsaKey, ec: ecKey, ed25519: edKey}
+}
+
+// jwks returns the public key set, with key IDs "rsa", "ec" and "ed".
+func (k *testKeys) jwks() []byte {
+	b64 := base64.RawURLEncoding.EncodeToString
+	ecPoint, _ := k.ec.PublicKey.Bytes()
+	data, _ := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": b64(k.rsa.N.Bytes()), "e": b64(big.NewInt(int64(k.rsa.E)).Bytes())},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecPoint[1:33]), "y": b64(ecPoint[33:])},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": b64(k.ed25519.Public().(ed25519.PublicKey))},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": b64(k.rsa.N.Bytes()), "e": "AQAB"},
+	}})
+	return data
+}
+
+// writeJWKS writes the public key set to a file, and returns its path.
+func (k *testKeys) writeJWKS(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, k.jwks(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// sign returns a JWT of claims, signed with alg by the key kid.
+func (k *testKeys) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "at+jwt"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	var err error
+	switch alg {
+	case "RS256":
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, digest[:])
+	case "PS256":
+		signature, err = rsa.SignPSS(rand.Reader, k.rsa, crypto.SHA256, digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	case "ES256":
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k.ec, digest[:])
+		if err == nil {
+			signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+	case "EdDSA":
+		signature = ed25519.Sign(k.ed25519, []byte(signed))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// validClaims returns the claims of a token valid for testAudience.
+func validClaims() map[string]any {
+	return map[string]any{
+		"iss":   testIssuer,
+		"sub":   "alice",
+		"aud":   testAudience,
+		"scope": "read input",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+	}
+}
+
+// withClaims returns a copy of claims, with the given claims replaced, or removed if
+// nil.
+func withClaims(claims map[string]any, replace map[string]any) map[string]any {
+	out := make(map[string]any, len(claims))
+	for k, v := range claims {
+		out[k] = v
+	}
+	for k, v := range replace {
+		if v == nil {
+			delete(out, k)
+		} else {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func newTestValidator(t *testing.T, keys *testKeys) *OAuthValidator {
+	t.Helper()
+	v, err := NewOAuthValidator(OAuthConfig{
+		Issuer:          testIssuer,
+		Audience:        testAudience,
+		JWKSFile:        keys.writeJWKS(t),
+		ScopesSupported: []string{"read", "input", "run"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestOAuthValidator_Validate(t *testing.T) {
+	keys := newTestKeys(t)
+	v := newTestValidator(t, keys)
+	claims := validClaims()
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{"RS256", keys.sign(t, "RS256", "rsa", claims), ""},
+		{"PS256", keys.sign(t, "PS256", "rsa", claims), ""},
+		{"ES256", keys.sign(t, "ES256", "ec", claims), ""},
+		{"EdDSA", keys.sign(t, "EdDSA", "ed", claims), ""},
+		{"EdDSA without key ID", keys.sign(t, "EdDSA", "", claims), ""},
+		{"audience list", keys.sign(t, "ES256", "ec", withClaims(claims, map[string]any{"aud": []string{"other", testAudience}})), ""},
+		{"within clock skew", keys.sign(t, "ES256", "ec", withClaims(claims, map[string]any{"exp": time.Now().Add(-30 * time.Second).Unix()})), ""},
+		{"expired", keys.sign(t, "ES256", "ec", withClaims(claims, map[string]any{"exp": time.Now().Add(-time.Hour).Unix()})), "token expired"},
+		{"no expiry", keys.sign(t, "ES256", "ec", withClaims(claims, map[string]any{"exp": nil})), "token has no expiry"},
+		{"not yet valid", keys.sign(t, "ES256", "ec", withClaims(claims, map[string]any{"nbf": time.Now().Add(time.Hour).Unix()})), "token not yet valid"},
+		{"other audience", keys.sign(t, "ES256", "ec", withClaims(claims, map[string]any{"aud": "https://other.example.com"})), "audience"},
+		{"no audience", keys.sign(t, "ES256", "ec", withClaims(claims, map[string]any{"aud": nil})), "audience"},
+		{"other issuer", keys.sign(t, "ES256", "ec", withClaims(claims, map[string]any{"iss": "https://evil.example.com"})), "not trusted"},
+		{"unknown key", keys.sign(t, "ES256", "other", claims), "not found"},
+		{"encryption key", keys.sign(t, "RS256", "enc", claims), "not found"},
+		{"algorithm of another key type", keys.sign(t, "RS256", "ec", claims), "does not match"},
+		{"unsigned", strings.Join(strings.Split(keys.sign(t, "none", "", claims), ".")[:2], ".") + ".", "unsupported token algorithm"},
+		{"HMAC", keys.sign(t, "HS256", "rsa", claims), "unsupported token algorithm"},
+		{"not a JWT", "secret-api-key", "malformed token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := v.Validate(context.Background(), tt.token)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				if principal.Name != "alice" || principal.Method != AuthOAuth {
+					t.Errorf("principal = %+v, want alice by OAuth", principal)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("tampered claims", func(t *testing.T) {
+		parts := strings.Split(keys.sign(t, "RS256", "rsa", claims), ".")
+		forged, _ := json.Marshal(withClaims(claims, map[string]any{"scope": "read input run"}))
+		parts[1] = base64.RawURLEncoding.EncodeToString(forged)
+		if _, err := v.Validate(context.Background(), strings.Join(parts, ".")); err != errInvalidSignature {
+			t.Errorf("Validate() error = %v, want %v", err, errInvalidSignature)
+		}
+	})
+}
+
+func TestOAuthValidator_Principal(t *testing.T) {
+	keys := newTestKeys(t)
+	v := newTestValidator(t, keys)
+
+	principal, err := v.Validate(context.Background(), keys.sign(t, "ES256", "ec", validClaims()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !principal.HasScope("read") || !principal.HasScope("input") || principal.HasScope("run") {
+		t.Errorf("scopes = %v, want read and input", principal.Scopes)
+	}
+	if !strings.HasPrefix(principal.id, "oauth:") {
+		t.Errorf("id = %q, want an oauth: fingerprint", principal.id)
+	}
+
+	// A token without scopes grants none.
+	principal, err = v.Validate(context.Background(), keys.sign(t, "ES256", "ec", withClaims(validClaims(), map[string]any{"scope": nil, "sub": nil, "client_id": "ci-bot"})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.Name != "ci-bot" {
+		t.Errorf("name = %q, want the client_id when there is no subject", principal.Name)
+	}
+	if principal.HasScope("read") {
+		t.Error("token without a scope claim grants read, want no scopes")
+	}
+}
+
+func TestNewOAuthValidator_Errors(t *testing.T) {
+	invalid := filepath.Join(t.TempDir(), "invalid.json")
+	if err := os.WriteFile(invalid, []byte(`{"keys":[{"kty":"EC","crv":"P-256","x":"AA","y":"AA"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		config  OAuthConfig
+		wantErr string
+	}{
+		{"no audience", OAuthConfig{Issuer: testIssuer}, "audience is required"},
+		{"no key source", OAuthConfig{Audience: testAudience}, "issuer or a JWKS file"},
+		{"missing file", OAuthConfig{Audience: testAudience, JWKSFile: filepath.Join(t.TempDir(), "missing.json")}, "failed to load"},
+		{"invalid key", OAuthConfig{Audience: testAudience, JWKSFile: invalid}, "invalid EC point"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewOAuthValidator(tt.config); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewOAuthValidator() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOAuthValidator_IssuerMetadata(t *testing.T) {
+	keys := newTestKeys(t)
+	var fetches atomic.Int32
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	mux.HandleFunc("/.well-known/oauth-authorization-server/tenant", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": ts.URL + "/tenant", "jwks_uri": ts.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Write(keys.jwks())
+	})
+
+	v, err := NewOAuthValidator(OAuthConfig{Issuer: ts.URL + "/tenant", Audience: testAudience, HTTPClient: ts.Client()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := withClaims(validClaims(), map[string]any{"iss": ts.URL + "/tenant"})
+	for range 2 {
+		if _, err := v.Validate(context.Background(), keys.sign(t, "RS256", "rsa", claims)); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+	}
+	// Unknown keys don't prompt another fetch so soon after the last.
+	if _, err := v.Validate(context.Background(), keys.sign(t, "RS256", "rotated", claims)); err == nil {
+		t.Error("Validate() accepted a token signed by an unknown key")
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("key set fetched %d times, want 1", n)
+	}
+}
+
+func TestOAuthValidator_OpenIDDiscovery(t *testing.T) {
+	keys := newTestKeys(t)
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": ts.URL, "jwks_uri": ts.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(keys.jwks())
+	})
+
+	v, err := NewOAuthValidator(OAuthConfig{Issuer: ts.URL, Audience: testAudience, HTTPClient: ts.Client()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Validate(context.Background(), keys.sign(t, "ES256", "ec", withClaims(validClaims(), map[string]any{"iss": ts.URL}))); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestHTTPTransport_OAuth(t *testing.T) {
+	keys := newTestKeys(t)
+	tr := NewHTTPTransport(&HTTPTransportConfig{APIKey: "static-key", OAuth: newTestValidator(t, keys)})
+	tr.scopedHandler = func(scoped Transport, msg *Message) (*Message, error) {
+		principal := scoped.(Authenticated).Principal()
+		result, _ := json.Marshal(map[string]string{"protocolVersion": "2025-11-25", "principal": principal.Method + ":" + principal.Name})
+		return &Message{JSONRPC: "2.0", ID: msg.ID, Result: result}, nil
+	}
+	ts := httptest.NewServer(tr.server.Handler)
+	defer ts.Close()
+	if !tr.IsAuthEnabled() {
+		t.Error("IsAuthEnabled() = false with OAuth configured")
+	}
+
+	t.Run("resource metadata", func(t *testing.T) {
+		resp, err := ts.Client().Get(ts.URL + ProtectedResourceMetadataPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d, want 200 without authentication", resp.StatusCode)
+		}
+		var metadata struct {
+			Resource             string   `json:"resource"`
+			AuthorizationServers []string `json:"authorization_servers"`
+			ScopesSupported      []string `json:"scopes_supported"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
+			t.Fatal(err)
+		}
+		if metadata.Resource != testAudience || len(metadata.AuthorizationServers) != 1 || metadata.AuthorizationServers[0] != testIssuer || len(metadata.ScopesSupported) != 3 {
+			t.Errorf("metadata = %+v", metadata)
+		}
+	})
+
+	initialize := func(t *testing.T, authorization string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+MCPEndpoint, strings.NewReader(initBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", acceptBoth)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	const metadataParam = `resource_metadata="https://mcp.example.com/.well-known/oauth-protected-resource"`
+
+	t.Run("missing token", func(t *testing.T) {
+		resp := initialize(t, "")
+		if challenge := resp.Header.Get("WWW-Authenticate"); resp.StatusCode != http.StatusUnauthorized || challenge != "Bearer "+metadataParam {
+			t.Errorf("got %d with challenge %q, want 401 pointing to the metadata", resp.StatusCode, challenge)
+		}
+	})
+	t.Run("invalid token", func(t *testing.T) {
+		expired := keys.sign(t, "ES256", "ec", withClaims(validClaims(), map[string]any{"exp": time.Now().Add(-time.Hour).Unix()}))
+		resp := initialize(t, "Bearer "+expired)
+		want := `Bearer error="invalid_token", error_description="token expired", ` + metadataParam
+		if challenge := resp.Header.Get("WWW-Authenticate"); resp.StatusCode != http.StatusUnauthorized || challenge != want {
+			t.Errorf("got %d with challenge %q, want 401 with %q", resp.StatusCode, challenge, want)
+		}
+	})
+	for _, tt := range []struct{ name, token, want string }{
+		{"access token", keys.sign(t, "ES256", "ec", validClaims()), "oauth:alice"},
+		{"API key", "static-key", "api_key:default"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := initialize(t, "Bearer "+tt.token)
+			var msg Message
+			if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+				t.Fatalf("status %d: %v", resp.StatusCode, err)
+			}
+			var result struct{ Principal string }
+			json.Unmarshal(msg.Result, &result)
+			if result.Principal != tt.want {
+				t.Errorf("principal = %q, want %q", result.Principal, tt.want)
+			}
+		})
+	}
+}
@@ -5,11 +5,25 @@
 package transport
 
 import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
 	"sync"
 	"time"
 )
 
+// clientBucketSweepInterval is how often ClientRateLimiter drops the buckets
+// of idle clients.
+const clientBucketSweepInterval = time.Minute
+
+// metricLabelPattern matches the tool and method names recorded as metric labels.
+var metricLabelPattern = regexp.MustCompile(`^[a-zA-Z0-9_/]{1,64}$`)
+
 // RateLimiter implements a token bucket rate limiting algorithm.
 // It provides thread-safe rate limiting with configurable requests per second.
 // When the bucket is empty, requests are rejected with HTTP 429 Too Many Requests.
@@ -65,14 +79,37 @@ func NewRateLimiterWithClock(requestsPerSecond float64, clock func() time.Time)
 // Allow checks if a request should be allowed and consumes a token if so.
 // Returns true if allowed, false if rate limited. Thread-safe.
 func (r *RateLimiter) Allow() bool {
+	allowed, _ := r.AllowN(1)
+	return allowed
+}
+
+// AllowN is like Allow, but consumes cost tokens. A cost above the burst size
+// is charged as a full bucket, so no request is rejected outright. When the
+// request is rejected, AllowN also returns how long until enough tokens are
+// available.
+func (r *RateLimiter) AllowN(cost float64) (bool, time.Duration) {
 	if r == nil {
-		return true // nil limiter means no rate limiting
+		return true, 0 // nil limiter means no rate limiting
 	}
 
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	now := r.clock()
+	r.refill(r.clock())
+	cost = min(cost, r.burst)
+
+	if r.tokens < cost {
+		// not enough tokens available
+		wait := time.Duration((cost - r.tokens) / r.rate * float64(time.Second))
+		return false, wait
+	}
+
+	r.tokens -= cost
+	return true, 0
+}
+
+// refill adds the tokens accrued since the last update. The caller must hold mu.
+func (r *RateLimiter) refill(now time.Time) {
 	elapsed := now.Sub(r.lastUpdate).Seconds()
 
 	// Refill tokens based on elapsed time
@@ -81,13 +118,14 @@ func (r *RateLimiter) Allow() bool {
 		r.tokens = r.burst
 	}
 	r.lastUpdate = now
+}
 
-	if r.tokens < 1 {
-		return false // no tokens available
-	}
-
-	r.tokens--
-	return true
+// full reports whether the bucket would be full at now, and so is
+// indistinguishable from a new one.
+func (r *RateLimiter) full(now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.tokens+now.Sub(r.lastUpdate).Seconds()*r.rate >= r.burst
 }
 
 // Tokens returns the current number of available tokens.
@@ -131,3 +169,178 @@ func RateLimitMiddleware(limiter *RateLimiter, next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// ClientRateLimiter applies a separate token bucket to each client, so one
+// client exhausting its bucket does not affect others. Requests are charged
+// by cost; see Cost. Each bucket holds 2x the rate, as for RateLimiter.
+//
+// Clients are identified by the transport, by their API key once
+// authenticated or otherwise their remote address. Buckets of idle clients
+// are dropped once they refill, so the set of clients tracked stays bounded
+// by the clients active in the last minute.
+type ClientRateLimiter struct {
+	clock     func() time.Time
+	lastSweep time.Time
+	buckets   map[string]*RateLimiter
+	costs     map[string]float64
+	rate      float64
+	mu        sync.Mutex
+}
+
+// NewClientRateLimiter creates a per-client rate limiter with the specified
+// rate in requests per second. costs weights tools/call requests by tool
+// name; tools not listed cost 1.
+// Returns nil if rate is 0 or negative (disabling rate limiting).
+func NewClientRateLimiter(requestsPerSecond float64, costs map[string]float64) *ClientRateLimiter {
+	return NewClientRateLimiterWithClock(requestsPerSecond, costs, time.Now)
+}
+
+// NewClientRateLimiterWithClock creates a per-client rate limiter with an
+// injectable clock. This is primarily used for testing to control time progression.
+func NewClientRateLimiterWithClock(requestsPerSecond float64, costs map[string]float64, clock func() time.Time) *ClientRateLimiter {
+	if requestsPerSecond <= 0 {
+		return nil
+	}
+	return &ClientRateLimiter{
+		clock:     clock,
+		lastSweep: clock(),
+		buckets:   make(map[string]*RateLimiter),
+		costs:     costs,
+		rate:      requestsPerSecond,
+	}
+}
+
+// Allow charges cost tokens to client's bucket. It returns true if the
+// request is allowed; otherwise, how long until the client can retry it.
+// A nil limiter allows everything.
+func (l *ClientRateLimiter) Allow(client string, cost float64) (bool, time.Duration) {
+	if l == nil || cost <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock()
+	if now.Sub(l.lastSweep) >= clientBucketSweepInterval {
+		for id, bucket := range l.buckets {
+			if bucket.full(now) {
+				delete(l.buckets, id)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	bucket, ok := l.buckets[client]
+	if !ok {
+		bucket = NewRateLimiterWithClock(l.rate, l.clock)
+		l.buckets[client] = bucket
+	}
+	return bucket.AllowN(cost)
+}
+
+// Cost returns the tokens msg costs: the tool's weight for a tools/call
+// request, or 1 for other requests. Notifications and responses are free, so
+// cancellations and answers to server-initiated requests are never rejected.
+func (l *ClientRateLimiter) Cost(msg *Message) float64 {
+	if len(msg.ID) == 0 || string(msg.ID) == "null" || msg.Method == "" {
+		return 0
+	}
+	if l != nil && msg.Method == "tools/call" {
+		if cost, ok := l.costs[toolCallName(msg)]; ok {
+			return cost
+		}
+	}
+	return 1
+}
+
+// Clients returns the number of clients with a tracked bucket.
+func (l *ClientRateLimiter) Clients() int {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// toolCallName returns the tool name of a tools/call request.
+func toolCallName(msg *Message) string {
+	var params struct {
+		Name string `json:"name"`
+	}
+	_ = json.Unmarshal(msg.Params, &params)
+	return params.Name
+}
+
+// admit charges msg to client's bucket in limiter. If the bucket is empty,
+// it records the rejection in metrics and returns the JSON-RPC error response
+// to send instead, with the delay before the request can be retried.
+func admit(limiter *ClientRateLimiter, metrics *MetricsRegistry, client string, msg *Message) (*Message, time.Duration) {
+	allowed, retryAfter := limiter.Allow(client, limiter.Cost(msg))
+	if allowed {
+		return nil, 0
+	}
+
+	request := msg.Method
+	if request == "tools/call" {
+		request = toolCallName(msg)
+	}
+	// The name is the client's; keep the metric's labels well-formed.
+	if !metricLabelPattern.MatchString(request) {
+		request = "unknown"
+	}
+	metrics.RecordRateLimited(request)
+
+	retryAfterMs := int64(math.Ceil(float64(retryAfter) / float64(time.Millisecond)))
+	data, _ := json.Marshal(map[string]int64{"retryAfterMs": retryAfterMs})
+	return &Message{
+		JSONRPC: "2.0",
+		ID:      msg.ID,
+		Error: &ErrorObj{
+			Code:    ErrCodeRateLimited,
+			Message: fmt.Sprintf("Rate limit exceeded, retry after %s", time.Duration(retryAfterMs)*time.Millisecond),
+			Data:    data,
+		},
+	}, retryAfter
+}
+
+// clientRateLimitMiddleware charges each request to its client's bucket in
+// limiter, rejecting it with 429 when the bucket is empty. The /health and
+// /metrics endpoints are exempt, as are POSTs of JSON-RPC messages to the
+// paths in messagePaths, which are charged per message once decoded.
+func clientRateLimitMiddleware(limiter *ClientRateLimiter, metrics *MetricsRegistry, next http.Handler, messagePaths ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" || r.URL.Path == "/metrics" ||
+			(r.Method == http.MethodPost && slices.Contains(messagePaths, r.URL.Path)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if allowed, retryAfter := limiter.Allow(clientID(r), 1); !allowed {
+			metrics.RecordRateLimited("http")
+			setRetryAfter(w, retryAfter)
+			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeRateLimited writes the JSON-RPC error response for a rejected
+// message, with a Retry-After header.
+func writeRateLimited(w http.ResponseWriter, response *Message, retryAfter time.Duration) {
+	setRetryAfter(w, retryAfter)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// setRetryAfter sets the Retry-After header to retryAfter, rounded up to
+// whole seconds.
+func setRetryAfter(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := max(int(math.Ceil(retryAfter.Seconds())), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+}
@@ -5,9 +5,12 @@
 package transport
 
 import (
+	"bytes"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -857,3 +860,151 @@ func TestRateLimitMiddleware_ExemptEndpointsWithConcurrentTraffic(t *testing.T)
 		t.Errorf("apiRateLimited = %d, want %d (all should be rate limited)", apiRateLimited.Load(), goroutines)
 	}
 }
+
+func TestRateLimiter_AllowN(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rl := NewRateLimiterWithClock(2.0, func() time.Time { return now }) // burst = 4
+
+	if ok, _ := rl.AllowN(3); !ok {
+		t.Fatal("cost 3 should be allowed from a full bucket")
+	}
+	ok, wait := rl.AllowN(3)
+	if ok || wait != time.Second {
+		t.Errorf("AllowN(3) with 1 token = %v, %v; want false, 1s", ok, wait)
+	}
+
+	// Costs above the burst size are charged as a full bucket.
+	now = now.Add(2 * time.Second)
+	if ok, _ := rl.AllowN(10); !ok {
+		t.Error("cost above burst should be allowed from a full bucket")
+	}
+	if tokens := rl.Tokens(); tokens != 0 {
+		t.Errorf("Tokens() = %v, want 0", tokens)
+	}
+}
+
+func TestClientRateLimiter_ClientsIsolated(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rl := NewClientRateLimiterWithClock(1.0, nil, func() time.Time { return now }) // burst = 2
+
+	for range 2 {
+		if ok, _ := rl.Allow("a", 1); !ok {
+			t.Fatal("client a should be allowed within its burst")
+		}
+	}
+	if ok, wait := rl.Allow("a", 1); ok || wait != time.Second {
+		t.Errorf("exhausted client a = %v, %v; want false, 1s", ok, wait)
+	}
+	if ok, _ := rl.Allow("b", 1); !ok {
+		t.Error("client b should not be limited by client a")
+	}
+	if n := rl.Clients(); n != 2 {
+		t.Errorf("Clients() = %d, want 2", n)
+	}
+
+	// Idle clients are forgotten once their buckets refill.
+	now = now.Add(clientBucketSweepInterval)
+	rl.Allow("c", 1)
+	if n := rl.Clients(); n != 1 {
+		t.Errorf("Clients() after sweep = %d, want 1", n)
+	}
+}
+
+func TestClientRateLimiter_Disabled(t *testing.T) {
+	rl := NewClientRateLimiter(0, nil)
+	if rl != nil {
+		t.Fatal("expected nil limiter for zero rate")
+	}
+	if ok, _ := rl.Allow("a", 100); !ok {
+		t.Error("nil limiter should always allow")
+	}
+}
+
+func TestClientRateLimiter_Cost(t *testing.T) {
+	rl := NewClientRateLimiter(1.0, map[string]float64{"screenshot": 5, "ping": 0})
+
+	tests := []struct {
+		name string
+		msg  string
+		want float64
+	}{
+		{"weighted tool", `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"screenshot"}}`, 5},
+		{"free tool", `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"ping"}}`, 0},
+		{"unweighted tool", `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"click"}}`, 1},
+		{"other method", `{"jsonrpc":"2.0","id":1,"method":"screenshot"}`, 1},
+		{"notification", `{"jsonrpc":"2.0","method":"notifications/cancelled"}`, 0},
+		{"response", `{"jsonrpc":"2.0","id":1,"result":{}}`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var msg Message
+			if err := json.Unmarshal([]byte(tt.msg), &msg); err != nil {
+				t.Fatal(err)
+			}
+			if got := rl.Cost(&msg); got != tt.want {
+				t.Errorf("Cost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdmit_RetryHint(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rl := NewClientRateLimiterWithClock(2.0, map[string]float64{"screenshot": 3}, func() time.Time { return now })
+	metrics := NewMetricsRegistry()
+	msg := &Message{JSONRPC: "2.0", ID: json.RawMessage(`7`), Method: "tools/call", Params: json.RawMessage(`{"name":"screenshot"}`)}
+
+	if response, _ := admit(rl, metrics, "a", msg); response != nil {
+		t.Fatalf("first call rejected: %+v", response.Error)
+	}
+	response, retryAfter := admit(rl, metrics, "a", msg)
+	if response == nil || response.Error == nil {
+		t.Fatal("second call should be rejected")
+	}
+	if response.Error.Code != ErrCodeRateLimited || string(response.ID) != "7" {
+		t.Errorf("response = %+v, want rate limit error for id 7", response)
+	}
+	if retryAfter != time.Second || string(response.Error.Data) != `{"retryAfterMs":1000}` {
+		t.Errorf("retry hint = %v, %s; want 1s", retryAfter, response.Error.Data)
+	}
+
+	var buf bytes.Buffer
+	if err := metrics.WritePrometheus(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `mcp_rate_limited_total{request="screenshot"} 1`) {
+		t.Errorf("rejection not counted:\n%s", buf.String())
+	}
+}
+
+func TestClientRateLimitMiddleware(t *testing.T) {
+	rl := NewClientRateLimiter(0.5, nil) // burst = 1
+	handler := clientRateLimitMiddleware(rl, NewMetricsRegistry(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), "/message")
+
+	get := func(method, path, remoteAddr string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "2" {
+			t.Errorf("Retry-After = %q, want 2", w.Header().Get("Retry-After"))
+		}
+		return w.Code
+	}
+
+	if code := get(http.MethodGet, "/events", "10.0.0.1:1234"); code != http.StatusOK {
+		t.Fatalf("first request status = %d, want 200", code)
+	}
+	if code := get(http.MethodGet, "/events", "10.0.0.1:5678"); code != http.StatusTooManyRequests {
+		t.Errorf("same address status = %d, want 429", code)
+	}
+	if code := get(http.MethodGet, "/events", "10.0.0.2:1234"); code != http.StatusOK {
+		t.Errorf("other address status = %d, want 200", code)
+	}
+	// Messages are charged once decoded, by the transport.
+	if code := get(http.MethodPost, "/message", "10.0.0.1:1234"); code != http.StatusOK {
+		t.Errorf("message POST status = %d, want 200", code)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2025 Joseph Cumines
+//
+// Server-initiated JSON-RPC request tracking
+
+package transport
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Requester is implemented by transports that can send server-initiated
+// requests (such as elicitation/create) to the client and await the response.
+type Requester interface {
+	// Request sends a JSON-RPC request to the client and blocks until the
+	// client responds or ctx is done. A JSON-RPC error response is returned as
+	// a message with Error set, not as a Go error.
+	Request(ctx context.Context, method string, params any) (*Message, error)
+}
+
+// IsResponse reports whether msg is a JSON-RPC response (has an ID but no method).
+func IsResponse(msg *Message) bool {
+	return msg.Method == "" && len(msg.ID) > 0 && string(msg.ID) != "null"
+}
+
+// pendingRequests tracks server-initiated requests awaiting a client response.
+// Server request IDs are strings prefixed "srv-", so they are never confused
+// with IDs chosen by the client, followed by random text so they can't be
+// guessed by other clients.
+type pendingRequests struct {
+	waiters map[string]chan *Message
+	mu      sync.Mutex
+}
+
+func newPendingRequests() *pendingRequests {
+	return &pendingRequests{waiters: make(map[string]chan *Message)}
+}
+
+// add allocates a request ID and registers a waiter for its response.
+func (p *pendingRequests) add() (string, chan *Message) {
+	id := "srv-" + rand.Text()
+	ch := make(chan *Message, 1)
+	p.mu.Lock()
+	p.waiters[id] = ch
+	p.mu.Unlock()
+	return id, ch
+}
+
+// remove unregisters the waiter for id.
+func (p *pendingRequests) remove(id string) {
+	p.mu.Lock()
+	delete(p.waiters, id)
+	p.mu.Unlock()
+}
+
+// resolve delivers a response to its waiter. It reports whether the response
+// matched a pending request.
+func (p *pendingRequests) resolve(msg *Message) bool {
+	var id string
+	if err := json.Unmarshal(msg.ID, &id); err != nil {
+		return false
+	}
+	p.mu.Lock()
+	ch, ok := p.waiters[id]
+	delete(p.waiters, id)
+	p.mu.Unlock()
+	if ok {
+		ch <- msg
+	}
+	return ok
+}
+
+// request sends a request using write and waits for its response.
+func (p *pendingRequests) request(ctx context.Context, method string, params any, write func(*Message) error) (*Message, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal params: %w", err)
+	}
+
+	id, ch := p.add()
+	defer p.remove(id)
+
+	idJSON, _ := json.Marshal(id)
+	if err := write(&Message{JSONRPC: "2.0", ID: idJSON, Method: method, Params: data}); err != nil {
+		return nil, err
+	}
+
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-ctx.Done():
+		// Per MCP 2025-11-25 basic/utilities/cancellation, tell the client the
+		// request was abandoned so it can stop processing it. Best effort.
+		cancelled, _ := json.Marshal(map[string]any{"requestId": id, "reason": ctx.Err().Error()})
+		_ = write(&Message{JSONRPC: "2.0", Method: "notifications/cancelled", Params: cancelled})
+		return nil, fmt.Errorf("no response to %s: %w", method, ctx.Err())
+	}
+}
@@ -6,6 +6,7 @@ package transport
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -25,12 +26,15 @@ import (
 //   - Read and Write may proceed concurrently without deadlock because they do
 //     not share a mutex. This is critical: ReadMessage blocks on stdin and must
 //     never hold a lock that WriteMessage requires.
+//   - Request may be called from any goroutine while another goroutine reads;
+//     responses to it are consumed by ReadMessage and never returned.
 //
 //lint:ignore BETTERALIGN struct is intentionally ordered for clarity
 type StdioTransport struct {
 	reader  *bufio.Reader
 	writer  io.Writer
-	writeMu sync.Mutex // protects writer only; never held during blocking reads
+	pending *pendingRequests // server-initiated requests awaiting a response
+	writeMu sync.Mutex       // protects writer only; never held during blocking reads
 	closed  atomic.Bool
 }
 
@@ -38,8 +42,9 @@ type StdioTransport struct {
 // The reader is typically os.Stdin and writer is typically os.Stdout.
 func NewStdioTransport(stdin io.Reader, stdout io.Writer) *StdioTransport {
 	return &StdioTransport{
-		reader: bufio.NewReader(stdin),
-		writer: stdout,
+		reader:  bufio.NewReader(stdin),
+		writer:  stdout,
+		pending: newPendingRequests(),
 	}
 }
 
@@ -117,10 +122,30 @@ type ErrorObj struct {
 // It blocks until a complete newline-delimited JSON message is available.
 // Returns an error if the transport is closed or reading fails.
 //
+// Responses from the client are delivered to the pending Request they
+// answer, and never returned; unsolicited responses are logged and dropped.
+//
 // This method does NOT hold a mutex during the blocking read so that
 // concurrent WriteMessage calls are never starved.
 // It is safe for exactly one goroutine to call ReadMessage at a time.
 func (t *StdioTransport) ReadMessage() (*Message, error) {
+	for {
+		msg, err := t.readMessage()
+		if err != nil {
+			return nil, err
+		}
+		if IsResponse(msg) {
+			if !t.pending.resolve(msg) {
+				log.Printf("Dropping response to unknown request %s", msg.ID)
+			}
+			continue
+		}
+		return msg, nil
+	}
+}
+
+// readMessage reads the next message from stdin.
+func (t *StdioTransport) readMessage() (*Message, error) {
 	if t.closed.Load() {
 		return nil, fmt.Errorf("transport is closed")
 	}
@@ -172,6 +197,13 @@ func (t *StdioTransport) WriteMessage(msg *Message) error {
 	return nil
 }
 
+// Request sends a server-initiated request to the client over stdout and waits
+// for the response to be read from stdin. Some goroutine must be reading
+// messages (e.g. via ReadMessage or Serve) for the response to be received.
+func (t *StdioTransport) Request(ctx context.Context, method string, params any) (*Message, error) {
+	return t.pending.request(ctx, method, params, t.WriteMessage)
+}
+
 // Close closes the transport and marks it as unavailable.
 // Subsequent operations will return an error.
 func (t *StdioTransport) Close() error {
@@ -5,7 +5,9 @@
 package transport
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"strings"
@@ -426,3 +428,104 @@ func TestConcurrentReadWrite(t *testing.T) {
 	}
 	pw.Close()
 }
+
+// TestStdioTransport_Request verifies a server-initiated request is written to
+// stdout, and that its response is consumed by ReadMessage rather than returned.
+func TestStdioTransport_Request(t *testing.T) {
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+	tr := NewStdioTransport(stdinR, stdoutW)
+	defer stdinW.Close()
+
+	type requestResult struct {
+		resp *Message
+		err  error
+	}
+	done := make(chan requestResult, 1)
+	go func() {
+		resp, err := tr.Request(context.Background(), "elicitation/create", map[string]string{"message": "ok?"})
+		done <- requestResult{resp, err}
+	}()
+
+	line, err := bufio.NewReader(stdoutR).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req Message
+	if err := json.Unmarshal([]byte(line), &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != "elicitation/create" || !strings.HasPrefix(string(req.ID), `"srv-`) || string(req.Params) != `{"message":"ok?"}` {
+		t.Fatalf("unexpected request: %s", line)
+	}
+
+	read := make(chan *Message, 1)
+	go func() {
+		msg, _ := tr.ReadMessage()
+		read <- msg
+	}()
+
+	// Unknown responses are dropped; the matching response resolves the
+	// request; the following request is returned by ReadMessage.
+	if _, err := io.WriteString(stdinW, `{"jsonrpc":"2.0","id":"srv-99","result":{}}`+"\n"+
+		`{"jsonrpc":"2.0","id":`+string(req.ID)+`,"result":{"action":"accept"}}`+"\n"+
+		`{"jsonrpc":"2.0","id":1,"method":"ping"}`+"\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case r := <-done:
+		if r.err != nil || string(r.resp.Result) != `{"action":"accept"}` {
+			t.Errorf("Request() = %+v, %v", r.resp, r.err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Request did not receive its response")
+	}
+	select {
+	case msg := <-read:
+		if msg == nil || msg.Method != "ping" {
+			t.Errorf("ReadMessage() = %+v, want ping request", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("ReadMessage did not return the ping request")
+	}
+}
+
+func TestStdioTransport_Request_ContextDone(t *testing.T) {
+	var out bytes.Buffer
+	tr := NewStdioTransport(strings.NewReader(""), &out)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := tr.Request(ctx, "elicitation/create", nil)
+	if err == nil || !strings.Contains(err.Error(), "no response to elicitation/create") {
+		t.Errorf("Request() error = %v, want timeout", err)
+	}
+	if len(tr.pending.waiters) != 0 {
+		t.Errorf("pending requests not cleaned up: %v", tr.pending.waiters)
+	}
+
+	// The abandoned request is cancelled with the client.
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected request and cancellation, got %q", out.String())
+	}
+	var request, cancelled Message
+	if err := json.Unmarshal([]byte(lines[0]), &request); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &cancelled); err != nil {
+		t.Fatal(err)
+	}
+	var params struct {
+		RequestID json.RawMessage `json:"requestId"`
+		Reason    string          `json:"reason"`
+	}
+	if err := json.Unmarshal(cancelled.Params, &params); err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.Method != "notifications/cancelled" || len(cancelled.ID) != 0 ||
+		string(params.RequestID) != string(request.ID) || params.Reason == "" {
+		t.Errorf("unexpected cancellation: %s", lines[1])
+	}
+}
@@ -0,0 +1,625 @@
+// Copyright 2025 Joseph Cumines
+//
+// Streamable HTTP transport (MCP 2025-11-25 basic/transports) — a single MCP
+// endpoint with Mcp-Session-Id sessions
+
+package transport
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// MCPEndpoint is the path of the Streamable HTTP endpoint.
+	MCPEndpoint = "/mcp"
+
+	// SessionIDHeader carries the session ID assigned at initialization.
+	SessionIDHeader = "Mcp-Session-Id"
+
+	// ProtocolVersionHeader carries the negotiated protocol version on
+	// requests after initialization.
+	ProtocolVersionHeader = "Mcp-Protocol-Version"
+)
+
+// ScopedHandler handles a message with a Transport scoped to it. Messages
+// written to tr, such as progress notifications and server-initiated
+// requests, are delivered to the client that sent msg rather than broadcast.
+type ScopedHandler func(tr Transport, msg *Message) (*Message, error)
+
+// errTooManySessions is returned by sessionRegistry.create when the session
+// limit is reached.
+var errTooManySessions = errors.New("too many sessions")
+
+// sessionRegistry tracks the sessions of the Streamable HTTP endpoint.
+// Sessions unused for idleTimeout are ended, and at most max are open at once.
+type sessionRegistry struct {
+	sessions    map[string]*httpSession
+	onClose     func(id string)
+	idleTimeout time.Duration
+	max         int
+	mu          sync.RWMutex
+}
+
+func newSessionRegistry(idleTimeout time.Duration, max int) *sessionRegistry {
+	return &sessionRegistry{
+		sessions:    make(map[string]*httpSession),
+		idleTimeout: idleTimeout,
+		max:         max,
+	}
+}
+
+// create starts a new session with a random ID, owned by the client owner.
+// Idle sessions are ended first, so they don't count towards the limit.
+func (r *sessionRegistry) create(t *HTTPTransport, owner string) (*httpSession, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	session := &httpSession{
+		id:         hex.EncodeToString(b),
+		owner:      owner,
+		transport:  t,
+		streams:    NewClientRegistry(),
+		pending:    newPendingRequests(),
+		lastActive: time.Now(),
+	}
+	r.expire()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.sessions) >= r.max {
+		return nil, errTooManySessions
+	}
+	r.sessions[session.id] = session
+	return session, nil
+}
+
+// get returns the session id, marking it active. An idle session is ended
+// instead.
+func (r *sessionRegistry) get(id string) (*httpSession, bool) {
+	r.mu.RLock()
+	session, ok := r.sessions[id]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	if session.idle(r.idleTimeout) {
+		r.remove(id)
+		return nil, false
+	}
+	session.touch()
+	return session, true
+}
+
+// expire ends the idle sessions.
+func (r *sessionRegistry) expire() {
+	for _, session := range r.all() {
+		if session.idle(r.idleTimeout) && r.remove(session.id) {
+			log.Printf("MCP session expired: %s", session.id)
+		}
+	}
+}
+
+// remove ends the session id, disconnecting its streams, and reports its end
+// to the onClose callback. It reports whether the session existed.
+func (r *sessionRegistry) remove(id string) bool {
+	r.mu.Lock()
+	session, ok := r.sessions[id]
+	delete(r.sessions, id)
+	onClose := r.onClose
+	r.mu.Unlock()
+	if !ok {
+		return false
+	}
+	session.close()
+	if onClose != nil {
+		onClose(id)
+	}
+	return true
+}
+
+// all returns the current sessions.
+func (r *sessionRegistry) all() []*httpSession {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	sessions := make([]*httpSession, 0, len(r.sessions))
+	for _, session := range r.sessions {
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+// Count returns the number of active sessions.
+func (r *sessionRegistry) Count() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.sessions)
+}
+
+// httpSession is a Streamable HTTP session. It is the Transport for messages
+// to the client that are not part of a request's response: they are sent on
+// one of the session's GET streams.
+type httpSession struct {
+	lastActive      time.Time
+	transport       *HTTPTransport
+	streams         *ClientRegistry
+	pending         *pendingRequests // server-initiated requests awaiting a response
+	id              string
+	owner           string // clientID of the client that created the session
+	protocolVersion string
+	mu              sync.RWMutex
+	closed          bool
+}
+
+// touch marks the session active.
+func (s *httpSession) touch() {
+	s.mu.Lock()
+	s.lastActive = time.Now()
+	s.mu.Unlock()
+}
+
+// idle reports whether the session has been unused for timeout. A session
+// with an open GET stream is never idle.
+func (s *httpSession) idle(timeout time.Duration) bool {
+	s.mu.RLock()
+	lastActive := s.lastActive
+	s.mu.RUnlock()
+	return time.Since(lastActive) >= timeout && s.streams.Count() == 0
+}
+
+// setProtocolVersion records the protocol version negotiated by initialize.
+// Requests can arrive on the session while initialize is still streaming.
+func (s *httpSession) setProtocolVersion(version string) {
+	s.mu.Lock()
+	s.protocolVersion = version
+	s.mu.Unlock()
+}
+
+// negotiatedVersion returns the protocol version negotiated by initialize, or
+// "" before it completes.
+func (s *httpSession) negotiatedVersion() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.protocolVersion
+}
+
+// SessionID returns the session ID.
+func (s *httpSession) SessionID() string {
+	return s.id
+}
+
+// Session returns the session itself.
+func (s *httpSession) Session() Transport {
+	return s
+}
+
+// ReadMessage is not supported: messages arrive via POST.
+func (s *httpSession) ReadMessage() (*Message, error) {
+	return nil, fmt.Errorf("ReadMessage is not supported by HTTP sessions")
+}
+
+// WriteMessage sends msg on one of the session's GET streams. Per MCP
+// 2025-11-25 basic/transports, a message is never sent on more than one
+// stream. With no stream connected, the message is retained for replay.
+func (s *httpSession) WriteMessage(msg *Message) error {
+	if s.IsClosed() {
+		return fmt.Errorf("session %s is closed", s.id)
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	s.SendEvent("message", string(data))
+	return nil
+}
+
+// SendEvent sends a custom SSE event on one of the session's GET streams.
+func (s *httpSession) SendEvent(eventType string, data string) {
+	if s.IsClosed() {
+		return
+	}
+	s.streams.Send(&SSEEvent{
+		ID:    s.transport.nextEventID(),
+		Event: eventType,
+		Data:  data,
+	})
+	s.transport.metrics.RecordSSEEvent()
+}
+
+// Request sends a server-initiated request on one of the session's GET
+// streams and waits for the client to POST the response.
+func (s *httpSession) Request(ctx context.Context, method string, params any) (*Message, error) {
+	if s.streams.Count() == 0 {
+		return nil, fmt.Errorf("no SSE stream open for session %s to receive %s", s.id, method)
+	}
+	return s.pending.request(ctx, method, params, s.WriteMessage)
+}
+
+// Close is a no-op: sessions end when the client sends DELETE or the
+// transport closes.
+func (s *httpSession) Close() error {
+	return nil
+}
+
+// IsClosed reports whether the session has ended.
+func (s *httpSession) IsClosed() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.closed || s.transport.IsClosed()
+}
+
+// close ends the session and disconnects its GET streams.
+func (s *httpSession) close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	for _, client := range s.streams.all() {
+		s.streams.Remove(client.ID)
+	}
+}
+
+// requestStream is the Transport for a request POSTed to the MCP endpoint.
+// Messages written while the request is being handled are sent on its
+// response, which becomes an SSE stream on the first write; the JSON-RPC
+// response then completes the stream. Messages written after the response,
+// or when the client can't accept an SSE response, go to the session.
+type requestStream struct {
+	w         http.ResponseWriter
+	flusher   http.Flusher
+	session   *httpSession
+	principal *Principal
+	mu        sync.Mutex
+	canStream bool
+	streaming bool
+	done      bool
+}
+
+// SessionID returns the ID of the request's session.
+func (s *requestStream) SessionID() string {
+	return s.session.id
+}
+
+// Session returns the request's session.
+func (s *requestStream) Session() Transport {
+	return s.session
+}
+
+// Principal returns the client that sent the request.
+func (s *requestStream) Principal() *Principal {
+	return s.principal
+}
+
+// ReadMessage is not supported: messages arrive via POST.
+func (s *requestStream) ReadMessage() (*Message, error) {
+	return nil, fmt.Errorf("ReadMessage is not supported by HTTP request streams")
+}
+
+// WriteMessage sends msg on the request's SSE response stream, or to the
+// session once the response is complete.
+func (s *requestStream) WriteMessage(msg *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done || !s.canStream {
+		return s.session.WriteMessage(msg)
+	}
+	return s.writeEventLocked(msg)
+}
+
+// Request sends a server-initiated request on the response stream and waits
+// for the client to POST the response.
+func (s *requestStream) Request(ctx context.Context, method string, params any) (*Message, error) {
+	return s.session.pending.request(ctx, method, params, s.WriteMessage)
+}
+
+// Close is a no-op: the stream ends with the request.
+func (s *requestStream) Close() error {
+	return nil
+}
+
+// IsClosed reports whether the request's session has ended.
+func (s *requestStream) IsClosed() bool {
+	return s.session.IsClosed()
+}
+
+// writeEventLocked writes msg as an SSE event, starting the stream if needed.
+func (s *requestStream) writeEventLocked(msg *Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	if !s.streaming {
+		s.w.Header().Set("Content-Type", "text/event-stream")
+		s.w.Header().Set("Cache-Control", "no-cache")
+		s.w.WriteHeader(http.StatusOK)
+		s.streaming = true
+	}
+	event := &SSEEvent{ID: s.session.transport.nextEventID(), Event: "message", Data: string(data)}
+	if err := writeSSEEvent(s.w, event); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	s.session.transport.metrics.RecordSSEEvent()
+	return nil
+}
+
+// finish completes the response with the handler's response, if any. Later
+// writes go to the session.
+func (s *requestStream) finish(response *Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done = true
+
+	if s.streaming {
+		if response != nil {
+			if err := s.writeEventLocked(response); err != nil {
+				log.Printf("Error writing response to session %s: %v", s.session.id, err)
+			}
+		}
+		return
+	}
+	// A request with no response, e.g. one the client cancelled.
+	if response == nil {
+		s.w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	s.w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(s.w).Encode(response); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// handleMCP serves the Streamable HTTP endpoint.
+func (t *HTTPTransport) handleMCP(w http.ResponseWriter, r *http.Request) {
+	if !allowedOrigin(t.config, r) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		t.handleMCPPost(w, r)
+	case http.MethodGet:
+		t.handleMCPGet(w, r)
+	case http.MethodDelete:
+		t.handleMCPDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleMCPPost handles a JSON-RPC message POSTed to the MCP endpoint.
+func (t *HTTPTransport) handleMCPPost(w http.ResponseWriter, r *http.Request) {
+	if !accepts(r, "application/json") && !accepts(r, "text/event-stream") {
+		http.Error(w, "Not acceptable: client must accept application/json or text/event-stream", http.StatusNotAcceptable)
+		return
+	}
+
+	var msg Message
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	handler := t.scopedHandler
+	if handler == nil && t.handler != nil {
+		handler = func(_ Transport, msg *Message) (*Message, error) { return t.handler(msg) }
+	}
+	if handler == nil {
+		http.Error(w, "Handler not set", http.StatusInternalServerError)
+		return
+	}
+
+	if response, retryAfter := admit(t.rateLimiter, t.metrics, clientID(r), &msg); response != nil {
+		writeRateLimited(w, response, retryAfter)
+		return
+	}
+
+	// initialize starts a session; everything else must belong to one.
+	var session *httpSession
+	initialize := msg.Method == "initialize"
+	if initialize {
+		var err error
+		if session, err = t.sessions.create(t, sessionOwner(r)); err != nil {
+			if errors.Is(err, errTooManySessions) {
+				http.Error(w, "Too many sessions", http.StatusServiceUnavailable)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(SessionIDHeader, session.id)
+	} else if session = t.sessionFor(w, r); session == nil {
+		return
+	}
+
+	// Responses to server-initiated requests and notifications are accepted
+	// with no body. A response only resolves requests sent to its session.
+	if IsResponse(&msg) {
+		if !session.pending.resolve(&msg) {
+			http.Error(w, "Unknown request ID", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if len(msg.ID) == 0 || string(msg.ID) == "null" {
+		if _, err := handler(session, &msg); err != nil {
+			log.Printf("Error handling notification %s: %v", msg.Method, err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	stream := &requestStream{w: w, session: session, principal: principalOf(r)}
+	if flusher, ok := w.(http.Flusher); ok && accepts(r, "text/event-stream") {
+		stream.flusher, stream.canStream = flusher, true
+	}
+
+	response, err := handler(stream, &msg)
+	if err != nil {
+		response = &Message{
+			JSONRPC: "2.0",
+			ID:      msg.ID,
+			Error:   &ErrorObj{Code: ErrCodeInternalError, Message: err.Error()},
+		}
+	}
+
+	if initialize {
+		if response == nil || response.Error != nil {
+			// No session without a successful initialization.
+			t.sessions.remove(session.id)
+			if !stream.streaming {
+				w.Header().Del(SessionIDHeader)
+			}
+		} else {
+			var result struct {
+				ProtocolVersion string `json:"protocolVersion"`
+			}
+			if json.Unmarshal(response.Result, &result) == nil {
+				session.setProtocolVersion(result.ProtocolVersion)
+			}
+			log.Printf("MCP session started: %s", session.id)
+		}
+	}
+
+	stream.finish(response)
+}
+
+// handleMCPGet opens an SSE stream for server messages to the session that
+// are not part of a request's response.
+func (t *HTTPTransport) handleMCPGet(w http.ResponseWriter, r *http.Request) {
+	if !accepts(r, "text/event-stream") {
+		http.Error(w, "Not acceptable: client must accept text/event-stream", http.StatusNotAcceptable)
+		return
+	}
+	session := t.sessionFor(w, r)
+	if session == nil {
+		return
+	}
+	t.serveSSE(w, r, session.streams, "")
+	// The session's idle time starts when its last stream closes.
+	session.touch()
+}
+
+// handleMCPDelete ends the session.
+func (t *HTTPTransport) handleMCPDelete(w http.ResponseWriter, r *http.Request) {
+	session := t.sessionFor(w, r)
+	if session == nil {
+		return
+	}
+	t.sessions.remove(session.id)
+	t.metrics.SetSSEConnections(t.sseConnections())
+	log.Printf("MCP session ended: %s", session.id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sessionFor returns the session identified by the request's Mcp-Session-Id
+// header, or writes the error response and returns nil.
+func (t *HTTPTransport) sessionFor(w http.ResponseWriter, r *http.Request) *httpSession {
+	id := r.Header.Get(SessionIDHeader)
+	if id == "" {
+		http.Error(w, "Missing "+SessionIDHeader+" header", http.StatusBadRequest)
+		return nil
+	}
+	session, ok := t.sessions.get(id)
+	if !ok || session.owner != sessionOwner(r) {
+		// Per MCP 2025-11-25, the client must start a new session. Sessions of
+		// other clients are not found, so their IDs can't be probed.
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return nil
+	}
+	if version, negotiated := r.Header.Get(ProtocolVersionHeader), session.negotiatedVersion(); version != "" && negotiated != "" && version != negotiated {
+		http.Error(w, fmt.Sprintf("Unsupported %s: %s (negotiated %s)", ProtocolVersionHeader, version, negotiated), http.StatusBadRequest)
+		return nil
+	}
+	return session
+}
+
+// sessionOwner identifies the client that owns the sessions it creates: its
+// authenticated identity, or "" without authentication.
+func sessionOwner(r *http.Request) string {
+	if principal := principalOf(r); principal != nil {
+		return principal.id
+	}
+	return ""
+}
+
+// accepts reports whether the request's Accept header permits mediaType. A
+// missing Accept header permits anything.
+func accepts(r *http.Request, mediaType string) bool {
+	header := r.Header.Get("Accept")
+	if header == "" {
+		return true
+	}
+	major, _, _ := strings.Cut(mediaType, "/")
+	for part := range strings.SplitSeq(header, ",") {
+		accepted, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if accepted == mediaType || accepted == "*/*" || accepted == major+"/*" {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeScoped is like Serve, but passes the handler a Transport scoped to
+// each message; see ScopedHandler. On the legacy endpoints, the scoped
+// transport's session is the HTTPTransport itself. Scoped transports of
+// requests implement Authenticated.
+func (t *HTTPTransport) ServeScoped(handler ScopedHandler) error {
+	t.scopedHandler = handler
+	return t.Serve(func(msg *Message) (*Message, error) {
+		return handler(t, msg)
+	})
+}
+
+// OnSessionClose registers fn to be called with the ID of each Streamable
+// HTTP session when it ends, so servers can release per-session state.
+func (t *HTTPTransport) OnSessionClose(fn func(id string)) {
+	t.sessions.mu.Lock()
+	defer t.sessions.mu.Unlock()
+	t.sessions.onClose = fn
+}
+
+// expireSessions ends idle Streamable HTTP sessions until the transport shuts
+// down.
+func (t *HTTPTransport) expireSessions() {
+	ticker := time.NewTicker(max(t.config.SessionIdleTimeout/2, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.shutdownCh:
+			return
+		case <-ticker.C:
+			t.sessions.expire()
+		}
+	}
+}
+
+// SessionCount returns the number of active Streamable HTTP sessions.
+func (t *HTTPTransport) SessionCount() int {
+	return t.sessions.Count()
+}
+
+// sseConnections counts the open SSE streams across the legacy endpoint and
+// every session.
+func (t *HTTPTransport) sseConnections() int {
+	n := t.clients.Count()
+	for _, session := range t.sessions.all() {
+		n += session.streams.Count()
+	}
+	return n
+}
@@ -0,0 +1,559 @@
+// Copyright 2025 Joseph Cumines
+//
+// Streamable HTTP transport unit tests
+
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	acceptBoth = "application/json, text/event-stream"
+	initBody   = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-11-25"}}`
+)
+
+// newStreamableServer starts a test server for a transport whose handler
+// answers initialize with protocol version 2025-11-25 and delegates other
+// methods to handle.
+func newStreamableServer(t *testing.T, cfg *HTTPTransportConfig, handle ScopedHandler) (*HTTPTransport, *httptest.Server) {
+	t.Helper()
+	tr := NewHTTPTransport(cfg)
+	tr.scopedHandler = func(scoped Transport, msg *Message) (*Message, error) {
+		if msg.Method == "initialize" {
+			return &Message{JSONRPC: "2.0", ID: msg.ID, Result: json.RawMessage(`{"protocolVersion":"2025-11-25"}`)}, nil
+		}
+		return handle(scoped, msg)
+	}
+	ts := httptest.NewServer(tr.server.Handler)
+	t.Cleanup(ts.Close)
+	return tr, ts
+}
+
+func echoResult(_ Transport, msg *Message) (*Message, error) {
+	if len(msg.ID) == 0 {
+		return nil, nil
+	}
+	return &Message{JSONRPC: "2.0", ID: msg.ID, Result: json.RawMessage(`{"ok":true}`)}, nil
+}
+
+// postMCP POSTs body to the MCP endpoint, with the session header if session
+// is not empty.
+func postMCP(t *testing.T, ts *httptest.Server, session, accept, body string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+MCPEndpoint, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if session != "" {
+		req.Header.Set(SessionIDHeader, session)
+	}
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// initSession initializes a session and returns its ID.
+func initSession(t *testing.T, ts *httptest.Server) string {
+	t.Helper()
+	resp := postMCP(t, ts, "", acceptBoth, initBody)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("initialize status = %d, want 200", resp.StatusCode)
+	}
+	session := resp.Header.Get(SessionIDHeader)
+	if session == "" {
+		t.Fatal("initialize response has no session ID")
+	}
+	return session
+}
+
+// readSSEMessages reads SSE "message" events from r until n are read.
+func readSSEMessages(t *testing.T, r io.Reader, n int) []*Message {
+	t.Helper()
+	var msgs []*Message
+	scanner := bufio.NewScanner(r)
+	for len(msgs) < n && scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var msg Message
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			t.Fatalf("invalid SSE data %q: %v", data, err)
+		}
+		msgs = append(msgs, &msg)
+	}
+	if len(msgs) < n {
+		t.Fatalf("read %d SSE messages, want %d (err: %v)", len(msgs), n, scanner.Err())
+	}
+	return msgs
+}
+
+func TestStreamable_SessionLifecycle(t *testing.T) {
+	tr, ts := newStreamableServer(t, nil, echoResult)
+	session := initSession(t, ts)
+	if got := tr.SessionCount(); got != 1 {
+		t.Errorf("SessionCount() = %d, want 1", got)
+	}
+
+	resp := postMCP(t, ts, session, acceptBoth, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("status = %d, content type = %q, want 200 JSON", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	var msg Message
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil || string(msg.ID) != "2" {
+		t.Errorf("unexpected response %+v: %v", msg, err)
+	}
+
+	if resp := postMCP(t, ts, session, acceptBoth, `{"jsonrpc":"2.0","method":"notifications/initialized"}`); resp.StatusCode != http.StatusAccepted {
+		t.Errorf("notification status = %d, want 202", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+MCPEndpoint, nil)
+	req.Header.Set(SessionIDHeader, session)
+	del, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	del.Body.Close()
+	if del.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE status = %d, want 204", del.StatusCode)
+	}
+	if resp := postMCP(t, ts, session, acceptBoth, `{"jsonrpc":"2.0","id":3,"method":"tools/list"}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status after DELETE = %d, want 404", resp.StatusCode)
+	}
+	if got := tr.SessionCount(); got != 0 {
+		t.Errorf("SessionCount() after DELETE = %d, want 0", got)
+	}
+}
+
+func TestStreamable_RejectsInvalidRequests(t *testing.T) {
+	_, ts := newStreamableServer(t, &HTTPTransportConfig{CORSOrigin: "https://app.example.com"}, echoResult)
+	session := initSession(t, ts)
+	list := `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`
+
+	for _, tc := range []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{"missing session", map[string]string{"Accept": acceptBoth}, http.StatusBadRequest},
+		{"unknown session", map[string]string{"Accept": acceptBoth, SessionIDHeader: "bogus"}, http.StatusNotFound},
+		{"protocol version mismatch", map[string]string{"Accept": acceptBoth, SessionIDHeader: session, ProtocolVersionHeader: "2024-11-05"}, http.StatusBadRequest},
+		{"not acceptable", map[string]string{"Accept": "text/html", SessionIDHeader: session}, http.StatusNotAcceptable},
+		{"foreign origin", map[string]string{"Accept": acceptBoth, SessionIDHeader: session, "Origin": "https://evil.example.com"}, http.StatusForbidden},
+		{"matching protocol version", map[string]string{"Accept": acceptBoth, SessionIDHeader: session, ProtocolVersionHeader: "2025-11-25"}, http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, ts.URL+MCPEndpoint, strings.NewReader(list))
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			resp, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tc.want)
+			}
+		})
+	}
+}
+
+func TestStreamable_FailedInitializeHasNoSession(t *testing.T) {
+	tr := NewHTTPTransport(nil)
+	tr.scopedHandler = func(_ Transport, msg *Message) (*Message, error) {
+		return &Message{JSONRPC: "2.0", ID: msg.ID, Error: &ErrorObj{Code: ErrCodeInvalidParams, Message: "bad"}}, nil
+	}
+	ts := httptest.NewServer(tr.server.Handler)
+	defer ts.Close()
+
+	resp := postMCP(t, ts, "", acceptBoth, initBody)
+	if resp.Header.Get(SessionIDHeader) != "" || tr.SessionCount() != 0 {
+		t.Errorf("failed initialize created session %q", resp.Header.Get(SessionIDHeader))
+	}
+}
+
+func TestStreamable_StreamsMessagesBeforeResponse(t *testing.T) {
+	_, ts := newStreamableServer(t, nil, func(scoped Transport, msg *Message) (*Message, error) {
+		if err := scoped.WriteMessage(&Message{JSONRPC: "2.0", Method: "notifications/progress", Params: json.RawMessage(`{"progress":1}`)}); err != nil {
+			return nil, err
+		}
+		return echoResult(scoped, msg)
+	})
+	session := initSession(t, ts)
+
+	resp := postMCP(t, ts, session, acceptBoth, `{"jsonrpc":"2.0","id":2,"method":"tools/call"}`)
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+	msgs := readSSEMessages(t, resp.Body, 2)
+	if msgs[0].Method != "notifications/progress" || string(msgs[1].ID) != "2" {
+		t.Errorf("unexpected stream: %+v, %+v", msgs[0], msgs[1])
+	}
+
+	// A client that only accepts JSON gets the response alone; the
+	// notification goes to the session instead.
+	resp = postMCP(t, ts, session, "application/json", `{"jsonrpc":"2.0","id":3,"method":"tools/call"}`)
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+}
+
+func TestStreamable_RequestOnResponseStream(t *testing.T) {
+	_, ts := newStreamableServer(t, nil, func(scoped Transport, msg *Message) (*Message, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		reply, err := scoped.(Requester).Request(ctx, "elicitation/create", map[string]any{"message": "ok?"})
+		if err != nil {
+			return nil, err
+		}
+		return &Message{JSONRPC: "2.0", ID: msg.ID, Result: reply.Result}, nil
+	})
+	session := initSession(t, ts)
+
+	resp := postMCP(t, ts, session, acceptBoth, `{"jsonrpc":"2.0","id":2,"method":"tools/call"}`)
+	reader := bufio.NewReader(resp.Body)
+	request := readSSEMessages(t, reader, 1)[0]
+	if request.Method != "elicitation/create" {
+		t.Fatalf("unexpected request: %+v", request)
+	}
+
+	answer, _ := json.Marshal(&Message{JSONRPC: "2.0", ID: request.ID, Result: json.RawMessage(`{"action":"accept"}`)})
+	if r := postMCP(t, ts, session, acceptBoth, string(answer)); r.StatusCode != http.StatusAccepted {
+		t.Fatalf("response status = %d, want 202", r.StatusCode)
+	}
+
+	final := readSSEMessages(t, reader, 1)[0]
+	if string(final.ID) != "2" || string(final.Result) != `{"action":"accept"}` {
+		t.Errorf("unexpected final response: %+v", final)
+	}
+}
+
+func TestStreamable_ResponsesResolveOnlyTheirSession(t *testing.T) {
+	_, ts := newStreamableServer(t, nil, func(scoped Transport, msg *Message) (*Message, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		reply, err := scoped.(Requester).Request(ctx, "elicitation/create", map[string]any{"message": "ok?"})
+		if err != nil {
+			return nil, err
+		}
+		return &Message{JSONRPC: "2.0", ID: msg.ID, Result: reply.Result}, nil
+	})
+	a, b := initSession(t, ts), initSession(t, ts)
+
+	resp := postMCP(t, ts, a, acceptBoth, `{"jsonrpc":"2.0","id":2,"method":"tools/call"}`)
+	reader := bufio.NewReader(resp.Body)
+	request := readSSEMessages(t, reader, 1)[0]
+
+	// Another session can't answer session A's request, even with its ID.
+	forged, _ := json.Marshal(&Message{JSONRPC: "2.0", ID: request.ID, Result: json.RawMessage(`{"action":"accept"}`)})
+	if r := postMCP(t, ts, b, acceptBoth, string(forged)); r.StatusCode != http.StatusBadRequest {
+		t.Fatalf("response from another session status = %d, want 400", r.StatusCode)
+	}
+
+	answer, _ := json.Marshal(&Message{JSONRPC: "2.0", ID: request.ID, Result: json.RawMessage(`{"action":"decline"}`)})
+	if r := postMCP(t, ts, a, acceptBoth, string(answer)); r.StatusCode != http.StatusAccepted {
+		t.Fatalf("response status = %d, want 202", r.StatusCode)
+	}
+	if final := readSSEMessages(t, reader, 1)[0]; string(final.Result) != `{"action":"decline"}` {
+		t.Errorf("unexpected final response: %+v", final)
+	}
+}
+
+func TestStreamable_GetStreamReceivesSessionMessages(t *testing.T) {
+	tr, ts := newStreamableServer(t, nil, echoResult)
+	a, b := initSession(t, ts), initSession(t, ts)
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+MCPEndpoint, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set(SessionIDHeader, a)
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	sessionA, _ := tr.sessions.get(a)
+	sessionB, _ := tr.sessions.get(b)
+	deadline := time.Now().Add(2 * time.Second)
+	for sessionA.streams.Count() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("GET stream not registered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Messages for session B never reach session A's stream.
+	if err := sessionB.WriteMessage(&Message{JSONRPC: "2.0", Method: "notifications/b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sessionA.WriteMessage(&Message{JSONRPC: "2.0", Method: "notifications/a"}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := readSSEMessages(t, resp.Body, 1)[0]; msg.Method != "notifications/a" {
+		t.Errorf("session A received %q", msg.Method)
+	}
+}
+
+func TestStreamable_LegacyEndpointsBehindFlag(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		tr := NewHTTPTransport(&HTTPTransportConfig{LegacySSE: legacy})
+		tr.handler = func(msg *Message) (*Message, error) {
+			return &Message{JSONRPC: "2.0", ID: msg.ID, Result: json.RawMessage(`{}`)}, nil
+		}
+		req := httptest.NewRequest(http.MethodPost, "/message", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+		w := httptest.NewRecorder()
+		tr.server.Handler.ServeHTTP(w, req)
+		if got := w.Code == http.StatusOK; got != legacy {
+			t.Errorf("LegacySSE=%v: /message status = %d", legacy, w.Code)
+		}
+	}
+}
+
+func TestClientRegistry_Send(t *testing.T) {
+	r := NewClientRegistry()
+	r.Send(&SSEEvent{ID: "1", Event: "message", Data: "stored"})
+	older := r.Add("")
+	time.Sleep(time.Millisecond)
+	newer := r.Add("")
+
+	r.Send(&SSEEvent{ID: "2", Event: "message", Data: "once"})
+	select {
+	case event := <-newer.ResponseChan:
+		if event.ID != "2" {
+			t.Errorf("newest client received event %s, want 2", event.ID)
+		}
+	default:
+		t.Error("newest client did not receive the event")
+	}
+	select {
+	case event := <-older.ResponseChan:
+		t.Errorf("older client received event %s; events go to one stream", event.ID)
+	default:
+	}
+	if replay := r.eventStore.GetSince("1"); len(replay) != 1 || replay[0].ID != "2" {
+		t.Errorf("events not stored for replay: %v", replay)
+	}
+}
+
+func TestStreamable_ClientRateLimitsIsolated(t *testing.T) {
+	tr, _ := newStreamableServer(t, &HTTPTransportConfig{RateLimit: 1, ToolCosts: map[string]float64{"screenshot": 2}}, echoResult)
+	post := func(remoteAddr, session, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, MCPEndpoint, strings.NewReader(body))
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", acceptBoth)
+		req.Header.Set(SessionIDHeader, session)
+		w := httptest.NewRecorder()
+		tr.server.Handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// Each client's bucket holds two requests: initialize, then one more.
+	a := post("10.0.0.1:1234", "", initBody).Header().Get(SessionIDHeader)
+	if w := post("10.0.0.1:1234", a, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`); w.Code != http.StatusOK || strings.Contains(w.Body.String(), "error") {
+		t.Fatalf("client a: status = %d, body = %s", w.Code, w.Body)
+	}
+	w := post("10.0.0.1:1234", a, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"screenshot"}}`)
+	var msg Message
+	if err := json.Unmarshal(w.Body.Bytes(), &msg); err != nil {
+		t.Fatalf("client a: invalid response %q: %v", w.Body, err)
+	}
+	if msg.Error == nil || msg.Error.Code != ErrCodeRateLimited || !strings.Contains(string(msg.Error.Data), "retryAfterMs") {
+		t.Errorf("client a: response = %s, want rate limit error with retry hint", w.Body)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("client a: rate limit error has no Retry-After header")
+	}
+
+	// The screenshot costs 2, as much as b's whole bucket.
+	b := post("10.0.0.2:1234", "", initBody).Header().Get(SessionIDHeader)
+	if b == "" {
+		t.Fatal("client b: initialize rate limited by client a")
+	}
+	if w := post("10.0.0.2:1234", b, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`); strings.Contains(w.Body.String(), "error") {
+		t.Errorf("client b: response = %s, want result", w.Body)
+	}
+}
+
+func TestStreamable_OnSessionClose(t *testing.T) {
+	tr, ts := newStreamableServer(t, nil, echoResult)
+	closed := make(chan string, 2)
+	tr.OnSessionClose(func(id string) { closed <- id })
+	session := initSession(t, ts)
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+MCPEndpoint, nil)
+	req.Header.Set(SessionIDHeader, session)
+	del, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	del.Body.Close()
+	select {
+	case id := <-closed:
+		if id != session {
+			t.Errorf("closed session = %q, want %q", id, session)
+		}
+	default:
+		t.Fatal("OnSessionClose callback not called on DELETE")
+	}
+
+	// Closing the transport ends the remaining sessions.
+	other := initSession(t, ts)
+	tr.Close()
+	select {
+	case id := <-closed:
+		if id != other {
+			t.Errorf("closed session = %q, want %q", id, other)
+		}
+	default:
+		t.Error("OnSessionClose callback not called on Close")
+	}
+}
+
+func TestStreamable_SendEventTargetsSession(t *testing.T) {
+	tr, ts := newStreamableServer(t, nil, echoResult)
+	a, b := initSession(t, ts), initSession(t, ts)
+	sessionA, _ := tr.sessions.get(a)
+	sessionB, _ := tr.sessions.get(b)
+	streamA, streamB := sessionA.streams.Add(""), sessionB.streams.Add("")
+
+	sessionA.SendEvent("observation", `{"n":1}`)
+	select {
+	case event := <-streamA.ResponseChan:
+		if event.Event != "observation" || event.Data != `{"n":1}` {
+			t.Errorf("unexpected event %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("session a did not receive its event")
+	}
+	select {
+	case event := <-streamB.ResponseChan:
+		t.Errorf("session b received %+v", event)
+	default:
+	}
+}
+
+func TestStreamable_SessionLimits(t *testing.T) {
+	tr, ts := newStreamableServer(t, &HTTPTransportConfig{MaxSessions: 2, SessionIdleTimeout: time.Hour}, echoResult)
+	closed := make(chan string, 1)
+	tr.OnSessionClose(func(id string) { closed <- id })
+	a := initSession(t, ts)
+	initSession(t, ts)
+
+	if resp := postMCP(t, ts, "", acceptBoth, initBody); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("initialize over the limit status = %d, want 503", resp.StatusCode)
+	}
+
+	// An idle session is ended, making room for a new one.
+	sessionA, _ := tr.sessions.get(a)
+	sessionA.mu.Lock()
+	sessionA.lastActive = time.Now().Add(-2 * time.Hour)
+	sessionA.mu.Unlock()
+	initSession(t, ts)
+	select {
+	case id := <-closed:
+		if id != a {
+			t.Errorf("expired session = %q, want %q", id, a)
+		}
+	default:
+		t.Fatal("idle session was not ended")
+	}
+	if resp := postMCP(t, ts, a, acceptBoth, `{"jsonrpc":"2.0","id":2,"method":"ping"}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expired session status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestStreamable_SessionBoundToClient(t *testing.T) {
+	_, ts := newStreamableServer(t, &HTTPTransportConfig{APIKeys: []APIKey{
+		{Name: "alice", Key: "alice-secret"},
+		{Name: "bob", Key: "bob-secret"},
+	}}, echoResult)
+	post := func(key, session, body string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+MCPEndpoint, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+key)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", acceptBoth)
+		if session != "" {
+			req.Header.Set(SessionIDHeader, session)
+		}
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("POST failed: %v", err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	session := post("alice-secret", "", initBody).Header.Get(SessionIDHeader)
+	if session == "" {
+		t.Fatal("initialize response has no session ID")
+	}
+	ping := `{"jsonrpc":"2.0","id":2,"method":"ping"}`
+	if resp := post("bob-secret", session, ping); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("another client's session status = %d, want 404", resp.StatusCode)
+	}
+	if resp := post("alice-secret", session, ping); resp.StatusCode != http.StatusOK {
+		t.Errorf("own session status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestStreamable_RequestsDuringInitialize(t *testing.T) {
+	tr := NewHTTPTransport(nil)
+	tr.scopedHandler = func(scoped Transport, msg *Message) (*Message, error) {
+		if msg.Method == "initialize" {
+			// Streaming sends the session ID before initialize completes, so
+			// the client's next requests can arrive while it is still running.
+			if err := scoped.WriteMessage(&Message{JSONRPC: "2.0", Method: "notifications/message", Params: json.RawMessage(`{}`)}); err != nil {
+				return nil, err
+			}
+			time.Sleep(100 * time.Millisecond)
+			return &Message{JSONRPC: "2.0", ID: msg.ID, Result: json.RawMessage(`{"protocolVersion":"2025-11-25"}`)}, nil
+		}
+		return echoResult(scoped, msg)
+	}
+	ts := httptest.NewServer(tr.server.Handler)
+	t.Cleanup(ts.Close)
+
+	resp := postMCP(t, ts, "", acceptBoth, initBody)
+	session := resp.Header.Get(SessionIDHeader)
+	if session == "" {
+		t.Fatal("initialize response has no session ID")
+	}
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			req, _ := http.NewRequest(http.MethodPost, ts.URL+MCPEndpoint, strings.NewReader(`{"jsonrpc":"2.0","id":2,"method":"ping"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept", acceptBoth)
+			req.Header.Set(SessionIDHeader, session)
+			req.Header.Set(ProtocolVersionHeader, "2025-11-25")
+			r, err := ts.Client().Do(req)
+			if err != nil {
+				t.Errorf("POST failed: %v", err)
+				return
+			}
+			r.Body.Close()
+			if r.StatusCode != http.StatusOK {
+				t.Errorf("status = %d, want 200", r.StatusCode)
+			}
+		})
+	}
+	readSSEMessages(t, resp.Body, 2)
+	wg.Wait()
+}
@@ -11,14 +11,17 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"io"
 	"math/big"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -1111,3 +1114,264 @@ func TestTLSServer_WithAuthentication(t *testing.T) {
 		}
 	})
 }
+
+// =============================================================================
+// Mutual TLS Tests
+// =============================================================================
+
+// testCA is a certificate authority for mutual TLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a certificate for subject signed by the CA, for a client, or
+// for a server at 127.0.0.1, and its PEM encoding and key.
+func (ca *testCA) issue(t *testing.T, subject pkix.Name, server bool) (tls.Certificate, []byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if server {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, certPEM, keyPEM
+}
+
+// startMutualTLSServer serves an HTTP transport requiring client certificates
+// signed by ca, whose handler answers initialize with the request's principal.
+// It returns the transport, its address, and the pool to verify it by.
+func startMutualTLSServer(t *testing.T, ca *testCA, cfg *HTTPTransportConfig) (*HTTPTransport, string, *x509.CertPool) {
+	t.Helper()
+	_, certPEM, keyPEM := ca.issue(t, pkix.Name{CommonName: "localhost"}, true)
+	certPath, keyPath, _ := writeCertFiles(t, certPEM, keyPEM)
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caPath, ca.pem, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to create listener: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	cfg.Address, cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile = addr, certPath, keyPath, caPath
+	tr := NewHTTPTransport(cfg)
+	t.Cleanup(func() { tr.Close() })
+	if !tr.IsMutualTLSEnabled() {
+		t.Fatal("IsMutualTLSEnabled() = false with a client CA")
+	}
+	go tr.ServeScoped(func(scoped Transport, msg *Message) (*Message, error) {
+		var described string
+		if p := scoped.(Authenticated).Principal(); p != nil {
+			described = p.Method + ":" + p.Name + ":" + p.CertSubject
+		}
+		result, _ := json.Marshal(map[string]string{"protocolVersion": "2025-11-25", "principal": described})
+		return &Message{JSONRPC: "2.0", ID: msg.ID, Result: result}, nil
+	})
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	for range 20 {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return tr, addr, roots
+}
+
+// mutualTLSInitialize sends initialize with the client certificates and
+// authorization, and returns the principal the server saw.
+func mutualTLSInitialize(addr string, roots *x509.CertPool, authorization string, certs ...tls.Certificate) (string, error) {
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}},
+		Timeout:   5 * time.Second,
+	}
+	req, _ := http.NewRequest(http.MethodPost, "https://"+addr+MCPEndpoint, strings.NewReader(initBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", acceptBoth)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var msg Message
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		return "", fmt.Errorf("status %d: %w", resp.StatusCode, err)
+	}
+	var result struct{ Principal string }
+	err = json.Unmarshal(msg.Result, &result)
+	return result.Principal, err
+}
+
+// TestMutualTLS_ClientCertificates verifies that only clients with
+// certificates signed by the client CA can connect, and that each is
+// identified and rate limited by its certificate.
+func TestMutualTLS_ClientCertificates(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	tr, addr, roots := startMutualTLSServer(t, ca, &HTTPTransportConfig{RateLimit: 100})
+
+	t.Run("no certificate", func(t *testing.T) {
+		if _, err := mutualTLSInitialize(addr, roots, ""); err == nil {
+			t.Error("request without a client certificate succeeded")
+		}
+	})
+	t.Run("certificate from another CA", func(t *testing.T) {
+		other, _, _ := newTestCA(t, "Other CA").issue(t, pkix.Name{CommonName: "mallory"}, false)
+		if _, err := mutualTLSInitialize(addr, roots, "", other); err == nil {
+			t.Error("request with an untrusted client certificate succeeded")
+		}
+	})
+	t.Run("valid certificates", func(t *testing.T) {
+		for _, name := range []string{"alice", "bob"} {
+			cert, _, _ := ca.issue(t, pkix.Name{CommonName: name, Organization: []string{"Example"}}, false)
+			principal, err := mutualTLSInitialize(addr, roots, "", cert)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "mtls:" + name + ":CN=" + name + ",O=Example"; principal != want {
+				t.Errorf("principal = %q, want %q", principal, want)
+			}
+		}
+		if n := tr.rateLimiter.Clients(); n != 2 {
+			t.Errorf("%d clients rate limited, want one per certificate", n)
+		}
+	})
+}
+
+// TestMutualTLS_CertificateScopes verifies that clients identified by their
+// certificate alone get the scopes of its subject, and no scopes otherwise.
+func TestMutualTLS_CertificateScopes(t *testing.T) {
+	scopes := map[string][]string{"CN=alice,O=Example": {"read", "input"}}
+	var got *Principal
+	handler := clientCertMiddleware(scopes, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = principalOf(r)
+	}))
+	for _, tt := range []struct {
+		subject pkix.Name
+		want    []string
+	}{
+		{pkix.Name{CommonName: "alice", Organization: []string{"Example"}}, []string{"read", "input"}},
+		{pkix.Name{CommonName: "bob", Organization: []string{"Example"}}, []string{}},
+	} {
+		req := httptest.NewRequest(http.MethodPost, MCPEndpoint, nil)
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: tt.subject}}}}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if got == nil || got.Scopes == nil || !slices.Equal(got.Scopes, tt.want) {
+			t.Errorf("%s: principal = %+v, want scopes %v", tt.subject, got, tt.want)
+		}
+		if got != nil && got.HasScope("run") {
+			t.Errorf("%s: certificate granted the run scope", tt.subject)
+		}
+	}
+}
+
+// TestMutualTLS_WithAPIKey verifies that when API keys are also required, the
+// key identifies the client and the certificate subject is added to it.
+func TestMutualTLS_WithAPIKey(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	_, addr, roots := startMutualTLSServer(t, ca, &HTTPTransportConfig{APIKey: "secret"})
+	cert, _, _ := ca.issue(t, pkix.Name{CommonName: "alice"}, false)
+
+	if _, err := mutualTLSInitialize(addr, roots, "", cert); err == nil {
+		t.Error("request with a certificate but no API key succeeded")
+	}
+	principal, err := mutualTLSInitialize(addr, roots, "Bearer secret", cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "api_key:default:CN=alice"; principal != want {
+		t.Errorf("principal = %q, want %q", principal, want)
+	}
+}
+
+// TestMutualTLS_RequiresTLS verifies that a client CA without a server
+// certificate is an error, rather than silently accepting any client.
+func TestMutualTLS_RequiresTLS(t *testing.T) {
+	tr := NewHTTPTransport(&HTTPTransportConfig{Address: "127.0.0.1:0", TLSClientCAFile: "/nonexistent/ca.pem"})
+	defer tr.Close()
+	if tr.IsMutualTLSEnabled() {
+		t.Error("IsMutualTLSEnabled() = true without a server certificate")
+	}
+	if err := tr.Serve(func(*Message) (*Message, error) { return nil, nil }); err == nil || !strings.Contains(err.Error(), "requires a TLS certificate") {
+		t.Errorf("Serve() error = %v, want a TLS required error", err)
+	}
+}
+
+// TestMutualTLS_InvalidCABundle verifies that a CA bundle without
+// certificates is rejected at startup.
+func TestMutualTLS_InvalidCABundle(t *testing.T) {
+	certPEM, keyPEM, err := generateSelfSignedCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath, keyPath, _ := writeCertFiles(t, certPEM, keyPEM)
+	tr := NewHTTPTransport(&HTTPTransportConfig{Address: "127.0.0.1:0", TLSCertFile: certPath, TLSKeyFile: keyPath, TLSClientCAFile: keyPath})
+	defer tr.Close()
+	if err := tr.Serve(func(*Message) (*Message, error) { return nil, nil }); err == nil || !strings.Contains(err.Error(), "no certificates found") {
+		t.Errorf("Serve() error = %v, want an invalid CA bundle error", err)
+	}
+}
@@ -1,7 +1,7 @@
 // Copyright 2025 Joseph Cumines
 
 // Package transport provides MCP message transport interfaces and implementations
-// for JSON-RPC 2.0 communication over stdio and HTTP/SSE.
+// for JSON-RPC 2.0 communication over stdio, HTTP/SSE and WebSocket.
 package transport
 
 // JSON-RPC 2.0 standard error codes.
@@ -21,6 +21,14 @@ const (
 
 	// ErrCodeInternalError indicates an internal JSON-RPC error.
 	ErrCodeInternalError = -32603
+
+	// ErrCodeRateLimited indicates the client exceeded its rate limit. It is
+	// in the range reserved for implementation-defined server errors.
+	ErrCodeRateLimited = -32000
+
+	// ErrCodeForbidden indicates the client's credentials do not permit the
+	// request. It is in the range reserved for implementation-defined server errors.
+	ErrCodeForbidden = -32001
 )
 
 // Transport defines the interface for MCP message transport.
@@ -29,9 +37,10 @@ const (
 // The transport manages the lifecycle of connections and handles serialization
 // of JSON-RPC 2.0 messages.
 //
-// There are two main implementations:
+// There are three main implementations:
 //   - StdioTransport: Uses stdin/stdout for communication (default)
 //   - HTTPTransport: Uses HTTP POST for requests and SSE for responses
+//   - WebSocketTransport: Uses a WebSocket connection per client
 //
 // Error handling:
 //   - io.EOF indicates the transport was closed by the peer
@@ -63,5 +72,55 @@ type Transport interface {
 	IsClosed() bool
 }
 
-// Ensure StdioTransport implements Transport interface
-var _ Transport = (*StdioTransport)(nil)
+// SessionTransport is implemented by the transports a multi-client transport
+// passes to its ScopedHandler. Servers key per-client state, such as resource
+// subscriptions, by SessionID.
+type SessionTransport interface {
+	Transport
+
+	// SessionID identifies the client session.
+	SessionID() string
+
+	// Session returns the transport for server-initiated messages to the
+	// session. Unlike a transport scoped to one request, it remains valid
+	// until the session ends.
+	Session() Transport
+}
+
+// EventSender is implemented by transports that can push custom SSE events,
+// such as observation events, to their clients.
+type EventSender interface {
+	// SendEvent sends an event of the given type to the transport's clients.
+	SendEvent(eventType string, data string)
+}
+
+// Ensure the transports implement Transport interface
+var (
+	_ Transport = (*StdioTransport)(nil)
+	_ Transport = (*HTTPTransport)(nil)
+	_ Transport = (*WebSocketTransport)(nil)
+)
+
+// Ensure the transports can send server-initiated requests
+var (
+	_ Requester = (*StdioTransport)(nil)
+	_ Requester = (*wsConn)(nil)
+)
+
+// Ensure Streamable HTTP sessions and WebSocket connections identify their client
+var (
+	_ SessionTransport = (*httpSession)(nil)
+	_ SessionTransport = (*requestStream)(nil)
+	_ SessionTransport = (*wsConn)(nil)
+	_ EventSender      = (*httpSession)(nil)
+	_ EventSender      = (*HTTPTransport)(nil)
+	_ EventSender      = (*wsConn)(nil)
+)
+
+// Ensure the transports scoped to requests identify their authenticated client
+var (
+	_ SessionTransport = (*legacyRequest)(nil)
+	_ Authenticated    = (*legacyRequest)(nil)
+	_ Authenticated    = (*requestStream)(nil)
+	_ Authenticated    = (*wsConn)(nil)
+)
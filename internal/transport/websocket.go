@@ -0,0 +1,823 @@
+// Copyright 2025 Joseph Cumines
+//
+// WebSocket transport for JSON-RPC 2.0 communication
+
+package transport
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WebSocket transport constants
+const (
+	// WebSocketEndpoint is the path clients open WebSocket connections on.
+	WebSocketEndpoint = "/ws"
+
+	// WebSocketSubprotocol is the Sec-WebSocket-Protocol selected when the
+	// client offers it. Clients need not offer a subprotocol.
+	WebSocketSubprotocol = "mcp"
+
+	// wsMaxMessageSize is the largest message accepted from a client.
+	wsMaxMessageSize = 16 << 20
+	// wsWriteTimeout bounds each frame write, so a stalled client can't block
+	// writers to its connection indefinitely.
+	wsWriteTimeout = 10 * time.Second
+	// wsPongWaitIntervals is how many heartbeat intervals the client may go
+	// without sending a frame, such as the pong to a ping, before the
+	// connection is closed as dead.
+	wsPongWaitIntervals = 2
+	// wsAcceptGUID is appended to the client's key to compute
+	// Sec-WebSocket-Accept, per RFC 6455 section 4.2.2.
+	wsAcceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+)
+
+// WebSocket opcodes (RFC 6455 section 5.2) and close codes (section 7.4.1).
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+
+	wsCloseNormal          = 1000
+	wsCloseGoingAway       = 1001
+	wsCloseProtocolError   = 1002
+	wsCloseUnsupportedData = 1003
+	wsCloseNoStatus        = 1005
+	wsClosePolicyViolation = 1008
+	wsCloseTooBig          = 1009
+)
+
+// wsCloseError is a protocol violation by the client, which closes the
+// connection with code.
+type wsCloseError struct {
+	reason string
+	code   int
+}
+
+func (e *wsCloseError) Error() string {
+	return fmt.Sprintf("websocket: %s (close %d)", e.reason, e.code)
+}
+
+// WebSocketTransport implements JSON-RPC 2.0 over WebSocket (RFC 6455) at
+// /ws, with GET /health and GET /metrics as served by HTTPTransport. It
+// shares HTTPTransport's configuration and its CORS, auth and rate limit
+// middleware, which apply to the upgrade request.
+//
+// Each connection carries one client session. Its messages are passed to the
+// ScopedHandler with a transport for the connection, and the responses
+// written back on it; server-initiated messages written to that transport go
+// to that client only. Each message is a single JSON-RPC message in a text
+// frame.
+type WebSocketTransport struct {
+	config      *HTTPTransportConfig
+	server      *http.Server
+	handler     ScopedHandler
+	conns       map[string]*wsConn
+	onClose     func(id string)
+	metrics     *MetricsRegistry
+	rateLimiter *ClientRateLimiter
+	apiKeys     *apiKeyStore
+	shutdownCh  chan struct{}
+	mu          sync.Mutex
+	closed      atomic.Bool
+}
+
+// NewWebSocketTransport creates a new WebSocket transport with the given
+// configuration. If config is nil, default configuration is used. The
+// transport sets up routes for the /ws, /health, and /metrics endpoints.
+// HeartbeatInterval is the interval between pings to each client.
+func NewWebSocketTransport(config *HTTPTransportConfig) *WebSocketTransport {
+	config = withHTTPDefaults(config)
+
+	t := &WebSocketTransport{
+		config:      config,
+		conns:       make(map[string]*wsConn),
+		metrics:     NewMetricsRegistry(),
+		rateLimiter: NewClientRateLimiter(config.RateLimit, config.ToolCosts),
+		apiKeys:     newAPIKeyStore(config),
+		shutdownCh:  make(chan struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(WebSocketEndpoint, t.handleWebSocket)
+	mux.HandleFunc("/health", t.handleHealth)
+	mux.HandleFunc("/metrics", t.handleMetrics)
+	if config.OAuth != nil {
+		mux.HandleFunc(ProtectedResourceMetadataPath, config.OAuth.handleProtectedResourceMetadata)
+	}
+
+	// Build middleware chain: CORS wrapper -> Rate limit wrapper -> Client cert wrapper -> Auth wrapper -> mux
+	// Auth runs first, so requests are rate limited by authenticated client.
+	var handler http.Handler = mux
+	handler = corsMiddleware(config, handler)
+	if t.rateLimiter != nil {
+		handler = clientRateLimitMiddleware(t.rateLimiter, t.metrics, handler)
+	}
+	if config.TLSClientCAFile != "" {
+		handler = clientCertMiddleware(config.ClientCertScopes, handler)
+	}
+	if t.apiKeys != nil || config.OAuth != nil {
+		handler = authMiddleware(t.apiKeys, config.OAuth, handler)
+	}
+
+	t.server = &http.Server{
+		Handler:      handler,
+		ReadTimeout:  config.ReadTimeout,
+		WriteTimeout: config.WriteTimeout,
+	}
+
+	return t
+}
+
+// handleWebSocket upgrades GET /ws to a WebSocket connection and serves it
+// until it closes.
+func (t *WebSocketTransport) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !allowedOrigin(t.config, r) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") {
+		w.Header().Set("Upgrade", "websocket")
+		http.Error(w, "WebSocket upgrade required", http.StatusUpgradeRequired)
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported WebSocket version", http.StatusUpgradeRequired)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "Invalid Sec-WebSocket-Key", http.StatusBadRequest)
+		return
+	}
+	if t.closed.Load() {
+		http.Error(w, "Server shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	netConn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "WebSocket not supported", http.StatusInternalServerError)
+		return
+	}
+	// The server's deadlines were for the upgrade request.
+	if err := netConn.SetDeadline(time.Time{}); err != nil {
+		log.Printf("WebSocket: failed to clear deadline: %v", err)
+	}
+
+	accept := sha1.Sum([]byte(key + wsAcceptGUID))
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(accept[:]) + "\r\n"
+	if headerHasToken(r.Header, "Sec-WebSocket-Protocol", WebSocketSubprotocol) {
+		response += "Sec-WebSocket-Protocol: " + WebSocketSubprotocol + "\r\n"
+	}
+	if _, err := rw.WriteString(response + "\r\n"); err != nil || rw.Flush() != nil {
+		netConn.Close()
+		return
+	}
+
+	conn, err := t.register(netConn, rw.Reader, clientID(r), principalOf(r))
+	if err != nil {
+		log.Printf("WebSocket: %v", err)
+		netConn.Close()
+		return
+	}
+	t.serveConn(conn)
+}
+
+// serveConn reads messages from conn until it closes. Requests are handled
+// concurrently by up to MaxConcurrency workers, each response written as it
+// completes; the rest wait in a queue while reading goes on. Notifications,
+// such as notifications/cancelled, are handled inline, and responses are
+// passed to the pending requests by ReadMessage, so neither is ever held up
+// behind the requests they affect.
+func (t *WebSocketTransport) serveConn(conn *wsConn) {
+	var wg sync.WaitGroup
+	defer func() {
+		wg.Wait()
+		t.unregister(conn)
+	}()
+	go conn.heartbeat(t.config.HeartbeatInterval, t.shutdownCh)
+
+	var (
+		mu      sync.Mutex
+		queue   []*Message
+		running int
+	)
+	// work handles msg, then the queued requests, until there are none left
+	// or the connection has closed.
+	work := func(msg *Message) {
+		for {
+			t.handle(conn, msg)
+			mu.Lock()
+			if len(queue) == 0 || conn.IsClosed() {
+				queue = nil
+				running--
+				mu.Unlock()
+				return
+			}
+			msg = queue[0]
+			queue[0] = nil
+			queue = queue[1:]
+			mu.Unlock()
+		}
+	}
+
+	for {
+		msg, err := conn.ReadMessage()
+		if err != nil {
+			if conn.IsClosed() {
+				if err != io.EOF {
+					log.Printf("WebSocket client %s: %v", conn.id, err)
+				}
+				return
+			}
+			// The connection is intact; only this message was malformed.
+			t.respond(conn, &Message{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &ErrorObj{Code: ErrCodeParseError, Message: err.Error()}})
+			continue
+		}
+		if len(msg.ID) == 0 {
+			t.handle(conn, msg)
+			continue
+		}
+		if response, _ := admit(t.rateLimiter, t.metrics, conn.client, msg); response != nil {
+			t.respond(conn, response)
+			continue
+		}
+		mu.Lock()
+		if running == t.config.MaxConcurrency {
+			queue = append(queue, msg)
+			mu.Unlock()
+			continue
+		}
+		running++
+		mu.Unlock()
+		wg.Go(func() { work(msg) })
+	}
+}
+
+// handle passes msg to the handler and writes its response, if any, to conn.
+func (t *WebSocketTransport) handle(conn *wsConn, msg *Message) {
+	response, err := t.handler(conn, msg)
+	if err != nil {
+		response = &Message{
+			JSONRPC: "2.0",
+			ID:      msg.ID,
+			Error: &ErrorObj{
+				Code:    ErrCodeInternalError,
+				Message: err.Error(),
+			},
+		}
+	}
+	if response != nil {
+		t.respond(conn, response)
+	}
+}
+
+// respond writes response to conn, logging failures.
+func (t *WebSocketTransport) respond(conn *wsConn, response *Message) {
+	if err := conn.WriteMessage(response); err != nil {
+		log.Printf("WebSocket client %s: error writing response: %v", conn.id, err)
+	}
+}
+
+// register adds a connection with a random session ID, for the client
+// identified by client and authenticated as principal.
+func (t *WebSocketTransport) register(netConn net.Conn, reader *bufio.Reader, client string, principal *Principal) (*wsConn, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	conn := &wsConn{
+		conn:        netConn,
+		reader:      reader,
+		pending:     newPendingRequests(),
+		done:        make(chan struct{}),
+		client:      client,
+		id:          hex.EncodeToString(b),
+		readTimeout: wsPongWaitIntervals * t.config.HeartbeatInterval,
+	}
+	conn.principal.Store(principal)
+
+	t.mu.Lock()
+	t.conns[conn.id] = conn
+	count := len(t.conns)
+	t.mu.Unlock()
+
+	t.metrics.SetWebSocketConnections(count)
+	log.Printf("WebSocket client connected: %s", conn.id)
+	return conn, nil
+}
+
+// unregister removes a connection, closing it if needed, and passes its
+// session ID to the OnSessionClose callback.
+func (t *WebSocketTransport) unregister(conn *wsConn) {
+	conn.close(wsCloseNormal, "")
+
+	t.mu.Lock()
+	delete(t.conns, conn.id)
+	count := len(t.conns)
+	onClose := t.onClose
+	t.mu.Unlock()
+
+	t.metrics.SetWebSocketConnections(count)
+	log.Printf("WebSocket client disconnected: %s", conn.id)
+	if onClose != nil {
+		onClose(conn.id)
+	}
+}
+
+// all returns a snapshot of the open connections.
+func (t *WebSocketTransport) all() []*wsConn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	conns := make([]*wsConn, 0, len(t.conns))
+	for _, conn := range t.conns {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+// handleHealth handles GET /health for health checks
+func (t *WebSocketTransport) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"status":      "ok",
+		"connections": t.ConnectionCount(),
+		"server_time": time.Now().UTC().Format(time.RFC3339),
+	}); err != nil {
+		log.Printf("Error encoding health response: %v", err)
+	}
+}
+
+// handleMetrics handles GET /metrics for Prometheus-style metrics exposition.
+func (t *WebSocketTransport) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeMetrics(w, t.metrics)
+}
+
+// ServeScoped starts the server, passing each message received to handler
+// with the transport for the connection it arrived on. It blocks until the
+// transport is closed or an error occurs.
+func (t *WebSocketTransport) ServeScoped(handler ScopedHandler) error {
+	t.handler = handler
+
+	listener, err := listen(t.config, "WebSocket")
+	if err != nil {
+		return err
+	}
+	if err := t.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// OnSessionClose registers fn to be called with the session ID of each
+// connection when it closes, so servers can release per-session state.
+func (t *WebSocketTransport) OnSessionClose(fn func(id string)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onClose = fn
+}
+
+// SetAPIKeys replaces the named API keys the transport accepts, e.g. when
+// they are reloaded from a file; the configured APIKey remains valid.
+// Connections authenticated by a key that is no longer accepted are closed
+// with status 1008 (policy violation); the others take on their key's new
+// scopes. It returns an error if the transport was created without keys, as
+// authentication is then disabled.
+func (t *WebSocketTransport) SetAPIKeys(keys []APIKey) error {
+	if t.apiKeys == nil {
+		return fmt.Errorf("authentication is not enabled")
+	}
+	t.apiKeys.set(t.config, keys)
+	for _, conn := range t.all() {
+		if principal := t.apiKeys.lookup(conn.principal.Load()); principal != nil {
+			conn.principal.Store(principal)
+		} else {
+			conn.close(wsClosePolicyViolation, "API key revoked")
+		}
+	}
+	return nil
+}
+
+// ConnectionCount returns the number of open WebSocket connections.
+func (t *WebSocketTransport) ConnectionCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.conns)
+}
+
+// Metrics returns the metrics registry for this transport.
+func (t *WebSocketTransport) Metrics() *MetricsRegistry {
+	return t.metrics
+}
+
+// ReadMessage is provided for Transport interface compatibility. Like
+// HTTPTransport, the WebSocket transport delivers messages to the handler
+// passed to ServeScoped; each connection's transport supports ReadMessage.
+func (t *WebSocketTransport) ReadMessage() (*Message, error) {
+	return nil, fmt.Errorf("ReadMessage is not supported by WebSocketTransport: use ServeScoped(handler) callback pattern instead")
+}
+
+// WriteMessage sends a message to every connected client. Messages for one
+// client are written to the transport passed to the ScopedHandler instead.
+func (t *WebSocketTransport) WriteMessage(msg *Message) error {
+	if t.closed.Load() {
+		return fmt.Errorf("transport is closed")
+	}
+	for _, conn := range t.all() {
+		if err := conn.WriteMessage(msg); err != nil {
+			log.Printf("WebSocket client %s: write error: %v", conn.id, err)
+		}
+	}
+	return nil
+}
+
+// Close closes every connection with status 1001 (going away) and shuts down
+// the server gracefully.
+func (t *WebSocketTransport) Close() error {
+	if t.closed.Swap(true) {
+		return nil
+	}
+
+	close(t.shutdownCh)
+	// Hijacked connections are not closed by Shutdown.
+	for _, conn := range t.all() {
+		conn.close(wsCloseGoingAway, "server shutdown")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+	defer cancel()
+
+	if err := t.server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to shutdown server: %w", err)
+	}
+
+	// Clean up Unix socket file if we were using one
+	if t.config.SocketPath != "" {
+		if err := os.Remove(t.config.SocketPath); err != nil && !os.IsNotExist(err) {
+			log.Printf("Warning: failed to remove socket file %s: %v", t.config.SocketPath, err)
+		}
+	}
+
+	return nil
+}
+
+// IsClosed returns true if the transport has been closed.
+func (t *WebSocketTransport) IsClosed() bool {
+	return t.closed.Load()
+}
+
+// wsConn is a WebSocket connection, and the client session it carries.
+//
+// ReadMessage is safe for a single reader goroutine; writes are serialized by
+// writeMu, which is never held during reads.
+//
+//lint:ignore BETTERALIGN struct is intentionally ordered for clarity
+type wsConn struct {
+	conn        net.Conn
+	reader      *bufio.Reader
+	pending     *pendingRequests // server-initiated requests awaiting a response
+	done        chan struct{}    // closed when the connection closes
+	client      string           // the client's identity, for rate limiting
+	id          string
+	readTimeout time.Duration // how long to wait for each frame from the client
+	principal   atomic.Pointer[Principal]
+	writeMu     sync.Mutex
+	closeOnce   sync.Once
+	closed      atomic.Bool
+}
+
+// SessionID returns the connection's session ID.
+func (c *wsConn) SessionID() string {
+	return c.id
+}
+
+// Session returns the connection itself, which carries the whole session.
+func (c *wsConn) Session() Transport {
+	return c
+}
+
+// Principal returns the client authenticated by the connection's upgrade request.
+func (c *wsConn) Principal() *Principal {
+	return c.principal.Load()
+}
+
+// ReadMessage reads the next JSON-RPC message from the client. Responses to
+// server-initiated requests are delivered to the pending Request they
+// answer, and never returned. It returns io.EOF once the connection closes;
+// an error for a message that is not valid JSON leaves the connection open.
+func (c *wsConn) ReadMessage() (*Message, error) {
+	for {
+		data, err := c.readData()
+		if err != nil {
+			return nil, err
+		}
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON: %w", err)
+		}
+		if IsResponse(&msg) {
+			if !c.pending.resolve(&msg) {
+				log.Printf("Dropping response to unknown request %s", msg.ID)
+			}
+			continue
+		}
+		return &msg, nil
+	}
+}
+
+// readData returns the payload of the next text message, reassembling
+// fragments and answering control frames as they arrive. Protocol errors
+// close the connection.
+func (c *wsConn) readData() ([]byte, error) {
+	var message []byte
+	fragmented := false
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			var closeErr *wsCloseError
+			if errors.As(err, &closeErr) {
+				c.close(closeErr.code, closeErr.reason)
+				return nil, err
+			}
+			wasClosed := c.closed.Load()
+			c.close(wsCloseGoingAway, "")
+			if wasClosed || errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+
+		switch opcode {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return nil, io.EOF
+			}
+		case wsOpPong:
+		case wsOpClose:
+			// Echo the client's status code, per RFC 6455 section 5.5.1.
+			code := wsCloseNormal
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+			}
+			if code == wsCloseNoStatus {
+				code = wsCloseNormal
+			}
+			c.close(code, "")
+			return nil, io.EOF
+		case wsOpText:
+			if fragmented {
+				c.close(wsCloseProtocolError, "expected continuation frame")
+				return nil, io.EOF
+			}
+			message = payload
+			fragmented = !fin
+		case wsOpContinuation:
+			if !fragmented {
+				c.close(wsCloseProtocolError, "unexpected continuation frame")
+				return nil, io.EOF
+			}
+			if len(message)+len(payload) > wsMaxMessageSize {
+				c.close(wsCloseTooBig, "message too big")
+				return nil, io.EOF
+			}
+			message = append(message, payload...)
+			fragmented = !fin
+		case wsOpBinary:
+			c.close(wsCloseUnsupportedData, "binary messages are not supported")
+			return nil, io.EOF
+		default:
+			c.close(wsCloseProtocolError, "unknown opcode")
+			return nil, io.EOF
+		}
+
+		if (opcode == wsOpText || opcode == wsOpContinuation) && fin {
+			return message, nil
+		}
+	}
+}
+
+// readFrame reads one frame from the client, unmasking its payload. The
+// client must start sending it within readTimeout: heartbeat pings make live
+// clients send pongs at least that often.
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	if err := c.conn.SetReadDeadline(time.Now().Add(c.readTimeout)); err != nil {
+		return false, 0, nil, err
+	}
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return false, 0, nil, &wsCloseError{code: wsCloseGoingAway, reason: "no response to ping"}
+		}
+		return false, 0, nil, err
+	}
+	if err := c.conn.SetReadDeadline(time.Time{}); err != nil {
+		return false, 0, nil, err
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, &wsCloseError{code: wsCloseProtocolError, reason: "reserved bits set"}
+	}
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, &wsCloseError{code: wsCloseProtocolError, reason: "client frames must be masked"}
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= wsOpClose && (!fin || length > 125) {
+		return false, 0, nil, &wsCloseError{code: wsCloseProtocolError, reason: "invalid control frame"}
+	}
+	if length > wsMaxMessageSize {
+		return false, 0, nil, &wsCloseError{code: wsCloseTooBig, reason: "message too big"}
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes an unfragmented frame to the client.
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closed.Load() {
+		return fmt.Errorf("transport is closed")
+	}
+	return c.writeFrameLocked(opcode, payload)
+}
+
+// writeFrameLocked writes a frame; the caller must hold writeMu. Server
+// frames are not masked.
+func (c *wsConn) writeFrameLocked(opcode byte, payload []byte) error {
+	header := make([]byte, 0, 10)
+	header = append(header, 0x80|opcode)
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	if err := c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout)); err != nil {
+		return err
+	}
+	buffers := net.Buffers{header, payload}
+	if _, err := buffers.WriteTo(c.conn); err != nil {
+		return fmt.Errorf("failed to write frame: %w", err)
+	}
+	return nil
+}
+
+// WriteMessage writes a JSON-RPC message to the client in a text frame.
+// Safe for concurrent use by multiple goroutines.
+func (c *wsConn) WriteMessage(msg *Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+	return c.writeFrame(wsOpText, data)
+}
+
+// Request sends a server-initiated request to the client and waits for the
+// response, which is read by the connection's reader.
+func (c *wsConn) Request(ctx context.Context, method string, params any) (*Message, error) {
+	return c.pending.request(ctx, method, params, c.WriteMessage)
+}
+
+// SendEvent sends an event to the client as a JSON-RPC notification: the
+// method is the event type prefixed with "notifications/", and the params
+// are the event data.
+func (c *wsConn) SendEvent(eventType string, data string) {
+	params := json.RawMessage(data)
+	if !json.Valid(params) {
+		params, _ = json.Marshal(data)
+	}
+	if err := c.WriteMessage(&Message{JSONRPC: "2.0", Method: "notifications/" + eventType, Params: params}); err != nil {
+		log.Printf("WebSocket client %s: failed to send %s event: %v", c.id, eventType, err)
+	}
+}
+
+// heartbeat pings the client every interval until the connection closes, or
+// the transport shuts down. A client that sends no frame, not even a pong,
+// for wsPongWaitIntervals intervals is closed by readFrame.
+func (c *wsConn) heartbeat(interval time.Duration, shutdown <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-shutdown:
+			c.close(wsCloseGoingAway, "server shutdown")
+			return
+		case <-ticker.C:
+			if err := c.writeFrame(wsOpPing, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Close closes the connection with status 1000 (normal closure).
+func (c *wsConn) Close() error {
+	c.close(wsCloseNormal, "")
+	return nil
+}
+
+// IsClosed returns true if the connection has been closed.
+func (c *wsConn) IsClosed() bool {
+	return c.closed.Load()
+}
+
+// close sends a close frame with code and reason, best effort, then closes
+// the connection. Only the first call has any effect.
+func (c *wsConn) close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.writeMu.Lock()
+		defer c.writeMu.Unlock()
+		c.closed.Store(true)
+		close(c.done)
+
+		payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+		_ = c.writeFrameLocked(wsOpClose, append(payload, reason...))
+		c.conn.Close()
+	})
+}
+
+// headerHasToken reports whether the comma-separated header name contains
+// token, compared case-insensitively.
+func headerHasToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for part := range strings.SplitSeq(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,483 @@
+// Copyright 2025 Joseph Cumines
+//
+// WebSocket transport unit tests
+
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const wsTestKey = "dGhlIHNhbXBsZSBub25jZQ=="
+
+// wsTestClient is a minimal WebSocket client speaking raw frames.
+type wsTestClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// newWebSocketServer starts a test server for a transport with handler.
+func newWebSocketServer(t *testing.T, cfg *HTTPTransportConfig, handler ScopedHandler) (*WebSocketTransport, *httptest.Server) {
+	t.Helper()
+	tr := NewWebSocketTransport(cfg)
+	tr.handler = handler
+	ts := httptest.NewServer(tr.server.Handler)
+	t.Cleanup(func() {
+		tr.Close()
+		ts.Close()
+	})
+	return tr, ts
+}
+
+// upgradeWebSocket sends an upgrade request with the standard headers,
+// overridden by header, and returns the response with the connection.
+func upgradeWebSocket(t *testing.T, ts *httptest.Server, header http.Header) (*http.Response, *wsTestClient) {
+	t.Helper()
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+WebSocketEndpoint, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", wsTestKey)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, &wsTestClient{t: t, conn: conn, reader: reader}
+}
+
+// dialWebSocket opens a WebSocket connection to ts.
+func dialWebSocket(t *testing.T, ts *httptest.Server) *wsTestClient {
+	t.Helper()
+	resp, client := upgradeWebSocket(t, ts, nil)
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("upgrade status = %d, want 101", resp.StatusCode)
+	}
+	return client
+}
+
+// writeFrame writes a masked frame.
+func (c *wsTestClient) writeFrame(fin bool, opcode byte, payload []byte) {
+	c.t.Helper()
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, 0x80|byte(n))
+	default:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	}
+	mask := [4]byte{1, 2, 3, 4}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		c.t.Fatalf("write frame: %v", err)
+	}
+}
+
+// send writes a JSON-RPC message in a text frame.
+func (c *wsTestClient) send(message string) {
+	c.t.Helper()
+	c.writeFrame(true, wsOpText, []byte(message))
+}
+
+// readFrame reads an unfragmented frame from the server.
+func (c *wsTestClient) readFrame() (opcode byte, payload []byte) {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		c.t.Fatalf("read frame: %v", err)
+	}
+	if header[0]&0x80 == 0 || header[1]&0x80 != 0 {
+		c.t.Fatalf("unexpected frame header %x", header)
+	}
+	length := int(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(c.reader, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(c.reader, ext[:])
+		length = int(binary.BigEndian.Uint64(ext[:]))
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		c.t.Fatalf("read payload: %v", err)
+	}
+	return header[0] & 0x0F, payload
+}
+
+// read reads the next JSON-RPC message, skipping pings.
+func (c *wsTestClient) read() *Message {
+	c.t.Helper()
+	for {
+		opcode, payload := c.readFrame()
+		if opcode == wsOpPing {
+			continue
+		}
+		if opcode != wsOpText {
+			c.t.Fatalf("opcode = %d, want text (payload %q)", opcode, payload)
+		}
+		var msg Message
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			c.t.Fatalf("invalid message %q: %v", payload, err)
+		}
+		return &msg
+	}
+}
+
+// readClose reads frames until a close frame, returning its status code.
+func (c *wsTestClient) readClose() int {
+	c.t.Helper()
+	for {
+		opcode, payload := c.readFrame()
+		if opcode == wsOpClose {
+			return int(binary.BigEndian.Uint16(payload))
+		}
+	}
+}
+
+func TestWebSocket_RoundTrip(t *testing.T) {
+	tr, ts := newWebSocketServer(t, nil, echoResult)
+	resp, client := upgradeWebSocket(t, ts, http.Header{"Sec-Websocket-Protocol": {"other, mcp"}})
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("upgrade status = %d, want 101", resp.StatusCode)
+	}
+	// The example handshake of RFC 6455 section 1.3.
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Sec-WebSocket-Accept = %q", got)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != WebSocketSubprotocol {
+		t.Errorf("Sec-WebSocket-Protocol = %q, want %q", got, WebSocketSubprotocol)
+	}
+
+	client.send(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	if msg := client.read(); string(msg.ID) != "1" || string(msg.Result) != `{"ok":true}` {
+		t.Errorf("unexpected response %+v", msg)
+	}
+	if got := tr.ConnectionCount(); got != 1 {
+		t.Errorf("ConnectionCount() = %d, want 1", got)
+	}
+}
+
+func TestWebSocket_RejectsInvalidUpgrades(t *testing.T) {
+	_, ts := newWebSocketServer(t, &HTTPTransportConfig{CORSOrigin: "https://allowed.example"}, echoResult)
+	tests := []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{"no upgrade", http.Header{"Upgrade": {"h2c"}}, http.StatusUpgradeRequired},
+		{"bad version", http.Header{"Sec-Websocket-Version": {"8"}}, http.StatusUpgradeRequired},
+		{"bad key", http.Header{"Sec-Websocket-Key": {"short"}}, http.StatusBadRequest},
+		{"bad origin", http.Header{"Origin": {"https://evil.example"}}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, _ := upgradeWebSocket(t, ts, tt.header)
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+
+	resp, err := ts.Client().Post(ts.URL+WebSocketEndpoint, "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want 405", resp.StatusCode)
+	}
+}
+
+func TestWebSocket_SharedMiddleware(t *testing.T) {
+	tr, ts := newWebSocketServer(t, &HTTPTransportConfig{APIKey: "secret", RateLimit: 1}, echoResult)
+
+	if resp, _ := upgradeWebSocket(t, ts, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status without API key = %d, want 401", resp.StatusCode)
+	}
+	resp, client := upgradeWebSocket(t, ts, http.Header{"Authorization": {"Bearer secret"}})
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status with API key = %d, want 101", resp.StatusCode)
+	}
+
+	// The upgrade and the requests the connection carries share the client's
+	// bucket of two.
+	for id := range 2 {
+		client.send(fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"tools/list"}`, id))
+	}
+	var limited int
+	for range 2 {
+		if msg := client.read(); msg.Error != nil && msg.Error.Code == ErrCodeRateLimited {
+			limited++
+		}
+	}
+	if limited != 1 {
+		t.Errorf("%d requests rate limited, want 1", limited)
+	}
+	if resp, _ := upgradeWebSocket(t, ts, http.Header{"Authorization": {"Bearer secret"}}); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("status over the rate limit = %d, want 429", resp.StatusCode)
+	}
+	if n := tr.rateLimiter.Clients(); n != 1 {
+		t.Errorf("%d clients rate limited, want 1; unauthenticated requests must not be tracked", n)
+	}
+}
+
+func TestWebSocket_SetAPIKeysRevokes(t *testing.T) {
+	tr, ts := newWebSocketServer(t, &HTTPTransportConfig{APIKeys: []APIKey{
+		{Name: "alice", Key: "alice-secret", Scopes: []string{"read"}},
+		{Name: "bob", Key: "bob-secret", Scopes: []string{"read"}},
+	}}, echoResult)
+	_, alice := upgradeWebSocket(t, ts, http.Header{"Authorization": {"Bearer alice-secret"}})
+	_, bob := upgradeWebSocket(t, ts, http.Header{"Authorization": {"Bearer bob-secret"}})
+	alice.send(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	alice.read()
+
+	if err := tr.SetAPIKeys([]APIKey{{Name: "alice", Key: "alice-secret", Scopes: []string{"read", "input"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if code := bob.readClose(); code != wsClosePolicyViolation {
+		t.Errorf("revoked key's connection closed with %d, want %d", code, wsClosePolicyViolation)
+	}
+	// Remaining connections take on their key's new scopes.
+	for _, conn := range tr.all() {
+		if p := conn.Principal(); !conn.IsClosed() && (p.Name != "alice" || !p.HasScope("input")) {
+			t.Errorf("connection principal = %+v, want alice with input scope", p)
+		}
+	}
+}
+
+func TestWebSocket_ServerRequest(t *testing.T) {
+	_, ts := newWebSocketServer(t, nil, func(tr Transport, msg *Message) (*Message, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		answer, err := tr.(Requester).Request(ctx, "elicitation/create", map[string]any{})
+		if err != nil {
+			return nil, err
+		}
+		return &Message{JSONRPC: "2.0", ID: msg.ID, Result: answer.Result}, nil
+	})
+	client := dialWebSocket(t, ts)
+
+	client.send(`{"jsonrpc":"2.0","id":1,"method":"tools/call"}`)
+	request := client.read()
+	if request.Method != "elicitation/create" {
+		t.Fatalf("unexpected server request %+v", request)
+	}
+	client.send(`{"jsonrpc":"2.0","id":` + string(request.ID) + `,"result":{"action":"accept"}}`)
+	if msg := client.read(); string(msg.ID) != "1" || string(msg.Result) != `{"action":"accept"}` {
+		t.Errorf("unexpected response %+v", msg)
+	}
+}
+
+func TestWebSocket_Frames(t *testing.T) {
+	_, ts := newWebSocketServer(t, nil, echoResult)
+	client := dialWebSocket(t, ts)
+
+	client.writeFrame(true, wsOpPing, []byte("hello"))
+	if opcode, payload := client.readFrame(); opcode != wsOpPong || string(payload) != "hello" {
+		t.Errorf("ping answered with opcode %d, payload %q; want pong", opcode, payload)
+	}
+
+	// A fragmented message, interleaved with a ping.
+	client.writeFrame(false, wsOpText, []byte(`{"jsonrpc":"2.0",`))
+	client.writeFrame(true, wsOpPing, nil)
+	client.writeFrame(true, wsOpContinuation, []byte(`"id":7,"method":"ping"}`))
+	if opcode, _ := client.readFrame(); opcode != wsOpPong {
+		t.Errorf("opcode = %d, want pong", opcode)
+	}
+	if msg := client.read(); string(msg.ID) != "7" {
+		t.Errorf("unexpected response to fragmented message %+v", msg)
+	}
+
+	// Invalid JSON is answered with a parse error, and the connection stays open.
+	client.send(`{not json`)
+	if msg := client.read(); msg.Error == nil || msg.Error.Code != ErrCodeParseError {
+		t.Errorf("unexpected response to invalid JSON %+v", msg)
+	}
+
+	client.writeFrame(true, wsOpBinary, []byte("{}"))
+	if code := client.readClose(); code != wsCloseUnsupportedData {
+		t.Errorf("binary message closed with %d, want %d", code, wsCloseUnsupportedData)
+	}
+}
+
+func TestWebSocket_Close(t *testing.T) {
+	tr, ts := newWebSocketServer(t, nil, echoResult)
+	closed := make(chan string, 2)
+	tr.OnSessionClose(func(id string) { closed <- id })
+
+	// The client closes: the server echoes the close and ends the session.
+	client := dialWebSocket(t, ts)
+	client.writeFrame(true, wsOpClose, binary.BigEndian.AppendUint16(nil, wsCloseNormal))
+	if code := client.readClose(); code != wsCloseNormal {
+		t.Errorf("close echoed with %d, want %d", code, wsCloseNormal)
+	}
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnSessionClose callback not called after client close")
+	}
+
+	// The server shuts down: clients are told it is going away.
+	client = dialWebSocket(t, ts)
+	tr.Close()
+	if code := client.readClose(); code != wsCloseGoingAway {
+		t.Errorf("shutdown closed with %d, want %d", code, wsCloseGoingAway)
+	}
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnSessionClose callback not called on shutdown")
+	}
+}
+
+func TestWebSocket_SendEvent(t *testing.T) {
+	sessions := make(chan Transport, 2)
+	_, ts := newWebSocketServer(t, nil, func(tr Transport, msg *Message) (*Message, error) {
+		sessions <- tr.(SessionTransport).Session()
+		return echoResult(tr, msg)
+	})
+	a, b := dialWebSocket(t, ts), dialWebSocket(t, ts)
+	a.send(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	a.read()
+	b.send(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	b.read()
+	sessionA, sessionB := <-sessions, <-sessions
+	if sessionA.(SessionTransport).SessionID() == sessionB.(SessionTransport).SessionID() {
+		t.Fatal("connections share a session ID")
+	}
+
+	// The event goes to a alone: b's next message is its response.
+	sessionA.(EventSender).SendEvent("observation", `{"n":1}`)
+	b.send(`{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+	if msg := a.read(); msg.Method != "notifications/observation" || string(msg.Params) != `{"n":1}` {
+		t.Errorf("unexpected event %+v", msg)
+	}
+	if msg := b.read(); string(msg.ID) != "2" {
+		t.Errorf("session b received %+v", msg)
+	}
+}
+
+func TestWebSocket_MaxConcurrency(t *testing.T) {
+	entered := make(chan string, 2)
+	release := make(chan struct{})
+	_, ts := newWebSocketServer(t, &HTTPTransportConfig{MaxConcurrency: 1}, func(tr Transport, msg *Message) (*Message, error) {
+		entered <- string(msg.ID)
+		<-release
+		return echoResult(tr, msg)
+	})
+	client := dialWebSocket(t, ts)
+	client.send(`{"jsonrpc":"2.0","id":1,"method":"tools/call"}`)
+	client.send(`{"jsonrpc":"2.0","id":2,"method":"tools/call"}`)
+
+	if id := <-entered; id != "1" {
+		t.Fatalf("first request handled = %s, want 1", id)
+	}
+	select {
+	case id := <-entered:
+		t.Fatalf("request %s handled while the only worker was busy", id)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	for _, want := range []string{"1", "2"} {
+		if msg := client.read(); string(msg.ID) != want {
+			t.Errorf("response ID = %s, want %s", msg.ID, want)
+		}
+	}
+}
+
+// TestWebSocket_CancelWhileSaturated cancels a request, and answers a server
+// request, while the only worker is busy and another request is waiting.
+func TestWebSocket_CancelWhileSaturated(t *testing.T) {
+	cancelled := make(chan struct{})
+	_, ts := newWebSocketServer(t, &HTTPTransportConfig{MaxConcurrency: 1}, func(tr Transport, msg *Message) (*Message, error) {
+		switch {
+		case msg.Method == "notifications/cancelled":
+			close(cancelled)
+			return nil, nil
+		case string(msg.ID) == "1":
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if _, err := tr.(Requester).Request(ctx, "elicitation/create", map[string]any{}); err != nil {
+				return nil, err
+			}
+			select {
+			case <-cancelled:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		return echoResult(tr, msg)
+	})
+	client := dialWebSocket(t, ts)
+
+	client.send(`{"jsonrpc":"2.0","id":1,"method":"tools/call"}`)
+	request := client.read()
+	if request.Method != "elicitation/create" {
+		t.Fatalf("unexpected server request %+v", request)
+	}
+	client.send(`{"jsonrpc":"2.0","id":2,"method":"tools/call"}`)
+	client.send(`{"jsonrpc":"2.0","id":` + string(request.ID) + `,"result":{"action":"decline"}}`)
+	client.send(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":1}}`)
+	for _, want := range []string{"1", "2"} {
+		if msg := client.read(); string(msg.ID) != want || msg.Error != nil {
+			t.Errorf("response = %+v, want a result for %s", msg, want)
+		}
+	}
+}
+
+func TestWebSocket_ClosesUnresponsiveClient(t *testing.T) {
+	_, ts := newWebSocketServer(t, &HTTPTransportConfig{HeartbeatInterval: 50 * time.Millisecond}, echoResult)
+
+	// A client answering pings stays connected.
+	live := dialWebSocket(t, ts)
+	for deadline := time.Now().Add(300 * time.Millisecond); time.Now().Before(deadline); {
+		if opcode, payload := live.readFrame(); opcode == wsOpPing {
+			live.writeFrame(true, wsOpPong, payload)
+		}
+	}
+	live.send(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	if msg := live.read(); string(msg.ID) != "1" {
+		t.Errorf("unexpected response %+v", msg)
+	}
+
+	// A client sending nothing is closed after two intervals.
+	dead := dialWebSocket(t, ts)
+	if code := dead.readClose(); code != wsCloseGoingAway {
+		t.Errorf("unresponsive client closed with %d, want %d", code, wsCloseGoingAway)
+	}
+}